@@ -29,7 +29,13 @@ type StateRequest struct {
 	// Current routes exported by this node
 	Routes []*Route `protobuf:"bytes,2,rep,name=routes,proto3" json:"routes,omitempty"`
 	// Timestamp of the request (Unix millis)
-	TimestampMs   int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// Optional features supported by the requesting node.
+	Capabilities *Capabilities `protobuf:"bytes,4,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -85,6 +91,68 @@ func (x *StateRequest) GetTimestampMs() int64 {
 	return 0
 }
 
+func (x *StateRequest) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *StateRequest) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
+// Capabilities advertised by a node in its StateRequest.
+type Capabilities struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Graceful restart timer in seconds: the receiver keeps the routes learned
+	// from this node for that long after losing its session, instead of
+	// flushing them. 0 means graceful restart is not supported.
+	GracefulRestartSeconds uint32 `protobuf:"varint,1,opt,name=graceful_restart_seconds,json=gracefulRestartSeconds,proto3" json:"graceful_restart_seconds,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *Capabilities) Reset() {
+	*x = Capabilities{}
+	mi := &file_api_v1_nnetman_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Capabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Capabilities) ProtoMessage() {}
+
+func (x *Capabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_nnetman_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Capabilities.ProtoReflect.Descriptor instead.
+func (*Capabilities) Descriptor() ([]byte, []int) {
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Capabilities) GetGracefulRestartSeconds() uint32 {
+	if x != nil {
+		return x.GracefulRestartSeconds
+	}
+	return 0
+}
+
 // StateResponse contains the peer's current state.
 type StateResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -95,14 +163,24 @@ type StateResponse struct {
 	// Timestamp of the response (Unix millis)
 	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
 	// Whether the peer accepted our routes
-	Accepted      bool `protobuf:"varint,4,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Accepted bool `protobuf:"varint,4,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// Peers the responder currently has a healthy session with and can relay
+	// traffic to (only set when the responder allows transit)
+	ReachablePeers []string `protobuf:"bytes,5,rep,name=reachable_peers,json=reachablePeers,proto3" json:"reachable_peers,omitempty"`
+	// Overlay next-hops of the responder per VNI, used as the gateway when
+	// relaying traffic through it
+	NextHops []*OverlayNextHop `protobuf:"bytes,6,rep,name=next_hops,json=nextHops,proto3" json:"next_hops,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StateResponse) Reset() {
 	*x = StateResponse{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[1]
+	mi := &file_api_v1_nnetman_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -114,7 +192,7 @@ func (x *StateResponse) String() string {
 func (*StateResponse) ProtoMessage() {}
 
 func (x *StateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[1]
+	mi := &file_api_v1_nnetman_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -127,7 +205,7 @@ func (x *StateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StateResponse.ProtoReflect.Descriptor instead.
 func (*StateResponse) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{1}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *StateResponse) GetNodeId() string {
@@ -158,6 +236,84 @@ func (x *StateResponse) GetAccepted() bool {
 	return false
 }
 
+func (x *StateResponse) GetReachablePeers() []string {
+	if x != nil {
+		return x.ReachablePeers
+	}
+	return nil
+}
+
+func (x *StateResponse) GetNextHops() []*OverlayNextHop {
+	if x != nil {
+		return x.NextHops
+	}
+	return nil
+}
+
+func (x *StateResponse) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
+// OverlayNextHop is the next-hop a node uses in a given overlay. A
+// dual-stack overlay is described by two entries for the same VNI, one per
+// address family.
+type OverlayNextHop struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// VNI of the overlay
+	Vni uint32 `protobuf:"varint,1,opt,name=vni,proto3" json:"vni,omitempty"`
+	// Next-hop IP address in that overlay
+	NextHop       string `protobuf:"bytes,2,opt,name=next_hop,json=nextHop,proto3" json:"next_hop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OverlayNextHop) Reset() {
+	*x = OverlayNextHop{}
+	mi := &file_api_v1_nnetman_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OverlayNextHop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OverlayNextHop) ProtoMessage() {}
+
+func (x *OverlayNextHop) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_nnetman_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OverlayNextHop.ProtoReflect.Descriptor instead.
+func (*OverlayNextHop) Descriptor() ([]byte, []int) {
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OverlayNextHop) GetVni() uint32 {
+	if x != nil {
+		return x.Vni
+	}
+	return 0
+}
+
+func (x *OverlayNextHop) GetNextHop() string {
+	if x != nil {
+		return x.NextHop
+	}
+	return ""
+}
+
 // Route represents a network route announcement.
 type Route struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -172,14 +328,21 @@ type Route struct {
 	// Optional: tags/communities for policy matching
 	Tags []string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
 	// VNI of the overlay this route belongs to (for multi-overlay support)
-	Vni           uint32 `protobuf:"varint,6,opt,name=vni,proto3" json:"vni,omitempty"`
+	Vni uint32 `protobuf:"varint,6,opt,name=vni,proto3" json:"vni,omitempty"`
+	// ID of the node that originated the route (empty from legacy peers,
+	// in which case the announcing peer is assumed to be the originator)
+	OriginatorId string `protobuf:"bytes,7,opt,name=originator_id,json=originatorId,proto3" json:"originator_id,omitempty"`
+	// Node IDs the announcement has traversed, originator first. Each node
+	// appends its own ID when re-advertising; a node never accepts a route
+	// whose path already contains its ID (loop prevention).
+	Path          []string `protobuf:"bytes,8,rep,name=path,proto3" json:"path,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Route) Reset() {
 	*x = Route{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[2]
+	mi := &file_api_v1_nnetman_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -191,7 +354,7 @@ func (x *Route) String() string {
 func (*Route) ProtoMessage() {}
 
 func (x *Route) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[2]
+	mi := &file_api_v1_nnetman_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -204,7 +367,7 @@ func (x *Route) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Route.ProtoReflect.Descriptor instead.
 func (*Route) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{2}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Route) GetPrefix() string {
@@ -249,6 +412,20 @@ func (x *Route) GetVni() uint32 {
 	return 0
 }
 
+func (x *Route) GetOriginatorId() string {
+	if x != nil {
+		return x.OriginatorId
+	}
+	return ""
+}
+
+func (x *Route) GetPath() []string {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
 // RouteAnnouncement sends new or updated routes to a peer.
 type RouteAnnouncement struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -257,14 +434,18 @@ type RouteAnnouncement struct {
 	// Routes being announced
 	Routes []*Route `protobuf:"bytes,2,rep,name=routes,proto3" json:"routes,omitempty"`
 	// Timestamp of the announcement (Unix millis)
-	TimestampMs   int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RouteAnnouncement) Reset() {
 	*x = RouteAnnouncement{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[3]
+	mi := &file_api_v1_nnetman_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -276,7 +457,7 @@ func (x *RouteAnnouncement) String() string {
 func (*RouteAnnouncement) ProtoMessage() {}
 
 func (x *RouteAnnouncement) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[3]
+	mi := &file_api_v1_nnetman_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -289,7 +470,7 @@ func (x *RouteAnnouncement) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RouteAnnouncement.ProtoReflect.Descriptor instead.
 func (*RouteAnnouncement) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{3}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *RouteAnnouncement) GetNodeId() string {
@@ -313,22 +494,37 @@ func (x *RouteAnnouncement) GetTimestampMs() int64 {
 	return 0
 }
 
+func (x *RouteAnnouncement) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
 // RouteWithdrawal notifies a peer that routes are being removed.
 type RouteWithdrawal struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// ID of the withdrawing node
 	NodeId string `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	// Prefixes being withdrawn
+	// Prefixes being withdrawn from every VNI. Kept for nodes that predate
+	// routes: a receiver ignores it when routes is set.
 	Prefixes []string `protobuf:"bytes,2,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
 	// Timestamp of the withdrawal (Unix millis)
-	TimestampMs   int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// Routes being withdrawn, identified by VNI and prefix (the other fields
+	// are not set).
+	Routes []*Route `protobuf:"bytes,4,rep,name=routes,proto3" json:"routes,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RouteWithdrawal) Reset() {
 	*x = RouteWithdrawal{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[4]
+	mi := &file_api_v1_nnetman_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -340,7 +536,7 @@ func (x *RouteWithdrawal) String() string {
 func (*RouteWithdrawal) ProtoMessage() {}
 
 func (x *RouteWithdrawal) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[4]
+	mi := &file_api_v1_nnetman_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -353,7 +549,7 @@ func (x *RouteWithdrawal) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RouteWithdrawal.ProtoReflect.Descriptor instead.
 func (*RouteWithdrawal) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{4}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *RouteWithdrawal) GetNodeId() string {
@@ -377,6 +573,20 @@ func (x *RouteWithdrawal) GetTimestampMs() int64 {
 	return 0
 }
 
+func (x *RouteWithdrawal) GetRoutes() []*Route {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *RouteWithdrawal) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
 // RouteAck acknowledges route announcements or withdrawals.
 type RouteAck struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -392,7 +602,7 @@ type RouteAck struct {
 
 func (x *RouteAck) Reset() {
 	*x = RouteAck{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[5]
+	mi := &file_api_v1_nnetman_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -404,7 +614,7 @@ func (x *RouteAck) String() string {
 func (*RouteAck) ProtoMessage() {}
 
 func (x *RouteAck) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[5]
+	mi := &file_api_v1_nnetman_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -417,7 +627,7 @@ func (x *RouteAck) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RouteAck.ProtoReflect.Descriptor instead.
 func (*RouteAck) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{5}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *RouteAck) GetAccepted() bool {
@@ -449,14 +659,18 @@ type KeepaliveRequest struct {
 	// Sequence number for detecting packet loss
 	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	// Timestamp (Unix millis)
-	TimestampMs   int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *KeepaliveRequest) Reset() {
 	*x = KeepaliveRequest{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[6]
+	mi := &file_api_v1_nnetman_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -468,7 +682,7 @@ func (x *KeepaliveRequest) String() string {
 func (*KeepaliveRequest) ProtoMessage() {}
 
 func (x *KeepaliveRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[6]
+	mi := &file_api_v1_nnetman_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -481,7 +695,7 @@ func (x *KeepaliveRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeepaliveRequest.ProtoReflect.Descriptor instead.
 func (*KeepaliveRequest) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{6}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *KeepaliveRequest) GetNodeId() string {
@@ -505,6 +719,13 @@ func (x *KeepaliveRequest) GetTimestampMs() int64 {
 	return 0
 }
 
+func (x *KeepaliveRequest) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
 // KeepaliveResponse acknowledges a keepalive request.
 type KeepaliveResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -515,14 +736,22 @@ type KeepaliveResponse struct {
 	// Timestamp (Unix millis)
 	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
 	// Peer's current health status
-	Health        *PeerHealth `protobuf:"bytes,4,opt,name=health,proto3" json:"health,omitempty"`
+	Health *PeerHealth `protobuf:"bytes,4,opt,name=health,proto3" json:"health,omitempty"`
+	// Peers the responder can relay traffic to (see StateResponse)
+	ReachablePeers []string `protobuf:"bytes,5,rep,name=reachable_peers,json=reachablePeers,proto3" json:"reachable_peers,omitempty"`
+	// Overlay next-hops of the responder per VNI (see StateResponse)
+	NextHops []*OverlayNextHop `protobuf:"bytes,6,rep,name=next_hops,json=nextHops,proto3" json:"next_hops,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *KeepaliveResponse) Reset() {
 	*x = KeepaliveResponse{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[7]
+	mi := &file_api_v1_nnetman_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -534,7 +763,7 @@ func (x *KeepaliveResponse) String() string {
 func (*KeepaliveResponse) ProtoMessage() {}
 
 func (x *KeepaliveResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[7]
+	mi := &file_api_v1_nnetman_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -547,7 +776,7 @@ func (x *KeepaliveResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeepaliveResponse.ProtoReflect.Descriptor instead.
 func (*KeepaliveResponse) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{7}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *KeepaliveResponse) GetNodeId() string {
@@ -578,6 +807,27 @@ func (x *KeepaliveResponse) GetHealth() *PeerHealth {
 	return nil
 }
 
+func (x *KeepaliveResponse) GetReachablePeers() []string {
+	if x != nil {
+		return x.ReachablePeers
+	}
+	return nil
+}
+
+func (x *KeepaliveResponse) GetNextHops() []*OverlayNextHop {
+	if x != nil {
+		return x.NextHops
+	}
+	return nil
+}
+
+func (x *KeepaliveResponse) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
 // PeerHealth reports the current health status of a peer.
 type PeerHealth struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -593,7 +843,7 @@ type PeerHealth struct {
 
 func (x *PeerHealth) Reset() {
 	*x = PeerHealth{}
-	mi := &file_api_v1_nnetman_proto_msgTypes[8]
+	mi := &file_api_v1_nnetman_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -605,7 +855,7 @@ func (x *PeerHealth) String() string {
 func (*PeerHealth) ProtoMessage() {}
 
 func (x *PeerHealth) ProtoReflect() protoreflect.Message {
-	mi := &file_api_v1_nnetman_proto_msgTypes[8]
+	mi := &file_api_v1_nnetman_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -618,7 +868,7 @@ func (x *PeerHealth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PeerHealth.ProtoReflect.Descriptor instead.
 func (*PeerHealth) Descriptor() ([]byte, []int) {
-	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{8}
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *PeerHealth) GetHealthy() bool {
@@ -642,60 +892,262 @@ func (x *PeerHealth) GetUptimeSeconds() uint64 {
 	return 0
 }
 
+// WatchRoutesRequest subscribes to the routes exported by a peer.
+type WatchRoutesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the watching node
+	NodeId string `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	// Timestamp of the request (Unix millis)
+	TimestampMs int64 `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRoutesRequest) Reset() {
+	*x = WatchRoutesRequest{}
+	mi := &file_api_v1_nnetman_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRoutesRequest) ProtoMessage() {}
+
+func (x *WatchRoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_nnetman_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRoutesRequest.ProtoReflect.Descriptor instead.
+func (*WatchRoutesRequest) Descriptor() ([]byte, []int) {
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WatchRoutesRequest) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *WatchRoutesRequest) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *WatchRoutesRequest) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
+// RouteUpdate is a message of a WatchRoutes stream.
+type RouteUpdate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the exporting node
+	NodeId string `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	// Sequence number, starting at 1 and increasing by one with every message
+	// of the stream. A receiver that sees a gap re-opens the stream.
+	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Whether announced is the full route set, replacing every route
+	// received before (always true for the first message)
+	Snapshot bool `protobuf:"varint,3,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// Routes added or changed
+	Announced []*Route `protobuf:"bytes,4,rep,name=announced,proto3" json:"announced,omitempty"`
+	// Routes withdrawn (only vni and prefix are set)
+	Withdrawn []*Route `protobuf:"bytes,5,rep,name=withdrawn,proto3" json:"withdrawn,omitempty"`
+	// Timestamp of the update (Unix millis)
+	TimestampMs int64 `protobuf:"varint,6,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	// HMAC-SHA256 over node_id, timestamp_ms and the message itself (encoded
+	// with this field empty), keyed with the peer's pre-shared key. Only set
+	// when the peer uses auth.mode=psk.
+	AuthHmac      []byte `protobuf:"bytes,15,opt,name=auth_hmac,json=authHmac,proto3" json:"auth_hmac,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RouteUpdate) Reset() {
+	*x = RouteUpdate{}
+	mi := &file_api_v1_nnetman_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RouteUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteUpdate) ProtoMessage() {}
+
+func (x *RouteUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_nnetman_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteUpdate.ProtoReflect.Descriptor instead.
+func (*RouteUpdate) Descriptor() ([]byte, []int) {
+	return file_api_v1_nnetman_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RouteUpdate) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *RouteUpdate) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *RouteUpdate) GetSnapshot() bool {
+	if x != nil {
+		return x.Snapshot
+	}
+	return false
+}
+
+func (x *RouteUpdate) GetAnnounced() []*Route {
+	if x != nil {
+		return x.Announced
+	}
+	return nil
+}
+
+func (x *RouteUpdate) GetWithdrawn() []*Route {
+	if x != nil {
+		return x.Withdrawn
+	}
+	return nil
+}
+
+func (x *RouteUpdate) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *RouteUpdate) GetAuthHmac() []byte {
+	if x != nil {
+		return x.AuthHmac
+	}
+	return nil
+}
+
 var File_api_v1_nnetman_proto protoreflect.FileDescriptor
 
 const file_api_v1_nnetman_proto_rawDesc = "" +
 	"\n" +
 	"\x14api/v1/nnetman.proto\x12\n" +
-	"nnetman.v1\"u\n" +
+	"nnetman.v1\"\xd0\x01\n" +
 	"\fStateRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12)\n" +
 	"\x06routes\x18\x02 \x03(\v2\x11.nnetman.v1.RouteR\x06routes\x12!\n" +
-	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\"\x92\x01\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12<\n" +
+	"\fcapabilities\x18\x04 \x01(\v2\x18.nnetman.v1.CapabilitiesR\fcapabilities\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"H\n" +
+	"\fCapabilities\x128\n" +
+	"\x18graceful_restart_seconds\x18\x01 \x01(\rR\x16gracefulRestartSeconds\"\x91\x02\n" +
 	"\rStateResponse\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12)\n" +
 	"\x06routes\x18\x02 \x03(\v2\x11.nnetman.v1.RouteR\x06routes\x12!\n" +
 	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12\x1a\n" +
-	"\baccepted\x18\x04 \x01(\bR\baccepted\"\x9d\x01\n" +
+	"\baccepted\x18\x04 \x01(\bR\baccepted\x12'\n" +
+	"\x0freachable_peers\x18\x05 \x03(\tR\x0ereachablePeers\x127\n" +
+	"\tnext_hops\x18\x06 \x03(\v2\x1a.nnetman.v1.OverlayNextHopR\bnextHops\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"=\n" +
+	"\x0eOverlayNextHop\x12\x10\n" +
+	"\x03vni\x18\x01 \x01(\rR\x03vni\x12\x19\n" +
+	"\bnext_hop\x18\x02 \x01(\tR\anextHop\"\xd6\x01\n" +
 	"\x05Route\x12\x16\n" +
 	"\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x19\n" +
 	"\bnext_hop\x18\x02 \x01(\tR\anextHop\x12\x16\n" +
 	"\x06metric\x18\x03 \x01(\rR\x06metric\x12#\n" +
 	"\rlease_seconds\x18\x04 \x01(\rR\fleaseSeconds\x12\x12\n" +
 	"\x04tags\x18\x05 \x03(\tR\x04tags\x12\x10\n" +
-	"\x03vni\x18\x06 \x01(\rR\x03vni\"z\n" +
+	"\x03vni\x18\x06 \x01(\rR\x03vni\x12#\n" +
+	"\roriginator_id\x18\a \x01(\tR\foriginatorId\x12\x12\n" +
+	"\x04path\x18\b \x03(\tR\x04path\"\x97\x01\n" +
 	"\x11RouteAnnouncement\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12)\n" +
 	"\x06routes\x18\x02 \x03(\v2\x11.nnetman.v1.RouteR\x06routes\x12!\n" +
-	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\"i\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"\xb1\x01\n" +
 	"\x0fRouteWithdrawal\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1a\n" +
 	"\bprefixes\x18\x02 \x03(\tR\bprefixes\x12!\n" +
-	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\"g\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12)\n" +
+	"\x06routes\x18\x04 \x03(\v2\x11.nnetman.v1.RouteR\x06routes\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"g\n" +
 	"\bRouteAck\x12\x1a\n" +
 	"\baccepted\x18\x01 \x01(\bR\baccepted\x12)\n" +
 	"\x10routes_processed\x18\x02 \x01(\rR\x0froutesProcessed\x12\x14\n" +
-	"\x05error\x18\x03 \x01(\tR\x05error\"j\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\x87\x01\n" +
 	"\x10KeepaliveRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1a\n" +
 	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12!\n" +
-	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\"\x9b\x01\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"\x9a\x02\n" +
 	"\x11KeepaliveResponse\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1a\n" +
 	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12!\n" +
 	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12.\n" +
-	"\x06health\x18\x04 \x01(\v2\x16.nnetman.v1.PeerHealthR\x06health\"n\n" +
+	"\x06health\x18\x04 \x01(\v2\x16.nnetman.v1.PeerHealthR\x06health\x12'\n" +
+	"\x0freachable_peers\x18\x05 \x03(\tR\x0ereachablePeers\x127\n" +
+	"\tnext_hops\x18\x06 \x03(\v2\x1a.nnetman.v1.OverlayNextHopR\bnextHops\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"n\n" +
 	"\n" +
 	"PeerHealth\x12\x18\n" +
 	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x1f\n" +
 	"\vroute_count\x18\x02 \x01(\rR\n" +
 	"routeCount\x12%\n" +
-	"\x0euptime_seconds\x18\x03 \x01(\x04R\ruptimeSeconds2\xa9\x02\n" +
+	"\x0euptime_seconds\x18\x03 \x01(\x04R\ruptimeSeconds\"m\n" +
+	"\x12WatchRoutesRequest\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12!\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac\"\x80\x02\n" +
+	"\vRouteUpdate\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12\x1a\n" +
+	"\bsnapshot\x18\x03 \x01(\bR\bsnapshot\x12/\n" +
+	"\tannounced\x18\x04 \x03(\v2\x11.nnetman.v1.RouteR\tannounced\x12/\n" +
+	"\twithdrawn\x18\x05 \x03(\v2\x11.nnetman.v1.RouteR\twithdrawn\x12!\n" +
+	"\ftimestamp_ms\x18\x06 \x01(\x03R\vtimestampMs\x12\x1b\n" +
+	"\tauth_hmac\x18\x0f \x01(\fR\bauthHmac2\xf3\x02\n" +
 	"\aNNetMan\x12D\n" +
 	"\rExchangeState\x12\x18.nnetman.v1.StateRequest\x1a\x19.nnetman.v1.StateResponse\x12E\n" +
 	"\x0eAnnounceRoutes\x12\x1d.nnetman.v1.RouteAnnouncement\x1a\x14.nnetman.v1.RouteAck\x12C\n" +
 	"\x0eWithdrawRoutes\x12\x1b.nnetman.v1.RouteWithdrawal\x1a\x14.nnetman.v1.RouteAck\x12L\n" +
-	"\tKeepalive\x12\x1c.nnetman.v1.KeepaliveRequest\x1a\x1d.nnetman.v1.KeepaliveResponse(\x010\x01B3Z1github.com/nishisan-dev/n-netman/api/v1;nnetmanv1b\x06proto3"
+	"\tKeepalive\x12\x1c.nnetman.v1.KeepaliveRequest\x1a\x1d.nnetman.v1.KeepaliveResponse(\x010\x01\x12H\n" +
+	"\vWatchRoutes\x12\x1e.nnetman.v1.WatchRoutesRequest\x1a\x17.nnetman.v1.RouteUpdate0\x01B3Z1github.com/nishisan-dev/n-netman/api/v1;nnetmanv1b\x06proto3"
 
 var (
 	file_api_v1_nnetman_proto_rawDescOnce sync.Once
@@ -709,36 +1161,48 @@ func file_api_v1_nnetman_proto_rawDescGZIP() []byte {
 	return file_api_v1_nnetman_proto_rawDescData
 }
 
-var file_api_v1_nnetman_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_api_v1_nnetman_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_api_v1_nnetman_proto_goTypes = []any{
-	(*StateRequest)(nil),      // 0: nnetman.v1.StateRequest
-	(*StateResponse)(nil),     // 1: nnetman.v1.StateResponse
-	(*Route)(nil),             // 2: nnetman.v1.Route
-	(*RouteAnnouncement)(nil), // 3: nnetman.v1.RouteAnnouncement
-	(*RouteWithdrawal)(nil),   // 4: nnetman.v1.RouteWithdrawal
-	(*RouteAck)(nil),          // 5: nnetman.v1.RouteAck
-	(*KeepaliveRequest)(nil),  // 6: nnetman.v1.KeepaliveRequest
-	(*KeepaliveResponse)(nil), // 7: nnetman.v1.KeepaliveResponse
-	(*PeerHealth)(nil),        // 8: nnetman.v1.PeerHealth
+	(*StateRequest)(nil),       // 0: nnetman.v1.StateRequest
+	(*Capabilities)(nil),       // 1: nnetman.v1.Capabilities
+	(*StateResponse)(nil),      // 2: nnetman.v1.StateResponse
+	(*OverlayNextHop)(nil),     // 3: nnetman.v1.OverlayNextHop
+	(*Route)(nil),              // 4: nnetman.v1.Route
+	(*RouteAnnouncement)(nil),  // 5: nnetman.v1.RouteAnnouncement
+	(*RouteWithdrawal)(nil),    // 6: nnetman.v1.RouteWithdrawal
+	(*RouteAck)(nil),           // 7: nnetman.v1.RouteAck
+	(*KeepaliveRequest)(nil),   // 8: nnetman.v1.KeepaliveRequest
+	(*KeepaliveResponse)(nil),  // 9: nnetman.v1.KeepaliveResponse
+	(*PeerHealth)(nil),         // 10: nnetman.v1.PeerHealth
+	(*WatchRoutesRequest)(nil), // 11: nnetman.v1.WatchRoutesRequest
+	(*RouteUpdate)(nil),        // 12: nnetman.v1.RouteUpdate
 }
 var file_api_v1_nnetman_proto_depIdxs = []int32{
-	2, // 0: nnetman.v1.StateRequest.routes:type_name -> nnetman.v1.Route
-	2, // 1: nnetman.v1.StateResponse.routes:type_name -> nnetman.v1.Route
-	2, // 2: nnetman.v1.RouteAnnouncement.routes:type_name -> nnetman.v1.Route
-	8, // 3: nnetman.v1.KeepaliveResponse.health:type_name -> nnetman.v1.PeerHealth
-	0, // 4: nnetman.v1.NNetMan.ExchangeState:input_type -> nnetman.v1.StateRequest
-	3, // 5: nnetman.v1.NNetMan.AnnounceRoutes:input_type -> nnetman.v1.RouteAnnouncement
-	4, // 6: nnetman.v1.NNetMan.WithdrawRoutes:input_type -> nnetman.v1.RouteWithdrawal
-	6, // 7: nnetman.v1.NNetMan.Keepalive:input_type -> nnetman.v1.KeepaliveRequest
-	1, // 8: nnetman.v1.NNetMan.ExchangeState:output_type -> nnetman.v1.StateResponse
-	5, // 9: nnetman.v1.NNetMan.AnnounceRoutes:output_type -> nnetman.v1.RouteAck
-	5, // 10: nnetman.v1.NNetMan.WithdrawRoutes:output_type -> nnetman.v1.RouteAck
-	7, // 11: nnetman.v1.NNetMan.Keepalive:output_type -> nnetman.v1.KeepaliveResponse
-	8, // [8:12] is the sub-list for method output_type
-	4, // [4:8] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	4,  // 0: nnetman.v1.StateRequest.routes:type_name -> nnetman.v1.Route
+	1,  // 1: nnetman.v1.StateRequest.capabilities:type_name -> nnetman.v1.Capabilities
+	4,  // 2: nnetman.v1.StateResponse.routes:type_name -> nnetman.v1.Route
+	3,  // 3: nnetman.v1.StateResponse.next_hops:type_name -> nnetman.v1.OverlayNextHop
+	4,  // 4: nnetman.v1.RouteAnnouncement.routes:type_name -> nnetman.v1.Route
+	4,  // 5: nnetman.v1.RouteWithdrawal.routes:type_name -> nnetman.v1.Route
+	10, // 6: nnetman.v1.KeepaliveResponse.health:type_name -> nnetman.v1.PeerHealth
+	3,  // 7: nnetman.v1.KeepaliveResponse.next_hops:type_name -> nnetman.v1.OverlayNextHop
+	4,  // 8: nnetman.v1.RouteUpdate.announced:type_name -> nnetman.v1.Route
+	4,  // 9: nnetman.v1.RouteUpdate.withdrawn:type_name -> nnetman.v1.Route
+	0,  // 10: nnetman.v1.NNetMan.ExchangeState:input_type -> nnetman.v1.StateRequest
+	5,  // 11: nnetman.v1.NNetMan.AnnounceRoutes:input_type -> nnetman.v1.RouteAnnouncement
+	6,  // 12: nnetman.v1.NNetMan.WithdrawRoutes:input_type -> nnetman.v1.RouteWithdrawal
+	8,  // 13: nnetman.v1.NNetMan.Keepalive:input_type -> nnetman.v1.KeepaliveRequest
+	11, // 14: nnetman.v1.NNetMan.WatchRoutes:input_type -> nnetman.v1.WatchRoutesRequest
+	2,  // 15: nnetman.v1.NNetMan.ExchangeState:output_type -> nnetman.v1.StateResponse
+	7,  // 16: nnetman.v1.NNetMan.AnnounceRoutes:output_type -> nnetman.v1.RouteAck
+	7,  // 17: nnetman.v1.NNetMan.WithdrawRoutes:output_type -> nnetman.v1.RouteAck
+	9,  // 18: nnetman.v1.NNetMan.Keepalive:output_type -> nnetman.v1.KeepaliveResponse
+	12, // 19: nnetman.v1.NNetMan.WatchRoutes:output_type -> nnetman.v1.RouteUpdate
+	15, // [15:20] is the sub-list for method output_type
+	10, // [10:15] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_api_v1_nnetman_proto_init() }
@@ -752,7 +1216,7 @@ func file_api_v1_nnetman_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_v1_nnetman_proto_rawDesc), len(file_api_v1_nnetman_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
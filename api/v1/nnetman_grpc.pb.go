@@ -23,6 +23,7 @@ const (
 	NNetMan_AnnounceRoutes_FullMethodName = "/nnetman.v1.NNetMan/AnnounceRoutes"
 	NNetMan_WithdrawRoutes_FullMethodName = "/nnetman.v1.NNetMan/WithdrawRoutes"
 	NNetMan_Keepalive_FullMethodName      = "/nnetman.v1.NNetMan/Keepalive"
+	NNetMan_WatchRoutes_FullMethodName    = "/nnetman.v1.NNetMan/WatchRoutes"
 )
 
 // NNetManClient is the client API for NNetMan service.
@@ -42,6 +43,10 @@ type NNetManClient interface {
 	WithdrawRoutes(ctx context.Context, in *RouteWithdrawal, opts ...grpc.CallOption) (*RouteAck, error)
 	// Keepalive is a bidirectional stream for health monitoring.
 	Keepalive(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[KeepaliveRequest, KeepaliveResponse], error)
+	// WatchRoutes streams the routes this node exports to the requesting peer:
+	// a snapshot of the full set first, then incremental updates as it changes.
+	// Peers watching a node no longer need its periodic AnnounceRoutes.
+	WatchRoutes(ctx context.Context, in *WatchRoutesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RouteUpdate], error)
 }
 
 type nNetManClient struct {
@@ -95,6 +100,25 @@ func (c *nNetManClient) Keepalive(ctx context.Context, opts ...grpc.CallOption)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NNetMan_KeepaliveClient = grpc.BidiStreamingClient[KeepaliveRequest, KeepaliveResponse]
 
+func (c *nNetManClient) WatchRoutes(ctx context.Context, in *WatchRoutesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RouteUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NNetMan_ServiceDesc.Streams[1], NNetMan_WatchRoutes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRoutesRequest, RouteUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NNetMan_WatchRoutesClient = grpc.ServerStreamingClient[RouteUpdate]
+
 // NNetManServer is the server API for NNetMan service.
 // All implementations must embed UnimplementedNNetManServer
 // for forward compatibility.
@@ -112,6 +136,10 @@ type NNetManServer interface {
 	WithdrawRoutes(context.Context, *RouteWithdrawal) (*RouteAck, error)
 	// Keepalive is a bidirectional stream for health monitoring.
 	Keepalive(grpc.BidiStreamingServer[KeepaliveRequest, KeepaliveResponse]) error
+	// WatchRoutes streams the routes this node exports to the requesting peer:
+	// a snapshot of the full set first, then incremental updates as it changes.
+	// Peers watching a node no longer need its periodic AnnounceRoutes.
+	WatchRoutes(*WatchRoutesRequest, grpc.ServerStreamingServer[RouteUpdate]) error
 	mustEmbedUnimplementedNNetManServer()
 }
 
@@ -134,6 +162,9 @@ func (UnimplementedNNetManServer) WithdrawRoutes(context.Context, *RouteWithdraw
 func (UnimplementedNNetManServer) Keepalive(grpc.BidiStreamingServer[KeepaliveRequest, KeepaliveResponse]) error {
 	return status.Error(codes.Unimplemented, "method Keepalive not implemented")
 }
+func (UnimplementedNNetManServer) WatchRoutes(*WatchRoutesRequest, grpc.ServerStreamingServer[RouteUpdate]) error {
+	return status.Error(codes.Unimplemented, "method WatchRoutes not implemented")
+}
 func (UnimplementedNNetManServer) mustEmbedUnimplementedNNetManServer() {}
 func (UnimplementedNNetManServer) testEmbeddedByValue()                 {}
 
@@ -216,6 +247,17 @@ func _NNetMan_Keepalive_Handler(srv interface{}, stream grpc.ServerStream) error
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NNetMan_KeepaliveServer = grpc.BidiStreamingServer[KeepaliveRequest, KeepaliveResponse]
 
+func _NNetMan_WatchRoutes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRoutesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NNetManServer).WatchRoutes(m, &grpc.GenericServerStream[WatchRoutesRequest, RouteUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NNetMan_WatchRoutesServer = grpc.ServerStreamingServer[RouteUpdate]
+
 // NNetMan_ServiceDesc is the grpc.ServiceDesc for NNetMan service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +285,11 @@ var NNetMan_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "WatchRoutes",
+			Handler:       _NNetMan_WatchRoutes_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "api/v1/nnetman.proto",
 }
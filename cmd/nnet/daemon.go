@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/observability"
+)
+
+// adminTimeout bounds every call to the daemon's admin API.
+const adminTimeout = 5 * time.Second
+
+// adminSocket returns the admin socket of the daemon: the --socket flag, or
+// admin.socket from the config file.
+func adminSocket(cfg *config.Config) (string, error) {
+	if socketPath != "" {
+		return socketPath, nil
+	}
+	if cfg == nil {
+		cfg = config.Defaults()
+	}
+	if !cfg.Admin.Enabled {
+		return "", fmt.Errorf("admin API disabled in %s (admin.enabled)", configPath)
+	}
+	return cfg.Admin.Socket, nil
+}
+
+// dialAdmin connects to the daemon's admin API. The returned function closes
+// the connection.
+func dialAdmin(cfg *config.Config) (pb.AdminClient, func(), error) {
+	path, err := adminSocket(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("daemon not running (admin socket %s): %w", path, err)
+	}
+	conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the daemon: %w", err)
+	}
+	return pb.NewAdminClient(conn), func() { conn.Close() }, nil
+}
+
+// adminError strips the gRPC framing from an admin API error.
+func adminError(err error) error {
+	if s, ok := status.FromError(err); ok {
+		return fmt.Errorf("daemon: %s", s.Message())
+	}
+	return err
+}
+
+// loadConfigOrDefaults loads the config file for the commands that only need
+// it to find the admin socket.
+func loadConfigOrDefaults() *config.Config {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// formatMillis renders a Unix-millis timestamp as the time elapsed since.
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.Since(time.UnixMilli(ms)).Round(time.Second).String() + " ago"
+}
+
+func peersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "peers",
+		Short: "List peer sessions of the running daemon",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, closeFn, err := dialAdmin(loadConfigOrDefaults())
+			if err != nil {
+				return err
+			}
+			defer closeFn()
+
+			ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+			defer cancel()
+			resp, err := client.ListPeers(ctx, &pb.ListPeersRequest{})
+			if err != nil {
+				return adminError(err)
+			}
+
+			if len(resp.Peers) == 0 {
+				fmt.Println("(no active peers)")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tENDPOINT\tSTATUS\tLAST SEEN\tROUTES\tSTREAM\tKEEPALIVE\tDEAD AFTER\tGR\tLINK")
+			fmt.Fprintln(w, "──\t────────\t──────\t─────────\t──────\t──────\t─────────\t──────────\t──\t────")
+			for _, p := range resp.Peers {
+				st := getStatusIcon(p.Status) + " " + p.Status
+				if p.RelayedVia != "" {
+					st += " (via " + p.RelayedVia + ")"
+				}
+				gr := "-"
+				if p.GracefulRestartSeconds > 0 {
+					gr = fmt.Sprintf("%ds", p.GracefulRestartSeconds)
+				}
+				stream := "-"
+				if p.RouteStream {
+					stream = "open"
+				}
+				var link *observability.LinkQuality
+				if p.LinkSamples > 0 {
+					link = &observability.LinkQuality{RTTMs: p.RttMs, JitterMs: p.JitterMs, LossPercent: p.LossPercent, Samples: p.LinkSamples}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%dms\t%dms\t%s\t%s\n",
+					p.Id, p.Endpoint, st, formatMillis(p.LastSeenMs), p.Routes, stream,
+					p.KeepaliveIntervalMs, p.DeadAfterMs, gr, formatLink(link))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.AddCommand(peersResetCmd())
+	return cmd
+}
+
+func peersResetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset <peer-id>",
+		Short: "Reset the session with a peer",
+		Long: `Reset closes the session with a peer, removes the routes learned from it
+and establishes the session again, so the peer re-announces its routes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, closeFn, err := dialAdmin(loadConfigOrDefaults())
+			if err != nil {
+				return err
+			}
+			defer closeFn()
+
+			ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+			defer cancel()
+			resp, err := client.ResetPeer(ctx, &pb.ResetPeerRequest{PeerId: args[0]})
+			if err != nil {
+				return adminError(err)
+			}
+			fmt.Printf("🔄 Peer %s reset (%d route(s) removed)\n", args[0], resp.RoutesRemoved)
+			return nil
+		},
+	}
+}
+
+func reconcileCmd() *cobra.Command {
+	var statusOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Trigger a reconciliation in the running daemon",
+		Long: `Reconcile asks the running daemon to reconcile every overlay now and
+prints the state of its reconciliation loop. With --status the loop state is
+only printed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, closeFn, err := dialAdmin(loadConfigOrDefaults())
+			if err != nil {
+				return err
+			}
+			defer closeFn()
+
+			ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+			defer cancel()
+			if !statusOnly {
+				if _, err := client.TriggerReconcile(ctx, &pb.TriggerReconcileRequest{}); err != nil {
+					return adminError(err)
+				}
+				fmt.Println("🔧 Reconciliation triggered")
+			}
+			st, err := client.GetReconcilerStatus(ctx, &pb.GetReconcilerStatusRequest{})
+			if err != nil {
+				return adminError(err)
+			}
+			printReconcilerStatus(st)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&statusOnly, "status", false, "Only show the reconciler state")
+	return cmd
+}
+
+// printReconcilerStatus prints the state of the daemon's reconciliation loop.
+func printReconcilerStatus(st *pb.ReconcilerStatus) {
+	running := "🔴 stopped"
+	if st.Running {
+		running = "🟢 running"
+	}
+	fmt.Printf("  Loop:     %s\n", running)
+	fmt.Printf("  Last run: %s\n", formatMillis(st.LastRunMs))
+	if len(st.Errors) == 0 {
+		fmt.Println("  Errors:   none")
+		return
+	}
+	fmt.Println("  Errors:")
+	for _, e := range st.Errors {
+		fmt.Printf("    ❌ %s\n", e)
+	}
+}
+
+func reloadCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reload",
+		Short: "Reload the configuration of the running daemon",
+		Long: `Reload asks the running daemon to re-read its configuration file and
+apply the changes, like sending it SIGHUP. An invalid file is rejected and the
+running configuration is kept.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, closeFn, err := dialAdmin(loadConfigOrDefaults())
+			if err != nil {
+				return err
+			}
+			defer closeFn()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, err := client.ReloadConfig(ctx, &pb.ReloadConfigRequest{}); err != nil {
+				return adminError(err)
+			}
+			fmt.Println("✅ Configuration reloaded")
+			return nil
+		},
+	}
+}
+
+// formatPath renders the node IDs a route traversed, or "-" when unknown.
+func formatPath(path []string) string {
+	if len(path) == 0 {
+		return "-"
+	}
+	return strings.Join(path, " → ")
+}
+
+// printDaemonRoutes prints the route table of the running daemon.
+func printDaemonRoutes(resp *pb.ListRoutesResponse) {
+	var local, learned []*pb.AdminRoute
+	for _, r := range resp.Routes {
+		if r.PeerId == "" {
+			local = append(local, r)
+		} else {
+			learned = append(learned, r)
+		}
+	}
+
+	fmt.Printf("📤 Exported Routes (announced by %s):\n", resp.NodeId)
+	fmt.Println("─────────────────────────────────────────")
+	if len(local) == 0 {
+		fmt.Println("  (none)")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  VNI\tPREFIX\tNEXT-HOP\tMETRIC")
+		fmt.Fprintln(w, "  ───\t──────\t────────\t──────")
+		for _, r := range local {
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%d\n", r.Vni, r.Prefix, r.NextHop, r.Metric)
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	fmt.Println("📥 Learned Routes:")
+	fmt.Println("─────────────────────────────────────────")
+	if len(learned) == 0 {
+		fmt.Println("  (none)")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  VNI\tPREFIX\tNEXT-HOP\tPEER\tPATH\tTABLE\tEXPIRES\tSTATE")
+		fmt.Fprintln(w, "  ───\t──────\t────────\t────\t────\t─────\t───────\t─────")
+		for _, r := range learned {
+			state := "🟢 installed"
+			if !r.Installed {
+				state = "🔴 " + r.Reason
+			}
+			if r.RelayedVia != "" {
+				state += " (relayed via " + r.RelayedVia + ")"
+			}
+			if r.StaleUntilMs != 0 {
+				state += " (stale, graceful restart)"
+			}
+			expires := "-"
+			if r.ExpiresAtMs != 0 {
+				expires = "in " + time.Until(time.UnixMilli(r.ExpiresAtMs)).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+				r.Vni, r.Prefix, r.NextHop, r.PeerId, formatPath(r.Path), r.Table, expires, state)
+		}
+		w.Flush()
+	}
+
+	if len(resp.Rejected) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("🚫 Rejected Announcements:")
+	fmt.Println("─────────────────────────────────────────")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  VNI\tPREFIX\tNEXT-HOP\tPEER\tWHEN\tREASON")
+	fmt.Fprintln(w, "  ───\t──────\t────────\t────\t────\t──────")
+	for _, r := range resp.Rejected {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\t%s\n",
+			r.Vni, r.Prefix, r.NextHop, r.PeerId, formatMillis(r.RejectedAtMs), r.Reason)
+	}
+	w.Flush()
+}
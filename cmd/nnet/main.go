@@ -4,17 +4,21 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	pb "github.com/nishisan-dev/n-netman/api/v1"
 	"github.com/nishisan-dev/n-netman/internal/config"
 	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/netplan"
 	"github.com/nishisan-dev/n-netman/internal/observability"
 	"github.com/nishisan-dev/n-netman/internal/reconciler"
 	"github.com/nishisan-dev/n-netman/internal/routing"
@@ -26,6 +30,7 @@ var (
 	buildDate = "unknown"
 
 	configPath string
+	socketPath string
 )
 
 func main() {
@@ -38,12 +43,16 @@ It allows you to apply configurations, check status, and manage your VXLAN overl
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "/etc/n-netman/n-netman.yaml", "Path to configuration file")
+	rootCmd.PersistentFlags().StringVar(&socketPath, "socket", "", "Path to the daemon admin socket (default: admin.socket from the config)")
 
 	// Add subcommands
 	rootCmd.AddCommand(versionCmd())
 	rootCmd.AddCommand(applyCmd())
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(routesCmd())
+	rootCmd.AddCommand(peersCmd())
+	rootCmd.AddCommand(reconcileCmd())
+	rootCmd.AddCommand(reloadCmd())
 	rootCmd.AddCommand(doctorCmd())
 	rootCmd.AddCommand(libvirtCmd())
 	rootCmd.AddCommand(certCmd())
@@ -74,60 +83,81 @@ func loadConfig() (*config.Config, error) {
 }
 
 func applyCmd() *cobra.Command {
-	var dryRun bool
+	var dryRun, prune bool
+	var output string
 
 	cmd := &cobra.Command{
 		Use:   "apply",
 		Short: "Apply configuration and reconcile state",
 		Long: `Apply reads the configuration file and reconciles the system state.
-It creates/updates VXLAN interfaces, bridges, and FDB entries as needed.`,
+It compares the configuration with the kernel, prints the resulting plan
+(links, MTU changes, VNI-change recreations, FDB entries, addresses, rules
+and routes) and executes it. With --dry-run only the plan is printed.
+With --prune (or reconcile.prune in the config) it also tears down the
+interfaces, addresses, rules and routes of overlays removed from the config.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid output format %q (expected text or json)", output)
+			}
 			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
 
-			fmt.Printf("📋 Loading configuration from: %s\n", configPath)
-			fmt.Printf("   Node ID: %s\n", cfg.Node.ID)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
 
-			overlays := cfg.GetOverlays()
-			peers := cfg.GetPeers()
-			fmt.Printf("   Config Version: %d\n", cfg.Version)
-			fmt.Printf("   Overlays: %d\n", len(overlays))
-			for _, o := range overlays {
-				fmt.Printf("     • VNI %d: %s (bridge: %s)\n", o.VNI, o.Name, o.Bridge.Name)
+			rec := reconciler.New(cfg,
+				reconciler.WithPrune(prune),
+				reconciler.WithExportedPrefixes(routing.NewManager(cfg).ExportedPrefixes))
+			plan, planErr := rec.Plan(ctx)
+
+			if output == "json" {
+				data, err := json.MarshalIndent(plan, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			} else {
+				fmt.Printf("📋 Loading configuration from: %s\n", configPath)
+				fmt.Printf("   Node ID: %s\n", cfg.Node.ID)
+				fmt.Printf("   Config Version: %d\n", cfg.Version)
+				fmt.Printf("   Overlays: %d\n", len(cfg.GetOverlays()))
+				fmt.Printf("   Peers: %d\n\n", len(cfg.GetPeers()))
+				plan.Render(os.Stdout)
+				fmt.Println()
 			}
-			fmt.Printf("   Peers: %d\n\n", len(peers))
 
 			if dryRun {
-				fmt.Println("🔍 Dry-run mode - no changes will be made")
-				fmt.Println("\nWould perform:")
-				for _, o := range overlays {
-					fmt.Printf("  • Create bridge: %s\n", o.Bridge.Name)
-					fmt.Printf("  • Create VXLAN: %s (VNI %d)\n", o.Name, o.VNI)
-				}
-				for _, peer := range peers {
-					fmt.Printf("  • Add FDB entry for peer: %s (%s)\n", peer.ID, peer.Endpoint.Address)
+				if output == "text" {
+					fmt.Println("🔍 Dry-run mode - no changes were made")
 				}
-				return nil
+				return planErr
+			}
+			if plan.Empty() {
+				return planErr
 			}
 
-			fmt.Println("🔧 Applying configuration...")
-
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			rec := reconciler.New(cfg)
-			if err := rec.RunOnce(ctx); err != nil {
-				return fmt.Errorf("reconciliation failed: %w", err)
+			if output == "text" {
+				fmt.Println("🔧 Applying configuration...")
+			}
+			if err := rec.Apply(ctx, plan); err != nil {
+				return fmt.Errorf("reconciliation failed: %w", errors.Join(planErr, err))
+			}
+			if planErr != nil {
+				return fmt.Errorf("reconciliation failed: %w", planErr)
 			}
 
-			fmt.Println("\n✅ Configuration applied successfully!")
+			if output == "text" {
+				fmt.Println("\n✅ Configuration applied successfully!")
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without making changes")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the execution plan without making changes")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Remove resources of overlays no longer in the config")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Plan output format: text or json")
 
 	return cmd
 }
@@ -145,6 +175,24 @@ func statusCmd() *cobra.Command {
 
 			fmt.Printf("🖥️  Node: %s (%s)\n\n", cfg.Node.ID, cfg.Node.Hostname)
 
+			fmt.Println("🛤️  Underlay:")
+			fmt.Println("─────────────────────────────────────────")
+			np, err := netplan.LoadConfig(cfg)
+			if err != nil {
+				fmt.Printf("  ⚠️  netplan: %v\n", err)
+			}
+			if u, err := netplan.InferUnderlay(cfg, np, nlink.NewLinkManager()); err != nil {
+				fmt.Printf("  ❌ not found: %v\n", err)
+			} else {
+				fmt.Printf("  %s %s (from %s: %s)\n", u.Interface, u.Address, u.Source, u.Reason)
+			}
+			for _, o := range cfg.GetOverlays() {
+				if o.UnderlayInterface != "" {
+					fmt.Printf("  %s: underlay_interface %s (configured)\n", o.Name, o.UnderlayInterface)
+				}
+			}
+			fmt.Println()
+
 			// Check VXLAN status
 			vxlanMgr := nlink.NewVXLANManager()
 			bridgeMgr := nlink.NewBridgeManager()
@@ -186,9 +234,15 @@ func statusCmd() *cobra.Command {
 					if bridgeInfo.Up {
 						status = "🟢 UP"
 					}
-					// Show bridge with configured IP if present
-					if o.Bridge.IPv4 != "" {
-						fmt.Printf("  %s %s (MTU %d, IP %s)\n", status, bridgeInfo.Name, bridgeInfo.MTU, o.Bridge.IPv4)
+					// Show bridge with configured IPs if present
+					var ips []string
+					for _, cidr := range []string{o.Bridge.IPv4, o.Bridge.IPv6} {
+						if cidr != "" {
+							ips = append(ips, cidr)
+						}
+					}
+					if len(ips) > 0 {
+						fmt.Printf("  %s %s (MTU %d, IP %s)\n", status, bridgeInfo.Name, bridgeInfo.MTU, strings.Join(ips, ", "))
 					} else {
 						fmt.Printf("  %s %s (MTU %d)\n", status, bridgeInfo.Name, bridgeInfo.MTU)
 					}
@@ -202,18 +256,45 @@ func statusCmd() *cobra.Command {
 			}
 			_ = vxlanFound // silence unused variable
 
-			// Try to get live status from daemon (best-effort).
-			daemonStatus := getDaemonStatus(cfg)
+			// Live state from the daemon: the admin API when it is reachable,
+			// otherwise the /status endpoint (best-effort).
+			var admin pb.AdminClient
+			var peerList *pb.ListPeersResponse
+			var daemonStatus *observability.NodeStatus
+			if client, closeFn, err := dialAdmin(cfg); err == nil {
+				defer closeFn()
+				ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+				peerList, err = client.ListPeers(ctx, &pb.ListPeersRequest{})
+				cancel()
+				if err == nil {
+					admin = client
+				}
+			}
+			if admin == nil {
+				daemonStatus = getDaemonStatus(cfg)
+			}
 
 			fmt.Println()
 			fmt.Println("👥 Configured Peers:")
 			fmt.Println("─────────────────────────────────────────")
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "  ID\tENDPOINT\tSTATUS\tROUTES")
-			fmt.Fprintln(w, "  ──\t────────\t──────\t──────")
-
-			if daemonStatus != nil {
+			fmt.Fprintln(w, "  ID\tENDPOINT\tSTATUS\tROUTES\tLINK")
+			fmt.Fprintln(w, "  ──\t────────\t──────\t──────\t────")
+
+			if peerList != nil {
+				for _, p := range peerList.Peers {
+					lastSeen := ""
+					if p.LastSeenMs != 0 && p.Status == "healthy" {
+						lastSeen = " (" + formatMillis(p.LastSeenMs) + ")"
+					}
+					var link *observability.LinkQuality
+					if p.LinkSamples > 0 {
+						link = &observability.LinkQuality{RTTMs: p.RttMs, JitterMs: p.JitterMs, LossPercent: p.LossPercent, Samples: p.LinkSamples}
+					}
+					fmt.Fprintf(w, "  %s\t%s\t%s %s%s\t%d\t%s\n", p.Id, p.Endpoint, getStatusIcon(p.Status), p.Status, lastSeen, p.Routes, formatLink(link))
+				}
+			} else if daemonStatus != nil {
 				// Use live status from daemon
 				for _, peer := range peers {
 					ps, ok := daemonStatus.Peers[peer.ID]
@@ -223,15 +304,15 @@ func statusCmd() *cobra.Command {
 						if ps.LastSeen != "" {
 							lastSeen = " (" + ps.LastSeen + " ago)"
 						}
-						fmt.Fprintf(w, "  %s\t%s\t%s %s%s\t%d\n", ps.ID, ps.Endpoint, statusIcon, ps.Status, lastSeen, ps.Routes)
+						fmt.Fprintf(w, "  %s\t%s\t%s %s%s\t%d\t%s\n", ps.ID, ps.Endpoint, statusIcon, ps.Status, lastSeen, ps.Routes, formatLink(ps.Link))
 					} else {
-						fmt.Fprintf(w, "  %s\t%s\t⏳ unknown\t-\n", peer.ID, peer.Endpoint.Address)
+						fmt.Fprintf(w, "  %s\t%s\t⏳ unknown\t-\t-\n", peer.ID, peer.Endpoint.Address)
 					}
 				}
 			} else {
 				// Fallback: daemon not running
 				for _, peer := range peers {
-					fmt.Fprintf(w, "  %s\t%s\t⚠️  daemon offline\t-\n", peer.ID, peer.Endpoint.Address)
+					fmt.Fprintf(w, "  %s\t%s\t⚠️  daemon offline\t-\t-\n", peer.ID, peer.Endpoint.Address)
 				}
 			}
 			w.Flush()
@@ -265,7 +346,7 @@ func statusCmd() *cobra.Command {
 			for _, o := range overlays {
 				table := o.Routing.Import.Install.Table
 				if table == 0 {
-					table = 100 // default
+					table = config.DefaultImportTable
 				}
 
 				installedRoutes, err := routeMgr.ListByProtocol(table, nlink.RouteProtocolNNetMan)
@@ -305,6 +386,24 @@ func statusCmd() *cobra.Command {
 				fmt.Println("  📥 Installed:  0 route(s)")
 			}
 
+			if admin == nil {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+			defer cancel()
+			if routes, err := admin.ListRoutes(ctx, &pb.ListRoutesRequest{}); err == nil && len(routes.Rejected) > 0 {
+				fmt.Printf("  🚫 Rejected:   %d route(s) (see 'nnet routes')\n", len(routes.Rejected))
+			}
+
+			fmt.Println()
+			fmt.Println("🔧 Reconciler:")
+			fmt.Println("─────────────────────────────────────────")
+			st, err := admin.GetReconcilerStatus(ctx, &pb.GetReconcilerStatusRequest{})
+			if err != nil {
+				return adminError(err)
+			}
+			printReconcilerStatus(st)
+
 			return nil
 		},
 	}
@@ -355,6 +454,14 @@ func getDaemonStatus(cfg *config.Config) *observability.NodeStatus {
 	return &status
 }
 
+// formatLink renders the keepalive link quality as "rtt ±jitter loss".
+func formatLink(l *observability.LinkQuality) string {
+	if l == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms ±%.1fms %.0f%% loss", l.RTTMs, l.JitterMs, l.LossPercent)
+}
+
 // getStatusIcon returns an emoji icon for peer status.
 func getStatusIcon(status string) string {
 	switch status {
@@ -370,15 +477,33 @@ func getStatusIcon(status string) string {
 }
 
 func routesCmd() *cobra.Command {
-	return &cobra.Command{
+	var vni uint32
+
+	cmd := &cobra.Command{
 		Use:   "routes",
 		Short: "List announced and learned routes",
+		Long: `List the routes announced to peers and the routes learned from them.
+When the daemon is running its route table is shown, with the kernel
+installation state of every learned route and the rejected announcements;
+otherwise the routes are read from the config file and the kernel.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
 
+			if client, closeFn, err := dialAdmin(cfg); err == nil {
+				defer closeFn()
+				ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+				defer cancel()
+				resp, err := client.ListRoutes(ctx, &pb.ListRoutesRequest{Vni: vni})
+				if err == nil {
+					printDaemonRoutes(resp)
+					return nil
+				}
+				fmt.Fprintf(os.Stderr, "⚠️  %v, reading config and kernel\n\n", adminError(err))
+			}
+
 			routingMgr := routing.NewManager(cfg)
 			overlays := cfg.GetOverlays()
 
@@ -418,7 +543,7 @@ func routesCmd() *cobra.Command {
 			for _, overlay := range overlays {
 				table := overlay.Routing.Import.Install.Table
 				if table == 0 {
-					table = 100
+					table = config.DefaultImportTable
 				}
 
 				installedRoutes, err := routeMgr.ListByProtocol(table, nlink.RouteProtocolNNetMan)
@@ -454,6 +579,10 @@ func routesCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().Uint32Var(&vni, "vni", 0, "Only show the routes of this VNI (daemon only)")
+
+	return cmd
 }
 
 func doctorCmd() *cobra.Command {
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/reconciler"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+// adminServer implements the local Admin API used by the nnet CLI. It is
+// served on a Unix socket only accessible by root, so requests are not
+// authenticated further.
+type adminServer struct {
+	pb.UnimplementedAdminServer
+
+	// ctx is the daemon's context: actions triggered by a request (reload,
+	// peer reset) outlive the request itself.
+	ctx  context.Context
+	live *atomic.Pointer[config.Config]
+
+	server     *controlplane.Server
+	client     *controlplane.Client
+	rec        *reconciler.Reconciler
+	reloader   *reloader
+	routingMgr *routing.Manager
+	routeTable *controlplane.RouteTable
+	routeMgr   *nlmgr.RouteManager
+	nextHops   *localNextHops
+
+	exportRoutes func() []controlplane.Route
+	logger       *slog.Logger
+
+	grpcServer *grpc.Server
+}
+
+// Start listens on the admin socket and serves the API in the background.
+// A socket left behind by a previous run is replaced.
+func (a *adminServer) Start(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale admin socket: %w", err)
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		lis.Close()
+		return fmt.Errorf("failed to restrict admin socket permissions: %w", err)
+	}
+
+	a.grpcServer = grpc.NewServer()
+	pb.RegisterAdminServer(a.grpcServer, a)
+
+	a.logger.Info("admin API listening", "socket", path)
+	go func() {
+		if err := a.grpcServer.Serve(lis); err != nil {
+			a.logger.Error("admin API server error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Stop stops the admin API. The listener removes the socket file.
+func (a *adminServer) Stop() {
+	if a.grpcServer != nil {
+		a.grpcServer.Stop()
+	}
+}
+
+// ListRoutes implements the ListRoutes RPC.
+func (a *adminServer) ListRoutes(ctx context.Context, req *pb.ListRoutesRequest) (*pb.ListRoutesResponse, error) {
+	cfg := a.live.Load()
+	resp := &pb.ListRoutesResponse{NodeId: cfg.Node.ID}
+
+	for _, r := range getLocalExportableRoutes(cfg, a.routingMgr, a.nextHops.Load()) {
+		if req.Vni != 0 && r.VNI != req.Vni {
+			continue
+		}
+		resp.Routes = append(resp.Routes, &pb.AdminRoute{
+			Prefix:       r.Prefix,
+			NextHop:      r.NextHop,
+			Metric:       r.Metric,
+			Vni:          r.VNI,
+			OriginatorId: cfg.Node.ID,
+		})
+	}
+
+	// Kernel routes installed by n-netman, listed once per table.
+	kernel := make(map[int][]nlmgr.RouteInfo)
+	learned := a.routeTable.All()
+	slices.SortFunc(learned, func(x, y controlplane.Route) int {
+		if c := int(x.VNI) - int(y.VNI); c != 0 {
+			return c
+		}
+		if c := strings.Compare(x.Prefix, y.Prefix); c != 0 {
+			return c
+		}
+		return strings.Compare(x.PeerID, y.PeerID)
+	})
+	for _, r := range learned {
+		if r.PeerID == "" || (req.Vni != 0 && r.VNI != req.Vni) {
+			continue
+		}
+		ar := &pb.AdminRoute{
+			Prefix:       r.Prefix,
+			NextHop:      r.NextHop,
+			Metric:       r.Metric,
+			Vni:          r.VNI,
+			PeerId:       r.PeerID,
+			OriginatorId: r.OriginatorID,
+			Path:         r.Path,
+			RelayedVia:   r.RelayedVia,
+			ExpiresAtMs:  r.ExpiresAt.UnixMilli(),
+		}
+		if !r.StaleUntil.IsZero() {
+			ar.StaleUntilMs = r.StaleUntil.UnixMilli()
+		}
+
+		table, allowed := importTarget(cfg, a.routingMgr, r)
+		ar.Table = uint32(table)
+		if !allowed {
+			ar.Reason = "rejected by import policy"
+			resp.Routes = append(resp.Routes, ar)
+			continue
+		}
+		if _, ok := kernel[table]; !ok {
+			routes, err := a.routeMgr.ListByProtocol(table, nlmgr.RouteProtocolNNetMan)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to list routes of table %d: %v", table, err)
+			}
+			kernel[table] = routes
+		}
+		gw := net.ParseIP(r.NextHop)
+		ar.Installed = slices.ContainsFunc(kernel[table], func(k nlmgr.RouteInfo) bool {
+			if k.Destination == nil || k.Destination.String() != r.Prefix {
+				return false
+			}
+			return gw != nil && (k.Gateway.Equal(gw) || slices.ContainsFunc(k.NextHops, gw.Equal))
+		})
+		if !ar.Installed {
+			best, _ := bestPaths(cfg, a.routeTable, vniPrefix{r.VNI, r.Prefix})
+			if slices.ContainsFunc(best, func(b controlplane.Route) bool { return b.PeerID == r.PeerID }) {
+				ar.Reason = "not present in the kernel"
+			} else {
+				ar.Reason = "not the best path"
+			}
+		}
+		resp.Routes = append(resp.Routes, ar)
+	}
+
+	for _, rej := range a.routeTable.Rejected() {
+		if req.Vni != 0 && rej.Route.VNI != req.Vni {
+			continue
+		}
+		resp.Rejected = append(resp.Rejected, &pb.RejectedRoute{
+			Prefix:       rej.Route.Prefix,
+			NextHop:      rej.Route.NextHop,
+			Vni:          rej.Route.VNI,
+			PeerId:       rej.Route.PeerID,
+			Reason:       rej.Reason,
+			RejectedAtMs: rej.RejectedAt.UnixMilli(),
+		})
+	}
+	return resp, nil
+}
+
+// ListPeers implements the ListPeers RPC.
+func (a *adminServer) ListPeers(ctx context.Context, req *pb.ListPeersRequest) (*pb.ListPeersResponse, error) {
+	statuses := a.client.GetPeerStatuses()
+	sessions := a.client.GetPeerStatus()
+
+	resp := &pb.ListPeersResponse{}
+	for _, peer := range a.live.Load().GetActivePeers() {
+		ps := statuses[peer.ID]
+		ap := &pb.AdminPeer{
+			Id:                     peer.ID,
+			Endpoint:               peer.Endpoint.Address,
+			Status:                 ps.Status,
+			Routes:                 uint32(ps.Routes),
+			RelayedVia:             ps.RelayedVia,
+			KeepaliveIntervalMs:    uint32(peer.Health.KeepAliveDuration().Milliseconds()),
+			DeadAfterMs:            uint32(peer.Health.DeadAfterDuration().Milliseconds()),
+			GracefulRestartSeconds: uint32(a.server.PeerRestartTime(peer.ID).Seconds()),
+			RouteStream:            a.client.HasRouteStream(peer.ID),
+		}
+		if s, ok := sessions[peer.ID]; ok && !s.LastSeen.IsZero() {
+			ap.LastSeenMs = s.LastSeen.UnixMilli()
+		}
+		if ps.Link != nil {
+			ap.RttMs = ps.Link.RTTMs
+			ap.JitterMs = ps.Link.JitterMs
+			ap.LossPercent = ps.Link.LossPercent
+			ap.LinkSamples = ps.Link.Samples
+		}
+		resp.Peers = append(resp.Peers, ap)
+	}
+	return resp, nil
+}
+
+// GetReconcilerStatus implements the GetReconcilerStatus RPC.
+func (a *adminServer) GetReconcilerStatus(ctx context.Context, req *pb.GetReconcilerStatusRequest) (*pb.ReconcilerStatus, error) {
+	st := a.rec.Status()
+	resp := &pb.ReconcilerStatus{Running: st.Running}
+	if !st.LastRun.IsZero() {
+		resp.LastRunMs = st.LastRun.UnixMilli()
+	}
+	// A cycle joins the errors of the overlays that failed.
+	if joined, ok := st.LastErr.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			resp.Errors = append(resp.Errors, err.Error())
+		}
+	} else if st.LastErr != nil {
+		resp.Errors = []string{st.LastErr.Error()}
+	}
+	return resp, nil
+}
+
+// TriggerReconcile implements the TriggerReconcile RPC.
+func (a *adminServer) TriggerReconcile(ctx context.Context, req *pb.TriggerReconcileRequest) (*pb.TriggerReconcileResponse, error) {
+	a.logger.Info("reconciliation requested via admin API")
+	a.rec.Trigger()
+	return &pb.TriggerReconcileResponse{}, nil
+}
+
+// ResetPeer implements the ResetPeer RPC. The learned routes are removed
+// before the session is established again, so the peer's fresh announcement
+// is installed from scratch.
+func (a *adminServer) ResetPeer(ctx context.Context, req *pb.ResetPeerRequest) (*pb.ResetPeerResponse, error) {
+	if err := a.client.ResetPeer(req.PeerId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	removed := a.routeTable.RemoveByPeer(req.PeerId)
+	removeLearnedRoutes(a.live.Load(), a.routeMgr, a.routingMgr, a.routeTable, removed, a.logger, "peer reset")
+	a.logger.Info("peer reset via admin API", "peer_id", req.PeerId, "routes_removed", len(removed))
+
+	// A peer that does not answer is retried by the refresh loop.
+	if err := a.client.ExchangeStateWithPeer(a.ctx, req.PeerId, a.exportRoutes()); err != nil {
+		a.logger.Warn("failed to exchange state after peer reset", "peer_id", req.PeerId, "error", err)
+	}
+	return &pb.ResetPeerResponse{RoutesRemoved: uint32(len(removed))}, nil
+}
+
+// ReloadConfig implements the ReloadConfig RPC.
+func (a *adminServer) ReloadConfig(ctx context.Context, req *pb.ReloadConfigRequest) (*pb.ReloadConfigResponse, error) {
+	a.logger.Info("configuration reload requested via admin API")
+	if err := a.reloader.Reload(a.ctx); err != nil {
+		a.logger.Error("configuration reload failed", "error", err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.ReloadConfigResponse{}, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+func newTestAdmin(cfg *config.Config) *adminServer {
+	var live atomic.Pointer[config.Config]
+	live.Store(cfg)
+	routeTable := controlplane.NewRouteTable()
+	return &adminServer{
+		ctx:          context.Background(),
+		live:         &live,
+		client:       controlplane.NewClient(cfg, routeTable, slog.Default()),
+		routingMgr:   routing.NewManager(cfg),
+		routeTable:   routeTable,
+		routeMgr:     nlmgr.NewRouteManager(),
+		nextHops:     &localNextHops{},
+		exportRoutes: func() []controlplane.Route { return nil },
+		logger:       slog.Default(),
+	}
+}
+
+func TestAdmin_ListRoutes(t *testing.T) {
+	// Neither overlay accepts anything, so no kernel lookup is needed.
+	a := newTestAdmin(v2TwoOverlays())
+	a.routeTable.Add(controlplane.Route{Prefix: "10.0.0.0/24", NextHop: "10.100.0.2", VNI: 100, PeerID: "b"})
+	a.routeTable.Add(controlplane.Route{Prefix: "10.9.0.0/24", NextHop: "10.200.0.2", VNI: 200, PeerID: "b"})
+	a.routeTable.Reject(controlplane.Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "c"}, "routing loop")
+	a.routeTable.Reject(controlplane.Route{Prefix: "10.2.0.0/24", VNI: 200, PeerID: "c"}, "routing loop")
+
+	resp, err := a.ListRoutes(context.Background(), &pb.ListRoutesRequest{Vni: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Routes) != 1 {
+		t.Fatalf("routes = %v, want only the VNI 100 route", resp.Routes)
+	}
+	r := resp.Routes[0]
+	if r.Prefix != "10.0.0.0/24" || r.PeerId != "b" || r.Table != 100 || r.Installed || r.Reason != "rejected by import policy" {
+		t.Errorf("route = %v, want 10.0.0.0/24 from b in table 100, not installed by import policy", r)
+	}
+	if len(resp.Rejected) != 1 || resp.Rejected[0].Prefix != "10.1.0.0/24" || resp.Rejected[0].Reason != "routing loop" {
+		t.Errorf("rejected = %v, want 10.1.0.0/24 (routing loop)", resp.Rejected)
+	}
+}
+
+func TestAdmin_ResetUnknownPeer(t *testing.T) {
+	a := newTestAdmin(v2TwoOverlays())
+	_, err := a.ResetPeer(context.Background(), &pb.ResetPeerRequest{PeerId: "nope"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("ResetPeer(unknown) error = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+// connectedRecheckInterval is how often the connected exports are compared
+// with the interface addresses without an address change notification (or
+// when the reconciler's netlink subscriptions failed).
+const connectedRecheckInterval = 30 * time.Second
+
+// watchConnectedExports keeps the peers up to date with the connected
+// subnets exported by include_connected until ctx is cancelled. changed is
+// signalled (see notifyConnectedChange) when an address is added to or
+// removed from an exported interface: the WatchRoutes streams are notified,
+// the new subnets are announced to the other peers and the subnets no longer
+// exported are withdrawn, instead of waiting for the next re-announcement or
+// for their lease to expire.
+func watchConnectedExports(ctx context.Context, changed <-chan struct{}, currentConfig func() *config.Config, routingMgr *routing.Manager, exportRoutes func() []controlplane.Route, server *controlplane.Server, client *controlplane.Client, logger *slog.Logger) {
+	ticker := time.NewTicker(connectedRecheckInterval)
+	defer ticker.Stop()
+
+	exported := connectedExports(currentConfig(), routingMgr)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+
+		next := connectedExports(currentConfig(), routingMgr)
+		added, removed := diffConnected(exported, next)
+		exported = next
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		for _, k := range added {
+			logger.Info("exporting connected subnet", "vni", k.vni, "prefix", k.prefix)
+		}
+		server.NotifyExportsChanged()
+
+		routes := exportRoutes()
+		if len(added) > 0 {
+			if err := client.AnnounceRoutes(ctx, routes); err != nil {
+				logger.Warn("failed to announce connected subnets", "error", err)
+			}
+		}
+
+		// A subnet also listed in networks stays exported.
+		var withdrawn []controlplane.Route
+		for _, k := range removed {
+			if slices.ContainsFunc(routes, func(r controlplane.Route) bool { return r.VNI == k.vni && r.Prefix == k.prefix }) {
+				continue
+			}
+			withdrawn = append(withdrawn, controlplane.Route{VNI: k.vni, Prefix: k.prefix})
+			logger.Info("withdrawing connected subnet", "vni", k.vni, "prefix", k.prefix)
+		}
+		if len(withdrawn) > 0 {
+			if err := client.WithdrawRoutes(ctx, withdrawn, routes); err != nil {
+				logger.Warn("failed to withdraw connected subnets", "routes", len(withdrawn), "error", err)
+			}
+		}
+	}
+}
+
+// notifyConnectedChange returns the address change callback of the
+// reconciler's kernel watcher (reconciler.WithAddressChanges). A change on an
+// exported interface signals changed without blocking; a signal already
+// pending covers it, as every check compares all the connected exports.
+func notifyConnectedChange(currentConfig func() *config.Config, changed chan<- struct{}) func(link string) {
+	return func(link string) {
+		if !slices.Contains(connectedInterfaces(currentConfig()), link) {
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// connectedInterfaces returns the interfaces whose connected subnets are
+// exported: the bridge and connected_interfaces of the overlays with
+// include_connected.
+func connectedInterfaces(cfg *config.Config) []string {
+	var out []string
+	for _, o := range cfg.GetOverlays() {
+		if o.Routing.Export.IncludeConnected {
+			out = append(out, o.Bridge.Name)
+			out = append(out, o.Routing.Export.ConnectedInterfaces...)
+		}
+	}
+	return out
+}
+
+// connectedExports returns the connected subnets currently exported, by
+// (VNI, prefix).
+func connectedExports(cfg *config.Config, routingMgr *routing.Manager) map[vniPrefix]bool {
+	out := make(map[vniPrefix]bool)
+	for _, o := range cfg.GetOverlays() {
+		for _, r := range routingMgr.GetConnectedRoutesForOverlay(o) {
+			out[vniPrefix{r.VNI, r.Prefix}] = true
+		}
+	}
+	return out
+}
+
+// diffConnected returns the connected subnets exported by next but not by
+// old, and the ones exported by old but not by next.
+func diffConnected(old, next map[vniPrefix]bool) (added, removed []vniPrefix) {
+	for k := range next {
+		if !old[k] {
+			added = append(added, k)
+		}
+	}
+	for k := range old {
+		if !next[k] {
+			removed = append(removed, k)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+// collectStaleRoutes returns the n-netman routes left in the kernel by the
+// previous run (graceful restart keeps them on shutdown). They keep
+// forwarding traffic while the peers re-announce them.
+func collectStaleRoutes(cfg *config.Config, routeMgr *nlmgr.RouteManager, logger *slog.Logger) []nlmgr.RouteInfo {
+	var stale []nlmgr.RouteInfo
+	for _, table := range distinctImportTables(cfg) {
+		routes, err := routeMgr.ListByProtocol(table, nlmgr.RouteProtocolNNetMan)
+		if err != nil {
+			logger.Warn("failed to list routes kept from the previous run", "table", table, "error", err)
+			continue
+		}
+		stale = append(stale, routes...)
+	}
+	return stale
+}
+
+// sweepStaleRoutes waits for the stale window and then removes the kept
+// routes that no peer re-announced. Routes re-announced with the same
+// next-hop are left untouched; those re-announced with another next-hop were
+// already replaced in the kernel.
+func sweepStaleRoutes(ctx context.Context, window time.Duration, stale []nlmgr.RouteInfo, currentConfig func() *config.Config, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, logger *slog.Logger) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(window):
+	}
+
+	removed := 0
+	for _, r := range unbackedRoutes(currentConfig(), routingMgr, routeTable.All(), stale) {
+		if err := routeMgr.Delete(nlmgr.RouteConfig{
+			Destination: r.Destination,
+			Gateway:     r.Gateway,
+			Table:       r.Table,
+			Metric:      r.Metric,
+			Protocol:    nlmgr.RouteProtocolNNetMan,
+		}); err != nil {
+			logger.Debug("failed to remove stale route", "prefix", r.Destination, "table", r.Table, "error", err)
+			continue
+		}
+		removed++
+		logger.Info("removed route", "reason", "not re-announced after restart",
+			"prefix", r.Destination, "next_hop", r.Gateway, "table", r.Table)
+	}
+	logger.Info("graceful restart stale window closed", "stale", len(stale), "removed", removed)
+}
+
+// unbackedRoutes returns the kernel routes in stale that no learned route
+// accounts for, matched by (table, prefix, next-hop). A multipath route is
+// matched by (table, prefix) only: once the prefix is learned again its
+// kernel route was reprogrammed with the current best paths.
+func unbackedRoutes(cfg *config.Config, routingMgr *routing.Manager, learned []controlplane.Route, stale []nlmgr.RouteInfo) []nlmgr.RouteInfo {
+	type key struct {
+		table   int
+		prefix  string
+		nextHop string
+	}
+	backed := make(map[key]bool)
+	for _, r := range learned {
+		if r.PeerID == "" {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(r.Prefix)
+		gw := net.ParseIP(r.NextHop)
+		if err != nil || gw == nil {
+			continue
+		}
+		table, allowed := importTarget(cfg, routingMgr, r)
+		if allowed {
+			backed[key{table, ipnet.String(), gw.String()}] = true
+			backed[key{table: table, prefix: ipnet.String()}] = true
+		}
+	}
+
+	var out []nlmgr.RouteInfo
+	for _, r := range stale {
+		if r.Destination == nil {
+			continue
+		}
+		k := key{r.Table, r.Destination.String(), r.Gateway.String()}
+		if len(r.NextHops) > 0 {
+			k.nextHop = ""
+		}
+		if backed[k] {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
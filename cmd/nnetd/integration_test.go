@@ -0,0 +1,554 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// The integration tests build a lab of network namespaces on the local host:
+// one namespace per node, each with an eth0 underlay joined to the others by
+// veth pairs on a bridge in a shared underlay namespace. Every node runs the
+// full daemon stack (reconciler, control plane server and client) in-process:
+// the test binary re-executes itself inside the node's namespace and runs the
+// daemon from TestIntegrationNode. Run as root with:
+//
+//	go test -tags integration -run Integration ./cmd/nnetd
+
+// nodeConfigEnv carries the config path of a node process.
+const nodeConfigEnv = "NNET_INTEGRATION_NODE_CONFIG"
+
+// convergeTimeout bounds every wait for the lab to reach a state.
+const convergeTimeout = 45 * time.Second
+
+// TestIntegrationNode is the entry point of a node process. It only runs
+// when started by the lab, inside the node's namespace, and runs the daemon
+// until the lab sends SIGTERM.
+func TestIntegrationNode(t *testing.T) {
+	path := os.Getenv(nodeConfigEnv)
+	if path == "" {
+		t.Skip("only runs as a lab node")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lab is a set of nodes sharing an underlay segment.
+type lab struct {
+	t        *testing.T
+	prefix   string
+	underlay string // namespace holding the underlay bridge
+	nodes    []*labNode
+}
+
+// labNode is one host of the lab.
+type labNode struct {
+	lab     *lab
+	index   int // 1-based, used to derive the addresses
+	id      string
+	ns      string
+	cfgPath string
+	logPath string
+	sock    string // admin API socket
+	exports []string
+	cmd     *exec.Cmd
+	done    chan error
+}
+
+func (n *labNode) underlayIP() string { return fmt.Sprintf("192.168.77.%d", n.index) }
+func (n *labNode) overlayIP() string  { return fmt.Sprintf("10.100.0.%d", n.index) }
+func (n *labNode) exportPrefix() string {
+	return fmt.Sprintf("172.16.%d.0/24", n.index)
+}
+
+// newLab creates the namespaces and underlay of an n-node lab. Everything is
+// torn down when the test ends.
+func newLab(t *testing.T, n int) *lab {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("integration tests require root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("integration tests require iproute2")
+	}
+
+	// The node logs live in dir; it is registered first so it is removed
+	// after the teardown dumped them.
+	dir := t.TempDir()
+	l := &lab{t: t, prefix: fmt.Sprintf("nnit%d", os.Getpid())}
+	l.underlay = l.prefix + "-ul"
+	t.Cleanup(l.teardown)
+
+	l.ip("netns", "add", l.underlay)
+	l.ip("-n", l.underlay, "link", "add", "br-ul", "type", "bridge")
+	l.ip("-n", l.underlay, "link", "set", "br-ul", "up")
+
+	for i := 1; i <= n; i++ {
+		node := &labNode{
+			lab:     l,
+			index:   i,
+			id:      fmt.Sprintf("node-%d", i),
+			ns:      fmt.Sprintf("%s-n%d", l.prefix, i),
+			cfgPath: filepath.Join(dir, fmt.Sprintf("node-%d.yaml", i)),
+			logPath: filepath.Join(dir, fmt.Sprintf("node-%d.log", i)),
+			sock:    filepath.Join(dir, fmt.Sprintf("node-%d.sock", i)),
+		}
+		node.exports = []string{node.exportPrefix()}
+		l.nodes = append(l.nodes, node)
+
+		// eth0 in the node, its peer end enslaved to the underlay bridge.
+		peer := fmt.Sprintf("ul%d", i)
+		l.ip("netns", "add", node.ns)
+		l.ip("-n", node.ns, "link", "set", "lo", "up")
+		l.ip("link", "add", "eth0", "netns", node.ns, "type", "veth", "peer", "name", peer, "netns", l.underlay)
+		l.ip("-n", node.ns, "addr", "add", node.underlayIP()+"/24", "dev", "eth0")
+		l.ip("-n", node.ns, "link", "set", "eth0", "up")
+		l.ip("-n", l.underlay, "link", "set", peer, "master", "br-ul", "up")
+	}
+	return l
+}
+
+func (l *lab) ip(args ...string) {
+	l.t.Helper()
+	if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+		l.t.Fatalf("ip %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+func (l *lab) teardown() {
+	for _, n := range l.nodes {
+		n.stop()
+		if l.t.Failed() {
+			if data, err := os.ReadFile(n.logPath); err == nil {
+				l.t.Logf("=== %s log ===\n%s", n.id, data)
+			}
+		}
+		_ = exec.Command("ip", "netns", "del", n.ns).Run()
+	}
+	_ = exec.Command("ip", "netns", "del", l.underlay).Run()
+}
+
+// start writes the node's config and starts its daemon process.
+func (l *lab) start() {
+	l.t.Helper()
+	for _, n := range l.nodes {
+		n.writeConfig()
+		n.start()
+	}
+}
+
+// writeConfig renders the node's config: one head-end replication overlay
+// (VNI 100) with every other node as a peer, exporting n.exports.
+func (n *labNode) writeConfig() {
+	t := n.lab.t
+	t.Helper()
+
+	var peers, exports strings.Builder
+	for _, other := range n.lab.nodes {
+		if other == n {
+			continue
+		}
+		fmt.Fprintf(&peers, `  - id: %q
+    endpoint:
+      address: %q
+    health:
+      keepalive_interval_ms: 500
+      dead_after_ms: 2000
+`, other.id, other.underlayIP())
+	}
+	for _, prefix := range n.exports {
+		fmt.Fprintf(&exports, "          - %q\n", prefix)
+	}
+
+	cfg := fmt.Sprintf(`version: 2
+node:
+  id: %q
+overlays:
+  - vni: 100
+    name: vxlan100
+    mtu: 1450
+    learning: true
+    bridge:
+      name: br-100
+      ipv4: %q
+    underlay_interface: eth0
+    routing:
+      export:
+        networks:
+%s      import:
+        accept_all: true
+        install:
+          table: 100
+          flush_on_peer_down: true
+          route_lease_seconds: 60
+peers:
+%stopology:
+  mode: full-mesh
+  relay_fallback: false
+security:
+  control_plane:
+    listen:
+      address: "0.0.0.0"
+      port: 9898
+observability:
+  logging:
+    level: debug
+    format: text
+  metrics:
+    enabled: false
+  healthcheck:
+    enabled: false
+admin:
+  enabled: true
+  socket: %q
+`, n.id, n.overlayIP()+"/24", exports.String(), peers.String(), n.sock)
+
+	// Write-and-rename so the daemon's file watcher never sees a partial file.
+	tmp := n.cfgPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, n.cfgPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (n *labNode) start() {
+	t := n.lab.t
+	t.Helper()
+	logFile, err := os.OpenFile(n.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.cmd = exec.Command("ip", "netns", "exec", n.ns, os.Args[0], "-test.run=^TestIntegrationNode$", "-test.v")
+	n.cmd.Env = append(os.Environ(), nodeConfigEnv+"="+n.cfgPath)
+	n.cmd.Stdout = logFile
+	n.cmd.Stderr = logFile
+	if err := n.cmd.Start(); err != nil {
+		logFile.Close()
+		t.Fatalf("failed to start %s: %v", n.id, err)
+	}
+	n.done = make(chan error, 1)
+	go func() {
+		n.done <- n.cmd.Wait()
+		logFile.Close()
+	}()
+}
+
+// stop shuts the node's daemon down gracefully (it flushes its routes) and
+// waits for it to exit.
+func (n *labNode) stop() {
+	if n.cmd == nil {
+		return
+	}
+	_ = n.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-n.done:
+	case <-time.After(15 * time.Second):
+		_ = n.cmd.Process.Kill()
+		<-n.done
+	}
+	n.cmd = nil
+}
+
+// handle returns a netlink handle bound to the node's namespace.
+func (n *labNode) handle() *netlink.Handle {
+	t := n.lab.t
+	t.Helper()
+	ns, err := netns.GetFromName(n.ns)
+	if err != nil {
+		t.Fatalf("namespace %s: %v", n.ns, err)
+	}
+	defer ns.Close()
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		t.Fatalf("netlink handle for %s: %v", n.ns, err)
+	}
+	return h
+}
+
+// floodPeers returns the head-end replication destinations on vxlan100.
+func (n *labNode) floodPeers() ([]string, error) {
+	h := n.handle()
+	defer h.Close()
+	link, err := h.LinkByName("vxlan100")
+	if err != nil {
+		return nil, err
+	}
+	neighs, err := h.NeighList(link.Attrs().Index, unix.AF_BRIDGE)
+	if err != nil {
+		return nil, err
+	}
+	var peers []string
+	for _, nb := range neighs {
+		if nlmgr.IsZeroMAC(nb.HardwareAddr) && nb.IP != nil {
+			peers = append(peers, nb.IP.String())
+		}
+	}
+	return peers, nil
+}
+
+// installedRoutes returns the n-netman routes in table 100 as
+// "prefix via gateway".
+func (n *labNode) installedRoutes() ([]string, error) {
+	h := n.handle()
+	defer h.Close()
+	routes, err := h.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		Table:    100,
+		Protocol: netlink.RouteProtocol(nlmgr.RouteProtocolNNetMan),
+	}, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, r := range routes {
+		out = append(out, fmt.Sprintf("%s via %s", r.Dst, r.Gw))
+	}
+	return out, nil
+}
+
+// admin returns a client of the node's admin API.
+func (n *labNode) admin() pb.AdminClient {
+	t := n.lab.t
+	t.Helper()
+	conn, err := grpc.NewClient("unix://"+n.sock, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewAdminClient(conn)
+}
+
+// dial opens a TCP connection from the node's namespace.
+func (n *labNode) dial(addr string) error {
+	var err error
+	n.inNamespace(func() {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", addr, 2*time.Second); err == nil {
+			conn.Close()
+		}
+	})
+	return err
+}
+
+// listen opens a TCP listener in the node's namespace that accepts and
+// closes connections until the test ends.
+func (n *labNode) listen(addr string) {
+	t := n.lab.t
+	t.Helper()
+	var ln net.Listener
+	var err error
+	n.inNamespace(func() { ln, err = net.Listen("tcp", addr) })
+	if err != nil {
+		t.Fatalf("%s: listen %s: %v", n.id, addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+}
+
+// inNamespace runs fn on an OS thread switched into the node's namespace.
+// Sockets created by fn stay in that namespace.
+func (n *labNode) inNamespace(fn func()) {
+	t := n.lab.t
+	t.Helper()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	target, err := netns.GetFromName(n.ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	if err := netns.Set(target); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := netns.Set(orig); err != nil {
+			// The thread is unusable; keep it locked so the runtime drops it.
+			runtime.LockOSThread()
+		}
+	}()
+	fn()
+}
+
+// eventually polls cond until it returns nil or convergeTimeout elapses.
+func eventually(t *testing.T, what string, cond func() error) {
+	t.Helper()
+	deadline := time.Now().Add(convergeTimeout)
+	for {
+		err := cond()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: %v", what, err)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// sameSet reports whether got and want hold the same strings, ignoring order.
+func sameSet(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range got {
+		seen[s]++
+	}
+	for _, s := range want {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
+
+// expectedRoutes returns the routes node n should have learned: the exports
+// of every running peer, via the peer's bridge IP.
+func (l *lab) expectedRoutes(n *labNode, skip ...*labNode) []string {
+	var want []string
+next:
+	for _, other := range l.nodes {
+		if other == n {
+			continue
+		}
+		for _, s := range skip {
+			if other == s {
+				continue next
+			}
+		}
+		for _, prefix := range other.exports {
+			want = append(want, fmt.Sprintf("%s via %s", prefix, other.overlayIP()))
+		}
+	}
+	return want
+}
+
+// waitRoutes waits until every node in nodes has exactly the expected
+// routes, ignoring the exports of the skipped nodes.
+func (l *lab) waitRoutes(nodes []*labNode, skip ...*labNode) {
+	l.t.Helper()
+	for _, n := range nodes {
+		want := l.expectedRoutes(n, skip...)
+		eventually(l.t, n.id+" routes", func() error {
+			got, err := n.installedRoutes()
+			if err != nil {
+				return err
+			}
+			if !sameSet(got, want) {
+				return fmt.Errorf("table 100 = %q, want %q", got, want)
+			}
+			return nil
+		})
+	}
+}
+
+func TestIntegration_ThreeNodeOverlay(t *testing.T) {
+	l := newLab(t, 3)
+	l.start()
+	n1, n2, n3 := l.nodes[0], l.nodes[1], l.nodes[2]
+
+	// The reconciler builds the overlay with a flood entry per peer.
+	for _, n := range l.nodes {
+		var want []string
+		for _, other := range l.nodes {
+			if other != n {
+				want = append(want, other.underlayIP())
+			}
+		}
+		eventually(t, n.id+" FDB", func() error {
+			got, err := n.floodPeers()
+			if err != nil {
+				return err
+			}
+			if !sameSet(got, want) {
+				return fmt.Errorf("flood peers = %v, want %v", got, want)
+			}
+			return nil
+		})
+	}
+
+	// VXLAN data plane: the bridges reach each other over the overlay.
+	n2.listen(n2.overlayIP() + ":7777")
+	eventually(t, "overlay connectivity "+n1.id+" -> "+n2.id, func() error {
+		return n1.dial(n2.overlayIP() + ":7777")
+	})
+
+	// Control plane: every node installs the exports of the others.
+	l.waitRoutes(l.nodes)
+
+	t.Run("admin API lists the learned routes as installed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		resp, err := n1.admin().ListRoutes(ctx, &pb.ListRoutesRequest{Vni: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range resp.Routes {
+			if r.PeerId == "" {
+				continue
+			}
+			if !r.Installed {
+				t.Errorf("route %s from %s not installed: %s", r.Prefix, r.PeerId, r.Reason)
+			}
+			got = append(got, fmt.Sprintf("%s via %s", r.Prefix, r.NextHop))
+		}
+		if want := l.expectedRoutes(n1); !sameSet(got, want) {
+			t.Errorf("learned routes = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("withdrawn export is removed from peers", func(t *testing.T) {
+		n2.exports = nil
+		n2.writeConfig() // picked up by the config file watcher
+		l.waitRoutes([]*labNode{n1, n3})
+	})
+
+	t.Run("routes of a dead peer are flushed", func(t *testing.T) {
+		n3.stop()
+		l.waitRoutes([]*labNode{n1, n2}, n3)
+		// The stopped node flushed its own table on shutdown.
+		if got, err := n3.installedRoutes(); err != nil || len(got) != 0 {
+			t.Fatalf("%s routes after shutdown = %q (err %v), want none", n3.id, got, err)
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/nishisan-dev/n-netman/internal/config"
 	"github.com/nishisan-dev/n-netman/internal/controlplane"
 	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/netplan"
 	"github.com/nishisan-dev/n-netman/internal/observability"
 	"github.com/nishisan-dev/n-netman/internal/reconciler"
 	"github.com/nishisan-dev/n-netman/internal/routing"
@@ -69,12 +71,35 @@ func main() {
 		"config", *configPath,
 	)
 
+	// Setup context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle shutdown signals
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigCh
+		slog.Info("received shutdown signal", "signal", sig)
+		cancel()
+	}()
+
+	if err := run(ctx, *configPath); err != nil {
+		slog.Error("daemon failed", "error", err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// run loads the configuration and runs the daemon (reconciler, control plane
+// server and client, observability) until ctx is canceled.
+func run(ctx context.Context, configPath string) error {
 	// Load configuration
 	loader := config.NewLoader()
-	cfg, err := loader.LoadFile(*configPath)
+	cfg, err := loader.LoadFile(configPath)
 	if err != nil {
-		slog.Error("failed to load configuration", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
 	// Reconfigure logging from the loaded config (level + format).
@@ -101,19 +126,10 @@ func main() {
 		}
 	}
 
-	// Setup context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Handle shutdown signals
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigCh
-		slog.Info("received shutdown signal", "signal", sig)
-		cancel()
-	}()
+	// The running config. Components hold their own copy of the pointer and
+	// are updated by the reloader; closures below always read the live one.
+	var live atomic.Pointer[config.Config]
+	live.Store(cfg)
 
 	// Initialize metrics
 	metrics := observability.NewMetrics(prometheus.DefaultRegisterer)
@@ -122,8 +138,7 @@ func main() {
 	// Start observability server (metrics + health)
 	obsServer := observability.NewServer(cfg, logger)
 	if err := obsServer.Start(ctx); err != nil {
-		slog.Error("failed to start observability server", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to start observability server: %w", err)
 	}
 	defer obsServer.Stop(context.Background())
 
@@ -136,31 +151,95 @@ func main() {
 	// Initialize route table for control plane
 	routeTable := controlplane.NewRouteTable()
 
-	// Create route installer callback (control plane -> kernel).
+	// Graceful restart: the routes kept by the previous run stay installed
+	// while the peers re-announce them; the ones nobody re-announces within
+	// the stale window are removed.
+	if cfg.GracefulRestart.Enabled {
+		if stale := collectStaleRoutes(cfg, routeMgr, logger); len(stale) > 0 {
+			logger.Info("graceful restart: keeping routes from the previous run",
+				"count", len(stale), "stale_window", cfg.GracefulRestart.StaleWindow())
+			go sweepStaleRoutes(ctx, cfg.GracefulRestart.StaleWindow(), stale, live.Load, routeMgr, routingMgr, routeTable, logger)
+		}
+	}
+
+	cpServer := controlplane.NewServer(cfg, routeTable, logger)
+
+	// Per-peer limits (max_prefixes, announcement rate), enforced on both
+	// the server and the client paths.
+	limits := controlplane.NewLimits(logger)
+	limits.SetMetrics(metrics)
+
+	// Create route installer callback (control plane -> kernel). Learned
+	// routes may be re-advertised, so the peers watching our routes are
+	// notified of the change.
 	routeInstaller := func(routes []controlplane.Route) {
-		installReceivedRoutes(cfg, routeMgr, routingMgr, routes, logger)
+		installReceivedRoutes(live.Load(), routeMgr, routingMgr, routeTable, routes, logger)
+		cpServer.NotifyExportsChanged()
 	}
 	// Create route remover callback (peer withdrawals -> kernel cleanup).
 	routeRemover := func(routes []controlplane.Route) {
-		deleteRoutesFromKernel(cfg, routeMgr, routes, logger, "withdrawn by peer")
+		removeLearnedRoutes(live.Load(), routeMgr, routingMgr, routeTable, routes, logger, "withdrawn by peer")
+		cpServer.NotifyExportsChanged()
+	}
+
+	// This node's next-hops, computed once per config and refreshed on
+	// address changes (see localNextHops).
+	nextHops := &localNextHops{}
+	nextHops.Refresh(cfg, routingMgr)
+	nextHopsChanged := make(chan struct{}, 1)
+
+	// Routes advertised to peers: local exports plus the learned routes
+	// re-advertised by a hub or a transit node.
+	exportRoutes := func() []controlplane.Route {
+		return getExportableRoutes(live.Load(), routingMgr, routeTable, nextHops.Load())
 	}
 
 	// Start gRPC control plane server
-	cpServer := controlplane.NewServer(cfg, routeTable, logger)
 	cpServer.SetRoutesReceivedCallback(routeInstaller)
 	cpServer.SetRoutesWithdrawnCallback(routeRemover)
+	cpServer.SetExportRoutesFunc(exportRoutes)
+	cpServer.SetMetrics(metrics)
+	cpServer.SetLimits(limits)
 	if err := cpServer.Start(); err != nil {
-		slog.Error("failed to start control plane server", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to start control plane server: %w", err)
 	}
 	defer cpServer.Stop()
 
 	// Start control plane client (connect to peers and keep routes fresh).
 	cpClient := controlplane.NewClient(cfg, routeTable, logger)
-	// Routes learned on the client path (ExchangeState responses) are installed too.
+	cpClient.SetMetrics(metrics)
+	cpClient.SetLimits(limits)
+	// Routes learned on the client path (ExchangeState responses and route
+	// streams) are installed too, and removed when withdrawn on a stream.
 	cpClient.SetRoutesReceivedCallback(routeInstaller)
+	cpClient.SetRoutesWithdrawnCallback(routeRemover)
+	// Peers watching our routes get every change on their stream and are
+	// skipped by the periodic re-announcement.
+	cpClient.SetRouteWatcherFunc(cpServer.IsWatching)
+	// Address changes on the interfaces exported by include_connected, seen by
+	// the reconciler's kernel watcher.
+	connectedChanged := make(chan struct{}, 1)
+	// Peers declared dead by their keepalive timers are handed to the refresh
+	// loop right away, so failover does not wait for the next health tick. If
+	// the buffer is full the event is dropped: relayed routes are refreshed on
+	// every health tick and flushed ones expire with their lease anyway.
+	peerDown := make(chan string, 64)
+	cpClient.SetPeerDownCallback(func(peerID string) {
+		select {
+		case peerDown <- peerID:
+		default:
+			logger.Warn("peer-down event dropped", "peer_id", peerID)
+		}
+	})
 	// Set client as status provider for /status endpoint
 	obsServer.SetStatusProvider(cpClient)
+	// Peers reachable through our sessions are offered as relay targets.
+	cpServer.SetRelayInfoFunc(func() controlplane.RelayInfo {
+		return controlplane.RelayInfo{
+			ReachablePeers: cpClient.HealthyPeers(),
+			NextHops:       nextHops.Load(),
+		}
+	})
 	go func() {
 		// Wait a bit for local setup before connecting to peers
 		time.Sleep(2 * time.Second)
@@ -169,19 +248,40 @@ func main() {
 		}
 
 		// Perform initial state exchange
-		localRoutes := getLocalExportableRoutes(cfg, routeTable)
-		if err := cpClient.ExchangeStateWithPeers(ctx, localRoutes); err != nil {
+		if err := cpClient.ExchangeStateWithPeers(ctx, exportRoutes()); err != nil {
 			slog.Warn("failed to exchange state with peers", "error", err)
 		}
 
 		// Start periodic health checks and route refresh loop.
-		go runRouteRefreshLoop(ctx, cpClient, cfg, routeTable, routeMgr, metrics, logger)
+		go runRouteRefreshLoop(ctx, cpClient, live.Load, routeTable, routeMgr, routingMgr, exportRoutes, cpServer.PeerRestartTime, peerDown, metrics, logger)
+
+		// Announce and withdraw connected subnets (include_connected) as
+		// interface addresses change.
+		go watchConnectedExports(ctx, connectedChanged, live.Load, routingMgr, exportRoutes, cpServer, cpClient, logger)
+
+		// Follow the underlay and bridge addresses the next-hops come from.
+		go nextHops.follow(ctx, nextHopsChanged, live.Load, routingMgr, cpServer, logger)
 	}()
 	defer cpClient.Disconnect()
 
 	// Start reconciler
+	// Kernel events reconcile the affected overlay right away; the periodic
+	// cycle only catches what the netlink subscriptions might miss. Address
+	// changes are forwarded to the connected exports and the next-hops.
+	connectedChange := notifyConnectedChange(live.Load, connectedChanged)
 	rec := reconciler.New(cfg,
 		reconciler.WithInterval(10*time.Second),
+		reconciler.WithEventDebounce(500*time.Millisecond),
+		reconciler.WithRouteRestore(func(overlay config.OverlayDef) {
+			restoreOverlayRoutes(live.Load(), overlay, routeMgr, routingMgr, routeTable, logger)
+		}),
+		reconciler.WithOwnRouteDeletions(routeMgr.OwnDeletion),
+		reconciler.WithExportedPrefixes(routingMgr.ExportedPrefixes),
+		reconciler.WithImportedPrefixes(routeMgr.InstalledPrefixes),
+		reconciler.WithAddressChanges(func(link string) {
+			connectedChange(link)
+			notifyAddressChange(nextHopsChanged)
+		}),
 		reconciler.WithLogger(logger),
 		reconciler.WithMetrics(metrics),
 	)
@@ -197,6 +297,75 @@ func main() {
 		}
 	}()
 
+	// Hot reload: SIGHUP or a change to the config file re-reads it and
+	// applies only what changed.
+	rl := &reloader{
+		path:         configPath,
+		loader:       loader,
+		live:         &live,
+		server:       cpServer,
+		client:       cpClient,
+		rec:          rec,
+		obs:          obsServer,
+		routingMgr:   routingMgr,
+		routeTable:   routeTable,
+		routeMgr:     routeMgr,
+		nextHops:     nextHops,
+		exportRoutes: exportRoutes,
+		metrics:      metrics,
+		logger:       logger,
+	}
+	reloadCh := make(chan struct{}, 1)
+	requestReload := func() {
+		select {
+		case reloadCh <- struct{}{}:
+		default:
+		}
+	}
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				logger.Info("received SIGHUP, reloading configuration")
+				requestReload()
+			case <-reloadCh:
+				if err := rl.Reload(ctx); err != nil {
+					logger.Error("configuration reload failed", "error", err)
+				}
+			}
+		}
+	}()
+	go watchConfigFile(ctx, configPath, configWatchInterval, func() {
+		logger.Info("configuration file changed, reloading", "config", configPath)
+		requestReload()
+	})
+
+	// Local admin API for the nnet CLI.
+	if cfg.Admin.Enabled {
+		admin := &adminServer{
+			ctx:          ctx,
+			live:         &live,
+			server:       cpServer,
+			client:       cpClient,
+			rec:          rec,
+			reloader:     rl,
+			routingMgr:   routingMgr,
+			routeTable:   routeTable,
+			routeMgr:     routeMgr,
+			nextHops:     nextHops,
+			exportRoutes: exportRoutes,
+			logger:       logger,
+		}
+		if err := admin.Start(cfg.Admin.Socket); err != nil {
+			return fmt.Errorf("failed to start admin API: %w", err)
+		}
+		defer admin.Stop()
+	}
+
 	// Mark as ready
 	obsServer.SetReady(true)
 
@@ -219,9 +388,16 @@ func main() {
 	cpServer.Stop()
 	cpClient.Disconnect()
 
+	// Graceful restart keeps the installed routes so traffic keeps flowing
+	// while the daemon restarts; the next run reconciles them.
+	if live.Load().GracefulRestart.Enabled {
+		slog.Info("graceful restart enabled, keeping installed routes", "routes", len(routeTable.All()))
+		return nil
+	}
+
 	// Cleanup: flush all routes installed by n-netman across every table used by
 	// the overlays (multi-overlay configs install into per-overlay tables).
-	for _, table := range distinctImportTables(cfg) {
+	for _, table := range distinctImportTables(live.Load()) {
 		slog.Info("flushing installed routes", "table", table, "protocol", nlmgr.RouteProtocolNNetMan)
 		if err := routeMgr.FlushByProtocol(table, nlmgr.RouteProtocolNNetMan); err != nil {
 			slog.Warn("failed to flush routes on shutdown", "table", table, "error", err)
@@ -230,192 +406,277 @@ func main() {
 		}
 	}
 
+	// Cleanup: remove the host routes pinning peers to their via_interface,
+	// so the underlay goes back to the host's own routing.
+	if err := routeMgr.FlushByProtocol(syscall.RT_TABLE_MAIN, nlmgr.RouteProtocolUnderlay); err != nil {
+		slog.Warn("failed to flush underlay host routes on shutdown", "error", err)
+	}
+
 	// Cleanup: delete VXLAN interface (optional, can be configured)
 	// Note: This is commented out by default as the VXLAN might be shared
 	// vxlanMgr := nlmgr.NewVXLANManager()
 	// vxlanMgr.Delete(cfg.Overlay.VXLAN.Name)
+	return nil
+}
+
+// restoreOverlayRoutes reinstalls the learned routes that go to the
+// overlay's import table after they were removed from the kernel externally.
+func restoreOverlayRoutes(cfg *config.Config, overlay config.OverlayDef, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, logger *slog.Logger) {
+	table := cfg.ImportTable(overlay.VNI)
+	var routes []controlplane.Route
+	for _, r := range routeTable.All() {
+		if r.PeerID == "" {
+			continue
+		}
+		if t, allowed := importTarget(cfg, routingMgr, r); allowed && t == table {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == 0 {
+		return
+	}
+	logger.Info("restoring routes removed from the kernel", "overlay", overlay.Name, "table", table, "routes", len(routes))
+	installReceivedRoutes(cfg, routeMgr, routingMgr, routeTable, routes, logger)
 }
 
 // installReceivedRoutes installs routes received from peers into the kernel.
 // Each route is filtered by its overlay's import policy and installed in the
-// table of its corresponding overlay (by VNI).
-func installReceivedRoutes(cfg *config.Config, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routes []controlplane.Route, logger *slog.Logger) {
-	// Build a VNI -> overlay mapping so we can apply the right import policy/table.
-	vniToOverlay := make(map[uint32]config.OverlayDef)
-	for _, overlay := range cfg.GetOverlays() {
-		vniToOverlay[uint32(overlay.VNI)] = overlay
+// table of its corresponding overlay (by VNI). The kernel holds a single
+// route per prefix and table, so each (VNI, prefix) of routes is installed
+// with its best paths among every peer that announced it.
+func installReceivedRoutes(cfg *config.Config, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, routes []controlplane.Route, logger *slog.Logger) {
+	kernel := make(map[int][]nlmgr.RouteInfo)
+	for _, key := range distinctPrefixes(routes) {
+		installBestPaths(cfg, routeMgr, routingMgr, routeTable, kernel, key, logger)
 	}
+}
+
+// vniPrefix identifies the kernel route of the routes learned for a prefix.
+type vniPrefix struct {
+	vni    uint32
+	prefix string
+}
 
+// distinctPrefixes returns the (VNI, prefix) pairs of routes, in order.
+func distinctPrefixes(routes []controlplane.Route) []vniPrefix {
+	seen := make(map[vniPrefix]bool, len(routes))
+	var out []vniPrefix
 	for _, r := range routes {
-		// Parse the prefix
-		_, ipnet, err := net.ParseCIDR(r.Prefix)
-		if err != nil {
-			logger.Warn("invalid prefix from peer",
-				"prefix", r.Prefix,
-				"peer", r.PeerID,
-				"error", err,
-			)
-			continue
+		key := vniPrefix{r.VNI, r.Prefix}
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, key)
 		}
+	}
+	return out
+}
 
-		// Parse next-hop
-		gw := net.ParseIP(r.NextHop)
-		if gw == nil {
-			logger.Warn("invalid next-hop from peer",
-				"next_hop", r.NextHop,
-				"peer", r.PeerID,
-			)
-			continue
+// multipathForVNI reports whether the best paths of the VNI's prefixes are
+// installed as ECMP routes: enabled by the overlay or the global install
+// config.
+func multipathForVNI(cfg *config.Config, vni uint32) bool {
+	for _, o := range cfg.GetOverlays() {
+		if uint32(o.VNI) == vni && o.Routing.Import.Install.Multipath {
+			return true
+		}
+	}
+	return cfg.Routing.Import.Install.Multipath
+}
+
+// bestPaths returns the best paths of a (VNI, prefix) among the routes
+// learned for it with a valid next-hop, and the number of such routes.
+func bestPaths(cfg *config.Config, routeTable *controlplane.RouteTable, key vniPrefix) ([]controlplane.Route, int) {
+	var candidates []controlplane.Route
+	for _, r := range routeTable.Candidates(key.vni, key.prefix) {
+		if net.ParseIP(r.NextHop) != nil {
+			candidates = append(candidates, r)
+		}
+	}
+	return controlplane.BestPaths(candidates, multipathForVNI(cfg, key.vni)), len(candidates)
+}
+
+// installBestPaths programs the kernel route of a (VNI, prefix) with the
+// best paths learned for it (see controlplane.BestPaths): the best next-hop,
+// or every equally good one as an ECMP route with multipath. Routes of the
+// prefix left in the table with another metric are removed; kernel caches
+// the n-netman routes of each table across calls. It returns false when
+// nothing was installed.
+func installBestPaths(cfg *config.Config, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, kernel map[int][]nlmgr.RouteInfo, key vniPrefix, logger *slog.Logger) bool {
+	_, ipnet, err := net.ParseCIDR(key.prefix)
+	if err != nil {
+		logger.Warn("invalid prefix from peer", "prefix", key.prefix, "vni", key.vni, "error", err)
+		return false
+	}
+
+	best, candidates := bestPaths(cfg, routeTable, key)
+	if len(best) == 0 {
+		return false
+	}
+
+	table, allowed := importTarget(cfg, routingMgr, best[0])
+	if !allowed {
+		logger.Info("route rejected by import policy",
+			"prefix", key.prefix,
+			"peer", best[0].PeerID,
+			"vni", key.vni,
+		)
+		return false
+	}
+
+	routeCfg := nlmgr.RouteConfig{
+		Destination: ipnet,
+		Table:       table,
+		Metric:      int(best[0].Metric),
+		Protocol:    nlmgr.RouteProtocolNNetMan,
+	}
+	nextHops := make([]string, 0, len(best))
+	peers := make([]string, 0, len(best))
+	for _, r := range best {
+		nextHops = append(nextHops, r.NextHop)
+		peers = append(peers, r.PeerID)
+	}
+	if len(best) == 1 {
+		routeCfg.Gateway = net.ParseIP(best[0].NextHop)
+	} else {
+		for _, nh := range nextHops {
+			routeCfg.NextHops = append(routeCfg.NextHops, net.ParseIP(nh))
 		}
+	}
 
-		// Apply the import policy and resolve the destination table for this VNI.
-		overlay, ok := vniToOverlay[r.VNI]
-		var (
-			allowed bool
-			table   int
+	if err := routeMgr.Replace(routeCfg); err != nil {
+		logger.Warn("failed to install route",
+			"prefix", key.prefix,
+			"next_hop", strings.Join(nextHops, ","),
+			"table", table,
+			"error", err,
 		)
-		if ok {
-			allowed = routingMgr.ShouldImportForOverlay(r, overlay)
-			table = overlay.Routing.Import.Install.Table
-		} else {
-			// Unknown VNI: fall back to the global import policy/table.
-			allowed = routingMgr.ShouldImport(r)
-			table = cfg.Routing.Import.Install.Table
-			logger.Debug("route VNI not found in overlays, using global import policy/table",
-				"vni", r.VNI,
-			)
-		}
-		if table == 0 {
-			table = 100 // default
-		}
-
-		if !allowed {
-			logger.Info("route rejected by import policy",
-				"prefix", r.Prefix,
-				"peer", r.PeerID,
-				"vni", r.VNI,
-			)
+		return false
+	}
+
+	logger.Info("installed route from peer",
+		"prefix", key.prefix,
+		"next_hop", strings.Join(nextHops, ","),
+		"peer", strings.Join(peers, ","),
+		"metric", best[0].Metric,
+		"table", table,
+		"vni", key.vni,
+		"candidates", candidates,
+	)
+
+	// Replace only overwrites the route with the same metric: a previous
+	// best path with another metric would stay behind it.
+	if _, ok := kernel[table]; !ok {
+		routes, err := routeMgr.ListByProtocol(table, nlmgr.RouteProtocolNNetMan)
+		if err != nil {
+			logger.Debug("failed to list routes", "table", table, "error", err)
+		}
+		kernel[table] = routes
+	}
+	for _, k := range kernel[table] {
+		if k.Destination == nil || k.Destination.String() != ipnet.String() || k.Metric == routeCfg.Metric {
 			continue
 		}
-
-		// Install the route
-		routeCfg := nlmgr.RouteConfig{
+		if err := routeMgr.Delete(nlmgr.RouteConfig{
 			Destination: ipnet,
-			Gateway:     gw,
 			Table:       table,
-			Metric:      int(r.Metric),
+			Metric:      k.Metric,
 			Protocol:    nlmgr.RouteProtocolNNetMan,
+		}); err != nil {
+			logger.Debug("failed to remove superseded route", "prefix", key.prefix, "metric", k.Metric, "table", table, "error", err)
 		}
+	}
+	return true
+}
 
-		if err := routeMgr.Replace(routeCfg); err != nil {
-			logger.Warn("failed to install route",
-				"prefix", r.Prefix,
-				"next_hop", r.NextHop,
-				"table", table,
-				"error", err,
-			)
-			continue
+// importTarget applies the import policy of the route's overlay (by VNI) and
+// resolves the table it is installed in. Routes for a VNI without an overlay
+// fall back to the global import policy and table.
+func importTarget(cfg *config.Config, routingMgr *routing.Manager, r controlplane.Route) (int, bool) {
+	for _, overlay := range cfg.GetOverlays() {
+		if uint32(overlay.VNI) == r.VNI {
+			return cfg.ImportTable(int(r.VNI)), routingMgr.ShouldImportForOverlay(r, overlay)
 		}
-
-		logger.Info("installed route from peer",
-			"prefix", r.Prefix,
-			"next_hop", r.NextHop,
-			"peer", r.PeerID,
-			"metric", r.Metric,
-			"table", table,
-			"vni", r.VNI,
-		)
 	}
+	slog.Debug("route VNI not found in overlays, using global import policy/table", "vni", r.VNI)
+	return cfg.ImportTable(int(r.VNI)), routingMgr.ShouldImport(r)
 }
 
-// getLocalExportableRoutes returns routes that should be exported to peers.
-// It derives the next-hop from the underlay or overlay bridge IP.
-func getLocalExportableRoutes(cfg *config.Config, routeTable *controlplane.RouteTable) []controlplane.Route {
+// getLocalExportableRoutes returns routes that should be exported to peers:
+// the networks of each overlay's export policy plus, with include_connected,
+// the connected subnets of its interfaces. Each route's next-hop is the
+// overlay bridge IP or underlay IP of its address family (nextHops, see
+// localNextHops).
+func getLocalExportableRoutes(cfg *config.Config, routingMgr *routing.Manager, nextHops controlplane.OverlayNextHops) []controlplane.Route {
 	routes := make([]controlplane.Route, 0)
 
-	// Detect local IP by finding an interface that can reach our peers.
-	localIP := detectLocalIP(cfg)
-	if localIP == "" {
-		return routes // Can't export routes without a valid next-hop
-	}
-
 	// Add routes from all overlays
 	for _, overlay := range cfg.GetOverlays() {
 		leaseSecs := uint32(overlay.Routing.Import.Install.RouteLeaseSeconds)
 		if leaseSecs == 0 {
 			leaseSecs = 30
 		}
-		metric := uint32(overlay.Routing.Export.Metric)
-		if metric == 0 {
-			metric = 100
-		}
 
-		// Determine next-hop: prefer bridge IP (overlay) over underlay IP.
-		nextHop := localIP
-		if overlay.Bridge.IPv4 != "" {
-			// Extract IP from CIDR (e.g., "10.100.0.1/24" -> "10.100.0.1")
-			nextHop = extractIPFromCIDR(overlay.Bridge.IPv4)
-		}
-
-		for _, prefix := range overlay.Routing.Export.Networks {
-			routes = append(routes, controlplane.Route{
-				Prefix:       prefix,
-				NextHop:      nextHop,
-				Metric:       metric,
-				LeaseSeconds: leaseSecs,
-				VNI:          uint32(overlay.VNI),
-			})
+		for _, r := range routingMgr.GetExportRoutesForOverlay(overlay) {
+			r.NextHop = nextHops.For(uint32(overlay.VNI), r.Prefix)
+			if r.NextHop == "" {
+				continue // Can't export a route without a next-hop of its family
+			}
+			r.LeaseSeconds = leaseSecs
+			routes = append(routes, r)
 		}
 	}
 
 	return routes
 }
 
-// detectLocalIP finds a local IP address to use for route announcements.
-// It uses a heuristic: pick the first interface with a subnet that contains the first peer.
-func detectLocalIP(cfg *config.Config) string {
-	peers := cfg.GetPeers()
-	if len(peers) == 0 {
-		return ""
+// getExportableRoutes returns every route advertised to peers: the local
+// exports plus the learned routes re-advertised under the active topology
+// (a hub re-advertising spoke routes, or a transit node re-exporting learned
+// routes, with itself as next-hop). Peers keep one route per (VNI, prefix)
+// from this node, so a prefix exported locally is not re-advertised.
+func getExportableRoutes(cfg *config.Config, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, nextHops controlplane.OverlayNextHops) []controlplane.Route {
+	routes := getLocalExportableRoutes(cfg, routingMgr, nextHops)
+	if !cfg.IsHub() && !cfg.Topology.TransitEnabled() {
+		return routes
 	}
-
-	// Get first peer's IP to determine our subnet
-	peerIP := net.ParseIP(peers[0].Endpoint.Address)
-	if peerIP == nil {
-		return ""
+	local := make(map[vniPrefix]bool, len(routes))
+	for _, r := range routes {
+		local[vniPrefix{r.VNI, r.Prefix}] = true
 	}
+	for _, r := range routingMgr.GetReadvertisedRoutes(routeTable.All(), nextHops) {
+		if !local[vniPrefix{r.VNI, r.Prefix}] {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
 
-	// Get all network interfaces
-	ifaces, err := net.Interfaces()
+// detectLocalIPs finds the local IP addresses to use for route announcements,
+// at most one per address family: the address of the underlay, inferred from
+// netplan when enabled, otherwise from the first interface whose subnet
+// contains the first peer, and the first global address of the other family
+// on the same interface. np is the netplan network of cfg (see
+// routing.Manager.Netplan).
+func detectLocalIPs(cfg *config.Config, np *netplan.Network) []string {
+	host := nlmgr.NewLinkManager()
+	u, err := netplan.InferUnderlay(cfg, np, host)
 	if err != nil {
-		return ""
+		return nil
 	}
-
-	for _, iface := range ifaces {
-		// Skip loopback and down interfaces
-		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+	ips := []string{u.Address.String()}
+	addrs, err := host.InterfaceAddresses(u.Interface)
+	if err != nil {
+		return ips
+	}
+	for _, ipnet := range addrs {
+		if ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
 			continue
 		}
-
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
-		}
-
-		for _, addr := range addrs {
-			ipnet, ok := addr.(*net.IPNet)
-			if !ok {
-				continue
-			}
-
-			// Check if peer IP is in same subnet
-			if ipnet.Contains(peerIP) {
-				return ipnet.IP.String()
-			}
+		if (ipnet.IP.To4() == nil) != (u.Address.To4() == nil) {
+			return append(ips, ipnet.IP.String())
 		}
 	}
-
-	return ""
+	return ips
 }
 
 // extractIPFromCIDR extracts the IP address from a CIDR string.
@@ -432,23 +693,6 @@ func extractIPFromCIDR(cidr string) string {
 	return ip.String()
 }
 
-// tableForVNI resolves the kernel routing table for a route's VNI, falling back
-// to the global/default table (or 100) when the VNI has no dedicated overlay.
-func tableForVNI(cfg *config.Config, vni uint32) int {
-	for _, o := range cfg.GetOverlays() {
-		if uint32(o.VNI) == vni {
-			if t := o.Routing.Import.Install.Table; t != 0 {
-				return t
-			}
-			return 100
-		}
-	}
-	if t := cfg.Routing.Import.Install.Table; t != 0 {
-		return t
-	}
-	return 100
-}
-
 // distinctImportTables returns every routing table n-netman may have installed
 // routes into (per-overlay tables plus the global/default table).
 func distinctImportTables(cfg *config.Config) []int {
@@ -456,7 +700,7 @@ func distinctImportTables(cfg *config.Config) []int {
 	var tables []int
 	add := func(t int) {
 		if t == 0 {
-			t = 100
+			t = config.DefaultImportTable
 		}
 		if _, ok := seen[t]; !ok {
 			seen[t] = struct{}{}
@@ -470,30 +714,50 @@ func distinctImportTables(cfg *config.Config) []int {
 	return tables
 }
 
-// deleteRoutesFromKernel removes the given routes from their per-VNI tables,
-// scoped to the n-netman protocol AND to the exact next-hop the peer installed.
-// Scoping by next-hop matters because the in-memory table keeps one entry per
-// (VNI, prefix, peer) while the kernel keeps a single route per (dst, table):
-// without it, peer A withdrawing a prefix could delete peer B's active route
-// for the same prefix/table.
+// removeLearnedRoutes updates the kernel once routes were removed from the
+// route table: a prefix still learned from other peers is reinstalled with
+// its remaining best paths, the kernel route of any other prefix is deleted.
+func removeLearnedRoutes(cfg *config.Config, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, routeTable *controlplane.RouteTable, removed []controlplane.Route, logger *slog.Logger, reason string) {
+	kernel := make(map[int][]nlmgr.RouteInfo)
+	reinstalled := make(map[vniPrefix]bool)
+	for _, key := range distinctPrefixes(removed) {
+		reinstalled[key] = installBestPaths(cfg, routeMgr, routingMgr, routeTable, kernel, key, logger)
+	}
+
+	var gone []controlplane.Route
+	for _, r := range removed {
+		if !reinstalled[vniPrefix{r.VNI, r.Prefix}] {
+			gone = append(gone, r)
+		}
+	}
+	deleteRoutesFromKernel(cfg, routeMgr, gone, logger, reason)
+}
+
+// deleteRoutesFromKernel removes the kernel routes of the given routes from
+// their per-VNI tables, scoped to the n-netman protocol. The kernel keeps a
+// single route per (prefix, table), with the next-hops of every best path
+// (see installBestPaths), so the deletion is not scoped to a next-hop: a
+// prefix still learned from another peer must be reinstalled instead (see
+// removeLearnedRoutes).
 func deleteRoutesFromKernel(cfg *config.Config, routeMgr *nlmgr.RouteManager, routes []controlplane.Route, logger *slog.Logger, reason string) {
+	type key struct {
+		table  int
+		prefix string
+	}
+	seen := make(map[key]bool, len(routes))
 	for _, r := range routes {
 		_, ipnet, err := net.ParseCIDR(r.Prefix)
 		if err != nil {
 			continue
 		}
-		gw := net.ParseIP(r.NextHop)
-		if gw == nil {
-			// Without a valid next-hop we cannot scope the deletion safely; skip
-			// (such a route was never installed by installReceivedRoutes anyway).
-			logger.Debug("skipping route deletion without a valid next-hop",
-				"reason", reason, "prefix", r.Prefix, "peer", r.PeerID)
+		table := cfg.ImportTable(int(r.VNI))
+		k := key{table, ipnet.String()}
+		if seen[k] {
 			continue
 		}
-		table := tableForVNI(cfg, r.VNI)
+		seen[k] = true
 		if err := routeMgr.Delete(nlmgr.RouteConfig{
 			Destination: ipnet,
-			Gateway:     gw,
 			Table:       table,
 			Protocol:    nlmgr.RouteProtocolNNetMan,
 		}); err != nil {
@@ -506,6 +770,75 @@ func deleteRoutesFromKernel(cfg *config.Config, routeMgr *nlmgr.RouteManager, ro
 	}
 }
 
+// relayPeerRoutes re-points the routes learned from a down peer at a healthy
+// relay peer that reported it still reaches it, reinstalling them with the
+// relay's overlay next-hop for each VNI. The kernel route is replaced in
+// place (same prefix, table and metric). Returns false when no relay is
+// available, so the caller can fall back to flushing the routes.
+func relayPeerRoutes(cfg *config.Config, client *controlplane.Client, routeTable *controlplane.RouteTable, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, peerID string, logger *slog.Logger) bool {
+	routes := routeTable.GetByPeer(peerID)
+	if len(routes) == 0 {
+		return false
+	}
+	relayID, nextHops, ok := client.FindRelay(peerID)
+	if !ok {
+		return false
+	}
+
+	relayed := make([]controlplane.Route, 0, len(routes))
+	for _, r := range routes {
+		if !r.StaleUntil.IsZero() {
+			// Held for the peer's graceful restart, not relayed.
+			continue
+		}
+		nextHop := nextHops.For(r.VNI, r.Prefix)
+		if nextHop == "" {
+			// The relay is not attached to this overlay; the route ages out.
+			continue
+		}
+		if r.RelayedVia == "" {
+			logger.Info("relaying route of down peer",
+				"prefix", r.Prefix, "peer", peerID, "relay", relayID, "next_hop", nextHop, "vni", r.VNI)
+		}
+		r.NextHop = nextHop
+		r.RelayedVia = relayID
+		routeTable.Add(r)
+		relayed = append(relayed, r)
+	}
+	if len(relayed) == 0 {
+		return false
+	}
+
+	installReceivedRoutes(cfg, routeMgr, routingMgr, routeTable, relayed, logger)
+	return true
+}
+
+// overlayNextHops returns this node's next-hops for every overlay, keyed by
+// VNI (see buildOverlayNextHops).
+func overlayNextHops(cfg *config.Config, np *netplan.Network) controlplane.OverlayNextHops {
+	return buildOverlayNextHops(cfg.GetOverlays(), detectLocalIPs(cfg, np))
+}
+
+// buildOverlayNextHops returns the next-hops this node announces for each
+// overlay, one per address family: the bridge IPv4/IPv6 (overlay) when
+// configured, otherwise the underlay address of that family (localIPs).
+func buildOverlayNextHops(overlays []config.OverlayDef, localIPs []string) controlplane.OverlayNextHops {
+	out := make(controlplane.OverlayNextHops)
+	for _, o := range overlays {
+		vni := uint32(o.VNI)
+		for _, ip := range localIPs {
+			out.Add(vni, ip)
+		}
+		// Extract IP from CIDR (e.g., "10.100.0.1/24" -> "10.100.0.1")
+		for _, cidr := range []string{o.Bridge.IPv4, o.Bridge.IPv6} {
+			if cidr != "" {
+				out.Add(vni, extractIPFromCIDR(cidr))
+			}
+		}
+	}
+	return out
+}
+
 // updatePeerRouteMetrics refreshes the peer/route gauges from live client state.
 func updatePeerRouteMetrics(client *controlplane.Client, m *observability.Metrics) {
 	if m == nil {
@@ -529,9 +862,9 @@ func updatePeerRouteMetrics(client *controlplane.Client, m *observability.Metric
 }
 
 // runRouteRefreshLoop periodically refreshes routes with peers.
-func runRouteRefreshLoop(ctx context.Context, client *controlplane.Client, cfg *config.Config, routeTable *controlplane.RouteTable, routeMgr *nlmgr.RouteManager, metrics *observability.Metrics, logger *slog.Logger) {
+func runRouteRefreshLoop(ctx context.Context, client *controlplane.Client, currentConfig func() *config.Config, routeTable *controlplane.RouteTable, routeMgr *nlmgr.RouteManager, routingMgr *routing.Manager, exportRoutes func() []controlplane.Route, restartTime func(peerID string) time.Duration, peerDown <-chan string, metrics *observability.Metrics, logger *slog.Logger) {
 	// Refresh interval is half the lease time
-	leaseSecs := cfg.Routing.Import.Install.RouteLeaseSeconds
+	leaseSecs := currentConfig().Routing.Import.Install.RouteLeaseSeconds
 	if leaseSecs <= 0 {
 		leaseSecs = 30
 	}
@@ -546,39 +879,58 @@ func runRouteRefreshLoop(ctx context.Context, client *controlplane.Client, cfg *
 	healthTicker := time.NewTicker(30 * time.Second)
 	defer healthTicker.Stop()
 
-	flushOnPeerDown := cfg.Routing.Import.Install.FlushOnPeerDown
-
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case peerID := <-peerDown:
+			cfg := currentConfig()
+			// A peer that advertised graceful restart keeps its routes until
+			// its restart timer runs out or it re-announces them.
+			if rt := restartTime(peerID); rt > 0 {
+				n := routeTable.MarkStale(peerID, time.Now().Add(rt))
+				logger.Info("peer down with graceful restart, keeping its routes",
+					"peer_id", peerID, "routes", n, "restart_time", rt)
+				updatePeerRouteMetrics(client, metrics)
+				continue
+			}
+			// Relay the down peer's routes through a healthy peer that still
+			// reaches it, or flush them.
+			if cfg.Topology.RelayFallback && relayPeerRoutes(cfg, client, routeTable, routeMgr, routingMgr, peerID, logger) {
+				updatePeerRouteMetrics(client, metrics)
+				continue
+			}
+			if cfg.Routing.Import.Install.FlushOnPeerDown {
+				removed := routeTable.RemoveByPeer(peerID)
+				removeLearnedRoutes(cfg, routeMgr, routingMgr, routeTable, removed, logger, "down peer")
+				logger.Info("cleaned up routes for down peer",
+					"peer_id", peerID,
+					"routes_removed", len(removed),
+				)
+			}
+			updatePeerRouteMetrics(client, metrics)
+
 		case <-healthTicker.C:
+			cfg := currentConfig()
+
 			// Pick up any peers that were not connected yet (best-effort).
 			if err := client.ConnectToPeers(); err != nil {
 				logger.Debug("reconnect attempt had errors", "error", err)
 			}
 
-			// Check peer health and get newly unhealthy peers
-			downPeers, err := client.CheckPeerHealth(ctx)
-			if err != nil {
-				logger.Warn("peer health check failed", "error", err)
-			}
-
-			// Remove routes from peers that just went down
-			if len(downPeers) > 0 && flushOnPeerDown {
-				for _, peerID := range downPeers {
-					removed := routeTable.RemoveByPeer(peerID)
-					deleteRoutesFromKernel(cfg, routeMgr, removed, logger, "down peer")
-					logger.Info("cleaned up routes for down peer",
-						"peer_id", peerID,
-						"routes_removed", len(removed),
-					)
+			// Keep relaying the routes of peers that are still down. This runs
+			// every cycle so relayed routes keep their lease (and may switch to
+			// another relay); they are replaced by the direct routes as soon as
+			// the peer announces again.
+			if cfg.Topology.RelayFallback {
+				for _, peerID := range client.UnhealthyPeers() {
+					relayPeerRoutes(cfg, client, routeTable, routeMgr, routingMgr, peerID, logger)
 				}
 			}
 
 			// Expire stale routes and remove them from the kernel.
 			expiredRoutes := routeTable.ExpireStale()
-			deleteRoutesFromKernel(cfg, routeMgr, expiredRoutes, logger, "expired lease")
+			removeLearnedRoutes(cfg, routeMgr, routingMgr, routeTable, expiredRoutes, logger, "expired lease")
 			if len(expiredRoutes) > 0 {
 				logger.Info("expired stale routes", "count", len(expiredRoutes))
 			}
@@ -587,10 +939,11 @@ func runRouteRefreshLoop(ctx context.Context, client *controlplane.Client, cfg *
 			updatePeerRouteMetrics(client, metrics)
 
 		case <-ticker.C:
-			// Re-announce our routes to peers
-			localRoutes := getLocalExportableRoutes(cfg, routeTable)
-			if len(localRoutes) > 0 {
-				if err := client.AnnounceRoutes(ctx, localRoutes); err != nil {
+			// Re-announce our routes to the peers that do not watch them
+			// (peers predating WatchRoutes).
+			routes := exportRoutes()
+			if len(routes) > 0 {
+				if err := client.AnnounceRoutes(ctx, routes); err != nil {
 					logger.Warn("failed to re-announce routes", "error", err)
 				}
 			}
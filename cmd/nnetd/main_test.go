@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/routing"
 )
 
 func v2TwoOverlays() *config.Config {
@@ -19,37 +28,249 @@ func v2TwoOverlays() *config.Config {
 	}
 }
 
-func TestTableForVNI(t *testing.T) {
+func TestDistinctImportTables(t *testing.T) {
+	cfg := v2TwoOverlays()
+	tables := distinctImportTables(cfg)
+	sort.Ints(tables)
+	// Overlays use 100 and 200; the global default (0 -> 100) dedups with 100.
+	if len(tables) != 2 || tables[0] != 100 || tables[1] != 200 {
+		t.Fatalf("distinctImportTables = %v, want [100 200]", tables)
+	}
+}
+
+func TestWatchConfigFile_CallsOnContentChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "n-netman.yaml")
+	if err := os.WriteFile(path, []byte("version: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 4)
+	go watchConfigFile(ctx, path, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	// Rewriting the same content is not a change.
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("version: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatal("unexpected reload for unchanged content")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Replace the file by renaming, as editors and config management do.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("version: 2\nnode:\n  id: b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected a reload after the file content changed")
+	}
+}
+
+func TestDroppedExports_PerOverlay(t *testing.T) {
+	old := []controlplane.Route{
+		{VNI: 100, Prefix: "10.0.0.0/24"},
+		{VNI: 100, Prefix: "10.1.0.0/24"},
+		{VNI: 200, Prefix: "10.0.0.0/24"},
+		{VNI: 200, Prefix: "192.168.5.0/24"}, // connected subnet
+	}
+	next := []controlplane.Route{
+		{VNI: 100, Prefix: "10.1.0.0/24"},
+		{VNI: 200, Prefix: "10.0.0.0/24"},
+	}
+
+	// 10.0.0.0/24 is still exported in VNI 200: only VNI 100 withdraws it.
+	// The connected subnet no longer exported is withdrawn too.
+	got := droppedExports(old, next)
+	if len(got) != 2 || got[0].VNI != 100 || got[0].Prefix != "10.0.0.0/24" ||
+		got[1].VNI != 200 || got[1].Prefix != "192.168.5.0/24" {
+		t.Fatalf("droppedExports = %+v, want VNI 100 10.0.0.0/24 and VNI 200 192.168.5.0/24", got)
+	}
+}
+
+func TestUnbackedRoutes(t *testing.T) {
 	cfg := v2TwoOverlays()
+	cfg.Overlays[0].Routing.Import.AcceptAll = true
+	cfg.Overlays[1].Routing.Import.AcceptAll = true
+	routingMgr := routing.NewManager(cfg)
+
+	kernel := func(prefix, gw string, table int) nlmgr.RouteInfo {
+		_, ipnet, _ := net.ParseCIDR(prefix)
+		return nlmgr.RouteInfo{Destination: ipnet, Gateway: net.ParseIP(gw), Table: table, Protocol: nlmgr.RouteProtocolNNetMan}
+	}
+	stale := []nlmgr.RouteInfo{
+		kernel("10.1.0.0/24", "10.100.0.2", 100), // re-announced as is
+		kernel("10.2.0.0/24", "10.100.0.2", 100), // re-announced via another next-hop
+		kernel("10.3.0.0/24", "10.200.0.2", 200), // not re-announced
+	}
+	// Multipath routes, reprogrammed once their prefix is learned again.
+	for _, prefix := range []string{"10.4.0.0/24", "10.5.0.0/24"} {
+		r := kernel(prefix, "", 100)
+		r.NextHops = []net.IP{net.ParseIP("10.100.0.2"), net.ParseIP("10.100.0.3")}
+		stale = append(stale, r)
+	}
+	learned := []controlplane.Route{
+		{Prefix: "10.1.0.0/24", NextHop: "10.100.0.2", VNI: 100, PeerID: "a"},
+		{Prefix: "10.2.0.0/24", NextHop: "10.100.0.3", VNI: 100, PeerID: "b"},
+		{Prefix: "10.3.0.0/24", NextHop: "10.200.0.2", VNI: 100, PeerID: "a"}, // other table
+		{Prefix: "10.4.0.0/24", NextHop: "10.100.0.4", VNI: 100, PeerID: "c"},
+	}
 
-	if got := tableForVNI(cfg, 100); got != 100 {
-		t.Errorf("tableForVNI(100) = %d, want 100", got)
+	got := unbackedRoutes(cfg, routingMgr, learned, stale)
+	var prefixes []string
+	for _, r := range got {
+		prefixes = append(prefixes, r.Destination.String())
 	}
-	if got := tableForVNI(cfg, 200); got != 200 {
-		t.Errorf("tableForVNI(200) = %d, want 200", got)
+	sort.Strings(prefixes)
+	if want := []string{"10.2.0.0/24", "10.3.0.0/24", "10.5.0.0/24"}; !slices.Equal(prefixes, want) {
+		t.Fatalf("unbackedRoutes = %v, want %v", prefixes, want)
+	}
+}
+
+func TestDiffConnected(t *testing.T) {
+	old := map[vniPrefix]bool{{100, "172.16.10.0/24"}: true, {100, "172.16.20.0/24"}: true}
+	next := map[vniPrefix]bool{{100, "172.16.10.0/24"}: true, {200, "172.16.20.0/24"}: true}
+
+	added, removed := diffConnected(old, next)
+	if len(added) != 1 || added[0] != (vniPrefix{200, "172.16.20.0/24"}) {
+		t.Fatalf("added = %v, want VNI 200 172.16.20.0/24", added)
 	}
-	// Unknown VNI falls back to the global/default table (100).
-	if got := tableForVNI(cfg, 999); got != 100 {
-		t.Errorf("tableForVNI(999) = %d, want 100 (default)", got)
+	if len(removed) != 1 || removed[0] != (vniPrefix{100, "172.16.20.0/24"}) {
+		t.Fatalf("removed = %v, want VNI 100 172.16.20.0/24", removed)
 	}
 }
 
-func TestTableForVNI_OverlayWithZeroTableDefaultsTo100(t *testing.T) {
+func TestNotifyConnectedChange(t *testing.T) {
+	cfg := &config.Config{Version: 2, Overlays: []config.OverlayDef{{
+		VNI:     100,
+		Bridge:  config.BridgeConfig{Name: "br-100"},
+		Routing: config.OverlayRouting{Export: config.ExportConfig{IncludeConnected: true, ConnectedInterfaces: []string{"eth1"}}},
+	}}}
+	changed := make(chan struct{}, 1)
+	notify := notifyConnectedChange(func() *config.Config { return cfg }, changed)
+
+	notify("eth0")
+	if len(changed) != 0 {
+		t.Fatal("change on an interface that is not exported signalled")
+	}
+	// A second change while one is pending must not block.
+	notify("br-100")
+	notify("eth1")
+	if len(changed) != 1 {
+		t.Fatalf("pending signals = %d, want 1", len(changed))
+	}
+}
+
+func TestGetExportableRoutes_TransitSelectedPath(t *testing.T) {
 	cfg := &config.Config{
 		Version:  2,
-		Overlays: []config.OverlayDef{{VNI: 100, Name: "a", Bridge: config.BridgeConfig{Name: "br-a"}}},
+		Node:     config.NodeConfig{ID: "t"},
+		Topology: config.TopologyConfig{Transit: "allow"},
+		Overlays: []config.OverlayDef{{VNI: 100, Name: "a", Bridge: config.BridgeConfig{Name: "br-a", IPv4: "10.100.0.1/24"}}},
 	}
-	if got := tableForVNI(cfg, 100); got != 100 {
-		t.Errorf("tableForVNI(100) with table 0 = %d, want 100", got)
+	cfg.Overlays[0].Routing.Export.Networks = []string{"192.168.9.0/24"}
+	routingMgr := routing.NewManager(cfg)
+	nextHops := controlplane.OverlayNextHops{100: {"10.100.0.1"}}
+
+	// Two candidates for 192.168.1.0/24, and a learned copy of a prefix
+	// exported locally.
+	routeTable := controlplane.NewRouteTable()
+	routeTable.Add(controlplane.Route{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "b", NextHop: "10.100.0.12", Metric: 100, LeaseSeconds: 30})
+	routeTable.Add(controlplane.Route{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "a", NextHop: "10.100.0.11", Metric: 100, LeaseSeconds: 30})
+	routeTable.Add(controlplane.Route{Prefix: "192.168.9.0/24", VNI: 100, PeerID: "c", NextHop: "10.100.0.13", Metric: 10, LeaseSeconds: 30})
+
+	var first []controlplane.Route
+	for i := 0; i < 10; i++ {
+		got := getExportableRoutes(cfg, routingMgr, routeTable, nextHops)
+		if len(got) != 2 {
+			t.Fatalf("exported %+v, want one route per prefix", got)
+		}
+		for _, r := range got {
+			switch r.Prefix {
+			case "192.168.9.0/24":
+				if r.PeerID != "" {
+					t.Fatalf("exported %+v for a local prefix, want the local route", r)
+				}
+			case "192.168.1.0/24":
+				if r.PeerID != "a" || r.Metric != 101 || r.NextHop != "10.100.0.1" {
+					t.Fatalf("re-advertised %+v, want the path from a through this node", r)
+				}
+			}
+		}
+		if first == nil {
+			first = got
+		} else if !slices.EqualFunc(first, got, func(a, b controlplane.Route) bool {
+			return a.Prefix == b.Prefix && a.PeerID == b.PeerID && a.NextHop == b.NextHop && a.Metric == b.Metric
+		}) {
+			t.Fatalf("exported %+v, then %+v", first, got)
+		}
 	}
 }
 
-func TestDistinctImportTables(t *testing.T) {
+func TestLocalNextHops_Refresh(t *testing.T) {
+	// Without peers the underlay is not inferred: only the bridge IPs count.
 	cfg := v2TwoOverlays()
-	tables := distinctImportTables(cfg)
-	sort.Ints(tables)
-	// Overlays use 100 and 200; the global default (0 -> 100) dedups with 100.
-	if len(tables) != 2 || tables[0] != 100 || tables[1] != 200 {
-		t.Fatalf("distinctImportTables = %v, want [100 200]", tables)
+	cfg.Overlays[0].Bridge.IPv4 = "10.100.0.1/24"
+	routingMgr := routing.NewManager(cfg)
+
+	var n localNextHops
+	if !n.Refresh(cfg, routingMgr) {
+		t.Fatal("expected the first refresh to report a change")
+	}
+	if got := n.Load().For(100, "10.9.0.0/24"); got != "10.100.0.1" {
+		t.Fatalf("next-hop = %q, want 10.100.0.1", got)
+	}
+	if n.Refresh(cfg, routingMgr) {
+		t.Fatal("expected an unchanged refresh to report no change")
+	}
+
+	next := v2TwoOverlays()
+	next.Overlays[0].Bridge.IPv4 = "10.100.0.2/24"
+	if !n.Refresh(next, routingMgr) || n.Load().For(100, "10.9.0.0/24") != "10.100.0.2" {
+		t.Fatalf("next-hops = %v after a new bridge IP, want 10.100.0.2", n.Load())
+	}
+}
+
+func TestBuildOverlayNextHops(t *testing.T) {
+	overlays := []config.OverlayDef{
+		{VNI: 100, Bridge: config.BridgeConfig{IPv4: "10.100.0.1/24"}},
+		{VNI: 200, Bridge: config.BridgeConfig{IPv6: "fd00:200::1/64"}},
+		{VNI: 300, Bridge: config.BridgeConfig{IPv4: "10.30.0.1/24", IPv6: "fd00:300::1/64"}},
+		{VNI: 400},
+	}
+
+	tests := []struct {
+		name     string
+		localIPs []string
+		vni      uint32
+		prefix   string
+		want     string
+	}{
+		{"ipv4 bridge", nil, 100, "172.16.0.0/24", "10.100.0.1"},
+		{"ipv4 bridge, no ipv6 next-hop", nil, 100, "2001:db8::/64", ""},
+		{"ipv6 bridge", nil, 200, "2001:db8::/64", "fd00:200::1"},
+		{"ipv6-only overlay, no ipv4 next-hop", nil, 200, "172.16.0.0/24", ""},
+		{"dual-stack bridge, ipv4 prefix", nil, 300, "172.16.0.0/24", "10.30.0.1"},
+		{"dual-stack bridge, ipv6 prefix", nil, 300, "2001:db8::/64", "fd00:300::1"},
+		{"bridge preferred over underlay", []string{"192.168.1.1", "2001:db8:ff::1"}, 300, "2001:db8::/64", "fd00:300::1"},
+		{"underlay of the missing family", []string{"192.168.1.1", "2001:db8:ff::1"}, 100, "2001:db8::/64", "2001:db8:ff::1"},
+		{"ipv6 underlay only", []string{"2001:db8:ff::1"}, 400, "172.16.0.0/24", ""},
+		{"ipv6 underlay, ipv6 prefix", []string{"2001:db8:ff::1"}, 400, "2001:db8::/64", "2001:db8:ff::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildOverlayNextHops(overlays, tt.localIPs).For(tt.vni, tt.prefix)
+			if got != tt.want {
+				t.Fatalf("next-hop of %s in VNI %d = %q, want %q", tt.prefix, tt.vni, got, tt.want)
+			}
+		})
 	}
 }
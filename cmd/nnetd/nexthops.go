@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+// nextHopRecheckInterval is how often the local next-hops are recomputed
+// without an address change notification (or when the reconciler's netlink
+// subscriptions failed).
+const nextHopRecheckInterval = 30 * time.Second
+
+// localNextHops holds this node's next-hops for every overlay (see
+// overlayNextHops). Inferring the underlay dumps the interfaces and
+// addresses of the host, so they are computed when a config is loaded and
+// refreshed on address changes, instead of on every keepalive response,
+// state exchange or route stream recheck.
+type localNextHops struct {
+	hops atomic.Pointer[controlplane.OverlayNextHops]
+}
+
+// Load returns the current next-hops.
+func (n *localNextHops) Load() controlplane.OverlayNextHops {
+	if h := n.hops.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// Refresh recomputes the next-hops for cfg and reports whether they changed.
+func (n *localNextHops) Refresh(cfg *config.Config, routingMgr *routing.Manager) bool {
+	next := overlayNextHops(cfg, routingMgr.Netplan())
+	prev := n.hops.Swap(&next)
+	return prev == nil || !reflect.DeepEqual(*prev, next)
+}
+
+// follow refreshes the next-hops until ctx is cancelled, each time changed is
+// signalled (see notifyAddressChange) and on every recheck. The WatchRoutes
+// streams are notified when they changed, so the exports follow the new
+// next-hops.
+func (n *localNextHops) follow(ctx context.Context, changed <-chan struct{}, currentConfig func() *config.Config, routingMgr *routing.Manager, server *controlplane.Server, logger *slog.Logger) {
+	ticker := time.NewTicker(nextHopRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+		if n.Refresh(currentConfig(), routingMgr) {
+			logger.Info("local next-hops changed", "next_hops", n.Load())
+			server.NotifyExportsChanged()
+		}
+	}
+}
+
+// notifyAddressChange signals changed without blocking; a signal already
+// pending covers the change, as every refresh recomputes all the next-hops.
+func notifyAddressChange(changed chan<- struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/observability"
+	"github.com/nishisan-dev/n-netman/internal/reconciler"
+	"github.com/nishisan-dev/n-netman/internal/routing"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
+// reloader applies a new configuration to the running daemon. The config is
+// swapped atomically in every component and only the differences are acted
+// upon: peer sessions are opened or closed, the routes of removed peers and
+// overlays are withdrawn from the kernel, and the learned routes are
+// re-evaluated against the new import policies.
+type reloader struct {
+	path   string
+	loader *config.Loader
+	live   *atomic.Pointer[config.Config]
+
+	server     *controlplane.Server
+	client     *controlplane.Client
+	rec        *reconciler.Reconciler
+	obs        *observability.Server
+	routingMgr *routing.Manager
+	routeTable *controlplane.RouteTable
+	routeMgr   *nlmgr.RouteManager
+	nextHops   *localNextHops
+
+	exportRoutes func() []controlplane.Route
+	metrics      *observability.Metrics
+	logger       *slog.Logger
+
+	mu sync.Mutex // serializes reloads (SIGHUP and the file watcher)
+}
+
+// Reload re-reads and validates the config file and applies it. An invalid
+// file is rejected and the running config is kept. Settings only applied at
+// startup keep their running values.
+func (rl *reloader) Reload(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	next, err := rl.loader.LoadFile(rl.path)
+	if err != nil {
+		return fmt.Errorf("invalid configuration, keeping the running one: %w", err)
+	}
+	old := rl.live.Load()
+	if reflect.DeepEqual(old, next) {
+		rl.logger.Info("configuration unchanged, nothing to reload")
+		return nil
+	}
+	diff := config.ComputeDiff(old, next)
+	if len(diff.RestartRequired) > 0 {
+		// Applying them now would e.g. change the node ID under the peers'
+		// sessions: the running values are kept until the restart.
+		rl.logger.Warn("some changes only take effect after a restart", "settings", diff.RestartRequired)
+		config.KeepRestartSettings(old, next)
+		diff.RestartRequired = nil
+		if reflect.DeepEqual(old, next) {
+			return nil
+		}
+	}
+
+	// The server reloads the PSKs first, so a missing key aborts the reload
+	// before anything was swapped.
+	if err := rl.server.SetConfig(next); err != nil {
+		return fmt.Errorf("failed to apply configuration, keeping the running one: %w", err)
+	}
+	// Taken before the swap: the routing manager holds the netplan routes of
+	// the running config.
+	oldExports := getLocalExportableRoutes(old, rl.routingMgr, rl.nextHops.Load())
+	rl.live.Store(next)
+	rl.routingMgr.SetConfig(next)
+	rl.nextHops.Refresh(next, rl.routingMgr)
+	rl.rec.SetConfig(next)
+	rl.obs.SetConfig(next)
+	if rl.metrics != nil {
+		rl.metrics.PeersConfigured.Set(float64(len(next.GetPeers())))
+	}
+
+	rl.logger.Info("applying configuration changes",
+		"peers_added", diff.AddedPeers,
+		"peers_removed", diff.RemovedPeers,
+		"peers_changed", diff.ChangedPeers,
+		"overlays_added", diff.AddedOverlays,
+		"overlays_removed", diff.RemovedOverlays,
+		"overlays_changed", diff.ChangedOverlays,
+		"routing_changed", diff.RoutingChanged,
+	)
+
+	// Peers: close the sessions of removed peers (and of peers no longer
+	// active under the topology) and drop the routes learned from them.
+	removedPeers, err := rl.client.SetConfig(next)
+	if err != nil {
+		rl.logger.Warn("failed to connect to some peers after reload", "error", err)
+	}
+	for _, peerID := range removedPeers {
+		removed := rl.routeTable.RemoveByPeer(peerID)
+		removeLearnedRoutes(old, rl.routeMgr, rl.routingMgr, rl.routeTable, removed, rl.logger, "peer removed from config")
+	}
+	// Routes of changed peers are checked again against their VNI membership
+	// and allowed_prefixes.
+	for _, peerID := range diff.ChangedPeers {
+		unauthorized := rl.revokeUnauthorizedRoutes(next, peerID)
+		removeLearnedRoutes(old, rl.routeMgr, rl.routingMgr, rl.routeTable, unauthorized, rl.logger, "peer no longer authorized")
+	}
+
+	// Overlays: routes of removed overlays are withdrawn. Their bridge and
+	// VXLAN interfaces are left in place unless reconcile.prune is set, in
+	// which case the triggered reconciliation removes them.
+	for _, vni := range diff.RemovedOverlays {
+		removed := rl.routeTable.RemoveByVNI(uint32(vni))
+		deleteRoutesFromKernel(old, rl.routeMgr, removed, rl.logger, "overlay removed from config")
+		if !next.Reconcile.Prune {
+			rl.logger.Info("overlay removed from config; its interfaces are left in place", "vni", vni)
+		}
+	}
+	if len(diff.ChangedOverlays) > 0 || diff.RoutingChanged {
+		rl.reimportRoutes(old, next)
+	}
+
+	// Pick up new overlays now instead of on the next tick.
+	rl.rec.Trigger()
+
+	rl.withdrawDroppedExports(ctx, oldExports, getLocalExportableRoutes(next, rl.routingMgr, rl.nextHops.Load()))
+	rl.server.NotifyExportsChanged()
+	if err := rl.client.ExchangeStateWithPeers(ctx, rl.exportRoutes()); err != nil {
+		rl.logger.Warn("failed to exchange state with peers after reload", "error", err)
+	}
+
+	rl.logger.Info("configuration reloaded", "config", rl.path)
+	return nil
+}
+
+// revokeUnauthorizedRoutes removes the routes learned from a peer that it is
+// no longer authorized to announce under next, and returns them.
+func (rl *reloader) revokeUnauthorizedRoutes(next *config.Config, peerID string) []controlplane.Route {
+	var revoked []controlplane.Route
+	for _, r := range rl.routeTable.GetByPeer(peerID) {
+		err := controlplane.AuthorizeRoute(next, r)
+		if err == nil {
+			continue
+		}
+		if removed, ok := rl.routeTable.RemoveRoute(r.VNI, r.Prefix, r.PeerID); ok {
+			rl.logger.Info("removing route no longer authorized", "prefix", r.Prefix, "vni", r.VNI, "peer", peerID, "error", err)
+			rl.routeTable.Reject(removed, err.Error())
+			revoked = append(revoked, removed)
+		}
+	}
+	return revoked
+}
+
+// reimportRoutes re-evaluates every learned route against the new import
+// policies: routes that are no longer accepted, or whose table changed, are
+// removed from the kernel, and the accepted ones are (re)installed.
+func (rl *reloader) reimportRoutes(old, next *config.Config) {
+	oldPolicy := routing.NewManager(old)
+
+	var stale, accepted []controlplane.Route
+	for _, r := range rl.routeTable.All() {
+		if r.PeerID == "" {
+			continue
+		}
+		oldTable, wasAllowed := importTarget(old, oldPolicy, r)
+		newTable, allowed := importTarget(next, rl.routingMgr, r)
+		if wasAllowed && (!allowed || oldTable != newTable) {
+			stale = append(stale, r)
+		}
+		if allowed {
+			accepted = append(accepted, r)
+		}
+	}
+
+	deleteRoutesFromKernel(old, rl.routeMgr, stale, rl.logger, "import policy changed")
+	installReceivedRoutes(next, rl.routeMgr, rl.routingMgr, rl.routeTable, accepted, rl.logger)
+}
+
+// withdrawDroppedExports withdraws from the peers the routes this node no
+// longer exports, instead of letting them age out with their lease. A prefix
+// is only withdrawn from the overlays that dropped it.
+func (rl *reloader) withdrawDroppedExports(ctx context.Context, old, next []controlplane.Route) {
+	dropped := droppedExports(old, next)
+	if len(dropped) == 0 {
+		return
+	}
+	if err := rl.client.WithdrawRoutes(ctx, dropped, rl.exportRoutes()); err != nil {
+		rl.logger.Warn("failed to withdraw dropped exports", "routes", len(dropped), "error", err)
+		return
+	}
+	for _, r := range dropped {
+		rl.logger.Info("withdrew route no longer exported", "vni", r.VNI, "prefix", r.Prefix)
+	}
+}
+
+// droppedExports returns the (VNI, prefix) pairs of the local exports old
+// (networks, connected subnets and netplan static routes, see
+// getLocalExportableRoutes) missing from next.
+func droppedExports(old, next []controlplane.Route) []controlplane.Route {
+	exported := make(map[vniPrefix]bool, len(next))
+	for _, r := range next {
+		exported[vniPrefix{r.VNI, r.Prefix}] = true
+	}
+	var dropped []controlplane.Route
+	for _, r := range old {
+		k := vniPrefix{r.VNI, r.Prefix}
+		if !exported[k] {
+			exported[k] = true // withdraw once
+			dropped = append(dropped, controlplane.Route{VNI: r.VNI, Prefix: r.Prefix})
+		}
+	}
+	return dropped
+}
+
+// watchConfigFile polls the config file and calls onChange when its content
+// changes. Polling the content (rather than watching the inode) also catches
+// editors and config-management tools that replace the file by renaming.
+func watchConfigFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	sum := func() []byte {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		h := sha256.Sum256(data)
+		return h[:]
+	}
+
+	last := sum()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur := sum()
+			if cur == nil || bytes.Equal(cur, last) {
+				// A missing file (e.g. mid-rename) is retried on the next tick.
+				continue
+			}
+			last = cur
+			onChange()
+		}
+	}
+}
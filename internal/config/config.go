@@ -1,21 +1,28 @@
 // Package config defines the configuration structures for n-netman.
 package config
 
-import "time"
+import (
+	"net"
+	"slices"
+	"time"
+)
 
 // Config is the root configuration structure for n-netman.
 type Config struct {
-	Version       int            `yaml:"version" validate:"required,min=1,max=2"`
-	Node          NodeConfig     `yaml:"node" validate:"required"`
-	Netplan       NetplanConfig  `yaml:"netplan"`
-	KVM           KVMConfig      `yaml:"kvm"`
-	Overlay       OverlayConfig  `yaml:"overlay"`  // Legado (v1)
-	Overlays      []OverlayDef   `yaml:"overlays"` // Novo (v2)
-	Peers         []PeerConfig   `yaml:"peers"`    // Novo (v2): peers no nível raiz
-	Routing       RoutingConfig  `yaml:"routing"`  // Global fallback
-	Topology      TopologyConfig `yaml:"topology"`
-	Security      SecurityConfig `yaml:"security"`
-	Observability ObsConfig      `yaml:"observability"`
+	Version         int                   `yaml:"version" validate:"required,min=1,max=2"`
+	Node            NodeConfig            `yaml:"node" validate:"required"`
+	Netplan         NetplanConfig         `yaml:"netplan"`
+	KVM             KVMConfig             `yaml:"kvm"`
+	Overlay         OverlayConfig         `yaml:"overlay"`  // Legado (v1)
+	Overlays        []OverlayDef          `yaml:"overlays"` // Novo (v2)
+	Peers           []PeerConfig          `yaml:"peers"`    // Novo (v2): peers no nível raiz
+	Routing         RoutingConfig         `yaml:"routing"`  // Global fallback
+	GracefulRestart GracefulRestartConfig `yaml:"graceful_restart"`
+	Reconcile       ReconcileConfig       `yaml:"reconcile"`
+	Topology        TopologyConfig        `yaml:"topology"`
+	Security        SecurityConfig        `yaml:"security"`
+	Observability   ObsConfig             `yaml:"observability"`
+	Admin           AdminConfig           `yaml:"admin"`
 }
 
 // NodeConfig defines the identity of this host.
@@ -175,9 +182,31 @@ type PeerConfig struct {
 	Endpoint EndpointConfig `yaml:"endpoint" validate:"required"`
 	Auth     AuthConfig     `yaml:"auth"`
 	Health   HealthConfig   `yaml:"health"`
+	Limits   LimitsConfig   `yaml:"limits"`
 	// VNIs lists the overlay VNIs this peer participates in (v2). When empty,
-	// the peer participates in all overlays (backward compatible).
+	// the peer participates in all overlays (backward compatible). Routes the
+	// peer announces for other VNIs are rejected.
 	VNIs []int `yaml:"vnis"`
+	// AllowedPrefixes restricts the prefixes this peer may announce: each
+	// route must be equal to or contained in one of them. When empty, any
+	// prefix is accepted. A peer that re-exports routes (transit) must be
+	// allowed the prefixes it relays.
+	AllowedPrefixes []string `yaml:"allowed_prefixes"`
+}
+
+// HasVNI reports whether the peer participates in the given overlay VNI.
+func (p *PeerConfig) HasVNI(vni int) bool {
+	return len(p.VNIs) == 0 || slices.Contains(p.VNIs, vni)
+}
+
+// FindPeer returns the configured peer with the given ID.
+func (c *Config) FindPeer(id string) (PeerConfig, bool) {
+	for _, p := range c.GetPeers() {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return PeerConfig{}, false
 }
 
 // EndpointConfig defines the network endpoint of a peer.
@@ -198,6 +227,51 @@ type HealthConfig struct {
 	DeadAfterMs         int `yaml:"dead_after_ms"`
 }
 
+// LimitsConfig bounds what a peer may announce, to protect the route table
+// and the kernel from a misbehaving peer.
+type LimitsConfig struct {
+	// MaxPrefixes is the maximum number of routes held from the peer; 0
+	// means unlimited.
+	MaxPrefixes int `yaml:"max_prefixes"`
+	// MaxPrefixesAction is what happens when the peer exceeds max_prefixes:
+	// "warn" (default) only reports it, "teardown" removes every route of the
+	// peer and refuses its announcements for teardown_seconds.
+	MaxPrefixesAction string `yaml:"max_prefixes_action"`
+	TeardownSeconds   int    `yaml:"teardown_seconds"`
+	// AnnounceRate and AnnounceBurst size the token bucket limiting the
+	// AnnounceRoutes and ExchangeState calls accepted from the peer (calls
+	// per second, and bucket size).
+	AnnounceRate  float64 `yaml:"announce_rate"`
+	AnnounceBurst int     `yaml:"announce_burst"`
+}
+
+// TeardownsPeer reports whether exceeding max_prefixes tears the peer down.
+func (l *LimitsConfig) TeardownsPeer() bool {
+	return l.MaxPrefixesAction == "teardown"
+}
+
+// TeardownDuration returns how long a torn down peer's announcements are
+// refused.
+func (l *LimitsConfig) TeardownDuration() time.Duration {
+	if l.TeardownSeconds <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(l.TeardownSeconds) * time.Second
+}
+
+// Rate returns the sustained announcement rate (calls per second) and the
+// burst accepted from the peer.
+func (l *LimitsConfig) Rate() (float64, int) {
+	rate, burst := l.AnnounceRate, l.AnnounceBurst
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst <= 0 {
+		burst = 10
+	}
+	return rate, burst
+}
+
 // RoutingConfig defines route export/import settings.
 type RoutingConfig struct {
 	Enabled bool         `yaml:"enabled"`
@@ -212,6 +286,9 @@ type ExportConfig struct {
 	IncludeConnected     bool     `yaml:"include_connected"`
 	IncludeNetplanStatic bool     `yaml:"include_netplan_static"`
 	Metric               int      `yaml:"metric"`
+	// ConnectedInterfaces lists interfaces whose connected subnets are
+	// exported along with the overlay bridge's when include_connected is set.
+	ConnectedInterfaces []string `yaml:"connected_interfaces"`
 }
 
 // ImportConfig defines which routes this node accepts.
@@ -229,24 +306,104 @@ type InstallConfig struct {
 	FlushOnPeerDown   bool              `yaml:"flush_on_peer_down"`
 	RouteLeaseSeconds int               `yaml:"route_lease_seconds"`
 	LookupRules       LookupRulesConfig `yaml:"lookup_rules"`
+	// Multipath installs every best path of a prefix learned from several
+	// peers as an ECMP route instead of only the first one.
+	Multipath bool `yaml:"multipath"`
+}
+
+// DefaultImportTable is the routing table learned routes are installed in
+// when install.table is not set.
+const DefaultImportTable = 100
+
+// ImportTable returns the routing table the learned routes of a VNI are
+// installed in: the install.table of its overlay, or the global one when no
+// overlay has that VNI, DefaultImportTable when unset.
+func (c *Config) ImportTable(vni int) int {
+	for _, o := range c.GetOverlays() {
+		if o.VNI == vni {
+			if t := o.Routing.Import.Install.Table; t != 0 {
+				return t
+			}
+			return DefaultImportTable
+		}
+	}
+	if t := c.Routing.Import.Install.Table; t != 0 {
+		return t
+	}
+	return DefaultImportTable
 }
 
 // LookupRulesConfig defines policy-based routing rules (ip rule).
 // When enabled, creates rules like: ip rule add iif <bridge> lookup <table>
+// In prefix mode the rules match the overlay's prefixes instead: from <prefix>
+// for the exported ones and to <prefix> for the imported ones.
 type LookupRulesConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Mode    string `yaml:"mode" validate:"omitempty,oneof=interface prefix"` // "interface" (default) or "prefix"
+	// Priority is the priority of the iif (or from) rules; the oif (or to)
+	// rules use Priority+1. 0 allocates a free pair from 100 upward, in VNI
+	// order. The range is checked by the loader.
+	Priority int `yaml:"priority"`
+}
+
+// PrefixMode reports whether the rules match prefixes instead of the bridge.
+func (l LookupRulesConfig) PrefixMode() bool {
+	return l.Mode == "prefix"
+}
+
+// GracefulRestartConfig keeps the routes installed by n-netman across daemon
+// restarts instead of flushing them on shutdown.
+type GracefulRestartConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RestartTimeSeconds is advertised to peers: how long they keep this
+	// node's routes after losing its session.
+	RestartTimeSeconds int `yaml:"restart_time_seconds" validate:"omitempty,min=1,max=3600"`
+	// StaleWindowSeconds is how long the kernel routes left by the previous
+	// run wait, after startup, to be re-announced by peers before removal.
+	StaleWindowSeconds int `yaml:"stale_window_seconds" validate:"omitempty,min=1,max=3600"`
+}
+
+// ReconcileConfig tunes the reconciler.
+type ReconcileConfig struct {
+	// Prune tears down the interfaces, bridge addresses, policy rules and
+	// routes of overlays removed from the config. Only resources n-netman
+	// marked as its own are removed.
+	Prune bool `yaml:"prune"`
+}
+
+// RestartTime returns the advertised restart timer as a time.Duration.
+func (g *GracefulRestartConfig) RestartTime() time.Duration {
+	if g.RestartTimeSeconds <= 0 {
+		return 120 * time.Second
+	}
+	return time.Duration(g.RestartTimeSeconds) * time.Second
+}
+
+// StaleWindow returns the startup stale-route window as a time.Duration.
+func (g *GracefulRestartConfig) StaleWindow() time.Duration {
+	if g.StaleWindowSeconds <= 0 {
+		return 120 * time.Second
+	}
+	return time.Duration(g.StaleWindowSeconds) * time.Second
 }
 
 // TopologyConfig defines the network topology mode.
 type TopologyConfig struct {
-	Mode          string              `yaml:"mode" validate:"omitempty,oneof=direct-preferred full-mesh hub-spoke static"`
+	Mode string `yaml:"mode" validate:"omitempty,oneof=direct-preferred full-mesh hub-spoke static"`
+	// Hubs lists the node IDs acting as hubs when mode=hub-spoke. A node whose
+	// node.id is listed is a hub; every other node is a spoke.
+	Hubs          []string            `yaml:"hubs"`
 	RelayFallback bool                `yaml:"relay_fallback"`
 	Transit       string              `yaml:"transit" validate:"omitempty,oneof=deny allow"`
 	TransitPolicy TransitPolicyConfig `yaml:"transit_policy"`
 }
 
 // TransitPolicyConfig defines transit routing policies.
+//
+// The lists name the peers that take part in transit through this node: a
+// route learned from peer A is re-exported to peer B only if both A and B are
+// transit peers. A denied peer never is; when AllowedTransitPeers is non-empty
+// only the listed peers are.
 type TransitPolicyConfig struct {
 	AllowedTransitPeers []string `yaml:"allowed_transit_peers"`
 	DeniedTransitPeers  []string `yaml:"denied_transit_peers"`
@@ -303,6 +460,13 @@ type HealthcheckConfig struct {
 	Listen  ListenConfig `yaml:"listen"`
 }
 
+// AdminConfig defines the local admin API used by the nnet CLI.
+type AdminConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Socket is the path of the root-only Unix socket the API listens on.
+	Socket string `yaml:"socket"`
+}
+
 // Defaults returns a Config with sensible default values.
 func Defaults() *Config {
 	return &Config{
@@ -310,7 +474,7 @@ func Defaults() *Config {
 		// 'required' validation instead of being silently treated as v1.
 		Version: 0,
 		Netplan: NetplanConfig{
-			Enabled:     true,
+			Enabled:     false,
 			ConfigPaths: []string{"/etc/netplan"},
 			Underlay: UnderlayConfig{
 				PreferAddressFamilies: []string{"ipv4"},
@@ -379,6 +543,10 @@ func Defaults() *Config {
 				},
 			},
 		},
+		Admin: AdminConfig{
+			Enabled: true,
+			Socket:  "/run/n-netman/nnetd.sock",
+		},
 	}
 }
 
@@ -439,20 +607,112 @@ func (c *Config) GetPeers() []PeerConfig {
 	return c.Overlay.Peers
 }
 
+// IsHubSpoke reports whether the hub-spoke topology mode is enabled.
+func (t *TopologyConfig) IsHubSpoke() bool {
+	return t.Mode == "hub-spoke"
+}
+
+// IsHub reports whether the given node ID is declared as a hub.
+func (t *TopologyConfig) IsHub(nodeID string) bool {
+	for _, h := range t.Hubs {
+		if h == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitEnabled reports whether this node re-exports routes learned from
+// one peer to the other peers (transit: allow).
+func (t *TopologyConfig) TransitEnabled() bool {
+	return t.Transit == "allow"
+}
+
+// IsTransitPeer reports whether routes learned from the given peer may be
+// re-exported, and whether re-exported routes may be sent to it, according
+// to transit_policy. Denied peers take precedence over allowed peers.
+func (t *TopologyConfig) IsTransitPeer(peerID string) bool {
+	for _, p := range t.TransitPolicy.DeniedTransitPeers {
+		if p == peerID {
+			return false
+		}
+	}
+	if len(t.TransitPolicy.AllowedTransitPeers) == 0 {
+		return true
+	}
+	for _, p := range t.TransitPolicy.AllowedTransitPeers {
+		if p == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHub reports whether this node acts as a hub in hub-spoke mode.
+func (c *Config) IsHub() bool {
+	return c.Topology.IsHubSpoke() && c.Topology.IsHub(c.Node.ID)
+}
+
+// IsSpoke reports whether this node acts as a spoke in hub-spoke mode.
+func (c *Config) IsSpoke() bool {
+	return c.Topology.IsHubSpoke() && !c.Topology.IsHub(c.Node.ID)
+}
+
+// GetActivePeers returns the configured peers this node actually peers with
+// under the active topology. In hub-spoke mode a spoke only peers with hubs;
+// hubs (and every other mode) peer with all configured peers.
+func (c *Config) GetActivePeers() []PeerConfig {
+	if !c.IsSpoke() {
+		return c.GetPeers()
+	}
+	var out []PeerConfig
+	for _, p := range c.GetPeers() {
+		if c.Topology.IsHub(p.ID) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// GetActivePeersForVNI is GetPeersForVNI restricted to the active peers of
+// the topology (a spoke only floods BUM traffic to hub VTEPs).
+func (c *Config) GetActivePeersForVNI(vni int) []PeerConfig {
+	if !c.IsSpoke() {
+		return c.GetPeersForVNI(vni)
+	}
+	var out []PeerConfig
+	for _, p := range c.GetPeersForVNI(vni) {
+		if c.Topology.IsHub(p.ID) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// IPv6VTEP reports whether the VTEPs of an overlay are IPv6: its peer
+// endpoints, or its multicast group. validateSemantics rejects overlays
+// mixing both families.
+func (c *Config) IPv6VTEP(o OverlayDef) bool {
+	if o.BUM.GetMode() == "multicast" {
+		if ip := net.ParseIP(o.BUM.Group); ip != nil {
+			return ip.To4() == nil
+		}
+	}
+	for _, p := range c.GetPeersForVNI(o.VNI) {
+		if ip := net.ParseIP(p.Endpoint.Address); ip != nil {
+			return ip.To4() == nil
+		}
+	}
+	return false
+}
+
 // GetPeersForVNI returns the peers that participate in the given overlay VNI.
 // A peer with no explicit VNIs participates in every overlay (backward compatible).
 func (c *Config) GetPeersForVNI(vni int) []PeerConfig {
 	var out []PeerConfig
 	for _, p := range c.GetPeers() {
-		if len(p.VNIs) == 0 {
+		if p.HasVNI(vni) {
 			out = append(out, p)
-			continue
-		}
-		for _, v := range p.VNIs {
-			if v == vni {
-				out = append(out, p)
-				break
-			}
 		}
 	}
 	return out
@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestImportTable(t *testing.T) {
+	mk := func(vni, table int, name, bridge string) OverlayDef {
+		o := OverlayDef{VNI: vni, Name: name, Bridge: BridgeConfig{Name: bridge}}
+		o.Routing.Import.Install.Table = table
+		return o
+	}
+	cfg := &Config{
+		Version:  2,
+		Overlays: []OverlayDef{mk(100, 100, "a", "br-a"), mk(200, 200, "b", "br-b"), mk(300, 0, "c", "br-c")},
+	}
+
+	if got := cfg.ImportTable(100); got != 100 {
+		t.Errorf("ImportTable(100) = %d, want 100", got)
+	}
+	if got := cfg.ImportTable(200); got != 200 {
+		t.Errorf("ImportTable(200) = %d, want 200", got)
+	}
+	// An overlay without a table uses the default, not the global table.
+	cfg.Routing.Import.Install.Table = 150
+	if got := cfg.ImportTable(300); got != DefaultImportTable {
+		t.Errorf("ImportTable(300) with table 0 = %d, want %d", got, DefaultImportTable)
+	}
+	// Unknown VNIs fall back to the global table, then the default.
+	if got := cfg.ImportTable(999); got != 150 {
+		t.Errorf("ImportTable(999) = %d, want 150 (global)", got)
+	}
+	cfg.Routing.Import.Install.Table = 0
+	if got := cfg.ImportTable(999); got != DefaultImportTable {
+		t.Errorf("ImportTable(999) = %d, want %d (default)", got, DefaultImportTable)
+	}
+}
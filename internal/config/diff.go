@@ -0,0 +1,113 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Diff describes what changed between two configurations, so a reload only
+// touches what is different.
+type Diff struct {
+	AddedPeers   []string
+	RemovedPeers []string
+	ChangedPeers []string // endpoint, auth, health or VNI membership changed
+
+	AddedOverlays   []int // VNIs
+	RemovedOverlays []int
+	ChangedOverlays []int
+
+	// RoutingChanged is set when the global routing policies or the topology
+	// changed, so every learned route must be re-evaluated.
+	RoutingChanged bool
+
+	// RestartRequired lists the settings that changed but are only applied
+	// at startup (node identity, listeners, TLS, logging, admin socket).
+	RestartRequired []string
+}
+
+// Empty reports whether the two configurations are equivalent.
+func (d Diff) Empty() bool {
+	return len(d.AddedPeers) == 0 && len(d.RemovedPeers) == 0 && len(d.ChangedPeers) == 0 &&
+		len(d.AddedOverlays) == 0 && len(d.RemovedOverlays) == 0 && len(d.ChangedOverlays) == 0 &&
+		!d.RoutingChanged && len(d.RestartRequired) == 0
+}
+
+// ComputeDiff compares the effective peers and overlays of two configs
+// (v1 and v2 are both resolved through GetPeers/GetOverlays).
+func ComputeDiff(old, next *Config) Diff {
+	var d Diff
+
+	oldPeers := make(map[string]PeerConfig)
+	for _, p := range old.GetPeers() {
+		oldPeers[p.ID] = p
+	}
+	for _, p := range next.GetPeers() {
+		prev, ok := oldPeers[p.ID]
+		switch {
+		case !ok:
+			d.AddedPeers = append(d.AddedPeers, p.ID)
+		case !reflect.DeepEqual(prev, p):
+			d.ChangedPeers = append(d.ChangedPeers, p.ID)
+		}
+		delete(oldPeers, p.ID)
+	}
+	for id := range oldPeers {
+		d.RemovedPeers = append(d.RemovedPeers, id)
+	}
+
+	oldOverlays := make(map[int]OverlayDef)
+	for _, o := range old.GetOverlays() {
+		oldOverlays[o.VNI] = o
+	}
+	for _, o := range next.GetOverlays() {
+		prev, ok := oldOverlays[o.VNI]
+		switch {
+		case !ok:
+			d.AddedOverlays = append(d.AddedOverlays, o.VNI)
+		case !reflect.DeepEqual(prev, o):
+			d.ChangedOverlays = append(d.ChangedOverlays, o.VNI)
+		}
+		delete(oldOverlays, o.VNI)
+	}
+	for vni := range oldOverlays {
+		d.RemovedOverlays = append(d.RemovedOverlays, vni)
+	}
+
+	d.RoutingChanged = !reflect.DeepEqual(old.Routing, next.Routing) ||
+		!reflect.DeepEqual(old.Topology, next.Topology)
+
+	if old.Node.ID != next.Node.ID {
+		d.RestartRequired = append(d.RestartRequired, "node.id")
+	}
+	if old.Security.ControlPlane.Listen != next.Security.ControlPlane.Listen {
+		d.RestartRequired = append(d.RestartRequired, "security.control_plane.listen")
+	}
+	if old.Security.ControlPlane.TLS != next.Security.ControlPlane.TLS {
+		d.RestartRequired = append(d.RestartRequired, "security.control_plane.tls")
+	}
+	if old.Observability != next.Observability {
+		d.RestartRequired = append(d.RestartRequired, "observability")
+	}
+	if old.Admin != next.Admin {
+		d.RestartRequired = append(d.RestartRequired, "admin")
+	}
+
+	slices.Sort(d.AddedPeers)
+	slices.Sort(d.RemovedPeers)
+	slices.Sort(d.ChangedPeers)
+	slices.Sort(d.AddedOverlays)
+	slices.Sort(d.RemovedOverlays)
+	slices.Sort(d.ChangedOverlays)
+	return d
+}
+
+// KeepRestartSettings copies into next the settings of old that are only
+// applied at startup (see Diff.RestartRequired), so the running daemon keeps
+// using them until it is restarted.
+func KeepRestartSettings(old, next *Config) {
+	next.Node.ID = old.Node.ID
+	next.Security.ControlPlane.Listen = old.Security.ControlPlane.Listen
+	next.Security.ControlPlane.TLS = old.Security.ControlPlane.TLS
+	next.Observability = old.Observability
+	next.Admin = old.Admin
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestComputeDiff(t *testing.T) {
+	base := func() *Config {
+		cfg := Defaults()
+		cfg.Version = 2
+		cfg.Node.ID = "node-a"
+		cfg.Overlays = []OverlayDef{
+			{VNI: 100, Name: "a", Bridge: BridgeConfig{Name: "br-a"}},
+			{VNI: 200, Name: "b", Bridge: BridgeConfig{Name: "br-b"}},
+		}
+		cfg.Peers = []PeerConfig{
+			{ID: "peer-1", Endpoint: EndpointConfig{Address: "10.0.0.1"}},
+			{ID: "peer-2", Endpoint: EndpointConfig{Address: "10.0.0.2"}},
+		}
+		return cfg
+	}
+
+	if d := ComputeDiff(base(), base()); !d.Empty() {
+		t.Fatalf("expected identical configs to produce an empty diff, got %+v", d)
+	}
+
+	next := base()
+	next.Peers = []PeerConfig{
+		{ID: "peer-2", Endpoint: EndpointConfig{Address: "10.0.0.22"}},
+		{ID: "peer-3", Endpoint: EndpointConfig{Address: "10.0.0.3"}},
+	}
+	next.Overlays = []OverlayDef{
+		{VNI: 100, Name: "a", Bridge: BridgeConfig{Name: "br-a"}},
+		{VNI: 300, Name: "c", Bridge: BridgeConfig{Name: "br-c"}},
+	}
+	next.Overlays[0].Routing.Import.Deny = []string{"10.9.0.0/16"}
+	next.Observability.Metrics.Listen.Port = 9999
+
+	d := ComputeDiff(base(), next)
+	check := func(name string, got, want []string) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	check("AddedPeers", d.AddedPeers, []string{"peer-3"})
+	check("RemovedPeers", d.RemovedPeers, []string{"peer-1"})
+	check("ChangedPeers", d.ChangedPeers, []string{"peer-2"})
+	check("RestartRequired", d.RestartRequired, []string{"observability"})
+	if !slices.Equal(d.AddedOverlays, []int{300}) || !slices.Equal(d.RemovedOverlays, []int{200}) || !slices.Equal(d.ChangedOverlays, []int{100}) {
+		t.Errorf("overlays added/removed/changed = %v/%v/%v, want [300]/[200]/[100]",
+			d.AddedOverlays, d.RemovedOverlays, d.ChangedOverlays)
+	}
+	if d.RoutingChanged {
+		t.Error("expected RoutingChanged=false when only an overlay policy changed")
+	}
+
+	next = base()
+	next.Topology.Transit = "allow"
+	if d := ComputeDiff(base(), next); !d.RoutingChanged {
+		t.Error("expected a topology change to set RoutingChanged")
+	}
+}
+
+func TestKeepRestartSettings(t *testing.T) {
+	old := Defaults()
+	old.Node.ID = "node-a"
+	next := Defaults()
+	next.Node.ID = "node-b"
+	next.Node.Hostname = "b"
+	next.Observability.Metrics.Listen.Port = 9999
+
+	KeepRestartSettings(old, next)
+	if d := ComputeDiff(old, next); len(d.RestartRequired) != 0 {
+		t.Fatalf("RestartRequired = %v after KeepRestartSettings, want none", d.RestartRequired)
+	}
+	if next.Node.Hostname != "b" {
+		t.Fatalf("hostname = %q, want the reloaded one", next.Node.Hostname)
+	}
+}
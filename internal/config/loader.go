@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
@@ -80,6 +82,7 @@ func (l *Loader) validateSemantics(cfg *Config) error {
 		seenName := make(map[string]int)
 		seenBridge := make(map[string]int)
 		seenTable := make(map[int]int)
+		seenPriority := make(map[int]int)
 		for i, o := range cfg.Overlays {
 			if o.VNI == 0 {
 				return fmt.Errorf("overlay[%d]: vni is required", i)
@@ -108,6 +111,15 @@ func (l *Loader) validateSemantics(cfg *Config) error {
 				}
 				seenTable[t] = i
 			}
+			// Each overlay's rules use priority and priority+1.
+			if lr := o.Routing.Import.Install.LookupRules; lr.Enabled && lr.Priority != 0 {
+				for _, p := range []int{lr.Priority, lr.Priority + 1} {
+					if prev, ok := seenPriority[p]; ok {
+						return fmt.Errorf("overlay[%d]: lookup_rules.priority %d overlaps the rules of overlay[%d] (each overlay uses priority and priority+1)", i, lr.Priority, prev)
+					}
+				}
+				seenPriority[lr.Priority], seenPriority[lr.Priority+1] = i, i
+			}
 		}
 
 		// V2 declares peers at the root ('peers:'); the legacy 'overlay.peers'
@@ -149,14 +161,83 @@ func (l *Loader) validateSemantics(cfg *Config) error {
 			}
 		}
 		if o.Bridge.IPv4 != "" {
-			if _, _, err := net.ParseCIDR(o.Bridge.IPv4); err != nil {
+			ip, _, err := net.ParseCIDR(o.Bridge.IPv4)
+			if err != nil {
 				return fmt.Errorf("overlay %q: bridge.ipv4 %q is not a valid CIDR: %w", o.Name, o.Bridge.IPv4, err)
 			}
+			if ip.To4() == nil {
+				return fmt.Errorf("overlay %q: bridge.ipv4 %q is not an IPv4 address", o.Name, o.Bridge.IPv4)
+			}
 		}
 		if o.Bridge.IPv6 != "" {
-			if _, _, err := net.ParseCIDR(o.Bridge.IPv6); err != nil {
+			ip, _, err := net.ParseCIDR(o.Bridge.IPv6)
+			if err != nil {
 				return fmt.Errorf("overlay %q: bridge.ipv6 %q is not a valid CIDR: %w", o.Name, o.Bridge.IPv6, err)
 			}
+			if ip.To4() != nil {
+				return fmt.Errorf("overlay %q: bridge.ipv6 %q is not an IPv6 address", o.Name, o.Bridge.IPv6)
+			}
+		}
+		// A VXLAN interface sends over a single address family, so the VTEPs
+		// of an overlay (peer endpoints and multicast group) must share one.
+		families := make(map[bool][]string)
+		for _, p := range cfg.GetPeersForVNI(o.VNI) {
+			if ip := net.ParseIP(p.Endpoint.Address); ip != nil {
+				families[ip.To4() == nil] = append(families[ip.To4() == nil], p.ID)
+			}
+		}
+		if ip := net.ParseIP(o.BUM.Group); ip != nil && o.BUM.GetMode() == "multicast" {
+			families[ip.To4() == nil] = append(families[ip.To4() == nil], "bum.group")
+		}
+		if len(families) > 1 {
+			return fmt.Errorf("overlay %q: VTEPs mix IPv4 (%s) and IPv6 (%s); restrict the peers of each family to their own overlays with vnis",
+				o.Name, strings.Join(families[false], ", "), strings.Join(families[true], ", "))
+		}
+		// The second rule of an overlay uses priority+1, which must stay below
+		// the main table rule (32766).
+		if p := o.Routing.Import.Install.LookupRules.Priority; p < 0 || p > 32764 {
+			return fmt.Errorf("overlay %q: routing.import.install.lookup_rules.priority %d must be between 1 and 32764", o.Name, p)
+		}
+		if o.Routing.Export.IncludeNetplanStatic && !cfg.Netplan.Enabled {
+			return fmt.Errorf("overlay %q: routing.export.include_netplan_static requires netplan.enabled", o.Name)
+		}
+	}
+
+	// A peer must get at least one keepalive before its dead timer fires.
+	for _, p := range cfg.GetPeers() {
+		if p.Health.DeadAfterDuration() <= p.Health.KeepAliveDuration() {
+			return fmt.Errorf("peer %q: health.dead_after_ms (%v) must be greater than health.keepalive_interval_ms (%v)",
+				p.ID, p.Health.DeadAfterDuration(), p.Health.KeepAliveDuration())
+		}
+		// The key itself is read at startup; only the reference is checked here.
+		if p.Auth.Mode == "psk" {
+			if !strings.HasPrefix(p.Auth.PSKRef, "file:") && !strings.HasPrefix(p.Auth.PSKRef, "env:") {
+				return fmt.Errorf("peer %q: auth.mode=psk requires auth.psk_ref with a file: or env: prefix (got %q)", p.ID, p.Auth.PSKRef)
+			}
+		}
+		switch p.Limits.MaxPrefixesAction {
+		case "", "warn", "teardown":
+		default:
+			return fmt.Errorf("peer %q: limits.max_prefixes_action must be warn or teardown (got %q)", p.ID, p.Limits.MaxPrefixesAction)
+		}
+		if p.Limits.MaxPrefixes < 0 || p.Limits.AnnounceRate < 0 || p.Limits.AnnounceBurst < 0 {
+			return fmt.Errorf("peer %q: limits must not be negative", p.ID)
+		}
+		for _, prefix := range p.AllowedPrefixes {
+			if _, _, err := net.ParseCIDR(prefix); err != nil {
+				return fmt.Errorf("peer %q: allowed_prefixes entry %q is not a valid CIDR: %w", p.ID, prefix, err)
+			}
+		}
+	}
+
+	// Hub-spoke needs at least one hub, and a spoke without any hub peer would
+	// be isolated from the overlay.
+	if cfg.Topology.IsHubSpoke() {
+		if len(cfg.Topology.Hubs) == 0 {
+			return fmt.Errorf("topology.mode=hub-spoke requires at least one entry in topology.hubs")
+		}
+		if cfg.IsSpoke() && len(cfg.GetActivePeers()) == 0 {
+			return fmt.Errorf("topology.mode=hub-spoke: spoke %q has no hub among its peers (topology.hubs: %v)", cfg.Node.ID, cfg.Topology.Hubs)
 		}
 	}
 
@@ -202,6 +283,10 @@ func (l *Loader) validateSemantics(cfg *Config) error {
 		}
 	}
 
+	if cfg.Admin.Enabled && !filepath.IsAbs(cfg.Admin.Socket) {
+		return fmt.Errorf("admin.socket must be an absolute path (got %q)", cfg.Admin.Socket)
+	}
+
 	return nil
 }
 
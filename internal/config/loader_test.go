@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoader_Load_ValidConfig(t *testing.T) {
@@ -567,6 +570,67 @@ overlays:
 	}
 }
 
+func TestLoader_Load_BridgeCIDRFamily(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge:
+      name: "br-a"
+`
+	for _, bridge := range []string{
+		"      ipv4: \"fd00:100::1/64\"\n",
+		"      ipv6: \"10.100.0.1/24\"\n",
+	} {
+		if _, err := NewLoader().Load([]byte(base + bridge)); err == nil {
+			t.Errorf("expected error for a bridge address of the wrong family: %q", bridge)
+		}
+	}
+	dual := base + "      ipv4: \"10.100.0.1/24\"\n      ipv6: \"fd00:100::1/64\"\n"
+	if _, err := NewLoader().Load([]byte(dual)); err != nil {
+		t.Fatalf("expected dual-stack bridge to load, got: %v", err)
+	}
+}
+
+func TestLoader_Load_VTEPFamilies(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "v4"
+    bridge: "br-v4"
+  - vni: 200
+    name: "v6"
+    bridge: "br-v6"
+peers:
+  - id: "a"
+    endpoint:
+      address: "192.168.1.10"
+`
+	// Without vnis the IPv6 peer joins the IPv4 overlay too.
+	mixed := base + "  - id: \"b\"\n    endpoint:\n      address: \"2001:db8::10\"\n"
+	_, err := NewLoader().Load([]byte(mixed))
+	if err == nil || !strings.Contains(err.Error(), "mix IPv4 (a) and IPv6 (b)") {
+		t.Fatalf("expected mixed VTEP families to be rejected, got: %v", err)
+	}
+
+	split := strings.Replace(base, "      address: \"192.168.1.10\"\n", "      address: \"192.168.1.10\"\n    vnis: [100]\n", 1) +
+		"  - id: \"b\"\n    endpoint:\n      address: \"2001:db8::10\"\n    vnis: [200]\n"
+	cfg, err := NewLoader().Load([]byte(split))
+	if err != nil {
+		t.Fatalf("expected one family per overlay to load, got: %v", err)
+	}
+	overlays := cfg.GetOverlays()
+	if cfg.IPv6VTEP(overlays[0]) || !cfg.IPv6VTEP(overlays[1]) {
+		t.Fatalf("IPv6VTEP = %v, %v; want false for v4 and true for v6", cfg.IPv6VTEP(overlays[0]), cfg.IPv6VTEP(overlays[1]))
+	}
+}
+
 func TestLoader_Load_VagrantStyleV2(t *testing.T) {
 	// Mirrors the config generated by the Vagrant lab (v2, root peers as a
 	// 4-space indented sequence, two overlays with distinct tables).
@@ -665,3 +729,286 @@ func TestConfig_GetPeersForVNI(t *testing.T) {
 		t.Fatalf("expected 2 peers for VNI 200 (all + only-200), got %d", len(for200))
 	}
 }
+
+func TestLoader_Load_HubSpoke(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "%s"
+topology:
+  mode: hub-spoke
+%s
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+peers:
+  - id: "hub-1"
+    endpoint:
+      address: "10.0.0.1"
+  - id: "spoke-2"
+    endpoint:
+      address: "10.0.0.3"
+`
+	cases := []struct {
+		name    string
+		nodeID  string
+		hubs    string
+		wantErr bool
+	}{
+		{name: "missing hubs is rejected", nodeID: "spoke-1", hubs: "", wantErr: true},
+		{name: "spoke with a hub peer is valid", nodeID: "spoke-1", hubs: `  hubs: ["hub-1"]`},
+		{name: "spoke without a hub peer is rejected", nodeID: "spoke-1", hubs: `  hubs: ["hub-9"]`, wantErr: true},
+		{name: "hub itself is valid", nodeID: "hub-9", hubs: `  hubs: ["hub-9"]`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewLoader().Load([]byte(fmt.Sprintf(base, tc.nodeID, tc.hubs)))
+			if tc.wantErr && err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_GetActivePeers_HubSpoke(t *testing.T) {
+	cfg := &Config{
+		Version:  2,
+		Node:     NodeConfig{ID: "spoke-1"},
+		Topology: TopologyConfig{Mode: "hub-spoke", Hubs: []string{"hub-1"}},
+		Peers: []PeerConfig{
+			{ID: "hub-1"},
+			{ID: "spoke-2"},
+			{ID: "spoke-3", VNIs: []int{200}},
+		},
+	}
+
+	// A spoke only talks to hubs.
+	if peers := cfg.GetActivePeers(); len(peers) != 1 || peers[0].ID != "hub-1" {
+		t.Fatalf("expected spoke to only see hub-1, got %+v", peers)
+	}
+	if peers := cfg.GetActivePeersForVNI(200); len(peers) != 1 || peers[0].ID != "hub-1" {
+		t.Fatalf("expected spoke FDB for VNI 200 to only contain hub-1, got %+v", peers)
+	}
+
+	// A hub talks to everyone.
+	cfg.Node.ID = "hub-1"
+	if peers := cfg.GetActivePeers(); len(peers) != 3 {
+		t.Fatalf("expected hub to see all 3 peers, got %d", len(peers))
+	}
+
+	// Full mesh is unaffected by topology.hubs.
+	cfg.Topology.Mode = "full-mesh"
+	cfg.Node.ID = "spoke-1"
+	if peers := cfg.GetActivePeersForVNI(200); len(peers) != 3 {
+		t.Fatalf("expected full-mesh spoke to see 3 peers for VNI 200, got %d", len(peers))
+	}
+}
+
+func TestLoader_Load_DeadAfterMustExceedKeepalive(t *testing.T) {
+	yaml := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+peers:
+  - id: "peer-1"
+    endpoint:
+      address: "10.0.0.2"
+    health:
+      keepalive_interval_ms: 2000
+      dead_after_ms: 1000
+`
+	if _, err := NewLoader().Load([]byte(yaml)); err == nil {
+		t.Fatal("expected error for dead_after_ms <= keepalive_interval_ms")
+	}
+}
+
+func TestLoader_Load_PSKRefPrefix(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+peers:
+  - id: "peer-1"
+    endpoint:
+      address: "10.0.0.2"
+    auth:
+      mode: psk
+`
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr bool
+	}{
+		{"file reference", `"file:/etc/n-netman/psk/peer-1.key"`, false},
+		{"env reference", `"env:NNET_PSK_PEER1"`, false},
+		{"missing reference", `""`, true},
+		{"bare path", `"/etc/n-netman/psk/peer-1.key"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Load([]byte(base + "      psk_ref: " + tt.ref + "\n"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_Load_AllowedPrefixes(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+peers:
+  - id: "peer-1"
+    endpoint:
+      address: "10.0.0.2"
+    allowed_prefixes:
+`
+	cfg, err := NewLoader().Load([]byte(base + "      - \"172.16.0.0/16\"\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Peers[0].AllowedPrefixes; len(got) != 1 || got[0] != "172.16.0.0/16" {
+		t.Fatalf("allowed_prefixes = %v, want [172.16.0.0/16]", got)
+	}
+	if _, err := NewLoader().Load([]byte(base + "      - \"172.16.0.0\"\n")); err == nil {
+		t.Fatal("expected an error for an allowed_prefixes entry that is not a CIDR")
+	}
+}
+
+func TestLoader_Load_PeerLimits(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+peers:
+  - id: "peer-1"
+    endpoint:
+      address: "10.0.0.2"
+    limits:
+      max_prefixes: 100
+`
+	cfg, err := NewLoader().Load([]byte(base + "      max_prefixes_action: teardown\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	lim := cfg.Peers[0].Limits
+	if lim.MaxPrefixes != 100 || !lim.TeardownsPeer() || lim.TeardownDuration() != 60*time.Second {
+		t.Fatalf("limits = %+v, want max_prefixes 100 with a 60s teardown", lim)
+	}
+	if rate, burst := lim.Rate(); rate != 1 || burst != 10 {
+		t.Fatalf("Rate() = %v, %v, want the defaults 1, 10", rate, burst)
+	}
+	if _, err := NewLoader().Load([]byte(base + "      max_prefixes_action: drop\n")); err == nil {
+		t.Fatal("expected an error for an unknown max_prefixes_action")
+	}
+}
+
+func TestLoader_Load_IncludeNetplanStaticRequiresNetplan(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+    routing:
+      export:
+        include_netplan_static: true
+`
+	if _, err := NewLoader().Load([]byte(base)); err == nil || !strings.Contains(err.Error(), "netplan.enabled") {
+		t.Fatalf("Load() error = %v, want include_netplan_static to require netplan.enabled", err)
+	}
+	cfg, err := NewLoader().Load([]byte(base + "netplan:\n  enabled: true\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Netplan.ConfigPaths; len(got) != 1 || got[0] != "/etc/netplan" {
+		t.Fatalf("netplan.config_paths = %v, want the default [/etc/netplan]", got)
+	}
+}
+
+func TestLoader_Load_LookupRulesPriority(t *testing.T) {
+	base := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+    routing:
+      import:
+        install:
+          table: 100
+          lookup_rules:
+            enabled: true
+            mode: prefix
+            priority: %d
+`
+	cfg, err := NewLoader().Load([]byte(fmt.Sprintf(base, 1000)))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	lookup := cfg.Overlays[0].Routing.Import.Install.LookupRules
+	if !lookup.PrefixMode() || lookup.Priority != 1000 {
+		t.Fatalf("lookup_rules = %+v, want prefix mode with priority 1000", lookup)
+	}
+	// The second rule uses priority+1, which must stay below the main table.
+	if _, err := NewLoader().Load([]byte(fmt.Sprintf(base, 32765))); err == nil {
+		t.Fatal("expected error for a lookup_rules.priority too close to the main table rule")
+	}
+}
+
+func TestLoader_Load_LookupRulesPriorityOverlap(t *testing.T) {
+	yaml := `
+version: 2
+node:
+  id: "test-node"
+overlays:
+  - vni: 100
+    name: "a"
+    bridge: "br-a"
+    routing:
+      import:
+        install:
+          table: 100
+          lookup_rules: {enabled: true, priority: 200}
+  - vni: 200
+    name: "b"
+    bridge: "br-b"
+    routing:
+      import:
+        install:
+          table: 200
+          lookup_rules: {enabled: true, priority: %d}
+`
+	if _, err := NewLoader().Load([]byte(fmt.Sprintf(yaml, 201))); err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Fatalf("Load() error = %v, want overlapping lookup_rules priorities rejected", err)
+	}
+	if _, err := NewLoader().Load([]byte(fmt.Sprintf(yaml, 202))); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+}
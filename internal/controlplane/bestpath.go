@@ -0,0 +1,86 @@
+package controlplane
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Candidates returns the routes learned from peers for a (VNI, prefix), one
+// per peer. Local routes are not candidates.
+func (rt *RouteTable) Candidates(vni uint32, prefix string) []Route {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	keys := rt.byPrefix[prefixKey(vni, prefix)]
+	routes := make([]Route, 0, len(keys))
+	for k := range keys {
+		routes = append(routes, rt.routes[k])
+	}
+	return routes
+}
+
+// comparePaths orders two routes for the same (VNI, prefix) by preference,
+// without the final tie-breaks on peer ID and next-hop: the lowest metric
+// first, then fresh routes before routes held for a graceful restart, routes
+// learned directly before relayed ones, and the shortest path.
+func comparePaths(a, b Route) int {
+	if c := cmp.Compare(a.Metric, b.Metric); c != 0 {
+		return c
+	}
+	if c := compareBool(!a.StaleUntil.IsZero(), !b.StaleUntil.IsZero()); c != 0 {
+		return c
+	}
+	if c := compareBool(a.RelayedVia != "", b.RelayedVia != ""); c != 0 {
+		return c
+	}
+	return cmp.Compare(len(a.Path), len(b.Path))
+}
+
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// BestPaths selects the paths installed for a (VNI, prefix) among the routes
+// learned for it from different peers (see comparePaths). Remaining ties are
+// broken by the lowest peer ID, then next-hop, so the choice does not depend
+// on the order the routes were learned in. Without multipath only the best
+// route is returned; with multipath every route as good as the best one is
+// returned (ECMP), one per distinct next-hop, in the same order.
+func BestPaths(candidates []Route, multipath bool) []Route {
+	if len(candidates) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(candidates)
+	slices.SortFunc(sorted, func(a, b Route) int {
+		if c := comparePaths(a, b); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.PeerID, b.PeerID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.NextHop, b.NextHop)
+	})
+	if !multipath {
+		return sorted[:1]
+	}
+
+	best := []Route{sorted[0]}
+	for _, r := range sorted[1:] {
+		if comparePaths(r, sorted[0]) != 0 {
+			break
+		}
+		if !slices.ContainsFunc(best, func(b Route) bool { return b.NextHop == r.NextHop }) {
+			best = append(best, r)
+		}
+	}
+	return best
+}
@@ -0,0 +1,143 @@
+package controlplane
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBestPaths(t *testing.T) {
+	stale := time.Now().Add(time.Minute)
+	tests := []struct {
+		name       string
+		candidates []Route
+		multipath  bool
+		want       []string // peer IDs
+	}{
+		{
+			name:       "no candidate",
+			candidates: nil,
+			want:       nil,
+		},
+		{
+			name: "lowest metric wins",
+			candidates: []Route{
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 200},
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 100},
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "fresh before stale",
+			candidates: []Route{
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 100, StaleUntil: stale},
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 100},
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "direct before relayed",
+			candidates: []Route{
+				{PeerID: "a", NextHop: "10.100.0.3", Metric: 100, RelayedVia: "c"},
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 100},
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "shortest path",
+			candidates: []Route{
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 100, Path: []string{"a", "d"}},
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 100, Path: []string{"b"}},
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "lowest peer ID breaks the tie",
+			candidates: []Route{
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 100},
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 100},
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "multipath keeps the equal-cost paths only",
+			candidates: []Route{
+				{PeerID: "c", NextHop: "10.100.0.3", Metric: 100},
+				{PeerID: "b", NextHop: "10.100.0.2", Metric: 200},
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 100},
+			},
+			multipath: true,
+			want:      []string{"a", "c"},
+		},
+		{
+			name: "multipath skips a duplicate next-hop",
+			candidates: []Route{
+				{PeerID: "a", NextHop: "10.100.0.1", Metric: 100},
+				{PeerID: "b", NextHop: "10.100.0.1", Metric: 100},
+			},
+			multipath: true,
+			want:      []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range BestPaths(tt.candidates, tt.multipath) {
+				got = append(got, r.PeerID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("BestPaths = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("BestPaths = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteTable_Candidates(t *testing.T) {
+	rt := NewRouteTable()
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100})              // local
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a"}) // candidate
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "b"}) // candidate
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 200, PeerID: "a"}) // other VNI
+	rt.Add(Route{Prefix: "10.2.0.0/24", VNI: 100, PeerID: "a"}) // other prefix
+
+	if got := rt.Candidates(100, "10.1.0.0/24"); len(got) != 2 {
+		t.Fatalf("Candidates = %+v, want the routes of a and b", got)
+	}
+}
+
+func TestRouteTable_CandidatesFollowRemovals(t *testing.T) {
+	rt := NewRouteTable()
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a"})
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "b"})
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "c"})
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "d", LeaseSeconds: 1})
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a", Metric: 5}) // update
+
+	rt.Remove(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "b"})
+	rt.RemoveByPeer("c")
+	for k, r := range rt.routes {
+		if r.PeerID == "d" {
+			r.ExpiresAt = time.Now().Add(-time.Second)
+			rt.routes[k] = r
+		}
+	}
+	rt.ExpireStale()
+
+	got := rt.Candidates(100, "10.1.0.0/24")
+	if len(got) != 1 || got[0].PeerID != "a" || got[0].Metric != 5 {
+		t.Fatalf("Candidates = %+v, want only the updated route of a", got)
+	}
+
+	rt.RemoveByVNI(100)
+	if got := rt.Candidates(100, "10.1.0.0/24"); len(got) != 0 {
+		t.Fatalf("Candidates = %+v, want none", got)
+	}
+	if len(rt.byPrefix) != 0 {
+		t.Errorf("byPrefix = %v, want empty after removing every route", rt.byPrefix)
+	}
+}
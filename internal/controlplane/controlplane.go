@@ -3,10 +3,17 @@ package controlplane
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
@@ -26,6 +33,11 @@ import (
 // stall route propagation to the others.
 const peerRPCTimeout = 10 * time.Second
 
+// stopGracePeriod bounds how long Stop waits for in-flight RPCs. Peers keep
+// their Keepalive streams open for as long as the session lives, so a graceful
+// stop alone would never return.
+const stopGracePeriod = 2 * time.Second
+
 // maxRecvMsgSize caps the size of an inbound route message (DoS guard).
 const maxRecvMsgSize = 1 << 20 // 1 MiB
 
@@ -40,16 +52,46 @@ type Route struct {
 	ReceivedAt   time.Time
 	ExpiresAt    time.Time
 	PeerID       string
+
+	// RelayedVia is set when the route's peer is down and its traffic is
+	// relayed through another peer (NextHop is then that relay's next-hop).
+	RelayedVia string
+
+	// StaleUntil is set while the route's peer is down and graceful
+	// restart keeps the route: it is removed if the peer has not announced it
+	// again by then (see MarkStale).
+	StaleUntil time.Time
+
+	// OriginatorID is the node that originated the route and Path the node IDs
+	// the announcement traversed (originator first). Both are empty for local
+	// routes and used for loop prevention when routes are re-exported.
+	OriginatorID string
+	Path         []string
 }
 
+// RejectedRoute is a route announcement that was not accepted into the table.
+type RejectedRoute struct {
+	Route      Route // as announced; only the identity and next-hop are set
+	Reason     string
+	RejectedAt time.Time
+}
+
+// maxRejectedRoutes bounds the rejected routes kept for inspection.
+const maxRejectedRoutes = 1024
+
 // RouteTable stores learned routes from peers.
 //
 // Routes are keyed by (VNI, prefix, peerID) so that the same prefix announced
 // in different overlays or by different peers does not collapse into a single
-// last-writer-wins entry.
+// last-writer-wins entry. The routes learned from peers are indexed by
+// (VNI, prefix) too, for the best-path selection (see Candidates). The last
+// rejection of each announcement is kept too, until the same route is
+// accepted.
 type RouteTable struct {
-	mu     sync.RWMutex
-	routes map[string]Route
+	mu       sync.RWMutex
+	routes   map[string]Route
+	byPrefix map[string]map[string]struct{} // vni|prefix -> route keys
+	rejected map[string]RejectedRoute
 }
 
 // routeKey is the composite identity of a route within the table.
@@ -57,10 +99,45 @@ func routeKey(r Route) string {
 	return fmt.Sprintf("%d|%s|%s", r.VNI, r.Prefix, r.PeerID)
 }
 
+// prefixKey identifies the (VNI, prefix) of a route.
+func prefixKey(vni uint32, prefix string) string {
+	return fmt.Sprintf("%d|%s", vni, prefix)
+}
+
+// put stores r under key and indexes it. Called with rt.mu held.
+func (rt *RouteTable) put(key string, r Route) {
+	rt.routes[key] = r
+	if r.PeerID == "" {
+		return
+	}
+	pk := prefixKey(r.VNI, r.Prefix)
+	keys := rt.byPrefix[pk]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		rt.byPrefix[pk] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// del removes the route stored under key and its index entry. Called with
+// rt.mu held.
+func (rt *RouteTable) del(key string, r Route) {
+	delete(rt.routes, key)
+	pk := prefixKey(r.VNI, r.Prefix)
+	if keys, ok := rt.byPrefix[pk]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(rt.byPrefix, pk)
+		}
+	}
+}
+
 // NewRouteTable creates a new route table.
 func NewRouteTable() *RouteTable {
 	return &RouteTable{
-		routes: make(map[string]Route),
+		routes:   make(map[string]Route),
+		byPrefix: make(map[string]map[string]struct{}),
+		rejected: make(map[string]RejectedRoute),
 	}
 }
 
@@ -74,14 +151,81 @@ func (rt *RouteTable) Add(r Route) {
 	if r.LeaseSeconds > 0 {
 		r.ExpiresAt = r.ReceivedAt.Add(time.Duration(r.LeaseSeconds) * time.Second)
 	}
-	rt.routes[routeKey(r)] = r
+	rt.put(routeKey(r), r)
+	delete(rt.rejected, routeKey(r))
+}
+
+// Reject records that an announcement was not accepted. When the table of
+// rejections is full the oldest one is dropped.
+func (rt *RouteTable) Reject(r Route, reason string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	key := routeKey(r)
+	if _, ok := rt.rejected[key]; !ok && len(rt.rejected) >= maxRejectedRoutes {
+		oldest := ""
+		for k, rej := range rt.rejected {
+			if oldest == "" || rej.RejectedAt.Before(rt.rejected[oldest].RejectedAt) {
+				oldest = k
+			}
+		}
+		delete(rt.rejected, oldest)
+	}
+	rt.rejected[key] = RejectedRoute{Route: r, Reason: reason, RejectedAt: time.Now()}
+}
+
+// Rejected returns the recorded rejections, most recent first.
+func (rt *RouteTable) Rejected() []RejectedRoute {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	out := make([]RejectedRoute, 0, len(rt.rejected))
+	for _, r := range rt.rejected {
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b RejectedRoute) int { return b.RejectedAt.Compare(a.RejectedAt) })
+	return out
 }
 
 // Remove removes the exact route (matched by VNI+prefix+peer).
 func (rt *RouteTable) Remove(r Route) {
 	rt.mu.Lock()
 	defer rt.mu.Unlock()
-	delete(rt.routes, routeKey(r))
+
+	key := routeKey(r)
+	if old, ok := rt.routes[key]; ok {
+		rt.del(key, old)
+	}
+}
+
+// RemoveRoute removes the route of a peer for a prefix in an overlay and
+// returns it.
+func (rt *RouteTable) RemoveRoute(vni uint32, prefix, peerID string) (Route, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	key := routeKey(Route{VNI: vni, Prefix: prefix, PeerID: peerID})
+	r, ok := rt.routes[key]
+	if ok {
+		rt.del(key, r)
+	}
+	return r, ok
+}
+
+// Renew extends the lease of the routes of a peer that is known to be alive
+// (its route stream is open). Routes held for a graceful restart or relayed
+// through another peer are left alone.
+func (rt *RouteTable) Renew(peerID string, now time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for k, r := range rt.routes {
+		if r.PeerID != peerID || r.LeaseSeconds == 0 || !r.StaleUntil.IsZero() || r.RelayedVia != "" {
+			continue
+		}
+		r.ExpiresAt = now.Add(time.Duration(r.LeaseSeconds) * time.Second)
+		rt.routes[k] = r
+	}
 }
 
 // RemoveByPrefixPeer removes every route for a given prefix announced by a
@@ -95,7 +239,7 @@ func (rt *RouteTable) RemoveByPrefixPeer(prefix, peerID string) []Route {
 	for k, r := range rt.routes {
 		if r.Prefix == prefix && r.PeerID == peerID {
 			removed = append(removed, r)
-			delete(rt.routes, k)
+			rt.del(k, r)
 		}
 	}
 	return removed
@@ -110,7 +254,58 @@ func (rt *RouteTable) RemoveByPeer(peerID string) []Route {
 	for k, r := range rt.routes {
 		if r.PeerID == peerID {
 			removed = append(removed, r)
-			delete(rt.routes, k)
+			rt.del(k, r)
+		}
+	}
+	return removed
+}
+
+// RemoveByVNI removes all routes learned for an overlay and returns them.
+func (rt *RouteTable) RemoveByVNI(vni uint32) []Route {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var removed []Route
+	for k, r := range rt.routes {
+		if r.VNI == vni && r.PeerID != "" {
+			removed = append(removed, r)
+			rt.del(k, r)
+		}
+	}
+	return removed
+}
+
+// MarkStale keeps the routes of a peer that went down until the given time
+// (its graceful-restart timer) instead of their lease. Routes the peer
+// announces again replace the stale entries. Returns the number of routes
+// marked.
+func (rt *RouteTable) MarkStale(peerID string, until time.Time) int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	n := 0
+	for k, r := range rt.routes {
+		if r.PeerID == peerID {
+			r.StaleUntil = until
+			r.ExpiresAt = until
+			rt.routes[k] = r
+			n++
+		}
+	}
+	return n
+}
+
+// RemoveStaleByPeer removes the routes of a peer that are still stale, i.e.
+// were not announced again since MarkStale, and returns them.
+func (rt *RouteTable) RemoveStaleByPeer(peerID string) []Route {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var removed []Route
+	for k, r := range rt.routes {
+		if r.PeerID == peerID && !r.StaleUntil.IsZero() {
+			removed = append(removed, r)
+			rt.del(k, r)
 		}
 	}
 	return removed
@@ -153,7 +348,7 @@ func (rt *RouteTable) ExpireStale() []Route {
 	for key, r := range rt.routes {
 		if !r.ExpiresAt.IsZero() && r.ExpiresAt.Before(now) {
 			expired = append(expired, r)
-			delete(rt.routes, key)
+			rt.del(key, r)
 		}
 	}
 	return expired
@@ -164,7 +359,7 @@ func (rt *RouteTable) ExpireStale() []Route {
 type Server struct {
 	pb.UnimplementedNNetManServer
 
-	cfg        *config.Config
+	cfg        atomic.Pointer[config.Config]
 	routeTable *RouteTable
 	logger     *slog.Logger
 	grpcServer *grpc.Server
@@ -174,22 +369,41 @@ type Server struct {
 	onRoutesReceived func(routes []Route)
 	// Callback invoked when routes are withdrawn (to remove them from the kernel)
 	onRoutesWithdrawn func(routes []Route)
+	// Provider of the routes advertised in ExchangeState responses (local
+	// routes with resolved next-hops, plus any re-advertised routes).
+	exportRoutes func() []Route
+	// Provider of the relay information advertised to peers when transit is
+	// allowed (which peers this node reaches and its per-VNI next-hops).
+	relayInfo func() RelayInfo
+	// Verifies the HMAC of requests from peers using auth.mode=psk.
+	psk *pskVerifier
+	// Optional Prometheus metrics (rejected announcements).
+	metrics *observability.Metrics
+	// Optional per-peer limits (max_prefixes, announcement rate).
+	limits *Limits
 
 	mu        sync.RWMutex
 	started   bool
 	startTime time.Time
+	// Graceful-restart timer advertised by each peer in its StateRequest.
+	restartTimes map[string]time.Duration
+	// Open WatchRoutes streams: change notification channel -> peer ID.
+	watchers map[chan struct{}]string
 }
 
 // NewServer creates a new control plane server.
 func NewServer(cfg *config.Config, routeTable *RouteTable, logger *slog.Logger) *Server {
-	return &Server{
-		cfg:        cfg,
+	s := &Server{
 		routeTable: routeTable,
 		logger:     logger,
 		// Set once at construction so it can be read without locking (e.g. in
 		// the Keepalive handler running on another goroutine).
-		startTime: time.Now(),
+		startTime:    time.Now(),
+		restartTimes: make(map[string]time.Duration),
+		watchers:     make(map[chan struct{}]string),
 	}
+	s.cfg.Store(cfg)
+	return s
 }
 
 // SetRoutesReceivedCallback sets the callback for when routes are received.
@@ -207,8 +421,114 @@ func (s *Server) SetRoutesWithdrawnCallback(fn func(routes []Route)) {
 	s.onRoutesWithdrawn = fn
 }
 
+// SetExportRoutesFunc sets the provider of the routes returned to peers in
+// ExchangeState responses. Without it, only the local routes loaded from the
+// config are returned (without a next-hop).
+func (s *Server) SetExportRoutesFunc(fn func() []Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exportRoutes = fn
+}
+
+// PeerRestartTime returns the graceful-restart timer advertised by a peer,
+// or 0 if it does not support graceful restart.
+func (s *Server) PeerRestartTime(peerID string) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.restartTimes[peerID]
+}
+
+// RelayInfo describes this node's ability to relay traffic for its peers.
+type RelayInfo struct {
+	ReachablePeers []string        // peers with a healthy session
+	NextHops       OverlayNextHops // overlay next-hops per VNI
+}
+
+// OverlayNextHops are the next-hops of a node per overlay VNI, at most one
+// per address family (a dual-stack overlay has both).
+type OverlayNextHops map[uint32][]string
+
+// Add records a next-hop of a VNI, replacing the one of the same family.
+func (n OverlayNextHops) Add(vni uint32, nextHop string) {
+	v6 := isIPv6(nextHop)
+	hops := slices.DeleteFunc(n[vni], func(nh string) bool { return isIPv6(nh) == v6 })
+	n[vni] = append(hops, nextHop)
+}
+
+// For returns the next-hop of a VNI for a prefix: the one of the prefix's
+// address family, or "" when the node has none (a gateway of the other
+// family would be refused by the kernel).
+func (n OverlayNextHops) For(vni uint32, prefix string) string {
+	v6 := isIPv6(prefix)
+	for _, nh := range n[vni] {
+		if isIPv6(nh) == v6 {
+			return nh
+		}
+	}
+	return ""
+}
+
+// isIPv6 reports whether an address or prefix is IPv6.
+func isIPv6(s string) bool {
+	return strings.Contains(s, ":")
+}
+
+// SetRelayInfoFunc sets the provider of the relay information returned to
+// peers in ExchangeState responses. It is only advertised when transit is
+// allowed, since relaying is transit in the data plane.
+func (s *Server) SetRelayInfoFunc(fn func() RelayInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.relayInfo = fn
+}
+
+// SetMetrics wires Prometheus metrics into the server (rejected
+// announcements).
+func (s *Server) SetMetrics(m *observability.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = m
+}
+
+func (s *Server) getMetrics() *observability.Metrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metrics
+}
+
+// SetLimits sets the per-peer limits enforced on announcements (shared with
+// the Client).
+func (s *Server) SetLimits(l *Limits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = l
+}
+
+func (s *Server) getLimits() *Limits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limits
+}
+
+// SetConfig swaps the configuration used to serve peers, reloads their PSKs
+// and drops the limit state of the peers it removed. The listen address and
+// TLS settings are bound at Start and are not changed by a reload.
+func (s *Server) SetConfig(cfg *config.Config) error {
+	keys, err := loadPeerPSKs(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load PSKs: %w", err)
+	}
+	if s.psk != nil {
+		s.psk.setKeys(keys)
+	}
+	s.cfg.Store(cfg)
+	s.getLimits().prune(cfg)
+	return nil
+}
+
 // Start starts the gRPC server.
 func (s *Server) Start() error {
+	cfg := s.cfg.Load()
 	s.mu.Lock()
 	if s.started {
 		s.mu.Unlock()
@@ -216,9 +536,15 @@ func (s *Server) Start() error {
 	}
 	s.mu.Unlock()
 
+	keys, err := loadPeerPSKs(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load PSKs: %w", err)
+	}
+	s.psk = newPSKVerifier(keys)
+
 	addr := fmt.Sprintf("%s:%d",
-		s.cfg.Security.ControlPlane.Listen.Address,
-		s.cfg.Security.ControlPlane.Listen.Port,
+		cfg.Security.ControlPlane.Listen.Address,
+		cfg.Security.ControlPlane.Listen.Port,
 	)
 
 	listener, err := net.Listen("tcp", addr)
@@ -243,8 +569,8 @@ func (s *Server) Start() error {
 		}),
 	}
 
-	if s.cfg.Security.ControlPlane.TLS.Enabled {
-		creds, err := LoadServerTLSConfig(&s.cfg.Security.ControlPlane.TLS)
+	if cfg.Security.ControlPlane.TLS.Enabled {
+		creds, err := LoadServerTLSConfig(&cfg.Security.ControlPlane.TLS)
 		if err != nil {
 			return fmt.Errorf("failed to load TLS config: %w", err)
 		}
@@ -279,7 +605,7 @@ func (s *Server) Start() error {
 // LoadLocalRoutes loads routes from config overlays into the route table.
 // These routes will be exported to peers when they connect.
 func (s *Server) LoadLocalRoutes() {
-	overlays := s.cfg.GetOverlays()
+	overlays := s.cfg.Load().GetOverlays()
 	count := 0
 
 	for _, overlay := range overlays {
@@ -315,7 +641,18 @@ func (s *Server) Stop() {
 	// GracefulStop blocks until in-flight handlers return; those handlers take
 	// s.mu, so it must run without the lock held to avoid a shutdown deadlock.
 	if srv != nil {
-		srv.GracefulStop()
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(stopGracePeriod):
+			// Cut the remaining (keepalive) streams.
+			srv.Stop()
+			<-done
+		}
 	}
 	s.logger.Info("control plane server stopped")
 }
@@ -343,109 +680,343 @@ func authenticatedPeerID(ctx context.Context) (string, bool) {
 // mTLS, the verified certificate CommonName is authoritative: a request whose
 // node_id does not match it is rejected (prevents a peer from spoofing another's
 // identity to inject or withdraw routes). When the connection is not mTLS, the
-// claimed id is used as-is (control plane runs without authentication).
-func (s *Server) resolvePeerID(ctx context.Context, claimed string) (string, error) {
-	cn, ok := authenticatedPeerID(ctx)
-	if !ok {
-		if claimed == "" {
-			return "", status.Error(codes.InvalidArgument, "node_id is required")
+// claimed id is used as-is.
+//
+// Requests from peers using auth.mode=psk must also carry a valid HMAC made
+// with the peer's pre-shared key and a timestamp within the replay window.
+// Once any peer uses PSK, node ids not in the peer list are rejected, so a
+// host cannot bypass the check by claiming an unknown identity.
+func (s *Server) resolvePeerID(ctx context.Context, req authMessage) (string, error) {
+	claimed := req.GetNodeId()
+	peerID := claimed
+	if cn, ok := authenticatedPeerID(ctx); ok {
+		if claimed != "" && claimed != cn {
+			return "", status.Errorf(codes.PermissionDenied,
+				"node_id %q does not match authenticated identity %q", claimed, cn)
 		}
-		return claimed, nil
+		peerID = cn
+	} else if claimed == "" {
+		return "", status.Error(codes.InvalidArgument, "node_id is required")
 	}
-	if claimed != "" && claimed != cn {
-		return "", status.Errorf(codes.PermissionDenied,
-			"node_id %q does not match authenticated identity %q", claimed, cn)
+
+	if s.psk.enabled() {
+		if !s.isConfiguredPeer(peerID) {
+			return "", status.Errorf(codes.PermissionDenied, "unknown peer %q", peerID)
+		}
+		if err := s.psk.verify(peerID, req, time.Now()); err != nil {
+			return "", err
+		}
 	}
-	return cn, nil
+	return peerID, nil
+}
+
+// isConfiguredPeer reports whether peerID is in this node's peer list.
+func (s *Server) isConfiguredPeer(peerID string) bool {
+	for _, p := range s.cfg.Load().GetPeers() {
+		if p.ID == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTopology rejects peers this node must not exchange routes with under
+// the active topology: in hub-spoke mode a spoke only talks to hubs.
+func (s *Server) checkTopology(peerID string) error {
+	cfg := s.cfg.Load()
+	if cfg.IsSpoke() && !cfg.Topology.IsHub(peerID) {
+		return status.Errorf(codes.PermissionDenied,
+			"spoke %q only peers with hubs; %q is not a hub", cfg.Node.ID, peerID)
+	}
+	return nil
 }
 
 // ingestRoutes validates and stores routes announced by a peer, rejecting
-// entries with an invalid prefix or next-hop so a misbehaving peer cannot
-// poison the route table. Returns the accepted routes.
-func (s *Server) ingestRoutes(pbRoutes []*pb.Route, peerID string) []Route {
+// entries with an invalid prefix or next-hop, or that the peer is not
+// authorized to announce (see AuthorizeRoute), so a misbehaving peer cannot
+// poison the route table. Returns the accepted routes, or a ResourceExhausted
+// error when the peer got torn down for exceeding its max_prefixes.
+func (s *Server) ingestRoutes(pbRoutes []*pb.Route, peerID string) ([]Route, error) {
+	cfg := s.cfg.Load()
 	out := make([]Route, 0, len(pbRoutes))
 	for _, r := range pbRoutes {
-		if _, _, err := net.ParseCIDR(r.Prefix); err != nil {
-			s.logger.Warn("rejecting route with invalid prefix", "prefix", r.Prefix, "peer", peerID)
+		route, err := admitRoute(cfg, r, peerID)
+		if err != nil {
+			s.logger.Warn("rejecting route", "prefix", r.Prefix, "vni", r.Vni, "peer", peerID, "error", err)
+			s.routeTable.Reject(rejectedFromPB(r, peerID), err.Error())
+			countRejected(s.getMetrics(), peerID, err)
 			continue
 		}
-		if r.NextHop != "" && net.ParseIP(r.NextHop) == nil {
-			s.logger.Warn("rejecting route with invalid next-hop", "next_hop", r.NextHop, "peer", peerID)
+		s.routeTable.Add(route)
+		out = append(out, route)
+	}
+
+	if removed, tornDown := s.getLimits().enforceMaxPrefixes(cfg, s.routeTable, peerID); tornDown {
+		s.mu.RLock()
+		callback := s.onRoutesWithdrawn
+		s.mu.RUnlock()
+		if callback != nil && len(removed) > 0 {
+			callback(removed)
+		}
+		return nil, status.Errorf(codes.ResourceExhausted, "peer %s exceeded max_prefixes", peerID)
+	}
+	return out, nil
+}
+
+// Reasons a route announcement is rejected. Errors wrap one of them, so the
+// rejection counter can be labelled by reason.
+var (
+	errInvalidPrefix    = errors.New("invalid prefix")
+	errInvalidNextHop   = errors.New("invalid next-hop")
+	errRoutingLoop      = errors.New("routing loop")
+	errUnknownPeer      = errors.New("unknown peer")
+	errVNINotAllowed    = errors.New("VNI not allowed")
+	errPrefixNotAllowed = errors.New("prefix not allowed")
+)
+
+// rejectReasonLabels maps the rejection reasons to their metric label.
+var rejectReasonLabels = []struct {
+	err   error
+	label string
+}{
+	{errInvalidPrefix, "invalid_prefix"},
+	{errInvalidNextHop, "invalid_next_hop"},
+	{errRoutingLoop, "routing_loop"},
+	{errUnknownPeer, "unknown_peer"},
+	{errVNINotAllowed, "vni_not_allowed"},
+	{errPrefixNotAllowed, "prefix_not_allowed"},
+}
+
+// countRejected counts a rejected announcement in the routes_rejected_total
+// metric, when metrics are set.
+func countRejected(m *observability.Metrics, peerID string, err error) {
+	if m == nil {
+		return
+	}
+	reason := "other"
+	for _, r := range rejectReasonLabels {
+		if errors.Is(err, r.err) {
+			reason = r.label
+			break
+		}
+	}
+	m.RoutesRejected.WithLabelValues(peerID, reason).Inc()
+}
+
+// admitRoute validates a route announced by peerID and checks that the peer
+// may announce it.
+func admitRoute(cfg *config.Config, r *pb.Route, peerID string) (Route, error) {
+	route, err := routeFromPB(r, peerID, cfg.Node.ID)
+	if err != nil {
+		return Route{}, err
+	}
+	if err := AuthorizeRoute(cfg, route); err != nil {
+		return Route{}, err
+	}
+	return route, nil
+}
+
+// AuthorizeRoute checks that the peer a route was learned from may announce
+// it: the peer must be a member of the route's VNI and, when it has
+// allowed_prefixes, the prefix must be within one of them. The peer is the
+// authenticated identity of the sender, so re-exported routes are checked
+// against the relaying peer. Peers missing from cfg may announce nothing:
+// without mTLS or a PSK their node_id is only a claim, so any sender could
+// pick an unconfigured ID to escape the policy.
+func AuthorizeRoute(cfg *config.Config, r Route) error {
+	peer, ok := cfg.FindPeer(r.PeerID)
+	if !ok {
+		return fmt.Errorf("%w %s", errUnknownPeer, r.PeerID)
+	}
+	if !peer.HasVNI(int(r.VNI)) {
+		return fmt.Errorf("%w: peer %s is not a member of VNI %d", errVNINotAllowed, r.PeerID, r.VNI)
+	}
+	if len(peer.AllowedPrefixes) == 0 {
+		return nil
+	}
+	prefix, err := netip.ParsePrefix(r.Prefix)
+	if err != nil {
+		return fmt.Errorf("%w %q", errInvalidPrefix, r.Prefix)
+	}
+	prefix = prefix.Masked()
+	for _, a := range peer.AllowedPrefixes {
+		allowed, err := netip.ParsePrefix(a)
+		if err != nil {
 			continue
 		}
-		route := Route{
-			Prefix:       r.Prefix,
-			NextHop:      r.NextHop,
-			Metric:       r.Metric,
-			LeaseSeconds: r.LeaseSeconds,
-			Tags:         r.Tags,
-			VNI:          r.Vni,
-			PeerID:       peerID,
+		if allowed.Bits() <= prefix.Bits() && allowed.Masked().Contains(prefix.Addr()) {
+			return nil
 		}
-		s.routeTable.Add(route)
-		out = append(out, route)
 	}
-	return out
+	return fmt.Errorf("%w: %s is outside the allowed_prefixes of peer %s", errPrefixNotAllowed, r.Prefix, r.PeerID)
+}
+
+// routeFromPB validates a route received from peerID and converts it to its
+// table form. Routes whose path already contains localID are rejected, which
+// stops re-exported routes from looping back to a node that has seen them.
+// Announcements from peers that predate originator/path tracking are treated
+// as originated by peerID.
+func routeFromPB(r *pb.Route, peerID, localID string) (Route, error) {
+	if _, _, err := net.ParseCIDR(r.Prefix); err != nil {
+		return Route{}, fmt.Errorf("%w %q", errInvalidPrefix, r.Prefix)
+	}
+	if r.NextHop != "" && net.ParseIP(r.NextHop) == nil {
+		return Route{}, fmt.Errorf("%w %q", errInvalidNextHop, r.NextHop)
+	}
+	if r.NextHop != "" && isIPv6(r.NextHop) != isIPv6(r.Prefix) {
+		return Route{}, fmt.Errorf("%w %q: not of the address family of %s", errInvalidNextHop, r.NextHop, r.Prefix)
+	}
+
+	originator := r.OriginatorId
+	path := r.Path
+	if originator == "" {
+		originator = peerID
+	}
+	if len(path) == 0 {
+		path = []string{peerID}
+	}
+	if originator == localID || slices.Contains(path, localID) {
+		return Route{}, fmt.Errorf("%w: path %v already contains %q", errRoutingLoop, path, localID)
+	}
+
+	return Route{
+		Prefix:       r.Prefix,
+		NextHop:      r.NextHop,
+		Metric:       r.Metric,
+		LeaseSeconds: r.LeaseSeconds,
+		Tags:         r.Tags,
+		VNI:          r.Vni,
+		PeerID:       peerID,
+		OriginatorID: originator,
+		Path:         path,
+	}, nil
+}
+
+// rejectedFromPB returns the identity of an announcement that failed
+// validation, for RouteTable.Reject.
+func rejectedFromPB(r *pb.Route, peerID string) Route {
+	return Route{Prefix: r.Prefix, NextHop: r.NextHop, VNI: r.Vni, PeerID: peerID}
 }
 
 // ExchangeState implements the ExchangeState RPC.
 // Called when a peer connects to perform initial state synchronization.
 func (s *Server) ExchangeState(ctx context.Context, req *pb.StateRequest) (*pb.StateResponse, error) {
-	peerID, err := s.resolvePeerID(ctx, req.NodeId)
+	cfg := s.cfg.Load()
+	peerID, err := s.resolvePeerID(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkTopology(peerID); err != nil {
+		return nil, err
+	}
+
+	if err := s.getLimits().admitAnnouncement(cfg, peerID); err != nil {
+		return nil, err
+	}
 
 	s.logger.Info("received state exchange request",
 		"peer_id", peerID,
 		"route_count", len(req.Routes),
 	)
 
+	s.mu.Lock()
+	if secs := req.GetCapabilities().GetGracefulRestartSeconds(); secs > 0 {
+		s.restartTimes[peerID] = time.Duration(secs) * time.Second
+	} else {
+		delete(s.restartTimes, peerID)
+	}
+	s.mu.Unlock()
+
 	// Process incoming routes from the peer before returning our current view.
-	incomingRoutes := s.ingestRoutes(req.Routes, peerID)
+	incomingRoutes, err := s.ingestRoutes(req.Routes, peerID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Invoke callback if set
 	s.mu.RLock()
 	callback := s.onRoutesReceived
+	withdrawn := s.onRoutesWithdrawn
 	s.mu.RUnlock()
 	if callback != nil && len(incomingRoutes) > 0 {
 		callback(incomingRoutes)
 	}
 
+	// A StateRequest carries the peer's full route set: after a graceful
+	// restart, the routes kept for it that it no longer announces are gone.
+	if stale := s.routeTable.RemoveStaleByPeer(peerID); len(stale) > 0 {
+		s.logger.Info("removed stale routes not re-announced after restart",
+			"peer_id", peerID, "count", len(stale))
+		if withdrawn != nil {
+			withdrawn(stale)
+		}
+	}
+
 	s.logger.Info("processed peer routes",
 		"peer_id", peerID,
 		"imported_count", len(incomingRoutes),
 	)
 
-	// Return our current routes to the peer
-	ourRoutes := s.getExportableRoutes()
-	pbRoutes := make([]*pb.Route, 0, len(ourRoutes))
-	for _, r := range ourRoutes {
-		pbRoutes = append(pbRoutes, &pb.Route{
-			Prefix:       r.Prefix,
-			NextHop:      r.NextHop,
-			Metric:       r.Metric,
-			LeaseSeconds: r.LeaseSeconds,
-			Tags:         r.Tags,
-			Vni:          r.VNI,
-		})
+	// Return our current routes to the peer (never echoing its own routes back).
+	reachable, nextHops := s.relayInfoFor(peerID)
+	resp := &pb.StateResponse{
+		NodeId:         cfg.Node.ID,
+		Routes:         routesForPeer(cfg, s.getExportableRoutes(), peerID),
+		TimestampMs:    time.Now().UnixMilli(),
+		Accepted:       true,
+		ReachablePeers: reachable,
+		NextHops:       nextHops,
 	}
+	if err := s.psk.sign(peerID, resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to sign response: %v", err)
+	}
+	return resp, nil
+}
 
-	return &pb.StateResponse{
-		NodeId:      s.cfg.Node.ID,
-		Routes:      pbRoutes,
-		TimestampMs: time.Now().UnixMilli(),
-		Accepted:    true,
-	}, nil
+// relayInfoFor returns the peers this node can relay to on behalf of peerID,
+// and its overlay next-hops, when transit through this node is allowed for it.
+func (s *Server) relayInfoFor(peerID string) ([]string, []*pb.OverlayNextHop) {
+	cfg := s.cfg.Load()
+	s.mu.RLock()
+	provider := s.relayInfo
+	s.mu.RUnlock()
+	if provider == nil || !cfg.Topology.TransitEnabled() || !cfg.Topology.IsTransitPeer(peerID) {
+		return nil, nil
+	}
+
+	info := provider()
+	var reachable []string
+	for _, p := range info.ReachablePeers {
+		if p != peerID && cfg.Topology.IsTransitPeer(p) {
+			reachable = append(reachable, p)
+		}
+	}
+	nextHops := make([]*pb.OverlayNextHop, 0, len(info.NextHops))
+	for vni, hops := range info.NextHops {
+		// IPv6 first: peers that keep a single next-hop per VNI keep the
+		// last one, the IPv4 next-hop they used before dual-stack.
+		hops = slices.Clone(hops)
+		slices.SortStableFunc(hops, func(a, b string) int { return compareBool(!isIPv6(a), !isIPv6(b)) })
+		for _, nh := range hops {
+			nextHops = append(nextHops, &pb.OverlayNextHop{Vni: vni, NextHop: nh})
+		}
+	}
+	return reachable, nextHops
 }
 
 // AnnounceRoutes implements the AnnounceRoutes RPC.
 // Called when a peer announces new or updated routes.
 func (s *Server) AnnounceRoutes(ctx context.Context, req *pb.RouteAnnouncement) (*pb.RouteAck, error) {
-	peerID, err := s.resolvePeerID(ctx, req.NodeId)
+	peerID, err := s.resolvePeerID(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkTopology(peerID); err != nil {
+		return nil, err
+	}
+	if err := s.getLimits().admitAnnouncement(s.cfg.Load(), peerID); err != nil {
+		return nil, err
+	}
 
 	s.logger.Debug("received route announcement",
 		"peer_id", peerID,
@@ -453,7 +1024,10 @@ func (s *Server) AnnounceRoutes(ctx context.Context, req *pb.RouteAnnouncement)
 	)
 
 	// Validate and store incoming routes.
-	incomingRoutes := s.ingestRoutes(req.Routes, peerID)
+	incomingRoutes, err := s.ingestRoutes(req.Routes, peerID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Invoke callback if set
 	s.mu.RLock()
@@ -477,21 +1051,31 @@ func (s *Server) AnnounceRoutes(ctx context.Context, req *pb.RouteAnnouncement)
 // WithdrawRoutes implements the WithdrawRoutes RPC.
 // Called when a peer withdraws routes.
 func (s *Server) WithdrawRoutes(ctx context.Context, req *pb.RouteWithdrawal) (*pb.RouteAck, error) {
-	peerID, err := s.resolvePeerID(ctx, req.NodeId)
+	peerID, err := s.resolvePeerID(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	s.logger.Info("received route withdrawal",
 		"peer_id", peerID,
+		"route_count", len(req.Routes),
 		"prefix_count", len(req.Prefixes),
 	)
 
-	// Remove the withdrawn prefixes for this peer (across VNIs) and collect them
-	// so the kernel routes can be deleted via the callback.
+	// Remove the withdrawn routes of this peer and collect them so the kernel
+	// routes can be deleted via the callback. Nodes that predate per-VNI
+	// withdrawals only send prefixes, removed across VNIs.
 	var withdrawn []Route
-	for _, prefix := range req.Prefixes {
-		withdrawn = append(withdrawn, s.routeTable.RemoveByPrefixPeer(prefix, peerID)...)
+	if len(req.Routes) > 0 {
+		for _, r := range req.Routes {
+			if route, ok := s.routeTable.RemoveRoute(r.Vni, r.Prefix, peerID); ok {
+				withdrawn = append(withdrawn, route)
+			}
+		}
+	} else {
+		for _, prefix := range req.Prefixes {
+			withdrawn = append(withdrawn, s.routeTable.RemoveByPrefixPeer(prefix, peerID)...)
+		}
 	}
 
 	s.mu.RLock()
@@ -520,14 +1104,24 @@ func (s *Server) Keepalive(stream grpc.BidiStreamingServer[pb.KeepaliveRequest,
 			return err
 		}
 
+		peerID, err := s.resolvePeerID(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if err := s.checkTopology(peerID); err != nil {
+			return err
+		}
+
 		s.logger.Debug("received keepalive",
-			"peer_id", req.NodeId,
+			"peer_id", peerID,
 			"sequence", req.Sequence,
 		)
 
-		// Send response
+		// Send response, piggybacking the relay information so peers learn
+		// relay candidates without a full state exchange.
+		reachable, nextHops := s.relayInfoFor(peerID)
 		resp := &pb.KeepaliveResponse{
-			NodeId:      s.cfg.Node.ID,
+			NodeId:      s.cfg.Load().Node.ID,
 			Sequence:    req.Sequence,
 			TimestampMs: time.Now().UnixMilli(),
 			Health: &pb.PeerHealth{
@@ -535,6 +1129,11 @@ func (s *Server) Keepalive(stream grpc.BidiStreamingServer[pb.KeepaliveRequest,
 				RouteCount:    uint32(len(s.routeTable.All())),
 				UptimeSeconds: uint64(time.Since(s.startTime).Seconds()),
 			},
+			ReachablePeers: reachable,
+			NextHops:       nextHops,
+		}
+		if err := s.psk.sign(peerID, resp); err != nil {
+			return status.Errorf(codes.Internal, "failed to sign response: %v", err)
 		}
 
 		if err := stream.Send(resp); err != nil {
@@ -544,9 +1143,17 @@ func (s *Server) Keepalive(stream grpc.BidiStreamingServer[pb.KeepaliveRequest,
 }
 
 // getExportableRoutes returns routes that should be exported to peers.
-// This would typically filter based on routing policies.
+// When an export provider is set it is authoritative (it resolves next-hops
+// and applies the topology's re-advertisement rules).
 func (s *Server) getExportableRoutes() []Route {
-	// For now, return all routes not learned from peers (i.e., local routes)
+	s.mu.RLock()
+	provider := s.exportRoutes
+	s.mu.RUnlock()
+	if provider != nil {
+		return provider()
+	}
+
+	// Fallback: all routes not learned from peers (i.e., local routes).
 	all := s.routeTable.All()
 	exportable := make([]Route, 0)
 	for _, r := range all {
@@ -557,14 +1164,61 @@ func (s *Server) getExportableRoutes() []Route {
 	return exportable
 }
 
+// routesForPeer converts routes to their wire form for a given peer. Local
+// routes (empty PeerID) are sent to every peer with this node as originator.
+// Re-exported routes have this node appended to their path and are filtered
+// per destination:
+//   - split horizon: never sent back to the peer they were learned from, nor
+//     to any node already on their path;
+//   - transit policy: only sent to peers allowed by transit_policy.
+func routesForPeer(cfg *config.Config, routes []Route, peerID string) []*pb.Route {
+	localID := cfg.Node.ID
+	out := make([]*pb.Route, 0, len(routes))
+	for _, r := range routes {
+		originator := localID
+		path := []string{localID}
+		if r.PeerID != "" {
+			if r.PeerID == peerID || slices.Contains(r.Path, peerID) || !cfg.Topology.IsTransitPeer(peerID) {
+				continue
+			}
+			originator = r.OriginatorID
+			if originator == "" {
+				originator = r.PeerID
+			}
+			path = append(slices.Clone(r.Path), localID)
+		}
+		out = append(out, &pb.Route{
+			Prefix:       r.Prefix,
+			NextHop:      r.NextHop,
+			Metric:       r.Metric,
+			LeaseSeconds: r.LeaseSeconds,
+			Tags:         r.Tags,
+			Vni:          r.VNI,
+			OriginatorId: originator,
+			Path:         path,
+		})
+	}
+	return out
+}
+
 // Client manages outbound connections to peer control-plane servers.
 type Client struct {
-	cfg        *config.Config
+	cfg        atomic.Pointer[config.Config]
 	routeTable *RouteTable
 	logger     *slog.Logger
 
 	// Callback invoked when routes are learned from a peer (to install them).
 	onRoutesReceived func(routes []Route)
+	// Callback invoked when a peer withdraws routes on its route stream.
+	onRoutesWithdrawn func(routes []Route)
+	// Reports whether a peer watches this node's routes.
+	isWatching func(peerID string) bool
+	// Callback invoked when a peer's dead timer fires (keepalive liveness).
+	onPeerDown func(peerID string)
+	// Optional Prometheus metrics (per-peer link quality).
+	metrics *observability.Metrics
+	// Optional per-peer limits (max_prefixes), shared with the Server.
+	limits *Limits
 
 	mu    sync.RWMutex
 	conns map[string]*peerConn // key: peer ID
@@ -578,6 +1232,20 @@ func (c *Client) SetRoutesReceivedCallback(fn func(routes []Route)) {
 	c.onRoutesReceived = fn
 }
 
+// SetLimits sets the per-peer limits enforced on the routes received from
+// peers (shared with the Server).
+func (c *Client) SetLimits(l *Limits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = l
+}
+
+func (c *Client) getLimits() *Limits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.limits
+}
+
 // peerConn represents a connection to a peer.
 type peerConn struct {
 	peerID   string
@@ -586,16 +1254,42 @@ type peerConn struct {
 	client   pb.NNetManClient
 	healthy  bool
 	lastSeen time.Time
+
+	// Stops the peer's keepalive and route stream loops (see runKeepalive
+	// and runWatch).
+	cancel context.CancelFunc
+	// Closes the open WatchRoutes stream; nil while none is open.
+	watchCancel context.CancelFunc
+	// Last link statistics published by the keepalive loop.
+	link *observability.LinkQuality
+
+	// Relay information from the peer's last StateResponse: the peers it
+	// can relay to and its overlay next-hops per VNI.
+	reachable []string
+	nextHops  OverlayNextHops
+}
+
+// setRelayInfo records the relay information carried by a StateResponse or
+// KeepaliveResponse. Must be called with c.mu held.
+func (p *peerConn) setRelayInfo(reachable []string, nextHops []*pb.OverlayNextHop) {
+	p.reachable = reachable
+	p.nextHops = make(OverlayNextHops, len(nextHops))
+	for _, nh := range nextHops {
+		if net.ParseIP(nh.NextHop) != nil {
+			p.nextHops.Add(nh.Vni, nh.NextHop)
+		}
+	}
 }
 
 // NewClient creates a new control plane client.
 func NewClient(cfg *config.Config, routeTable *RouteTable, logger *slog.Logger) *Client {
-	return &Client{
-		cfg:        cfg,
+	c := &Client{
 		routeTable: routeTable,
 		logger:     logger,
 		conns:      make(map[string]*peerConn),
 	}
+	c.cfg.Store(cfg)
+	return c
 }
 
 // ConnectToPeers establishes connections to all configured peers that are not
@@ -603,8 +1297,9 @@ func NewClient(cfg *config.Config, routeTable *RouteTable, logger *slog.Logger)
 // missing on a previous attempt.
 func (c *Client) ConnectToPeers() error {
 	var firstErr error
-	// Peers are resolved version-aware (root 'peers:' for v2, 'overlay.peers' for v1).
-	for _, peer := range c.cfg.GetPeers() {
+	// Peers are resolved version-aware (root 'peers:' for v2, 'overlay.peers' for
+	// v1) and topology-aware (a hub-spoke spoke only dials hubs).
+	for _, peer := range c.cfg.Load().GetActivePeers() {
 		if err := c.connectPeer(peer); err != nil {
 			c.logger.Warn("failed to connect to peer",
 				"peer_id", peer.ID,
@@ -624,6 +1319,7 @@ func (c *Client) ConnectToPeers() error {
 // grpc.ClientConn reconnects transparently with backoff after transient
 // failures, so this only needs to create the connection once per peer.
 func (c *Client) connectPeer(peer config.PeerConfig) error {
+	cfg := c.cfg.Load()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -632,16 +1328,16 @@ func (c *Client) connectPeer(peer config.PeerConfig) error {
 		return nil
 	}
 
-	addr := fmt.Sprintf("%s:%d", peer.Endpoint.Address, c.cfg.Security.ControlPlane.Listen.Port)
+	addr := fmt.Sprintf("%s:%d", peer.Endpoint.Address, cfg.Security.ControlPlane.Listen.Port)
 
 	c.logger.Debug("connecting to peer", "peer_id", peer.ID, "address", addr)
 
 	// Configure TLS if enabled, otherwise use insecure credentials
 	var transportCreds credentials.TransportCredentials
-	if c.cfg.Security.ControlPlane.TLS.Enabled {
+	if cfg.Security.ControlPlane.TLS.Enabled {
 		var err error
 		// The peer endpoint address must be present in the peer certificate SANs.
-		transportCreds, err = LoadClientTLSConfig(&c.cfg.Security.ControlPlane.TLS, peer.Endpoint.Address)
+		transportCreds, err = LoadClientTLSConfig(&cfg.Security.ControlPlane.TLS, peer.Endpoint.Address)
 		if err != nil {
 			return fmt.Errorf("failed to load TLS config: %w", err)
 		}
@@ -658,6 +1354,22 @@ func (c *Client) connectPeer(peer config.PeerConfig) error {
 		}),
 	}
 
+	// In PSK mode every request to this peer is signed with its key, and its
+	// answers must be signed with it too.
+	if peer.Auth.Mode == "psk" {
+		key, err := LoadPSK(peer.Auth.PSKRef)
+		if err != nil {
+			return fmt.Errorf("failed to load PSK: %w", err)
+		}
+		opts = append(opts, pskDialOptions(peer.ID, key)...)
+	}
+
+	// A peer pinned to an underlay interface is only reached through it.
+	if via := peer.Endpoint.ViaInterface; via != "" {
+		opts = append(opts, grpc.WithContextDialer(viaInterfaceDialer(via)))
+		c.logger.Debug("binding peer connection to interface", "peer_id", peer.ID, "interface", via)
+	}
+
 	conn, err := grpc.NewClient(addr, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create client for %s: %w", addr, err)
@@ -665,6 +1377,7 @@ func (c *Client) connectPeer(peer config.PeerConfig) error {
 
 	client := pb.NewNNetManClient(conn)
 
+	ctx, cancel := context.WithCancel(context.Background())
 	c.conns[peer.ID] = &peerConn{
 		peerID:   peer.ID,
 		address:  addr,
@@ -672,8 +1385,14 @@ func (c *Client) connectPeer(peer config.PeerConfig) error {
 		client:   client,
 		healthy:  true,
 		lastSeen: time.Now(),
+		cancel:   cancel,
 	}
 
+	// Liveness is tracked over a long-lived Keepalive stream per peer, and
+	// the peer's routes are received over a WatchRoutes stream.
+	go c.runKeepalive(ctx, peer.ID, client, peer.Health.KeepAliveDuration(), peer.Health.DeadAfterDuration())
+	go c.runWatch(ctx, peer.ID, client)
+
 	c.logger.Info("connected to peer", "peer_id", peer.ID, "address", addr)
 	return nil
 }
@@ -684,17 +1403,97 @@ func (c *Client) Disconnect() {
 	defer c.mu.Unlock()
 
 	for id, pc := range c.conns {
-		if pc.conn != nil {
-			pc.conn.Close()
-		}
+		pc.close()
 		delete(c.conns, id)
 	}
 
 	c.logger.Info("disconnected from all peers")
 }
 
+// close stops the peer's keepalive loop and closes its connection.
+func (p *peerConn) close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+// SetConfig swaps the configuration and brings the peer sessions in line
+// with it: sessions to peers that are no longer active are closed, peers
+// whose settings (or the control-plane transport) changed are redialled, and
+// new peers are connected. It returns the IDs of the peers whose sessions
+// were closed for good, so the caller can drop the routes learned from them.
+func (c *Client) SetConfig(cfg *config.Config) ([]string, error) {
+	old := c.cfg.Swap(cfg)
+
+	previous := make(map[string]config.PeerConfig)
+	for _, p := range old.GetActivePeers() {
+		previous[p.ID] = p
+	}
+	active := make(map[string]config.PeerConfig)
+	for _, p := range cfg.GetActivePeers() {
+		active[p.ID] = p
+	}
+	transportChanged := !reflect.DeepEqual(old.Security.ControlPlane, cfg.Security.ControlPlane)
+
+	var removed []string
+	c.mu.Lock()
+	for id, pc := range c.conns {
+		p, ok := active[id]
+		if ok && !transportChanged && reflect.DeepEqual(p, previous[id]) {
+			continue
+		}
+		pc.close()
+		delete(c.conns, id)
+		if ok {
+			c.logger.Info("peer settings changed, reconnecting", "peer_id", id)
+			continue
+		}
+		c.logger.Info("peer removed from config, disconnected", "peer_id", id)
+		removed = append(removed, id)
+		if c.metrics != nil {
+			c.metrics.PeerRTT.DeleteLabelValues(id)
+			c.metrics.PeerJitter.DeleteLabelValues(id)
+			c.metrics.PeerLoss.DeleteLabelValues(id)
+		}
+	}
+	c.mu.Unlock()
+	slices.Sort(removed)
+
+	return removed, c.ConnectToPeers()
+}
+
+// ResetPeer closes the session with an active peer. The next
+// ConnectToPeers or ExchangeStateWithPeer establishes it again.
+func (c *Client) ResetPeer(peerID string) error {
+	if !slices.ContainsFunc(c.cfg.Load().GetActivePeers(), func(p config.PeerConfig) bool { return p.ID == peerID }) {
+		return fmt.Errorf("unknown peer %q", peerID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pc, ok := c.conns[peerID]; ok {
+		pc.close()
+		delete(c.conns, peerID)
+	}
+	c.logger.Info("peer session reset", "peer_id", peerID)
+	return nil
+}
+
+// capabilities returns the optional features this node advertises to peers.
+func capabilities(cfg *config.Config) *pb.Capabilities {
+	caps := &pb.Capabilities{}
+	if cfg.GracefulRestart.Enabled {
+		caps.GracefulRestartSeconds = uint32(cfg.GracefulRestart.RestartTime() / time.Second)
+	}
+	return caps
+}
+
 // ExchangeStateWithPeers performs initial state exchange with all connected peers.
 func (c *Client) ExchangeStateWithPeers(ctx context.Context, localRoutes []Route) error {
+	cfg := c.cfg.Load()
 	c.mu.RLock()
 	peers := make([]*peerConn, 0, len(c.conns))
 	for _, pc := range c.conns {
@@ -704,26 +1503,13 @@ func (c *Client) ExchangeStateWithPeers(ctx context.Context, localRoutes []Route
 	}
 	c.mu.RUnlock()
 
-	// Convert routes to proto
-	pbRoutes := make([]*pb.Route, 0, len(localRoutes))
-	for _, r := range localRoutes {
-		pbRoutes = append(pbRoutes, &pb.Route{
-			Prefix:       r.Prefix,
-			NextHop:      r.NextHop,
-			Metric:       r.Metric,
-			LeaseSeconds: r.LeaseSeconds,
-			Tags:         r.Tags,
-			Vni:          r.VNI,
-		})
-	}
-
-	req := &pb.StateRequest{
-		NodeId:      c.cfg.Node.ID,
-		Routes:      pbRoutes,
-		TimestampMs: time.Now().UnixMilli(),
-	}
-
 	for _, pc := range peers {
+		req := &pb.StateRequest{
+			NodeId:       cfg.Node.ID,
+			Routes:       routesForPeer(cfg, localRoutes, pc.peerID),
+			TimestampMs:  time.Now().UnixMilli(),
+			Capabilities: capabilities(cfg),
+		}
 		if err := c.exchangeWithPeer(ctx, pc, req); err != nil {
 			c.logger.Warn("failed to exchange state with peer",
 				"peer_id", pc.peerID,
@@ -736,6 +1522,35 @@ func (c *Client) ExchangeStateWithPeers(ctx context.Context, localRoutes []Route
 	return nil
 }
 
+// ExchangeStateWithPeer connects to a single active peer, if needed, and
+// exchanges state with it.
+func (c *Client) ExchangeStateWithPeer(ctx context.Context, peerID string, localRoutes []Route) error {
+	cfg := c.cfg.Load()
+	i := slices.IndexFunc(cfg.GetActivePeers(), func(p config.PeerConfig) bool { return p.ID == peerID })
+	if i < 0 {
+		return fmt.Errorf("unknown peer %q", peerID)
+	}
+	if err := c.connectPeer(cfg.GetActivePeers()[i]); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	pc := c.conns[peerID]
+	c.mu.RUnlock()
+
+	req := &pb.StateRequest{
+		NodeId:       cfg.Node.ID,
+		Routes:       routesForPeer(cfg, localRoutes, peerID),
+		TimestampMs:  time.Now().UnixMilli(),
+		Capabilities: capabilities(cfg),
+	}
+	if err := c.exchangeWithPeer(ctx, pc, req); err != nil {
+		c.markPeerUnhealthy(peerID)
+		return err
+	}
+	return nil
+}
+
 // exchangeWithPeer performs state exchange with a single peer.
 func (c *Client) exchangeWithPeer(ctx context.Context, pc *peerConn, req *pb.StateRequest) error {
 	rpcCtx, cancel := context.WithTimeout(ctx, peerRPCTimeout)
@@ -747,19 +1562,11 @@ func (c *Client) exchangeWithPeer(ctx context.Context, pc *peerConn, req *pb.Sta
 	}
 
 	// Store received routes and install them in the kernel via the callback.
-	received := make([]Route, 0, len(resp.Routes))
-	for _, r := range resp.Routes {
-		route := Route{
-			Prefix:       r.Prefix,
-			NextHop:      r.NextHop,
-			Metric:       r.Metric,
-			LeaseSeconds: r.LeaseSeconds,
-			Tags:         r.Tags,
-			VNI:          r.Vni,
-			PeerID:       resp.NodeId,
-		}
-		c.routeTable.Add(route)
-		received = append(received, route)
+	// They are attributed to the peer dialed (whose identity TLS verifies),
+	// not to the node ID it claims.
+	received, err := c.ingestRoutes(resp.Routes, pc.peerID)
+	if err != nil {
+		c.logger.Warn("refusing routes from peer", "peer_id", pc.peerID, "error", err)
 	}
 
 	c.mu.RLock()
@@ -781,18 +1588,57 @@ func (c *Client) exchangeWithPeer(ctx context.Context, pc *peerConn, req *pb.Sta
 	if p, ok := c.conns[pc.peerID]; ok {
 		p.healthy = true
 		p.lastSeen = time.Now()
+		p.setRelayInfo(resp.ReachablePeers, resp.NextHops)
 	}
 	c.mu.Unlock()
 
 	return nil
 }
 
-// AnnounceRoutes sends route announcements to all connected peers.
+// ingestRoutes validates and stores routes received from a peer and returns
+// the accepted ones (see Server.ingestRoutes). The routes of a peer torn down
+// for exceeding its max_prefixes are refused.
+func (c *Client) ingestRoutes(pbRoutes []*pb.Route, peerID string) ([]Route, error) {
+	limits := c.getLimits()
+	if limits.tornDown(peerID) {
+		return nil, fmt.Errorf("peer %s is torn down for exceeding max_prefixes", peerID)
+	}
+
+	cfg := c.cfg.Load()
+	out := make([]Route, 0, len(pbRoutes))
+	for _, r := range pbRoutes {
+		route, err := admitRoute(cfg, r, peerID)
+		if err != nil {
+			c.logger.Warn("rejecting route", "prefix", r.Prefix, "vni", r.Vni, "peer", peerID, "error", err)
+			c.routeTable.Reject(rejectedFromPB(r, peerID), err.Error())
+			countRejected(c.getMetrics(), peerID, err)
+			continue
+		}
+		c.routeTable.Add(route)
+		out = append(out, route)
+	}
+
+	if removed, tornDown := limits.enforceMaxPrefixes(cfg, c.routeTable, peerID); tornDown {
+		c.mu.RLock()
+		callback := c.onRoutesWithdrawn
+		c.mu.RUnlock()
+		if callback != nil && len(removed) > 0 {
+			callback(removed)
+		}
+		return nil, fmt.Errorf("peer %s exceeded max_prefixes", peerID)
+	}
+	return out, nil
+}
+
+// AnnounceRoutes sends route announcements to all connected peers, except
+// those watching this node's routes (see SetRouteWatcherFunc).
 func (c *Client) AnnounceRoutes(ctx context.Context, routes []Route) error {
+	cfg := c.cfg.Load()
 	c.mu.RLock()
+	isWatching := c.isWatching
 	peers := make([]*peerConn, 0, len(c.conns))
 	for _, pc := range c.conns {
-		if pc.healthy && pc.client != nil {
+		if pc.healthy && pc.client != nil && (isWatching == nil || !isWatching(pc.peerID)) {
 			peers = append(peers, pc)
 		}
 	}
@@ -802,26 +1648,12 @@ func (c *Client) AnnounceRoutes(ctx context.Context, routes []Route) error {
 		return nil
 	}
 
-	// Convert routes to proto
-	pbRoutes := make([]*pb.Route, 0, len(routes))
-	for _, r := range routes {
-		pbRoutes = append(pbRoutes, &pb.Route{
-			Prefix:       r.Prefix,
-			NextHop:      r.NextHop,
-			Metric:       r.Metric,
-			LeaseSeconds: r.LeaseSeconds,
-			Tags:         r.Tags,
-			Vni:          r.VNI,
-		})
-	}
-
-	req := &pb.RouteAnnouncement{
-		NodeId:      c.cfg.Node.ID,
-		Routes:      pbRoutes,
-		TimestampMs: time.Now().UnixMilli(),
-	}
-
 	for _, pc := range peers {
+		req := &pb.RouteAnnouncement{
+			NodeId:      cfg.Node.ID,
+			Routes:      routesForPeer(cfg, routes, pc.peerID),
+			TimestampMs: time.Now().UnixMilli(),
+		}
 		if err := c.announceToSinglePeer(ctx, pc, req); err != nil {
 			c.logger.Warn("failed to announce routes to peer",
 				"peer_id", pc.peerID,
@@ -865,8 +1697,13 @@ func (c *Client) announceToSinglePeer(ctx context.Context, pc *peerConn, req *pb
 	return nil
 }
 
-// WithdrawRoutes sends route withdrawal to all connected peers.
-func (c *Client) WithdrawRoutes(ctx context.Context, prefixes []string) error {
+// WithdrawRoutes sends route withdrawal to all connected peers. Routes are
+// identified by VNI and prefix; their prefixes are also sent for peers that
+// predate per-VNI withdrawals, which remove a prefix from every VNI. A prefix
+// this node still exports in another VNI (in exported) is left out of that
+// list, so those peers keep it until its lease expires instead of losing a
+// route that is still valid.
+func (c *Client) WithdrawRoutes(ctx context.Context, routes, exported []Route) error {
 	c.mu.RLock()
 	peers := make([]*peerConn, 0, len(c.conns))
 	for _, pc := range c.conns {
@@ -881,10 +1718,19 @@ func (c *Client) WithdrawRoutes(ctx context.Context, prefixes []string) error {
 	}
 
 	req := &pb.RouteWithdrawal{
-		NodeId:      c.cfg.Node.ID,
-		Prefixes:    prefixes,
+		NodeId:      c.cfg.Load().Node.ID,
 		TimestampMs: time.Now().UnixMilli(),
 	}
+	stillExported := make(map[string]bool, len(exported))
+	for _, r := range exported {
+		stillExported[r.Prefix] = true
+	}
+	for _, r := range routes {
+		req.Routes = append(req.Routes, &pb.Route{Prefix: r.Prefix, Vni: r.VNI})
+		if !stillExported[r.Prefix] && !slices.Contains(req.Prefixes, r.Prefix) {
+			req.Prefixes = append(req.Prefixes, r.Prefix)
+		}
+	}
 
 	for _, pc := range peers {
 		rpcCtx, cancel := context.WithTimeout(ctx, peerRPCTimeout)
@@ -949,55 +1795,57 @@ func (c *Client) IsHealthy() bool {
 	return false
 }
 
-// CheckPeerHealth performs health checks on all peers.
-// Returns a list of peer IDs that became unhealthy during this check.
-func (c *Client) CheckPeerHealth(ctx context.Context) ([]string, error) {
+// HealthyPeers returns the IDs of the peers with a healthy session.
+func (c *Client) HealthyPeers() []string {
 	c.mu.RLock()
-	peers := make([]*peerConn, 0, len(c.conns))
-	for _, pc := range c.conns {
-		if pc.client != nil {
-			peers = append(peers, pc)
+	defer c.mu.RUnlock()
+
+	var ids []string
+	for id, pc := range c.conns {
+		if pc.healthy {
+			ids = append(ids, id)
 		}
 	}
-	c.mu.RUnlock()
+	slices.Sort(ids)
+	return ids
+}
 
-	var newlyUnhealthy []string
+// UnhealthyPeers returns the IDs of the connected peers currently marked
+// unhealthy.
+func (c *Client) UnhealthyPeers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	for _, pc := range peers {
-		// Use ExchangeState as a lightweight liveness probe (no routes sent).
-		req := &pb.StateRequest{
-			NodeId:      c.cfg.Node.ID,
-			Routes:      nil, // Empty for health check
-			TimestampMs: time.Now().UnixMilli(),
+	var ids []string
+	for id, pc := range c.conns {
+		if !pc.healthy {
+			ids = append(ids, id)
 		}
+	}
+	slices.Sort(ids)
+	return ids
+}
 
-		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		_, err := pc.client.ExchangeState(ctx, req)
-		cancel()
+// FindRelay looks for a healthy peer that reported it can still reach
+// peerID. It returns the relay's ID and its overlay next-hops per VNI. The
+// lowest peer ID wins so every node picks the same relay deterministically.
+func (c *Client) FindRelay(peerID string) (string, OverlayNextHops, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-		c.mu.Lock()
-		if p, ok := c.conns[pc.peerID]; ok {
-			if err != nil {
-				// Treat various connection errors as peer down
-				code := status.Code(err)
-				if code == codes.Unavailable || code == codes.DeadlineExceeded ||
-					code == codes.Canceled || code == codes.Unknown {
-					wasHealthy := p.healthy
-					p.healthy = false
-					c.logger.Warn("peer unreachable", "peer_id", pc.peerID, "error", err, "grpc_code", code.String())
-					if wasHealthy {
-						newlyUnhealthy = append(newlyUnhealthy, pc.peerID)
-					}
-				}
-			} else {
-				p.healthy = true
-				p.lastSeen = time.Now()
-			}
+	var best *peerConn
+	for id, pc := range c.conns {
+		if id == peerID || !pc.healthy || len(pc.nextHops) == 0 || !slices.Contains(pc.reachable, peerID) {
+			continue
+		}
+		if best == nil || id < best.peerID {
+			best = pc
 		}
-		c.mu.Unlock()
 	}
-
-	return newlyUnhealthy, nil
+	if best == nil {
+		return "", nil, false
+	}
+	return best.peerID, maps.Clone(best.nextHops), true
 }
 
 // GetPeerStatuses returns the status of all peers.
@@ -1008,8 +1856,8 @@ func (c *Client) GetPeerStatuses() map[string]observability.PeerStatus {
 
 	result := make(map[string]observability.PeerStatus)
 
-	// Start with configured peers
-	for _, peer := range c.cfg.GetPeers() {
+	// Start with the peers this node actually peers with under the topology.
+	for _, peer := range c.cfg.Load().GetActivePeers() {
 		ps := observability.PeerStatus{
 			ID:       peer.ID,
 			Endpoint: peer.Endpoint.Address,
@@ -1019,6 +1867,7 @@ func (c *Client) GetPeerStatuses() map[string]observability.PeerStatus {
 
 		// Update with actual connection status
 		if pc, ok := c.conns[peer.ID]; ok {
+			ps.Link = pc.link
 			if pc.healthy {
 				ps.Status = "healthy"
 				if !pc.lastSeen.IsZero() {
@@ -1029,6 +1878,8 @@ func (c *Client) GetPeerStatuses() map[string]observability.PeerStatus {
 			}
 		}
 
+		ps.Limits = c.limits.peerStatus(c.cfg.Load(), peer.ID)
+
 		result[peer.ID] = ps
 	}
 
@@ -1037,6 +1888,9 @@ func (c *Client) GetPeerStatuses() map[string]observability.PeerStatus {
 		if route.PeerID != "" {
 			if ps, ok := result[route.PeerID]; ok {
 				ps.Routes++
+				if route.RelayedVia != "" {
+					ps.RelayedVia = route.RelayedVia
+				}
 				result[route.PeerID] = ps
 			}
 		}
@@ -1051,7 +1905,7 @@ func (c *Client) GetRouteStats() observability.RouteStats {
 	stats := observability.RouteStats{}
 
 	// Exported routes from config, summed across all overlays (v1 and v2).
-	for _, o := range c.cfg.GetOverlays() {
+	for _, o := range c.cfg.Load().GetOverlays() {
 		stats.Exported += len(o.Routing.Export.Networks)
 	}
 
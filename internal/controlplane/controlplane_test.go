@@ -2,38 +2,181 @@ package controlplane
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
 	pb "github.com/nishisan-dev/n-netman/api/v1"
 	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/observability"
 )
 
 func TestResolvePeerID_NoMTLS(t *testing.T) {
 	s := NewServer(&config.Config{}, NewRouteTable(), slog.Default())
 
-	if _, err := s.resolvePeerID(context.Background(), ""); err == nil {
+	if _, err := s.resolvePeerID(context.Background(), &pb.StateRequest{}); err == nil {
 		t.Fatal("expected error for empty node_id without mTLS")
 	}
-	id, err := s.resolvePeerID(context.Background(), "host-a")
+	id, err := s.resolvePeerID(context.Background(), &pb.StateRequest{NodeId: "host-a"})
 	if err != nil || id != "host-a" {
 		t.Fatalf("expected (host-a, nil), got (%q, %v)", id, err)
 	}
 }
 
 func TestIngestRoutes_RejectsInvalid(t *testing.T) {
-	s := NewServer(&config.Config{}, NewRouteTable(), slog.Default())
+	s := NewServer(&config.Config{Version: 2, Peers: []config.PeerConfig{{ID: "host-a"}}}, NewRouteTable(), slog.Default())
 	in := []*pb.Route{
 		{Prefix: "10.0.0.0/24", NextHop: "10.0.0.1", Vni: 100},
 		{Prefix: "bad-prefix", NextHop: "10.0.0.1"},
 		{Prefix: "10.1.0.0/24", NextHop: "not-an-ip"},
 		{Prefix: "10.2.0.0/24"}, // empty next-hop is allowed
 	}
-	out := s.ingestRoutes(in, "host-a")
+	out, err := s.ingestRoutes(in, "host-a")
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(out) != 2 {
 		t.Fatalf("expected 2 accepted routes (valid prefix+nexthop and empty nexthop), got %d", len(out))
 	}
+	rejected := s.routeTable.Rejected()
+	if len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected routes, got %d", len(rejected))
+	}
+	for _, r := range rejected {
+		if r.Route.PeerID != "host-a" || r.Reason == "" {
+			t.Errorf("rejected route %+v lacks its peer or reason", r)
+		}
+	}
+}
+
+func TestAuthorizeRoute(t *testing.T) {
+	cfg := &config.Config{Version: 2, Peers: []config.PeerConfig{
+		{ID: "a", VNIs: []int{100}, AllowedPrefixes: []string{"10.1.0.0/16", "2001:db8::/32"}},
+		{ID: "b"},
+	}}
+	tests := []struct {
+		name string
+		r    Route
+		want error
+	}{
+		{"member VNI, allowed prefix", Route{PeerID: "a", VNI: 100, Prefix: "10.1.2.0/24"}, nil},
+		{"allowed prefix itself", Route{PeerID: "a", VNI: 100, Prefix: "10.1.0.0/16"}, nil},
+		{"allowed IPv6 prefix", Route{PeerID: "a", VNI: 100, Prefix: "2001:db8:1::/48"}, nil},
+		{"other VNI", Route{PeerID: "a", VNI: 200, Prefix: "10.1.2.0/24"}, errVNINotAllowed},
+		{"outside allowed prefixes", Route{PeerID: "a", VNI: 100, Prefix: "10.2.0.0/24"}, errPrefixNotAllowed},
+		{"wider than allowed", Route{PeerID: "a", VNI: 100, Prefix: "10.0.0.0/8"}, errPrefixNotAllowed},
+		{"unrestricted peer", Route{PeerID: "b", VNI: 200, Prefix: "192.168.0.0/24"}, nil},
+		{"unknown peer", Route{PeerID: "z", VNI: 300, Prefix: "192.168.0.0/24"}, errUnknownPeer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AuthorizeRoute(cfg, tt.r)
+			if (tt.want == nil && err != nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("AuthorizeRoute() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngestRoutes_RejectsUnauthorized(t *testing.T) {
+	cfg := &config.Config{
+		Version: 2,
+		Node:    config.NodeConfig{ID: "local"},
+		Peers:   []config.PeerConfig{{ID: "a", VNIs: []int{100}, AllowedPrefixes: []string{"10.1.0.0/16"}}},
+	}
+	reg := prometheus.NewRegistry()
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+	s.SetMetrics(observability.NewMetrics(reg))
+
+	out, err := s.ingestRoutes([]*pb.Route{
+		{Prefix: "10.1.0.0/24", Vni: 100},
+		{Prefix: "10.1.0.0/24", Vni: 200}, // VNI 200 belongs to other tenants
+		{Prefix: "10.9.0.0/24", Vni: 100},
+		{Prefix: "10.8.0.0/24", Vni: 100},
+	}, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].VNI != 100 || out[0].Prefix != "10.1.0.0/24" {
+		t.Fatalf("accepted = %+v, want only VNI 100 10.1.0.0/24", out)
+	}
+	if n := len(s.routeTable.Rejected()); n != 3 {
+		t.Fatalf("expected 3 rejected routes, got %d", n)
+	}
+
+	counts := make(map[string]float64)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "nnetman_routes_rejected_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			counts[labels["peer_id"]+"/"+labels["reason"]] = m.GetCounter().GetValue()
+		}
+	}
+	if counts["a/vni_not_allowed"] != 1 || counts["a/prefix_not_allowed"] != 2 {
+		t.Fatalf("routes_rejected_total = %v, want a/vni_not_allowed=1 and a/prefix_not_allowed=2", counts)
+	}
+}
+
+func TestAnnounceRoutes_RejectsSpoofedUnknownPeer(t *testing.T) {
+	cfg := &config.Config{
+		Version: 2,
+		Node:    config.NodeConfig{ID: "local"},
+		Peers:   []config.PeerConfig{{ID: "a", VNIs: []int{100}, AllowedPrefixes: []string{"10.1.0.0/16"}}},
+	}
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+	var received []Route
+	s.SetRoutesReceivedCallback(func(routes []Route) { received = append(received, routes...) })
+
+	// Without mTLS or a PSK the node_id is only a claim; an unconfigured ID
+	// must not escape the policy of the configured peers.
+	ack, err := s.AnnounceRoutes(context.Background(), &pb.RouteAnnouncement{
+		NodeId: "spoofed",
+		Routes: []*pb.Route{{Prefix: "10.9.0.0/24", Vni: 200}},
+	})
+	if err != nil {
+		t.Fatalf("AnnounceRoutes: %v", err)
+	}
+	if ack.RoutesProcessed != 0 || len(received) != 0 || len(s.routeTable.GetByPeer("spoofed")) != 0 {
+		t.Fatalf("accepted %d routes (%+v) from an unknown peer", ack.RoutesProcessed, received)
+	}
+	rejected := s.routeTable.Rejected()
+	if len(rejected) != 1 || !strings.Contains(rejected[0].Reason, errUnknownPeer.Error()) {
+		t.Fatalf("rejected = %+v, want the route rejected as from an unknown peer", rejected)
+	}
+}
+
+func TestRouteTable_RejectedClearedOnAdd(t *testing.T) {
+	rt := NewRouteTable()
+	r := Route{Prefix: "10.0.0.0/24", NextHop: "10.0.0.1", VNI: 100, PeerID: "a"}
+	rt.Reject(r, "routing loop")
+	time.Sleep(time.Millisecond)
+	rt.Reject(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a"}, "invalid next-hop")
+
+	if got := rt.Rejected(); len(got) != 2 || got[0].Route.Prefix != "10.1.0.0/24" {
+		t.Fatalf("Rejected() = %+v, want 2 entries, most recent first", got)
+	}
+
+	// Accepting the same route clears its rejection.
+	rt.Add(r)
+	if got := rt.Rejected(); len(got) != 1 || got[0].Route.Prefix != "10.1.0.0/24" {
+		t.Fatalf("Rejected() after Add = %+v, want only 10.1.0.0/24", got)
+	}
 }
 
 func TestRouteTable_CompositeKeyAvoidsCollisions(t *testing.T) {
@@ -107,3 +250,361 @@ func TestRouteTable_ExpireStale(t *testing.T) {
 		t.Fatalf("expected 1 route remaining (local), got %d", got)
 	}
 }
+
+func TestRoutesForPeer_SplitHorizon(t *testing.T) {
+	routes := []Route{
+		{Prefix: "10.0.0.0/24", VNI: 100},              // local
+		{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a"}, // re-advertised from a
+		{Prefix: "10.2.0.0/24", VNI: 100, PeerID: "b"}, // re-advertised from b
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "self"}}
+	got := routesForPeer(cfg, routes, "a")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 routes for peer a (local + b), got %d", len(got))
+	}
+	for _, r := range got {
+		if r.Prefix == "10.1.0.0/24" {
+			t.Fatal("route learned from peer a must not be advertised back to it")
+		}
+	}
+}
+
+func TestCheckTopology_HubSpoke(t *testing.T) {
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "spoke-1"},
+		Topology: config.TopologyConfig{Mode: "hub-spoke", Hubs: []string{"hub-1"}},
+	}
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+
+	if err := s.checkTopology("hub-1"); err != nil {
+		t.Fatalf("expected spoke to accept hub, got %v", err)
+	}
+	if err := s.checkTopology("spoke-2"); err == nil {
+		t.Fatal("expected spoke to reject another spoke")
+	}
+
+	cfg.Node.ID = "hub-1"
+	if err := s.checkTopology("spoke-2"); err != nil {
+		t.Fatalf("expected hub to accept spokes, got %v", err)
+	}
+}
+
+func TestRoutesForPeer_PathAndTransitPolicy(t *testing.T) {
+	cfg := &config.Config{
+		Node: config.NodeConfig{ID: "t"},
+		Topology: config.TopologyConfig{
+			Transit:       "allow",
+			TransitPolicy: config.TransitPolicyConfig{DeniedTransitPeers: []string{"untrusted"}},
+		},
+	}
+	routes := []Route{
+		{Prefix: "10.0.0.0/24", VNI: 100},
+		{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "b", OriginatorID: "a", Path: []string{"a", "b"}},
+	}
+
+	got := routesForPeer(cfg, routes, "c")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 routes for peer c, got %d", len(got))
+	}
+	if got[0].OriginatorId != "t" || !slices.Equal(got[0].Path, []string{"t"}) {
+		t.Fatalf("expected local route originated by t, got originator=%q path=%v", got[0].OriginatorId, got[0].Path)
+	}
+	if got[1].OriginatorId != "a" || !slices.Equal(got[1].Path, []string{"a", "b", "t"}) {
+		t.Fatalf("expected re-exported route path a,b,t, got originator=%q path=%v", got[1].OriginatorId, got[1].Path)
+	}
+	// The stored path must not be mutated by the append.
+	if len(routes[1].Path) != 2 {
+		t.Fatalf("route table path mutated: %v", routes[1].Path)
+	}
+
+	// A peer already on the path does not get the route again.
+	if got := routesForPeer(cfg, routes, "a"); len(got) != 1 {
+		t.Fatalf("expected only the local route for originator a, got %d", len(got))
+	}
+	// Denied transit peers only receive local routes.
+	if got := routesForPeer(cfg, routes, "untrusted"); len(got) != 1 {
+		t.Fatalf("expected only the local route for a denied transit peer, got %d", len(got))
+	}
+}
+
+func TestRouteFromPB_LoopPrevention(t *testing.T) {
+	// Legacy announcement without originator/path: the peer is the originator.
+	r, err := routeFromPB(&pb.Route{Prefix: "10.0.0.0/24", NextHop: "10.0.0.1"}, "a", "self")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.OriginatorID != "a" || !slices.Equal(r.Path, []string{"a"}) {
+		t.Fatalf("expected originator a and path [a], got %q %v", r.OriginatorID, r.Path)
+	}
+
+	// A route that already traversed this node is a loop.
+	looped := &pb.Route{Prefix: "10.0.0.0/24", OriginatorId: "x", Path: []string{"x", "self", "a"}}
+	if _, err := routeFromPB(looped, "a", "self"); err == nil {
+		t.Fatal("expected loop to be rejected")
+	}
+	own := &pb.Route{Prefix: "10.0.0.0/24", OriginatorId: "self", Path: []string{"b"}}
+	if _, err := routeFromPB(own, "b", "self"); err == nil {
+		t.Fatal("expected own route to be rejected")
+	}
+}
+
+func TestRouteFromPB_NextHopFamily(t *testing.T) {
+	for _, tt := range []struct {
+		prefix, nextHop string
+		ok              bool
+	}{
+		{"10.0.0.0/24", "10.100.0.1", true},
+		{"2001:db8::/64", "fd00:100::1", true},
+		{"2001:db8::/64", "", true},
+		{"2001:db8::/64", "10.100.0.1", false},
+		{"10.0.0.0/24", "fd00:100::1", false},
+	} {
+		_, err := routeFromPB(&pb.Route{Prefix: tt.prefix, NextHop: tt.nextHop}, "a", "self")
+		if (err == nil) != tt.ok {
+			t.Errorf("routeFromPB(%s via %q) error = %v, want ok=%v", tt.prefix, tt.nextHop, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, errInvalidNextHop) {
+			t.Errorf("routeFromPB(%s via %q) error = %v, want errInvalidNextHop", tt.prefix, tt.nextHop, err)
+		}
+	}
+}
+
+func TestRelayInfoFor_OnlyWithTransit(t *testing.T) {
+	cfg := &config.Config{Node: config.NodeConfig{ID: "relay"}}
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+	s.SetRelayInfoFunc(func() RelayInfo {
+		return RelayInfo{
+			ReachablePeers: []string{"a", "b"},
+			NextHops:       OverlayNextHops{100: {"10.100.0.5", "fd00:100::5"}},
+		}
+	})
+
+	reachable, nextHops := s.relayInfoFor("a")
+	if len(reachable) != 0 || len(nextHops) != 0 {
+		t.Fatalf("expected no relay info with transit deny, got %v %v", reachable, nextHops)
+	}
+
+	cfg.Topology.Transit = "allow"
+	reachable, nextHops = s.relayInfoFor("a")
+	if !slices.Equal(reachable, []string{"b"}) {
+		t.Fatalf("expected only b to be offered to a, got %v", reachable)
+	}
+	// IPv6 first, so peers keeping one next-hop per VNI keep the IPv4 one.
+	if len(nextHops) != 2 || nextHops[0].NextHop != "fd00:100::5" || nextHops[1].NextHop != "10.100.0.5" {
+		t.Fatalf("expected both relay next-hops for VNI 100, IPv6 first, got %+v", nextHops)
+	}
+}
+
+func TestOverlayNextHops(t *testing.T) {
+	n := OverlayNextHops{}
+	n.Add(100, "10.100.0.1")
+	n.Add(100, "fd00:100::1")
+	n.Add(100, "10.100.0.2") // replaces the IPv4 next-hop
+	n.Add(200, "fd00:200::1")
+
+	for _, tt := range []struct {
+		vni    uint32
+		prefix string
+		want   string
+	}{
+		{100, "172.16.0.0/24", "10.100.0.2"},
+		{100, "2001:db8:1::/64", "fd00:100::1"},
+		{200, "2001:db8:2::/64", "fd00:200::1"},
+		{200, "172.16.0.0/24", ""}, // IPv6-only overlay
+		{300, "172.16.0.0/24", ""},
+	} {
+		if got := n.For(tt.vni, tt.prefix); got != tt.want {
+			t.Errorf("For(%d, %s) = %q, want %q", tt.vni, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// withdrawalRecorder keeps the last RouteWithdrawal sent to a peer.
+type withdrawalRecorder struct {
+	pb.NNetManClient
+	req *pb.RouteWithdrawal
+}
+
+func (w *withdrawalRecorder) WithdrawRoutes(_ context.Context, req *pb.RouteWithdrawal, _ ...grpc.CallOption) (*pb.RouteAck, error) {
+	w.req = req
+	return &pb.RouteAck{}, nil
+}
+
+func TestClient_WithdrawRoutes_KeepsPrefixExportedByOtherVNI(t *testing.T) {
+	c := NewClient(&config.Config{Node: config.NodeConfig{ID: "a"}}, NewRouteTable(), slog.Default())
+	peer := &withdrawalRecorder{}
+	c.conns["b"] = &peerConn{peerID: "b", client: peer, healthy: true}
+
+	withdrawn := []Route{
+		{Prefix: "10.0.0.0/24", VNI: 100},
+		{Prefix: "10.0.1.0/24", VNI: 100},
+	}
+	exported := []Route{{Prefix: "10.0.0.0/24", VNI: 200}}
+	if err := c.WithdrawRoutes(context.Background(), withdrawn, exported); err != nil {
+		t.Fatalf("WithdrawRoutes: %v", err)
+	}
+	if peer.req == nil {
+		t.Fatal("no withdrawal sent")
+	}
+	if len(peer.req.Routes) != 2 {
+		t.Errorf("routes = %v, want both (VNI, prefix) pairs", peer.req.Routes)
+	}
+	// Older peers drop a bare prefix from every VNI, so the prefix still
+	// exported in VNI 200 must not be listed.
+	if want := []string{"10.0.1.0/24"}; !slices.Equal(peer.req.Prefixes, want) {
+		t.Errorf("prefixes = %v, want %v", peer.req.Prefixes, want)
+	}
+}
+
+func TestClient_FindRelay(t *testing.T) {
+	c := NewClient(&config.Config{}, NewRouteTable(), slog.Default())
+	c.conns["b"] = &peerConn{peerID: "b", healthy: false}
+	c.conns["c"] = &peerConn{peerID: "c", healthy: true, reachable: []string{"x"}, nextHops: OverlayNextHops{100: {"10.100.0.3"}}}
+	c.conns["d"] = &peerConn{peerID: "d", healthy: true, reachable: []string{"b"}, nextHops: OverlayNextHops{100: {"10.100.0.4"}}}
+	c.conns["e"] = &peerConn{peerID: "e", healthy: true, reachable: []string{"b"}, nextHops: OverlayNextHops{100: {"10.100.0.5"}}}
+
+	relay, nextHops, ok := c.FindRelay("b")
+	if !ok || relay != "d" || nextHops.For(100, "10.1.0.0/24") != "10.100.0.4" {
+		t.Fatalf("expected relay d (lowest healthy peer reaching b), got %q %v %v", relay, nextHops, ok)
+	}
+
+	c.conns["d"].healthy = false
+	c.conns["e"].healthy = false
+	if _, _, ok := c.FindRelay("b"); ok {
+		t.Fatal("expected no relay when no healthy peer reaches b")
+	}
+	if got := c.UnhealthyPeers(); !slices.Equal(got, []string{"b", "d", "e"}) {
+		t.Fatalf("UnhealthyPeers = %v, want [b d e]", got)
+	}
+}
+
+func TestClient_SetConfigSyncsPeers(t *testing.T) {
+	peer := func(id, addr string) config.PeerConfig {
+		return config.PeerConfig{ID: id, Endpoint: config.EndpointConfig{Address: addr}}
+	}
+	old := &config.Config{Version: 2, Peers: []config.PeerConfig{
+		peer("b", "127.0.0.2"), peer("c", "127.0.0.3"), peer("d", "127.0.0.4"),
+	}}
+	c := NewClient(old, NewRouteTable(), slog.Default())
+	defer c.Disconnect()
+	for _, id := range []string{"b", "c", "d"} {
+		conn, err := grpc.NewClient("passthrough:///"+id, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.conns[id] = &peerConn{peerID: id, conn: conn, healthy: true}
+	}
+	kept := c.conns["c"]
+
+	next := &config.Config{Version: 2, Peers: []config.PeerConfig{
+		peer("c", "127.0.0.3"), peer("d", "127.0.0.44"), peer("e", "127.0.0.5"),
+	}}
+	removed, err := c.SetConfig(next)
+	if err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if !slices.Equal(removed, []string{"b"}) {
+		t.Fatalf("removed = %v, want [b]", removed)
+	}
+	if c.conns["c"] != kept {
+		t.Error("expected the session of an unchanged peer to be kept")
+	}
+	if pc := c.conns["d"]; pc == nil || pc.address != "127.0.0.44:0" {
+		t.Errorf("expected changed peer d to be redialled at its new address, got %+v", pc)
+	}
+	if _, ok := c.conns["e"]; !ok {
+		t.Error("expected new peer e to be connected")
+	}
+	if _, ok := c.conns["b"]; ok {
+		t.Error("expected removed peer b to be disconnected")
+	}
+}
+
+func TestExchangeState_GracefulRestart(t *testing.T) {
+	rt := NewRouteTable()
+	s := NewServer(&config.Config{Version: 2, Node: config.NodeConfig{ID: "local"}, Peers: []config.PeerConfig{{ID: "a"}}}, rt, slog.Default())
+	var withdrawn []Route
+	s.SetRoutesWithdrawnCallback(func(routes []Route) { withdrawn = append(withdrawn, routes...) })
+
+	req := &pb.StateRequest{
+		NodeId: "a",
+		Routes: []*pb.Route{
+			{Prefix: "10.1.0.0/24", NextHop: "10.100.0.2", Vni: 100, LeaseSeconds: 30},
+			{Prefix: "10.2.0.0/24", NextHop: "10.100.0.2", Vni: 100, LeaseSeconds: 30},
+		},
+		Capabilities: &pb.Capabilities{GracefulRestartSeconds: 90},
+	}
+	if _, err := s.ExchangeState(context.Background(), req); err != nil {
+		t.Fatalf("ExchangeState: %v", err)
+	}
+	if got := s.PeerRestartTime("a"); got != 90*time.Second {
+		t.Fatalf("PeerRestartTime = %v, want 90s", got)
+	}
+
+	// The peer goes down: its routes are held for the restart timer.
+	until := time.Now().Add(90 * time.Second)
+	if n := rt.MarkStale("a", until); n != 2 {
+		t.Fatalf("MarkStale marked %d routes, want 2", n)
+	}
+	for _, r := range rt.GetByPeer("a") {
+		if !r.ExpiresAt.Equal(until) {
+			t.Fatalf("expected stale route to expire with the restart timer, got %v", r.ExpiresAt)
+		}
+	}
+
+	// It comes back announcing only one of them: the other one is removed.
+	req.Routes = req.Routes[:1]
+	req.Capabilities = nil
+	if _, err := s.ExchangeState(context.Background(), req); err != nil {
+		t.Fatalf("ExchangeState: %v", err)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].Prefix != "10.2.0.0/24" {
+		t.Fatalf("expected the route not re-announced to be withdrawn, got %+v", withdrawn)
+	}
+	routes := rt.GetByPeer("a")
+	if len(routes) != 1 || routes[0].Prefix != "10.1.0.0/24" || !routes[0].StaleUntil.IsZero() {
+		t.Fatalf("expected the re-announced route to be fresh, got %+v", routes)
+	}
+	if got := s.PeerRestartTime("a"); got != 0 {
+		t.Fatalf("expected the restart timer to be cleared when no longer advertised, got %v", got)
+	}
+}
+
+func TestWithdrawRoutes_PerVNI(t *testing.T) {
+	rt := NewRouteTable()
+	s := NewServer(&config.Config{Node: config.NodeConfig{ID: "local"}}, rt, slog.Default())
+	var withdrawn []Route
+	s.SetRoutesWithdrawnCallback(func(routes []Route) { withdrawn = append(withdrawn, routes...) })
+	reset := func() {
+		withdrawn = nil
+		rt.Add(Route{Prefix: "10.0.0.0/24", VNI: 100, PeerID: "a"})
+		rt.Add(Route{Prefix: "10.0.0.0/24", VNI: 200, PeerID: "a"})
+	}
+
+	// A (VNI, prefix) pair only removes the route of that overlay.
+	reset()
+	_, err := s.WithdrawRoutes(context.Background(), &pb.RouteWithdrawal{
+		NodeId:   "a",
+		Prefixes: []string{"10.0.0.0/24"},
+		Routes:   []*pb.Route{{Prefix: "10.0.0.0/24", Vni: 100}},
+	})
+	if err != nil {
+		t.Fatalf("WithdrawRoutes: %v", err)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].VNI != 100 {
+		t.Fatalf("withdrawn = %+v, want only VNI 100", withdrawn)
+	}
+	if routes := rt.GetByPeer("a"); len(routes) != 1 || routes[0].VNI != 200 {
+		t.Fatalf("remaining routes = %+v, want only VNI 200", routes)
+	}
+
+	// Bare prefixes from older nodes remove the prefix across VNIs.
+	reset()
+	if _, err := s.WithdrawRoutes(context.Background(), &pb.RouteWithdrawal{NodeId: "a", Prefixes: []string{"10.0.0.0/24"}}); err != nil {
+		t.Fatalf("WithdrawRoutes: %v", err)
+	}
+	if len(withdrawn) != 2 || len(rt.GetByPeer("a")) != 0 {
+		t.Fatalf("withdrawn = %+v, want the prefix removed from both VNIs", withdrawn)
+	}
+}
@@ -0,0 +1,37 @@
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// viaInterfaceDialer returns a gRPC dialer whose sockets are bound to an
+// underlay interface (SO_BINDTODEVICE), so the session with a peer pinned by
+// endpoint.via_interface leaves through it whatever the routing table says.
+func viaInterfaceDialer(iface string) func(context.Context, string) (net.Conn, error) {
+	d := net.Dialer{Control: bindToDevice(iface)}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// bindToDevice returns a net.Dialer Control function binding the socket to
+// an interface.
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		var bindErr error
+		if err := c.Control(func(fd uintptr) {
+			bindErr = unix.SetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE, iface)
+		}); err != nil {
+			return err
+		}
+		if bindErr != nil {
+			return fmt.Errorf("failed to bind to interface %s: %w", iface, bindErr)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package controlplane
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestViaInterfaceDialer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	conn, err := viaInterfaceDialer("lo")(context.Background(), lis.Addr().String())
+	if err != nil {
+		t.Fatalf("dial bound to lo: %v", err)
+	}
+	conn.Close()
+
+	if _, err := viaInterfaceDialer("missing0")(context.Background(), lis.Addr().String()); err == nil || !strings.Contains(err.Error(), "interface missing0") {
+		t.Fatalf("dial bound to a missing interface = %v, want a bind error", err)
+	}
+}
@@ -0,0 +1,224 @@
+package controlplane
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/observability"
+)
+
+// SetMetrics wires Prometheus metrics into the client (per-peer link
+// quality measured over the keepalive streams).
+func (c *Client) SetMetrics(m *observability.Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+}
+
+func (c *Client) getMetrics() *observability.Metrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metrics
+}
+
+// SetPeerDownCallback sets the callback invoked when a peer is declared dead
+// (no keepalive response within its dead_after_ms). It runs on the peer's
+// keepalive goroutine and must not block.
+func (c *Client) SetPeerDownCallback(fn func(peerID string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onPeerDown = fn
+}
+
+// runKeepalive holds a Keepalive stream open with a peer until ctx is
+// cancelled, sending a request every interval. The peer is marked healthy on
+// every response and declared dead once deadAfter elapses without one. A
+// broken stream is re-opened on the next tick; the dead timer keeps running
+// meanwhile, so an unreachable peer is detected even if the stream cannot be
+// established.
+//
+// The echoed sequence numbers also feed the peer's link statistics (RTT,
+// jitter and loss; see linkStats), published to /status and Prometheus.
+func (c *Client) runKeepalive(ctx context.Context, peerID string, client pb.NNetManClient, interval, deadAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		stream       pb.NNetMan_KeepaliveClient
+		streamCancel context.CancelFunc
+		seq          uint64
+		lastAck      = time.Now()
+		stats        = newLinkStats()
+	)
+	acks := make(chan keepaliveAck, 16)
+	errs := make(chan error, 1)
+	defer func() {
+		if streamCancel != nil {
+			streamCancel()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ack := <-acks:
+			lastAck = ack.at
+			if rtt, ok := stats.acked(ack.resp.Sequence, ack.at); ok {
+				c.observeRTT(peerID, rtt)
+			}
+			c.peerAlive(peerID, ack.resp, stats)
+
+		case err := <-errs:
+			// The receive side owns the stream lifecycle: only a receive error
+			// drops it, so a new stream is never torn down by a stale error.
+			c.logger.Debug("keepalive stream closed", "peer_id", peerID, "error", err)
+			streamCancel()
+			stream, streamCancel = nil, nil
+
+		case <-ticker.C:
+			if stream == nil {
+				var err error
+				stream, streamCancel, err = openKeepalive(ctx, client, acks, errs)
+				if err != nil {
+					c.logger.Debug("failed to open keepalive stream", "peer_id", peerID, "error", err)
+				}
+			}
+
+			sent := false
+			if stream != nil {
+				seq++
+				req := &pb.KeepaliveRequest{
+					NodeId:      c.cfg.Load().Node.ID,
+					Sequence:    seq,
+					TimestampMs: time.Now().UnixMilli(),
+				}
+				if err := stream.Send(req); err != nil {
+					// Cancelling makes Recv fail, which reports on errs.
+					streamCancel()
+				} else {
+					stats.sent(seq, time.Now())
+					sent = true
+				}
+			}
+			// A keepalive that could not be sent is lost, like one
+			// unanswered for the dead interval.
+			if !sent {
+				stats.missed()
+			}
+			if stats.expire(time.Now(), deadAfter) > 0 || !sent {
+				c.publishLinkStats(peerID, stats)
+			}
+
+			if time.Since(lastAck) > deadAfter {
+				c.peerDead(peerID, time.Since(lastAck))
+			}
+		}
+	}
+}
+
+// keepaliveAck is a keepalive response with its arrival time, taken on the
+// receive goroutine so RTT samples do not include queuing in the loop.
+type keepaliveAck struct {
+	resp *pb.KeepaliveResponse
+	at   time.Time
+}
+
+// openKeepalive opens a Keepalive stream and starts forwarding its responses
+// to acks (and its terminal error to errs).
+func openKeepalive(ctx context.Context, client pb.NNetManClient, acks chan<- keepaliveAck, errs chan<- error) (pb.NNetMan_KeepaliveClient, context.CancelFunc, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Keepalive(streamCtx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	go recvKeepalives(streamCtx, stream, acks, errs)
+	return stream, cancel, nil
+}
+
+// recvKeepalives forwards responses from a Keepalive stream until it fails,
+// then reports the error. errs is buffered and at most one stream is open at
+// a time, so the report never blocks.
+func recvKeepalives(ctx context.Context, stream pb.NNetMan_KeepaliveClient, acks chan<- keepaliveAck, errs chan<- error) {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			errs <- err
+			return
+		}
+		select {
+		case acks <- keepaliveAck{resp: resp, at: time.Now()}:
+		case <-ctx.Done():
+			// Keep draining until Recv fails so the error is still reported.
+		}
+	}
+}
+
+// peerAlive records a keepalive response from a peer.
+func (c *Client) peerAlive(peerID string, resp *pb.KeepaliveResponse, stats *linkStats) {
+	c.mu.Lock()
+	if p, ok := c.conns[peerID]; ok {
+		if !p.healthy {
+			c.logger.Info("peer is alive again", "peer_id", peerID)
+		}
+		p.healthy = true
+		p.lastSeen = time.Now()
+		p.setRelayInfo(resp.ReachablePeers, resp.NextHops)
+	}
+	c.mu.Unlock()
+
+	c.publishLinkStats(peerID, stats)
+}
+
+// observeRTT records an RTT sample in the peer's RTT histogram.
+func (c *Client) observeRTT(peerID string, rtt time.Duration) {
+	if m := c.getMetrics(); m != nil {
+		m.PeerRTT.WithLabelValues(peerID).Observe(rtt.Seconds())
+	}
+}
+
+// publishLinkStats exposes the peer's current link statistics on /status
+// and in the jitter/loss gauges.
+func (c *Client) publishLinkStats(peerID string, stats *linkStats) {
+	snap := stats.snapshot()
+
+	c.mu.Lock()
+	if p, ok := c.conns[peerID]; ok {
+		p.link = snap
+	}
+	c.mu.Unlock()
+
+	if m := c.getMetrics(); m != nil && snap != nil {
+		m.PeerJitter.WithLabelValues(peerID).Set(stats.jitter.Seconds())
+		m.PeerLoss.WithLabelValues(peerID).Set(stats.loss())
+	}
+}
+
+// peerDead marks a peer unhealthy after its dead timer fired and notifies the
+// peer-down callback on the healthy -> dead transition only. The peer's
+// route stream is closed: it is re-opened, with a fresh snapshot, once the
+// peer is alive again.
+func (c *Client) peerDead(peerID string, silence time.Duration) {
+	c.mu.Lock()
+	p, ok := c.conns[peerID]
+	wasHealthy := ok && p.healthy
+	if ok {
+		p.healthy = false
+		if p.watchCancel != nil {
+			p.watchCancel()
+		}
+	}
+	callback := c.onPeerDown
+	c.mu.Unlock()
+
+	if !wasHealthy {
+		return
+	}
+	c.logger.Warn("peer declared dead", "peer_id", peerID, "silence", silence.Round(time.Millisecond))
+	if callback != nil {
+		callback(peerID)
+	}
+}
@@ -0,0 +1,174 @@
+package controlplane
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+// unreachableClient fails every Keepalive attempt, like a peer whose
+// underlay path is down.
+type unreachableClient struct {
+	pb.NNetManClient
+}
+
+func (unreachableClient) Keepalive(context.Context, ...grpc.CallOption) (grpc.BidiStreamingClient[pb.KeepaliveRequest, pb.KeepaliveResponse], error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestKeepalive_DeadTimerFires(t *testing.T) {
+	c := NewClient(&config.Config{}, NewRouteTable(), slog.Default())
+	c.conns["b"] = &peerConn{peerID: "b", healthy: true}
+
+	down := make(chan string, 1)
+	c.SetPeerDownCallback(func(peerID string) { down <- peerID })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.runKeepalive(ctx, "b", unreachableClient{}, 10*time.Millisecond, 50*time.Millisecond)
+
+	select {
+	case id := <-down:
+		if id != "b" {
+			t.Fatalf("expected peer b to be declared dead, got %q", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dead timer did not fire")
+	}
+	if got := c.UnhealthyPeers(); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("expected b to be unhealthy, got %v", got)
+	}
+
+	// Keepalives that could not be sent count as lost.
+	c.mu.RLock()
+	link := c.conns["b"].link
+	c.mu.RUnlock()
+	if link == nil || link.LossPercent != 100 {
+		t.Fatalf("expected 100%% loss towards an unreachable peer, got %+v", link)
+	}
+}
+
+func TestKeepalive_StreamKeepsPeerAlive(t *testing.T) {
+	srvCfg := &config.Config{
+		Node:     config.NodeConfig{ID: "b"},
+		Topology: config.TopologyConfig{Transit: "allow"},
+	}
+	srv := NewServer(srvCfg, NewRouteTable(), slog.Default())
+	srv.SetRelayInfoFunc(func() RelayInfo {
+		return RelayInfo{ReachablePeers: []string{"c"}, NextHops: OverlayNextHops{100: {"10.100.0.2"}}}
+	})
+
+	client := newBufconnClient(t, srv)
+
+	c := NewClient(&config.Config{Node: config.NodeConfig{ID: "a"}}, NewRouteTable(), slog.Default())
+	c.conns["b"] = &peerConn{peerID: "b", healthy: false}
+	c.SetPeerDownCallback(func(peerID string) { t.Errorf("peer %s unexpectedly declared dead", peerID) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.runKeepalive(ctx, "b", client, 10*time.Millisecond, time.Second)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if relay, _, ok := c.FindRelay("c"); ok {
+			if relay != "b" {
+				t.Fatalf("expected relay b, got %q", relay)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("peer b never became healthy with relay info over the keepalive stream")
+}
+
+func TestLinkStats_RTTJitterLoss(t *testing.T) {
+	l := newLinkStats()
+	t0 := time.Unix(0, 0)
+
+	// Two answered keepalives: RTT 10ms then 18ms -> jitter 8ms/16.
+	l.sent(1, t0)
+	if rtt, ok := l.acked(1, t0.Add(10*time.Millisecond)); !ok || rtt != 10*time.Millisecond {
+		t.Fatalf("expected rtt 10ms, got %v (ok=%v)", rtt, ok)
+	}
+	l.sent(2, t0.Add(time.Second))
+	l.acked(2, t0.Add(time.Second+18*time.Millisecond))
+	if l.jitter != 500*time.Microsecond {
+		t.Fatalf("expected jitter 0.5ms, got %v", l.jitter)
+	}
+
+	// Two unanswered keepalives expire as lost; a late answer is ignored.
+	l.sent(3, t0.Add(2*time.Second))
+	l.sent(4, t0.Add(3*time.Second))
+	if lost := l.expire(t0.Add(10*time.Second), 5*time.Second); lost != 2 {
+		t.Fatalf("expected 2 lost keepalives, got %d", lost)
+	}
+	if _, ok := l.acked(3, t0.Add(11*time.Second)); ok {
+		t.Fatal("expected late answer for an expired keepalive to be ignored")
+	}
+	if got := l.loss(); got != 0.5 {
+		t.Fatalf("expected loss 0.5, got %v", got)
+	}
+
+	snap := l.snapshot()
+	if snap == nil || snap.RTTMs != 18 || snap.LossPercent != 50 || snap.Samples != 2 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+}
+
+func TestKeepalive_RejectsTopologyDeniedPeer(t *testing.T) {
+	srvCfg := &config.Config{
+		Node:     config.NodeConfig{ID: "spoke-1"},
+		Topology: config.TopologyConfig{Mode: "hub-spoke", Hubs: []string{"hub-1"}},
+	}
+	srv := NewServer(srvCfg, NewRouteTable(), slog.Default())
+
+	client := newBufconnClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stream, err := client.Keepalive(ctx)
+	if err != nil {
+		t.Fatalf("open keepalive stream: %v", err)
+	}
+	if err := stream.Send(&pb.KeepaliveRequest{NodeId: "spoke-2", Sequence: 1}); err != nil {
+		t.Fatalf("send keepalive: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected spoke-2 keepalive to be rejected with PermissionDenied, got %v", err)
+	}
+}
+
+// newBufconnClient serves srv on an in-memory listener and returns a client
+// connected to it, dialed with opts. The server and the connection are
+// closed when the test ends.
+func newBufconnClient(t *testing.T, srv pb.NNetManServer, opts ...grpc.DialOption) pb.NNetManClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer()
+	pb.RegisterNNetManServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewNNetManClient(conn)
+}
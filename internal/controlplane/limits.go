@@ -0,0 +1,245 @@
+package controlplane
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	"github.com/nishisan-dev/n-netman/internal/observability"
+)
+
+// Limits enforces the per-peer limits of the config (peers[].limits): the
+// number of routes held from a peer (max_prefixes) and the rate of its
+// AnnounceRoutes and ExchangeState calls. It is shared by the Server and the
+// Client, which both learn routes from peers.
+type Limits struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	peers   map[string]*peerLimitState
+	metrics *observability.Metrics
+	now     func() time.Time
+}
+
+// peerLimitState is the limit state of a single peer.
+type peerLimitState struct {
+	// Token bucket of the announcement rate limit.
+	tokens      float64
+	refilledAt  time.Time
+	rateLimited bool
+	refused     uint64
+
+	// The peer holds more routes than max_prefixes (warn mode).
+	overMaxPrefixes bool
+	// Announcements are refused until then (teardown mode).
+	tornDownUntil time.Time
+}
+
+// NewLimits creates the limit state of the peers.
+func NewLimits(logger *slog.Logger) *Limits {
+	return &Limits{
+		logger: logger,
+		peers:  make(map[string]*peerLimitState),
+		now:    time.Now,
+	}
+}
+
+// SetMetrics wires Prometheus metrics into the limits (violations).
+func (l *Limits) SetMetrics(m *observability.Metrics) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics = m
+}
+
+// peerLimits returns the limits configured for a peer. Peers missing from
+// cfg get the defaults.
+func peerLimits(cfg *config.Config, peerID string) config.LimitsConfig {
+	peer, _ := cfg.FindPeer(peerID)
+	return peer.Limits
+}
+
+// unknownPeersKey keys the limit state shared by the peers missing from the
+// config. Their node_id is not authenticated, so a state per claimed ID would
+// let any sender grow the map (and the metric labels) without bound.
+const unknownPeersKey = ""
+
+// limitKey returns the key of the limit state of a peer.
+func limitKey(cfg *config.Config, peerID string) string {
+	if _, ok := cfg.FindPeer(peerID); ok {
+		return peerID
+	}
+	return unknownPeersKey
+}
+
+// state returns the limit state of the peer with the given key, creating it
+// if needed. l.mu is held.
+func (l *Limits) state(key string) *peerLimitState {
+	st, ok := l.peers[key]
+	if !ok {
+		st = &peerLimitState{tokens: -1}
+		l.peers[key] = st
+	}
+	return st
+}
+
+// prune drops the limit state of the peers no longer in cfg, once the config
+// was reloaded.
+func (l *Limits) prune(cfg *config.Config) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.peers {
+		if key != unknownPeersKey && limitKey(cfg, key) != key {
+			delete(l.peers, key)
+		}
+	}
+}
+
+// admitAnnouncement takes a token from the peer's bucket. It returns a
+// ResourceExhausted error when the peer is torn down or over its rate.
+func (l *Limits) admitAnnouncement(cfg *config.Config, peerID string) error {
+	if l == nil {
+		return nil
+	}
+	lim := peerLimits(cfg, peerID)
+	rate, burst := lim.Rate()
+	key := limitKey(cfg, peerID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	st := l.state(key)
+
+	if now.Before(st.tornDownUntil) {
+		return status.Errorf(codes.ResourceExhausted,
+			"peer %s torn down for exceeding max_prefixes until %s", peerID, st.tornDownUntil.Format(time.RFC3339))
+	}
+
+	// A new bucket starts full.
+	if st.tokens < 0 {
+		st.tokens = float64(burst)
+	} else {
+		st.tokens = min(float64(burst), st.tokens+now.Sub(st.refilledAt).Seconds()*rate)
+	}
+	st.refilledAt = now
+	if st.tokens >= 1 {
+		st.tokens--
+		st.rateLimited = false
+		return nil
+	}
+
+	st.refused++
+	l.countViolation(key, "announce_rate")
+	// Log once per burst of refused calls.
+	if !st.rateLimited {
+		st.rateLimited = true
+		l.logger.Warn("peer exceeded its announcement rate limit, refusing announcements",
+			"peer_id", peerID, "rate", rate, "burst", burst)
+	}
+	return status.Errorf(codes.ResourceExhausted, "peer %s exceeded its announcement rate limit", peerID)
+}
+
+// tornDown reports whether the announcements of a peer are refused.
+func (l *Limits) tornDown(peerID string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, ok := l.peers[peerID]
+	return ok && l.now().Before(st.tornDownUntil)
+}
+
+// enforceMaxPrefixes checks the routes held from a peer against its
+// max_prefixes once an announcement was stored. In warn mode the violation is
+// only reported; in teardown mode every route of the peer is removed from rt
+// and returned, so the caller removes them from the kernel, and the peer's
+// announcements are refused for teardown_seconds.
+func (l *Limits) enforceMaxPrefixes(cfg *config.Config, rt *RouteTable, peerID string) (removed []Route, tornDown bool) {
+	if l == nil {
+		return nil, false
+	}
+	lim := peerLimits(cfg, peerID)
+	if lim.MaxPrefixes <= 0 {
+		l.mu.Lock()
+		if st, ok := l.peers[peerID]; ok {
+			st.overMaxPrefixes = false
+		}
+		l.mu.Unlock()
+		return nil, false
+	}
+	held := len(rt.GetByPeer(peerID))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.state(peerID)
+	if held <= lim.MaxPrefixes {
+		if st.overMaxPrefixes {
+			l.logger.Info("peer back under max_prefixes", "peer_id", peerID, "routes", held, "max_prefixes", lim.MaxPrefixes)
+		}
+		st.overMaxPrefixes = false
+		return nil, false
+	}
+
+	l.countViolation(peerID, "max_prefixes")
+	if !lim.TeardownsPeer() {
+		if !st.overMaxPrefixes {
+			l.logger.Warn("peer exceeded max_prefixes", "peer_id", peerID, "routes", held, "max_prefixes", lim.MaxPrefixes)
+		}
+		st.overMaxPrefixes = true
+		return nil, false
+	}
+
+	st.overMaxPrefixes = false
+	st.tornDownUntil = l.now().Add(lim.TeardownDuration())
+	removed = rt.RemoveByPeer(peerID)
+	l.logger.Warn("peer exceeded max_prefixes, tearing it down",
+		"peer_id", peerID,
+		"routes", held,
+		"max_prefixes", lim.MaxPrefixes,
+		"routes_removed", len(removed),
+		"until", st.tornDownUntil,
+	)
+	return removed, true
+}
+
+// peerStatus returns the limit state of a peer for /status, or nil when it
+// has no violation to report.
+func (l *Limits) peerStatus(cfg *config.Config, peerID string) *observability.PeerLimitStatus {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, ok := l.peers[peerID]
+	if !ok {
+		return nil
+	}
+	ps := &observability.PeerLimitStatus{
+		MaxPrefixes:          peerLimits(cfg, peerID).MaxPrefixes,
+		OverMaxPrefixes:      st.overMaxPrefixes,
+		RateLimited:          st.rateLimited,
+		RefusedAnnouncements: st.refused,
+	}
+	if l.now().Before(st.tornDownUntil) {
+		ps.TornDownUntil = st.tornDownUntil.Format(time.RFC3339)
+	}
+	if !ps.OverMaxPrefixes && !ps.RateLimited && ps.RefusedAnnouncements == 0 && ps.TornDownUntil == "" {
+		return nil
+	}
+	return ps
+}
+
+// countViolation counts a violation of a peer limit in the
+// peer_limit_violations_total metric, when metrics are set. l.mu is held.
+func (l *Limits) countViolation(peerID, limit string) {
+	if l.metrics != nil {
+		l.metrics.PeerLimitViolations.WithLabelValues(peerID, limit).Inc()
+	}
+}
@@ -0,0 +1,145 @@
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+// newTestLimits returns limits driven by a fake clock.
+func newTestLimits() (*Limits, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimits(slog.Default())
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimits_AnnouncementRate(t *testing.T) {
+	cfg := &config.Config{Version: 2, Peers: []config.PeerConfig{
+		{ID: "a", Limits: config.LimitsConfig{AnnounceRate: 1, AnnounceBurst: 2}},
+	}}
+	l, now := newTestLimits()
+
+	for i := range 2 {
+		if err := l.admitAnnouncement(cfg, "a"); err != nil {
+			t.Fatalf("call %d within the burst refused: %v", i+1, err)
+		}
+	}
+	err := l.admitAnnouncement(cfg, "a")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over the burst = %v, want ResourceExhausted", err)
+	}
+	if ps := l.peerStatus(cfg, "a"); ps == nil || !ps.RateLimited || ps.RefusedAnnouncements != 1 {
+		t.Fatalf("status = %+v, want rate limited with 1 refused announcement", ps)
+	}
+
+	// One token is back after a second.
+	*now = now.Add(time.Second)
+	if err := l.admitAnnouncement(cfg, "a"); err != nil {
+		t.Fatalf("call after the refill refused: %v", err)
+	}
+}
+
+func TestLimits_MaxPrefixesWarn(t *testing.T) {
+	cfg := &config.Config{Version: 2, Peers: []config.PeerConfig{
+		{ID: "a", Limits: config.LimitsConfig{MaxPrefixes: 1}},
+	}}
+	l, _ := newTestLimits()
+	rt := NewRouteTable()
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "a"})
+	rt.Add(Route{Prefix: "10.2.0.0/24", VNI: 100, PeerID: "a"})
+
+	if removed, tornDown := l.enforceMaxPrefixes(cfg, rt, "a"); tornDown || len(removed) != 0 {
+		t.Fatalf("warn mode tore the peer down (removed %d routes)", len(removed))
+	}
+	if len(rt.GetByPeer("a")) != 2 {
+		t.Fatal("warn mode must keep the routes")
+	}
+	if ps := l.peerStatus(cfg, "a"); ps == nil || !ps.OverMaxPrefixes || ps.MaxPrefixes != 1 {
+		t.Fatalf("status = %+v, want over max_prefixes (1)", ps)
+	}
+
+	rt.RemoveRoute(100, "10.2.0.0/24", "a")
+	l.enforceMaxPrefixes(cfg, rt, "a")
+	if ps := l.peerStatus(cfg, "a"); ps != nil {
+		t.Fatalf("status = %+v, want nothing to report once back under the limit", ps)
+	}
+}
+
+func TestServer_MaxPrefixesTeardown(t *testing.T) {
+	cfg := &config.Config{
+		Version: 2,
+		Node:    config.NodeConfig{ID: "local"},
+		Peers: []config.PeerConfig{{ID: "a", Limits: config.LimitsConfig{
+			MaxPrefixes:       2,
+			MaxPrefixesAction: "teardown",
+			TeardownSeconds:   30,
+		}}},
+	}
+	l, now := newTestLimits()
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+	s.SetLimits(l)
+	var withdrawn []Route
+	s.SetRoutesWithdrawnCallback(func(routes []Route) { withdrawn = append(withdrawn, routes...) })
+
+	announce := func(prefixes ...string) error {
+		req := &pb.RouteAnnouncement{NodeId: "a"}
+		for _, p := range prefixes {
+			req.Routes = append(req.Routes, &pb.Route{Prefix: p, Vni: 100})
+		}
+		_, err := s.AnnounceRoutes(context.Background(), req)
+		return err
+	}
+
+	if err := announce("10.1.0.0/24", "10.2.0.0/24"); err != nil {
+		t.Fatalf("announcement within the limit refused: %v", err)
+	}
+	if err := announce("10.3.0.0/24"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("announcement over the limit = %v, want ResourceExhausted", err)
+	}
+	if len(withdrawn) != 3 || len(s.routeTable.GetByPeer("a")) != 0 {
+		t.Fatalf("withdrawn %d routes, %d left; want every route of the peer removed", len(withdrawn), len(s.routeTable.GetByPeer("a")))
+	}
+
+	// Announcements are refused until the teardown ends.
+	if err := announce("10.1.0.0/24"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("announcement while torn down = %v, want ResourceExhausted", err)
+	}
+	if ps := l.peerStatus(cfg, "a"); ps == nil || ps.TornDownUntil == "" {
+		t.Fatalf("status = %+v, want the teardown reported", ps)
+	}
+	*now = now.Add(31 * time.Second)
+	if err := announce("10.1.0.0/24"); err != nil {
+		t.Fatalf("announcement after the teardown refused: %v", err)
+	}
+}
+
+func TestLimits_UnknownPeersShareState(t *testing.T) {
+	cfg := &config.Config{Version: 2, Peers: []config.PeerConfig{{ID: "a"}}}
+	l, _ := newTestLimits()
+
+	// Every claimed node_id missing from the config lands in one state.
+	for i := range 100 {
+		_ = l.admitAnnouncement(cfg, fmt.Sprintf("spoofed-%d", i))
+	}
+	if err := l.admitAnnouncement(cfg, "a"); err != nil {
+		t.Fatalf("configured peer refused: %v", err)
+	}
+	if n := len(l.peers); n != 2 {
+		t.Fatalf("limit states = %d, want 2 (peer a and the unknown peers)", n)
+	}
+
+	// A peer removed by a reload loses its state.
+	l.prune(&config.Config{Version: 2})
+	if _, ok := l.peers["a"]; ok {
+		t.Fatal("state of a removed peer kept after prune")
+	}
+}
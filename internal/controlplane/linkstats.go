@@ -0,0 +1,116 @@
+package controlplane
+
+import (
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/observability"
+)
+
+// lossWindow is the number of most recent keepalives the loss ratio is
+// computed over.
+const lossWindow = 100
+
+// linkStats measures the underlay quality towards a peer from the keepalive
+// sequence numbers echoed back by it: round-trip time, jitter (RFC 3550
+// smoothed RTT variation) and loss over the last lossWindow keepalives.
+// It is owned by the peer's keepalive goroutine and is not safe for
+// concurrent use.
+type linkStats struct {
+	pending map[uint64]time.Time // sequence -> sent at
+
+	rtt     time.Duration
+	jitter  time.Duration
+	samples uint64
+
+	outcomes [lossWindow]bool // true = answered
+	count    int
+	next     int
+}
+
+func newLinkStats() *linkStats {
+	return &linkStats{pending: make(map[uint64]time.Time)}
+}
+
+// sent records that keepalive seq was sent at the given time.
+func (l *linkStats) sent(seq uint64, at time.Time) {
+	l.pending[seq] = at
+}
+
+// acked records the response to keepalive seq and returns its RTT. Responses
+// to unknown sequences (already counted as lost, or duplicates) are ignored.
+func (l *linkStats) acked(seq uint64, at time.Time) (time.Duration, bool) {
+	sentAt, ok := l.pending[seq]
+	if !ok {
+		return 0, false
+	}
+	delete(l.pending, seq)
+
+	rtt := at.Sub(sentAt)
+	if l.samples > 0 {
+		d := rtt - l.rtt
+		if d < 0 {
+			d = -d
+		}
+		l.jitter += (d - l.jitter) / 16
+	}
+	l.rtt = rtt
+	l.samples++
+	l.record(true)
+	return rtt, true
+}
+
+// expire counts the keepalives unanswered for longer than timeout as lost
+// and returns how many were.
+func (l *linkStats) expire(now time.Time, timeout time.Duration) int {
+	lost := 0
+	for seq, sentAt := range l.pending {
+		if now.Sub(sentAt) > timeout {
+			delete(l.pending, seq)
+			l.record(false)
+			lost++
+		}
+	}
+	return lost
+}
+
+// missed counts a keepalive that could not be sent (no stream to the peer,
+// or a failed send) as lost, so a link that is down shows as lossy instead
+// of keeping its last measurements.
+func (l *linkStats) missed() {
+	l.record(false)
+}
+
+func (l *linkStats) record(answered bool) {
+	l.outcomes[l.next] = answered
+	l.next = (l.next + 1) % lossWindow
+	if l.count < lossWindow {
+		l.count++
+	}
+}
+
+// loss returns the fraction of lost keepalives in the window (0..1).
+func (l *linkStats) loss() float64 {
+	if l.count == 0 {
+		return 0
+	}
+	lost := 0
+	for i := 0; i < l.count; i++ {
+		if !l.outcomes[i] {
+			lost++
+		}
+	}
+	return float64(lost) / float64(l.count)
+}
+
+// snapshot returns the current measurements for /status.
+func (l *linkStats) snapshot() *observability.LinkQuality {
+	if l.count == 0 {
+		return nil
+	}
+	return &observability.LinkQuality{
+		RTTMs:       float64(l.rtt.Microseconds()) / 1000,
+		JitterMs:    float64(l.jitter.Microseconds()) / 1000,
+		LossPercent: l.loss() * 100,
+		Samples:     l.samples,
+	}
+}
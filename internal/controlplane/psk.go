@@ -0,0 +1,293 @@
+package controlplane
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+// pskReplayWindow bounds how far a signed message's timestamp_ms may be from
+// the receiver's clock. Messages outside it are rejected as replays, and
+// HMACs seen within it are remembered so an exact replay is rejected too.
+const pskReplayWindow = 30 * time.Second
+
+// authMessage is implemented by every message exchanged between peers that
+// carries the sender's identity and, in PSK mode, its HMAC: the requests, and
+// the responses and stream messages that carry routes or relay information.
+type authMessage interface {
+	proto.Message
+	GetNodeId() string
+	GetTimestampMs() int64
+	GetAuthHmac() []byte
+}
+
+// LoadPSK reads the pre-shared key named by a psk_ref: "file:<path>" reads
+// the key from a file, "env:<VAR>" from an environment variable. Surrounding
+// whitespace (e.g. a trailing newline) is trimmed.
+func LoadPSK(ref string) ([]byte, error) {
+	var key string
+	switch {
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PSK: %w", err)
+		}
+		key = string(data)
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("PSK environment variable %s is not set", name)
+		}
+		key = v
+	default:
+		return nil, fmt.Errorf("invalid psk_ref %q (expected file:<path> or env:<VAR>)", ref)
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("PSK referenced by %q is empty", ref)
+	}
+	return []byte(key), nil
+}
+
+// loadPeerPSKs loads the key of every configured peer using auth.mode=psk.
+func loadPeerPSKs(cfg *config.Config) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, p := range cfg.GetPeers() {
+		if p.Auth.Mode != "psk" {
+			continue
+		}
+		key, err := LoadPSK(p.Auth.PSKRef)
+		if err != nil {
+			return nil, fmt.Errorf("peer %s: %w", p.ID, err)
+		}
+		keys[p.ID] = key
+	}
+	return keys, nil
+}
+
+// computeHMAC returns HMAC-SHA256(key, type || 0 || node_id || 0 ||
+// timestamp_ms || payload), where type is the full name of the message and
+// payload its deterministic encoding with the auth_hmac field cleared. The
+// type keeps a signed message from being passed off as another message with
+// the same encoding (e.g. a request as a response).
+func computeHMAC(key []byte, msg authMessage) ([]byte, error) {
+	unsigned := proto.Clone(msg)
+	m := unsigned.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName("auth_hmac")
+	if fd == nil {
+		return nil, fmt.Errorf("%s has no auth_hmac field", m.Descriptor().FullName())
+	}
+	m.Clear(fd)
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(msg.GetTimestampMs()))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(m.Descriptor().FullName()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(msg.GetNodeId()))
+	mac.Write([]byte{0})
+	mac.Write(ts[:])
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// signMessage sets the auth_hmac field of msg.
+func signMessage(key []byte, msg authMessage) error {
+	sum, err := computeHMAC(key, msg)
+	if err != nil {
+		return err
+	}
+	m := msg.ProtoReflect()
+	m.Set(m.Descriptor().Fields().ByName("auth_hmac"), protoreflect.ValueOfBytes(sum))
+	return nil
+}
+
+// pskVerifier checks signed messages against the peers' keys, and signs the
+// messages sent to them.
+type pskVerifier struct {
+	mu   sync.Mutex
+	keys map[string][]byte    // peer ID -> key
+	seen map[string]time.Time // HMAC -> first seen
+	// order holds the HMACs of seen by first seen, so the expired ones are
+	// evicted from the front instead of sweeping the whole cache.
+	order []seenHMAC
+}
+
+type seenHMAC struct {
+	mac string
+	at  time.Time
+}
+
+func newPSKVerifier(keys map[string][]byte) *pskVerifier {
+	return &pskVerifier{keys: keys, seen: make(map[string]time.Time)}
+}
+
+// setKeys replaces the peers' keys (config reload). The replay cache is
+// kept, so a request seen before the reload cannot be replayed after it.
+func (v *pskVerifier) setKeys(keys map[string][]byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+}
+
+// enabled reports whether any peer uses PSK authentication.
+func (v *pskVerifier) enabled() bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.keys) > 0
+}
+
+// sign sets the auth_hmac field of a message sent to peerID. Messages to
+// peers without a PSK are left unsigned.
+func (v *pskVerifier) sign(peerID string, msg authMessage) error {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	key, ok := v.keys[peerID]
+	v.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return signMessage(key, msg)
+}
+
+// verify authenticates a message from peerID. Peers without a PSK are not
+// checked.
+func (v *pskVerifier) verify(peerID string, msg authMessage, now time.Time) error {
+	v.mu.Lock()
+	key, ok := v.keys[peerID]
+	v.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if len(msg.GetAuthHmac()) == 0 {
+		return status.Errorf(codes.Unauthenticated, "peer %q requires PSK authentication", peerID)
+	}
+	skew := now.Sub(time.UnixMilli(msg.GetTimestampMs()))
+	if skew > pskReplayWindow || skew < -pskReplayWindow {
+		return status.Errorf(codes.Unauthenticated, "message timestamp outside the %v replay window (skew %v)", pskReplayWindow, skew.Round(time.Millisecond))
+	}
+	want, err := computeHMAC(key, msg)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to verify HMAC: %v", err)
+	}
+	if !hmac.Equal(want, msg.GetAuthHmac()) {
+		return status.Errorf(codes.Unauthenticated, "invalid HMAC for peer %q", peerID)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.expireSeen(now)
+	if _, dup := v.seen[string(want)]; dup {
+		return status.Errorf(codes.Unauthenticated, "replayed message from peer %q", peerID)
+	}
+	v.seen[string(want)] = now
+	v.order = append(v.order, seenHMAC{mac: string(want), at: now})
+	return nil
+}
+
+// expireSeen forgets the HMACs seen more than twice the replay window ago:
+// their timestamp is already rejected. Called with v.mu held.
+func (v *pskVerifier) expireSeen(now time.Time) {
+	n := 0
+	for n < len(v.order) && now.Sub(v.order[n].at) > 2*pskReplayWindow {
+		delete(v.seen, v.order[n].mac)
+		n++
+	}
+	// The evicted prefix is released when append reallocates.
+	v.order = v.order[n:]
+}
+
+// pskDialOptions returns the client interceptors that sign every outgoing
+// request (unary requests and each message sent on a stream) with the key of
+// peerID, and authenticate the signed messages it answers with (StateResponse,
+// and each message of the Keepalive and WatchRoutes streams).
+func pskDialOptions(peerID string, key []byte) []grpc.DialOption {
+	v := newPSKVerifier(map[string][]byte{peerID: key})
+	unary := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if r, ok := req.(authMessage); ok {
+			if err := signMessage(key, r); err != nil {
+				return err
+			}
+		}
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		return v.verifyResponse(peerID, reply)
+	}
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &signingStream{ClientStream: cs, key: key, peerID: peerID, verifier: v}, nil
+	}
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(unary),
+		grpc.WithStreamInterceptor(stream),
+	}
+}
+
+// verifyResponse authenticates a message received from peerID. Messages
+// without an auth_hmac field (e.g. RouteAck) carry no state and are not
+// checked.
+func (v *pskVerifier) verifyResponse(peerID string, m any) error {
+	r, ok := m.(authMessage)
+	if !ok {
+		return nil
+	}
+	if r.GetNodeId() != peerID {
+		return status.Errorf(codes.Unauthenticated, "response from %q, expected peer %q", r.GetNodeId(), peerID)
+	}
+	return v.verify(peerID, r, time.Now())
+}
+
+// signingStream signs every message sent on a client stream and
+// authenticates every message received on it.
+type signingStream struct {
+	grpc.ClientStream
+	key      []byte
+	peerID   string
+	verifier *pskVerifier
+}
+
+func (s *signingStream) SendMsg(m any) error {
+	if r, ok := m.(authMessage); ok {
+		if err := signMessage(s.key, r); err != nil {
+			return err
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *signingStream) RecvMsg(m any) error {
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.verifier.verifyResponse(s.peerID, m)
+}
@@ -0,0 +1,226 @@
+package controlplane
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+func TestLoadPSK(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peer.key")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NNET_TEST_PSK", "from-env")
+
+	cases := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "file:" + path, want: "s3cret"},
+		{ref: "env:NNET_TEST_PSK", want: "from-env"},
+		{ref: "env:NNET_TEST_PSK_UNSET", wantErr: true},
+		{ref: "file:" + filepath.Join(t.TempDir(), "missing.key"), wantErr: true},
+		{ref: path, wantErr: true}, // no scheme
+	}
+	for _, tc := range cases {
+		got, err := LoadPSK(tc.ref)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("LoadPSK(%q): expected error", tc.ref)
+			}
+			continue
+		}
+		if err != nil || string(got) != tc.want {
+			t.Errorf("LoadPSK(%q) = (%q, %v), want %q", tc.ref, got, err, tc.want)
+		}
+	}
+}
+
+func newPSKServer() *Server {
+	cfg := &config.Config{
+		Version: 2,
+		Node:    config.NodeConfig{ID: "self"},
+		Peers: []config.PeerConfig{
+			{ID: "a", Auth: config.AuthConfig{Mode: "psk"}},
+			{ID: "open"},
+		},
+	}
+	s := NewServer(cfg, NewRouteTable(), slog.Default())
+	s.psk = newPSKVerifier(map[string][]byte{"a": []byte("key-a")})
+	return s
+}
+
+func signedAnnouncement(t *testing.T, key string, nodeID string, ts time.Time) *pb.RouteAnnouncement {
+	t.Helper()
+	req := &pb.RouteAnnouncement{
+		NodeId:      nodeID,
+		Routes:      []*pb.Route{{Prefix: "10.0.0.0/24", NextHop: "10.0.0.1", Vni: 100}},
+		TimestampMs: ts.UnixMilli(),
+	}
+	if err := signMessage([]byte(key), req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestResolvePeerID_PSK(t *testing.T) {
+	s := newPSKServer()
+	ctx := context.Background()
+
+	req := signedAnnouncement(t, "key-a", "a", time.Now())
+	if id, err := s.resolvePeerID(ctx, req); err != nil || id != "a" {
+		t.Fatalf("expected valid signed request to resolve to a, got (%q, %v)", id, err)
+	}
+	if _, err := s.resolvePeerID(ctx, req); err == nil {
+		t.Fatal("expected exact replay to be rejected")
+	}
+
+	tampered := signedAnnouncement(t, "key-a", "a", time.Now())
+	tampered.Routes[0].NextHop = "10.0.0.66"
+	if _, err := s.resolvePeerID(ctx, tampered); err == nil {
+		t.Fatal("expected tampered payload to be rejected")
+	}
+
+	if _, err := s.resolvePeerID(ctx, signedAnnouncement(t, "wrong-key", "a", time.Now())); err == nil {
+		t.Fatal("expected wrong key to be rejected")
+	}
+	if _, err := s.resolvePeerID(ctx, signedAnnouncement(t, "key-a", "a", time.Now().Add(-time.Minute))); err == nil {
+		t.Fatal("expected stale timestamp to be rejected")
+	}
+	if _, err := s.resolvePeerID(ctx, &pb.RouteAnnouncement{NodeId: "a", TimestampMs: time.Now().UnixMilli()}); err == nil {
+		t.Fatal("expected unsigned request from a PSK peer to be rejected")
+	}
+
+	// Peers without PSK are unaffected; unknown identities are refused.
+	if _, err := s.resolvePeerID(ctx, &pb.RouteAnnouncement{NodeId: "open"}); err != nil {
+		t.Fatalf("expected non-PSK peer to be accepted, got %v", err)
+	}
+	if _, err := s.resolvePeerID(ctx, &pb.RouteAnnouncement{NodeId: "intruder"}); err == nil {
+		t.Fatal("expected unknown node_id to be rejected when PSK is in use")
+	}
+}
+
+func TestPSKVerifier_ExpiresSeen(t *testing.T) {
+	v := newPSKVerifier(map[string][]byte{"a": []byte("key-a")})
+	t0 := time.Now()
+
+	first := signedAnnouncement(t, "key-a", "a", t0)
+	if err := v.verify("a", first, t0); err != nil {
+		t.Fatalf("expected first message to verify, got %v", err)
+	}
+	second := signedAnnouncement(t, "key-a", "a", t0.Add(pskReplayWindow))
+	if err := v.verify("a", second, t0.Add(pskReplayWindow)); err != nil {
+		t.Fatalf("expected second message to verify, got %v", err)
+	}
+
+	later := t0.Add(2*pskReplayWindow + time.Second)
+	third := signedAnnouncement(t, "key-a", "a", later)
+	if err := v.verify("a", third, later); err != nil {
+		t.Fatalf("expected third message to verify, got %v", err)
+	}
+	if _, ok := v.seen[string(first.GetAuthHmac())]; ok {
+		t.Error("expected the expired HMAC to be evicted")
+	}
+	if len(v.seen) != 2 || len(v.order) != 2 {
+		t.Errorf("expected 2 HMACs left, got seen=%d order=%d", len(v.seen), len(v.order))
+	}
+	if err := v.verify("a", third, later); err == nil {
+		t.Error("expected replay of a remembered HMAC to be rejected")
+	}
+}
+
+func TestPSKDialOptions_SignRequests(t *testing.T) {
+	s := newPSKServer()
+
+	req := func() *pb.StateRequest {
+		return &pb.StateRequest{NodeId: "a", TimestampMs: time.Now().UnixMilli()}
+	}
+	if _, err := newBufconnClient(t, s).ExchangeState(context.Background(), req()); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unsigned client to be rejected with Unauthenticated, got %v", err)
+	}
+	signed := newBufconnClient(t, s, pskDialOptions("self", []byte("key-a"))...)
+	if _, err := signed.ExchangeState(context.Background(), req()); err != nil {
+		t.Fatalf("expected signed unary request and response to be accepted, got %v", err)
+	}
+
+	stream, err := signed.Keepalive(context.Background())
+	if err != nil {
+		t.Fatalf("open keepalive: %v", err)
+	}
+	for seq := uint64(1); seq <= 2; seq++ {
+		if err := stream.Send(&pb.KeepaliveRequest{NodeId: "a", Sequence: seq, TimestampMs: time.Now().UnixMilli()}); err != nil {
+			t.Fatalf("send keepalive: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("expected signed keepalive %d to be answered, got %v", seq, err)
+		}
+	}
+}
+
+func TestPSKDialOptions_VerifyResponses(t *testing.T) {
+	// A server that does not sign its answers (no PSK for a).
+	s := newPSKServer()
+	s.psk = newPSKVerifier(nil)
+	signed := newBufconnClient(t, s, pskDialOptions("self", []byte("key-a"))...)
+
+	req := &pb.StateRequest{NodeId: "a", TimestampMs: time.Now().UnixMilli()}
+	if _, err := signed.ExchangeState(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unsigned StateResponse to be rejected with Unauthenticated, got %v", err)
+	}
+
+	stream, err := signed.WatchRoutes(context.Background(), &pb.WatchRoutesRequest{NodeId: "a", TimestampMs: time.Now().UnixMilli()})
+	if err != nil {
+		t.Fatalf("open watch: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unsigned RouteUpdate to be rejected with Unauthenticated, got %v", err)
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	v := newPSKVerifier(map[string][]byte{"b": []byte("key-b")})
+	resp := func(nodeID string) *pb.StateResponse {
+		r := &pb.StateResponse{NodeId: nodeID, TimestampMs: time.Now().UnixMilli(), Accepted: true}
+		if err := signMessage([]byte("key-b"), r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if err := v.verifyResponse("b", resp("b")); err != nil {
+		t.Fatalf("expected signed response from b to be accepted, got %v", err)
+	}
+	if err := v.verifyResponse("b", resp("c")); err == nil {
+		t.Fatal("expected response claiming another node to be rejected")
+	}
+	tampered := resp("b")
+	tampered.Routes = []*pb.Route{{Prefix: "0.0.0.0/0", NextHop: "10.0.0.66", Vni: 100}}
+	if err := v.verifyResponse("b", tampered); err == nil {
+		t.Fatal("expected tampered response to be rejected")
+	}
+
+	// A signed request with the same encoding is not a valid response.
+	req := &pb.StateRequest{NodeId: "b", TimestampMs: time.Now().UnixMilli()}
+	if err := signMessage([]byte("key-b"), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.verifyResponse("b", &pb.StateResponse{NodeId: req.NodeId, TimestampMs: req.TimestampMs, AuthHmac: req.AuthHmac}); err == nil {
+		t.Fatal("expected a request signature to be rejected on a response")
+	}
+
+	// Messages without auth_hmac (acks) are not checked.
+	if err := v.verifyResponse("b", &pb.RouteAck{Accepted: true}); err != nil {
+		t.Fatalf("expected RouteAck to pass, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package controlplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+)
+
+// Route streaming: a node watches the routes of each peer with WatchRoutes.
+// The peer sends a snapshot of the routes it exports to the watcher, then
+// only the changes, each update numbered. While the stream is open the
+// watcher renews the leases of the routes itself, so leases only expire when
+// the peer crashes without anyone noticing; periodic re-announcements are
+// kept for peers that predate WatchRoutes.
+
+const (
+	// watchRecheckInterval is how often a WatchRoutes stream re-evaluates
+	// the exported routes without a change notification. Only differences
+	// are sent, so an idle stream stays silent.
+	watchRecheckInterval = 10 * time.Second
+
+	// watchRenewInterval is how often the watcher renews the leases of the
+	// routes received over an open stream.
+	watchRenewInterval = 10 * time.Second
+
+	// watchRetryMin and watchRetryMax bound the backoff between attempts to
+	// open a stream; watchRetryLegacy is the interval used with peers that do
+	// not implement WatchRoutes, in case they get upgraded.
+	watchRetryMin    = time.Second
+	watchRetryMax    = 30 * time.Second
+	watchRetryLegacy = time.Minute
+
+	// watchHealthPoll is how often a closed stream checks whether its peer is
+	// healthy again.
+	watchHealthPoll = 250 * time.Millisecond
+)
+
+// errResync ends a stream whose updates cannot be applied in order.
+var errResync = errors.New("route stream out of sync")
+
+// NotifyExportsChanged wakes up the WatchRoutes streams so they send the
+// changes of the exported routes right away instead of at their next
+// recheck.
+func (s *Server) NotifyExportsChanged() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for notify := range s.watchers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// IsWatching reports whether a peer currently watches this node's routes.
+func (s *Server) IsWatching(peerID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range s.watchers {
+		if id == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchRoutes implements the WatchRoutes RPC.
+func (s *Server) WatchRoutes(req *pb.WatchRoutesRequest, stream grpc.ServerStreamingServer[pb.RouteUpdate]) error {
+	ctx := stream.Context()
+	peerID, err := s.resolvePeerID(ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := s.checkTopology(peerID); err != nil {
+		return err
+	}
+
+	notify := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.watchers[notify] = peerID
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, notify)
+		s.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(watchRecheckInterval)
+	defer ticker.Stop()
+
+	var (
+		sent = make(map[string]*pb.Route)
+		seq  uint64
+	)
+	send := func(snapshot bool) error {
+		cfg := s.cfg.Load()
+		announced, withdrawn := diffExports(sent, routesForPeer(cfg, s.getExportableRoutes(), peerID))
+		if !snapshot && len(announced) == 0 && len(withdrawn) == 0 {
+			return nil
+		}
+		seq++
+		update := &pb.RouteUpdate{
+			NodeId:      cfg.Node.ID,
+			Sequence:    seq,
+			Snapshot:    snapshot,
+			Announced:   announced,
+			Withdrawn:   withdrawn,
+			TimestampMs: time.Now().UnixMilli(),
+		}
+		if err := s.psk.sign(peerID, update); err != nil {
+			return status.Errorf(codes.Internal, "failed to sign route update: %v", err)
+		}
+		return stream.Send(update)
+	}
+
+	if err := send(true); err != nil {
+		return err
+	}
+	s.logger.Info("peer watching routes", "peer_id", peerID, "routes", len(sent))
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("peer stopped watching routes", "peer_id", peerID)
+			return nil
+		case <-notify:
+		case <-ticker.C:
+		}
+		if err := send(false); err != nil {
+			return err
+		}
+	}
+}
+
+// exportKey identifies an exported route on a stream: a peer holds one route
+// per (VNI, prefix) from each node.
+func exportKey(r *pb.Route) string {
+	return fmt.Sprintf("%d|%s", r.Vni, r.Prefix)
+}
+
+// diffExports compares the routes last sent on a stream with the current
+// ones and updates sent. It returns the new or changed routes and the
+// withdrawn ones (VNI and prefix only). Only the first route of current for
+// a (VNI, prefix) counts, as the peer would only keep one.
+func diffExports(sent map[string]*pb.Route, current []*pb.Route) (announced, withdrawn []*pb.Route) {
+	seen := make(map[string]bool, len(current))
+	for _, r := range current {
+		key := exportKey(r)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if prev, ok := sent[key]; ok && proto.Equal(prev, r) {
+			continue
+		}
+		sent[key] = r
+		announced = append(announced, r)
+	}
+	for key, r := range sent {
+		if !seen[key] {
+			withdrawn = append(withdrawn, &pb.Route{Prefix: r.Prefix, Vni: r.Vni})
+			delete(sent, key)
+		}
+	}
+	return announced, withdrawn
+}
+
+// SetRoutesWithdrawnCallback sets the callback for routes withdrawn on a
+// WatchRoutes stream, so they are removed from the kernel.
+func (c *Client) SetRoutesWithdrawnCallback(fn func(routes []Route)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRoutesWithdrawn = fn
+}
+
+// SetRouteWatcherFunc sets the function reporting whether a peer watches
+// this node's routes (see Server.IsWatching). AnnounceRoutes skips those
+// peers: they already receive every change on their stream.
+func (c *Client) SetRouteWatcherFunc(fn func(peerID string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isWatching = fn
+}
+
+// runWatch keeps a WatchRoutes stream open with a peer until ctx is
+// cancelled. A stream is only opened while the peer is healthy, and is
+// closed when its dead timer fires (see peerDead): the routes received on
+// it may be flushed then, and the next stream starts with a snapshot that
+// reinstalls them.
+func (c *Client) runWatch(ctx context.Context, peerID string, client pb.NNetManClient) {
+	backoff := watchRetryMin
+	for {
+		if !c.waitHealthy(ctx, peerID) {
+			return
+		}
+
+		start := time.Now()
+		err := c.watchRoutes(ctx, peerID, client)
+		c.setRouteStream(peerID, client, nil)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A stream that ran for a while resets the backoff.
+		if time.Since(start) > watchRetryMax {
+			backoff = watchRetryMin
+		}
+		wait := backoff
+		switch {
+		case errors.Is(err, errResync):
+			c.logger.Warn("route stream out of sync, resyncing", "peer_id", peerID, "error", err)
+			wait = 0
+		case status.Code(err) == codes.Unimplemented:
+			c.logger.Debug("peer does not support route streaming, using periodic announcements", "peer_id", peerID)
+			wait = watchRetryLegacy
+		case err != nil:
+			c.logger.Debug("route stream closed", "peer_id", peerID, "error", err)
+			backoff = min(backoff*2, watchRetryMax)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// waitHealthy blocks until the peer is healthy and not torn down for
+// exceeding its max_prefixes. It returns false when ctx is cancelled or the
+// peer's session is gone.
+func (c *Client) waitHealthy(ctx context.Context, peerID string) bool {
+	ticker := time.NewTicker(watchHealthPoll)
+	defer ticker.Stop()
+	for {
+		c.mu.RLock()
+		pc, ok := c.conns[peerID]
+		healthy := ok && pc.healthy
+		limits := c.limits
+		c.mu.RUnlock()
+		if !ok {
+			return false
+		}
+		if healthy && !limits.tornDown(peerID) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// setRouteStream records the cancel function of the peer's open stream, or
+// nil once it is closed. Streams of a session that was replaced in the
+// meantime (peer reset or reconnect) are ignored.
+func (c *Client) setRouteStream(peerID string, client pb.NNetManClient, cancel context.CancelFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pc, ok := c.conns[peerID]; ok && pc.client == client {
+		pc.watchCancel = cancel
+	}
+}
+
+// HasRouteStream reports whether the routes of a peer are received over an
+// open WatchRoutes stream.
+func (c *Client) HasRouteStream(peerID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pc, ok := c.conns[peerID]
+	return ok && pc.watchCancel != nil
+}
+
+// watchRoutes runs one WatchRoutes stream until it fails.
+func (c *Client) watchRoutes(ctx context.Context, peerID string, client pb.NNetManClient) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.WatchRoutes(streamCtx, &pb.WatchRoutesRequest{
+		NodeId:      c.cfg.Load().Node.ID,
+		TimestampMs: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	updates := make(chan *pb.RouteUpdate, 16)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			u, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case updates <- u:
+			case <-streamCtx.Done():
+			}
+		}
+	}()
+
+	renew := time.NewTicker(watchRenewInterval)
+	defer renew.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case err := <-errs:
+			return err
+
+		case u := <-updates:
+			if seq == 0 && !u.Snapshot {
+				return fmt.Errorf("%w: stream did not start with a snapshot", errResync)
+			}
+			if seq != 0 && u.Sequence != seq+1 {
+				return fmt.Errorf("%w: got update %d after %d", errResync, u.Sequence, seq)
+			}
+			if seq == 0 {
+				c.setRouteStream(peerID, client, cancel)
+			}
+			seq = u.Sequence
+			if err := c.applyRouteUpdate(peerID, u); err != nil {
+				return err
+			}
+
+		case <-renew.C:
+			c.routeTable.Renew(peerID, time.Now())
+		}
+	}
+}
+
+// applyRouteUpdate stores the routes of an update and installs or removes
+// them through the callbacks. A snapshot replaces every route of the peer.
+// It fails when the peer is torn down for exceeding its max_prefixes.
+func (c *Client) applyRouteUpdate(peerID string, u *pb.RouteUpdate) error {
+	received, err := c.ingestRoutes(u.Announced, peerID)
+	if err != nil {
+		return err
+	}
+
+	var withdrawn []Route
+	if u.Snapshot {
+		keep := make(map[string]bool, len(received))
+		for _, r := range received {
+			keep[routeKey(r)] = true
+		}
+		for _, r := range c.routeTable.GetByPeer(peerID) {
+			if !keep[routeKey(r)] {
+				c.routeTable.Remove(r)
+				withdrawn = append(withdrawn, r)
+			}
+		}
+	}
+	for _, w := range u.Withdrawn {
+		if r, ok := c.routeTable.RemoveRoute(w.Vni, w.Prefix, peerID); ok {
+			withdrawn = append(withdrawn, r)
+		}
+	}
+
+	c.mu.RLock()
+	onReceived, onWithdrawn := c.onRoutesReceived, c.onRoutesWithdrawn
+	c.mu.RUnlock()
+	if onReceived != nil && len(received) > 0 {
+		onReceived(received)
+	}
+	if onWithdrawn != nil && len(withdrawn) > 0 {
+		onWithdrawn(withdrawn)
+	}
+
+	c.logger.Debug("applied route update",
+		"peer_id", peerID,
+		"sequence", u.Sequence,
+		"snapshot", u.Snapshot,
+		"announced", len(received),
+		"withdrawn", len(withdrawn),
+	)
+	return nil
+}
@@ -0,0 +1,170 @@
+package controlplane
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/nishisan-dev/n-netman/api/v1"
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+func TestDiffExports(t *testing.T) {
+	sent := make(map[string]*pb.Route)
+	current := []*pb.Route{
+		{Prefix: "10.1.0.0/24", NextHop: "10.100.0.1", Vni: 100},
+		{Prefix: "10.1.0.0/24", NextHop: "10.200.0.1", Vni: 200},
+	}
+	announced, withdrawn := diffExports(sent, current)
+	if len(announced) != 2 || len(withdrawn) != 0 {
+		t.Fatalf("first diff = %d announced, %d withdrawn, want 2 and 0", len(announced), len(withdrawn))
+	}
+
+	// Nothing changed: nothing to send.
+	announced, withdrawn = diffExports(sent, current)
+	if len(announced) != 0 || len(withdrawn) != 0 {
+		t.Fatalf("unchanged diff = %v / %v, want nothing", announced, withdrawn)
+	}
+
+	// The metric of one route changes and the other one goes away.
+	current = []*pb.Route{{Prefix: "10.1.0.0/24", NextHop: "10.100.0.1", Vni: 100, Metric: 50}}
+	announced, withdrawn = diffExports(sent, current)
+	if len(announced) != 1 || announced[0].Metric != 50 {
+		t.Fatalf("announced = %v, want the route with the new metric", announced)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].Vni != 200 || withdrawn[0].Prefix != "10.1.0.0/24" || withdrawn[0].NextHop != "" {
+		t.Fatalf("withdrawn = %v, want only VNI 200 10.1.0.0/24", withdrawn)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent holds %d routes, want 1", len(sent))
+	}
+}
+
+func TestDiffExports_DuplicateKey(t *testing.T) {
+	// Two routes for one (VNI, prefix): the first one is sent, and a pass
+	// with the same routes sends nothing.
+	sent := make(map[string]*pb.Route)
+	current := []*pb.Route{
+		{Prefix: "10.1.0.0/24", NextHop: "10.100.0.1", Vni: 100, OriginatorId: "a"},
+		{Prefix: "10.1.0.0/24", NextHop: "10.100.0.1", Vni: 100, OriginatorId: "b"},
+	}
+	announced, _ := diffExports(sent, current)
+	if len(announced) != 1 || announced[0].OriginatorId != "a" {
+		t.Fatalf("announced = %v, want the route from a only", announced)
+	}
+	if announced, withdrawn := diffExports(sent, current); len(announced) != 0 || len(withdrawn) != 0 {
+		t.Fatalf("unchanged diff = %v / %v, want nothing", announced, withdrawn)
+	}
+}
+
+func TestRouteTable_Renew(t *testing.T) {
+	rt := NewRouteTable()
+	rt.Add(Route{Prefix: "10.1.0.0/24", VNI: 100, PeerID: "b", LeaseSeconds: 30})
+	rt.Add(Route{Prefix: "10.2.0.0/24", VNI: 100, PeerID: "b", LeaseSeconds: 30, RelayedVia: "c"})
+	rt.Add(Route{Prefix: "10.3.0.0/24", VNI: 100, PeerID: "d", LeaseSeconds: 30})
+
+	now := time.Now().Add(time.Hour)
+	rt.Renew("b", now)
+	for _, r := range rt.All() {
+		renewed := r.ExpiresAt.Equal(now.Add(30 * time.Second))
+		if want := r.Prefix == "10.1.0.0/24"; renewed != want {
+			t.Errorf("route %s renewed = %v, want %v", r.Prefix, renewed, want)
+		}
+	}
+}
+
+func TestApplyRouteUpdate_SnapshotReplacesPeerRoutes(t *testing.T) {
+	c := NewClient(&config.Config{Version: 2, Node: config.NodeConfig{ID: "a"}, Peers: []config.PeerConfig{{ID: "b"}}}, NewRouteTable(), slog.Default())
+	var withdrawn []Route
+	c.SetRoutesWithdrawnCallback(func(routes []Route) { withdrawn = append(withdrawn, routes...) })
+
+	// Routes held from a previous session of the peer.
+	c.routeTable.Add(Route{Prefix: "10.1.0.0/24", NextHop: "10.100.0.2", VNI: 100, PeerID: "b"})
+	c.routeTable.Add(Route{Prefix: "10.2.0.0/24", NextHop: "10.100.0.2", VNI: 100, PeerID: "b"})
+
+	c.applyRouteUpdate("b", &pb.RouteUpdate{
+		NodeId:    "b",
+		Sequence:  1,
+		Snapshot:  true,
+		Announced: []*pb.Route{{Prefix: "10.1.0.0/24", NextHop: "10.100.0.2", Vni: 100, OriginatorId: "b", Path: []string{"b"}}},
+	})
+	if len(withdrawn) != 1 || withdrawn[0].Prefix != "10.2.0.0/24" {
+		t.Fatalf("withdrawn = %+v, want the route missing from the snapshot", withdrawn)
+	}
+	if routes := c.routeTable.GetByPeer("b"); len(routes) != 1 || routes[0].Prefix != "10.1.0.0/24" {
+		t.Fatalf("routes of b = %+v, want only 10.1.0.0/24", routes)
+	}
+}
+
+func TestWatchRoutes_SnapshotThenChanges(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		exports = []Route{
+			{Prefix: "10.1.0.0/24", NextHop: "10.100.0.2", VNI: 100, LeaseSeconds: 30},
+			{Prefix: "10.2.0.0/24", NextHop: "10.100.0.2", VNI: 100, LeaseSeconds: 30},
+		}
+	)
+	srv := NewServer(&config.Config{Node: config.NodeConfig{ID: "b"}}, NewRouteTable(), slog.Default())
+	srv.SetExportRoutesFunc(func() []Route {
+		mu.Lock()
+		defer mu.Unlock()
+		return exports
+	})
+
+	client := newBufconnClient(t, srv)
+	c := NewClient(&config.Config{Version: 2, Node: config.NodeConfig{ID: "a"}, Peers: []config.PeerConfig{{ID: "b"}}}, NewRouteTable(), slog.Default())
+	c.conns["b"] = &peerConn{peerID: "b", client: client, healthy: true}
+	withdrawn := make(chan Route, 4)
+	c.SetRoutesWithdrawnCallback(func(routes []Route) {
+		for _, r := range routes {
+			withdrawn <- r
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.runWatch(ctx, "b", client)
+
+	// The snapshot installs both routes.
+	waitFor(t, "snapshot", func() bool { return len(c.routeTable.GetByPeer("b")) == 2 && c.HasRouteStream("b") })
+	if !srv.IsWatching("a") {
+		t.Fatal("expected the server to report a watching a")
+	}
+
+	// One export goes away: only its withdrawal is sent.
+	mu.Lock()
+	exports = exports[:1]
+	mu.Unlock()
+	srv.NotifyExportsChanged()
+
+	select {
+	case r := <-withdrawn:
+		if r.Prefix != "10.2.0.0/24" || r.VNI != 100 {
+			t.Fatalf("withdrawn %+v, want VNI 100 10.2.0.0/24", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("withdrawal not received")
+	}
+	if routes := c.routeTable.GetByPeer("b"); len(routes) != 1 || routes[0].Prefix != "10.1.0.0/24" {
+		t.Fatalf("routes of b = %+v, want only 10.1.0.0/24", routes)
+	}
+
+	// Closing the watcher unregisters it on the server.
+	cancel()
+	waitFor(t, "unregister", func() bool { return !srv.IsWatching("a") })
+}
+
+// waitFor polls cond until it holds or fails the test after 2s.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
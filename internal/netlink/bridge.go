@@ -3,6 +3,9 @@ package netlink
 import (
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/vishvananda/netlink"
 )
@@ -36,12 +39,17 @@ func (m *BridgeManager) Create(cfg BridgeConfig) error {
 			// Refuse to destroy a non-bridge interface that shares the name.
 			return fmt.Errorf("interface %s exists but is not a bridge (%T); refusing to replace it", cfg.Name, existing)
 		}
-		// Reconcile MTU drift and ensure the bridge is up.
+		// Reconcile MTU and STP drift and ensure the bridge is up.
 		if cfg.MTU > 0 && existing.Attrs().MTU != cfg.MTU {
 			if err := netlink.LinkSetMTU(existing, cfg.MTU); err != nil {
 				return fmt.Errorf("failed to set MTU on bridge %s: %w", cfg.Name, err)
 			}
 		}
+		if bridgeSTP(cfg.Name) != cfg.STP {
+			if err := setBridgeSTP(cfg.Name, cfg.STP); err != nil {
+				return err
+			}
+		}
 		return netlink.LinkSetUp(existing)
 	}
 
@@ -63,9 +71,11 @@ func (m *BridgeManager) Create(cfg BridgeConfig) error {
 		return fmt.Errorf("failed to get created bridge %s: %w", cfg.Name, err)
 	}
 
-	// Configure STP
-	// Note: STP is controlled via sysfs, not netlink directly
-	// For now, we skip STP configuration (can be added later via sysfs)
+	if cfg.STP {
+		if err := setBridgeSTP(cfg.Name, true); err != nil {
+			return err
+		}
+	}
 
 	// Bring bridge up
 	if err := netlink.LinkSetUp(link); err != nil {
@@ -134,6 +144,7 @@ func (m *BridgeManager) Get(name string) (*BridgeInfo, error) {
 		Name:               bridge.Attrs().Name,
 		MTU:                bridge.Attrs().MTU,
 		Up:                 bridge.Attrs().Flags&net.FlagUp != 0,
+		STP:                bridgeSTP(name),
 		AttachedInterfaces: attachedInterfaces,
 	}, nil
 }
@@ -143,9 +154,38 @@ type BridgeInfo struct {
 	Name               string
 	MTU                int
 	Up                 bool
+	STP                bool
 	AttachedInterfaces []string
 }
 
+// STP is controlled via sysfs, not netlink: stp_state is 0 when disabled,
+// 1 (kernel) or 2 (user space, e.g. mstpd) when enabled.
+func bridgeSTPPath(name string) string {
+	return filepath.Join("/sys/class/net", name, "bridge", "stp_state")
+}
+
+// bridgeSTP reports whether STP is enabled on a bridge. A state that cannot
+// be read (e.g. sysfs of another network namespace) counts as disabled.
+func bridgeSTP(name string) bool {
+	data, err := os.ReadFile(bridgeSTPPath(name))
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) != "0"
+}
+
+// setBridgeSTP enables or disables STP on a bridge.
+func setBridgeSTP(name string, enabled bool) error {
+	state := "0"
+	if enabled {
+		state = "1"
+	}
+	if err := os.WriteFile(bridgeSTPPath(name), []byte(state), 0o644); err != nil {
+		return fmt.Errorf("failed to set STP on bridge %s: %w", name, err)
+	}
+	return nil
+}
+
 // AddInterface adds an interface to the bridge.
 func (m *BridgeManager) AddInterface(bridgeName, ifaceName string) error {
 	bridgeLink, err := netlink.LinkByName(bridgeName)
@@ -217,3 +257,52 @@ func (m *BridgeManager) AddAddress(bridgeName, cidr string) error {
 
 	return nil
 }
+
+// Addresses returns the addresses configured on a bridge in CIDR format.
+func (m *BridgeManager) Addresses(bridgeName string) ([]string, error) {
+	link, err := netlink.LinkByName(bridgeName)
+	if err != nil {
+		return nil, fmt.Errorf("bridge %s not found: %w", bridgeName, err)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses on %s: %w", bridgeName, err)
+	}
+
+	result := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		result = append(result, a.IPNet.String())
+	}
+	return result, nil
+}
+
+// DeleteAddress removes an IP address from a bridge interface. A missing
+// bridge or address is not an error.
+func (m *BridgeManager) DeleteAddress(bridgeName, cidr string) error {
+	link, err := netlink.LinkByName(bridgeName)
+	if err != nil {
+		return nil
+	}
+
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", cidr, err)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses on %s: %w", bridgeName, err)
+	}
+
+	for _, a := range addrs {
+		if a.IPNet.String() == addr.IPNet.String() {
+			if err := netlink.AddrDel(link, &a); err != nil {
+				return fmt.Errorf("failed to delete address %s from %s: %w", cidr, bridgeName, err)
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
 	"golang.org/x/sys/unix"
 )
 
@@ -24,6 +26,9 @@ type FDBEntry struct {
 	RemoteIP  net.IP           // Remote VTEP IP
 	VXLANName string           // VXLAN interface name
 	Permanent bool             // Permanent entry (won't age out)
+	// Via is the underlay interface the encapsulated traffic to RemoteIP
+	// leaves through (`via` in `bridge fdb`); empty when unset.
+	Via string
 }
 
 // Add adds an FDB entry to a VXLAN interface.
@@ -107,6 +112,11 @@ func (m *FDBManager) List(vxlanName string) ([]FDBEntry, error) {
 		return nil, fmt.Errorf("failed to list FDB entries: %w", err)
 	}
 
+	vias, err := fdbVias(link.Attrs().Index)
+	if err != nil {
+		return nil, err
+	}
+
 	var entries []FDBEntry
 	for _, n := range neighs {
 		// Filter to only FDB entries with IP addresses (VTEP destinations)
@@ -116,6 +126,7 @@ func (m *FDBManager) List(vxlanName string) ([]FDBEntry, error) {
 				RemoteIP:  n.IP,
 				VXLANName: vxlanName,
 				Permanent: n.State == netlink.NUD_PERMANENT,
+				Via:       vias[fdbKey(n.HardwareAddr, n.IP)],
 			})
 		}
 	}
@@ -123,9 +134,69 @@ func (m *FDBManager) List(vxlanName string) ([]FDBEntry, error) {
 	return entries, nil
 }
 
+// fdbKey identifies an FDB entry of a VXLAN interface.
+func fdbKey(mac net.HardwareAddr, ip net.IP) string {
+	if IsZeroMAC(mac) {
+		mac = make(net.HardwareAddr, 6)
+	}
+	return mac.String() + "/" + ip.String()
+}
+
+// fdbVias returns the `via` interface (NDA_IFINDEX) of the FDB entries of a
+// VXLAN interface that have one, by fdbKey. The netlink library does not
+// decode this attribute, so the neighbour table is dumped here directly.
+func fdbVias(linkIndex int) (map[string]string, error) {
+	req := nl.NewNetlinkRequest(unix.RTM_GETNEIGH, unix.NLM_F_DUMP)
+	req.AddData(&netlink.Ndmsg{Family: unix.AF_BRIDGE, Index: uint32(linkIndex)})
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWNEIGH)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list FDB entries: %w", err)
+	}
+
+	vias := make(map[string]string)
+	names := make(map[int]string)
+	for _, m := range msgs {
+		if len(m) < unix.SizeofNdMsg || int(nl.NativeEndian().Uint32(m[4:8])) != linkIndex {
+			continue
+		}
+		attrs, err := nl.ParseRouteAttr(m[unix.SizeofNdMsg:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FDB entry: %w", err)
+		}
+		var mac net.HardwareAddr
+		var ip net.IP
+		via := 0
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case unix.NDA_LLADDR:
+				mac = net.HardwareAddr(a.Value)
+			case unix.NDA_DST:
+				ip = net.IP(a.Value)
+			case unix.NDA_IFINDEX:
+				via = int(nl.NativeEndian().Uint32(a.Value))
+			}
+		}
+		if ip == nil || via == 0 {
+			continue
+		}
+		name, ok := names[via]
+		if !ok {
+			if l, err := netlink.LinkByIndex(via); err == nil {
+				name = l.Attrs().Name
+			} else {
+				name = strconv.Itoa(via)
+			}
+			names[via] = name
+		}
+		vias[fdbKey(mac, ip)] = name
+	}
+	return vias, nil
+}
+
 // AddPeer adds a remote VXLAN peer (VTEP) to the FDB.
 // This is a convenience method that adds an FDB entry with MAC 00:00:00:00:00:00
-// to enable flooding to this peer for unknown destinations.
+// to enable flooding to this peer for unknown destinations. When via is set,
+// the encapsulated traffic to the peer leaves through that underlay interface.
 //
 // NOTE: We use 'bridge fdb append' command directly instead of vishvananda/netlink
 // library's NeighAppend due to a known issue where NeighAppend returns "operation
@@ -133,13 +204,17 @@ func (m *FDBManager) List(vxlanName string) ([]FDBEntry, error) {
 // See: https://github.com/vishvananda/netlink/issues/714
 // The issue suggests using Family: AF_BRIDGE, Flags: NTF_SELF, State: NUD_PERMANENT,
 // but this combination still fails in some kernel versions/configurations.
-func (m *FDBManager) AddPeer(vxlanName string, remoteIP net.IP) error {
+func (m *FDBManager) AddPeer(vxlanName string, remoteIP net.IP, via string) error {
 	// Use bridge command directly - more reliable than netlink library for VXLAN FDB
-	// bridge fdb append 00:00:00:00:00:00 dev <vxlan> dst <remote_ip>
-	cmd := exec.Command("bridge", "fdb", "append",
+	// bridge fdb append 00:00:00:00:00:00 dev <vxlan> dst <remote_ip> [via <dev>]
+	args := []string{"fdb", "append",
 		"00:00:00:00:00:00",
 		"dev", vxlanName,
-		"dst", remoteIP.String())
+		"dst", remoteIP.String()}
+	if via != "" {
+		args = append(args, "via", via)
+	}
+	cmd := exec.Command("bridge", args...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -163,9 +238,9 @@ func (m *FDBManager) DeletePeer(vxlanName string, remoteIP net.IP) error {
 	})
 }
 
-// isZeroMAC reports whether a hardware address is the all-zeros BUM MAC
+// IsZeroMAC reports whether a hardware address is the all-zeros BUM MAC
 // (00:00:00:00:00:00). Some kernels report it as an empty slice.
-func isZeroMAC(mac net.HardwareAddr) bool {
+func IsZeroMAC(mac net.HardwareAddr) bool {
 	if len(mac) == 0 {
 		return true
 	}
@@ -191,7 +266,7 @@ func (m *FDBManager) SyncPeers(vxlanName string, desiredPeers []net.IP) error {
 	// Build set of current BUM peer IPs (all-zeros MAC entries only).
 	currentPeers := make(map[string]bool)
 	for _, entry := range current {
-		if isZeroMAC(entry.MAC) {
+		if IsZeroMAC(entry.MAC) {
 			currentPeers[entry.RemoteIP.String()] = true
 		}
 	}
@@ -205,7 +280,7 @@ func (m *FDBManager) SyncPeers(vxlanName string, desiredPeers []net.IP) error {
 	// Add missing peers
 	for _, ip := range desiredPeers {
 		if !currentPeers[ip.String()] {
-			if err := m.AddPeer(vxlanName, ip); err != nil {
+			if err := m.AddPeer(vxlanName, ip, ""); err != nil {
 				return fmt.Errorf("failed to add peer %s: %w", ip, err)
 			}
 		}
@@ -213,7 +288,7 @@ func (m *FDBManager) SyncPeers(vxlanName string, desiredPeers []net.IP) error {
 
 	// Remove stale BUM entries (only all-zeros entries we manage).
 	for _, entry := range current {
-		if !isZeroMAC(entry.MAC) {
+		if !IsZeroMAC(entry.MAC) {
 			continue
 		}
 		if !desiredSet[entry.RemoteIP.String()] {
@@ -18,8 +18,8 @@ func TestIsZeroMAC(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if got := isZeroMAC(tc.mac); got != tc.want {
-				t.Fatalf("isZeroMAC(%v) = %v, want %v", tc.mac, got, tc.want)
+			if got := IsZeroMAC(tc.mac); got != tc.want {
+				t.Fatalf("IsZeroMAC(%v) = %v, want %v", tc.mac, got, tc.want)
 			}
 		})
 	}
@@ -0,0 +1,229 @@
+package netlink
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// OwnerAliasPrefix marks the interface alias (ifalias) of the devices created
+// by n-netman, so resources of overlays removed from the config can be found
+// and torn down later. The alias survives daemon restarts.
+const OwnerAliasPrefix = "n-netman:"
+
+// Ownership is what n-netman records on a device it owns. On a VXLAN device
+// it describes everything the overlay put on the host: its bridge, the table
+// its routes and policy rules use, and the addresses added to the bridge. On
+// a bridge only the VNI is recorded.
+type Ownership struct {
+	VNI       int
+	Bridge    string
+	Table     int
+	Addresses []string
+	// IPv6 is set on a VXLAN device with IPv6 VTEPs. The kernel does not
+	// report an unspecified local address, so it is the only trace of the
+	// family of a socket bound to "::" (see LocalAddress).
+	IPv6 bool
+}
+
+// Alias encodes the ownership as an interface alias, e.g.
+// "n-netman:vni=100,bridge=br-100,table=200,addr=10.100.0.1/24,family=ipv6".
+func (o Ownership) Alias() string {
+	parts := []string{"vni=" + strconv.Itoa(o.VNI)}
+	if o.Bridge != "" {
+		parts = append(parts, "bridge="+o.Bridge)
+	}
+	if o.Table != 0 {
+		parts = append(parts, "table="+strconv.Itoa(o.Table))
+	}
+	for _, a := range o.Addresses {
+		parts = append(parts, "addr="+a)
+	}
+	if o.IPv6 {
+		parts = append(parts, "family=ipv6")
+	}
+	return OwnerAliasPrefix + strings.Join(parts, ",")
+}
+
+// ParseOwnerAlias decodes an alias written by Ownership.Alias. It returns
+// false for aliases not set by n-netman.
+func ParseOwnerAlias(alias string) (Ownership, bool) {
+	rest, ok := strings.CutPrefix(alias, OwnerAliasPrefix)
+	if !ok {
+		return Ownership{}, false
+	}
+
+	var o Ownership
+	for _, kv := range strings.Split(rest, ",") {
+		key, value, _ := strings.Cut(kv, "=")
+		switch key {
+		case "vni":
+			vni, err := strconv.Atoi(value)
+			if err != nil {
+				return Ownership{}, false
+			}
+			o.VNI = vni
+		case "bridge":
+			o.Bridge = value
+		case "table":
+			table, err := strconv.Atoi(value)
+			if err != nil {
+				return Ownership{}, false
+			}
+			o.Table = table
+		case "addr":
+			o.Addresses = append(o.Addresses, value)
+		case "family":
+			o.IPv6 = value == "ipv6"
+		}
+	}
+	if o.VNI == 0 {
+		return Ownership{}, false
+	}
+	return o, true
+}
+
+// OwnedLink is a device carrying an n-netman ownership alias.
+type OwnedLink struct {
+	Name      string
+	Kind      string // "vxlan" or "bridge"
+	Ownership Ownership
+}
+
+// LinkManager reads generic interface state and manages the ownership
+// markers of n-netman devices.
+type LinkManager struct{}
+
+// NewLinkManager creates a new link manager.
+func NewLinkManager() *LinkManager {
+	return &LinkManager{}
+}
+
+// Addresses returns the IP addresses of an interface, IPv4 first.
+func (m *LinkManager) Addresses(name string) ([]net.IP, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", name, err)
+	}
+	var ips []net.IP
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		addrs, err := netlink.AddrList(link, family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list addresses on %s: %w", name, err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	return ips, nil
+}
+
+// UpInterfaces returns the names of the interfaces that are up, loopback
+// excluded.
+func (m *LinkManager) UpInterfaces() ([]string, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	var names []string
+	for _, l := range links {
+		attrs := l.Attrs()
+		if attrs.Flags&net.FlagLoopback != 0 || attrs.Flags&net.FlagUp == 0 {
+			continue
+		}
+		names = append(names, attrs.Name)
+	}
+	return names, nil
+}
+
+// InterfaceAddresses returns the addresses of an interface with their subnet
+// mask, IPv4 first.
+func (m *LinkManager) InterfaceAddresses(name string) ([]*net.IPNet, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", name, err)
+	}
+	var out []*net.IPNet
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		addrs, err := netlink.AddrList(link, family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list addresses on %s: %w", name, err)
+		}
+		for _, a := range addrs {
+			out = append(out, a.IPNet)
+		}
+	}
+	return out, nil
+}
+
+// ConnectedPrefixes returns the subnets of the addresses of an interface,
+// IPv4 first. Link-local addresses are skipped, and a subnet shared by
+// several addresses is returned once.
+func (m *LinkManager) ConnectedPrefixes(name string) ([]*net.IPNet, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", name, err)
+	}
+	var prefixes []*net.IPNet
+	seen := make(map[string]bool)
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		addrs, err := netlink.AddrList(link, family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list addresses on %s: %w", name, err)
+		}
+		for _, a := range addrs {
+			if a.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			prefix := &net.IPNet{IP: a.IP.Mask(a.Mask), Mask: a.Mask}
+			if !seen[prefix.String()] {
+				seen[prefix.String()] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	return prefixes, nil
+}
+
+// SetOwnership records the ownership in the device's alias. The alias is
+// only written when it changed.
+func (m *LinkManager) SetOwnership(name string, o Ownership) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("interface %s not found: %w", name, err)
+	}
+	alias := o.Alias()
+	if link.Attrs().Alias == alias {
+		return nil
+	}
+	if err := netlink.LinkSetAlias(link, alias); err != nil {
+		return fmt.Errorf("failed to set alias on %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListOwned returns the VXLAN devices and bridges owned by n-netman.
+func (m *LinkManager) ListOwned() ([]OwnedLink, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	var owned []OwnedLink
+	for _, l := range links {
+		o, ok := ParseOwnerAlias(l.Attrs().Alias)
+		if !ok {
+			continue
+		}
+		switch l.(type) {
+		case *netlink.Vxlan:
+			owned = append(owned, OwnedLink{Name: l.Attrs().Name, Kind: "vxlan", Ownership: o})
+		case *netlink.Bridge:
+			owned = append(owned, OwnedLink{Name: l.Attrs().Name, Kind: "bridge", Ownership: o})
+		}
+	}
+	return owned, nil
+}
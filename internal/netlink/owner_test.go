@@ -0,0 +1,32 @@
+package netlink
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnership_AliasRoundTrip(t *testing.T) {
+	cases := []Ownership{
+		{VNI: 100},
+		{VNI: 200, Bridge: "br-200", Table: 200},
+		{VNI: 300, Bridge: "br-300", Table: 100, Addresses: []string{"10.30.0.1/24", "fd00:30::1/64"}},
+		{VNI: 400, Bridge: "br-400", Table: 400, IPv6: true},
+	}
+	for _, want := range cases {
+		got, ok := ParseOwnerAlias(want.Alias())
+		if !ok {
+			t.Fatalf("ParseOwnerAlias(%q) not recognized", want.Alias())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestParseOwnerAlias_Foreign(t *testing.T) {
+	for _, alias := range []string{"", "uplink to core", "n-netman:", "n-netman:vni=abc", "n-netman:bridge=br0"} {
+		if _, ok := ParseOwnerAlias(alias); ok {
+			t.Fatalf("ParseOwnerAlias(%q) should not be recognized", alias)
+		}
+	}
+}
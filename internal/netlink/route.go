@@ -3,22 +3,42 @@ package netlink
 import (
 	"fmt"
 	"net"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
+// ownDeletionTTL bounds how long a deletion made by a RouteManager waits for
+// its netlink notification before it is forgotten.
+const ownDeletionTTL = 5 * time.Second
+
 // RouteManager manages Linux routing table entries.
-type RouteManager struct{}
+type RouteManager struct {
+	mu sync.Mutex
+	// deleting holds the routes this manager deleted whose RTM_DELROUTE
+	// notification was not claimed yet (see OwnDeletion).
+	deleting map[string][]time.Time
+	// installed holds the metrics of the n-netman routes in each table by
+	// destination, kept by Add, Replace and Delete (see InstalledPrefixes).
+	installed map[int]map[string]map[int]bool
+}
 
 // NewRouteManager creates a new route manager.
 func NewRouteManager() *RouteManager {
-	return &RouteManager{}
+	return &RouteManager{
+		deleting:  make(map[string][]time.Time),
+		installed: make(map[int]map[string]map[int]bool),
+	}
 }
 
 // RouteConfig defines a route to be installed.
 type RouteConfig struct {
 	Destination *net.IPNet // Destination network (e.g., 172.16.10.0/24)
 	Gateway     net.IP     // Next-hop gateway (can be nil for directly connected)
+	NextHops    []net.IP   // ECMP next-hops (multipath); replaces Gateway when set
 	Device      string     // Output interface (optional, used if no gateway)
 	Table       int        // Routing table (0 = main, or custom table number)
 	Metric      int        // Route metric/priority
@@ -28,6 +48,9 @@ type RouteConfig struct {
 // Protocol constants for route identification
 const (
 	RouteProtocolNNetMan = 99 // Custom protocol ID for n-netman routes
+	// RouteProtocolUnderlay marks the host routes pinning the VTEP of a peer
+	// to its endpoint.via_interface, kept apart from the learned routes.
+	RouteProtocolUnderlay = 100
 )
 
 // Add adds a route to the routing table.
@@ -38,6 +61,11 @@ func (m *RouteManager) Add(cfg RouteConfig) error {
 		Protocol: netlink.RouteProtocol(cfg.Protocol),
 	}
 
+	if len(cfg.NextHops) > 0 {
+		route.Gw = nil
+		route.MultiPath = multiPath(cfg.NextHops)
+	}
+
 	// Set table if specified
 	if cfg.Table > 0 {
 		route.Table = cfg.Table
@@ -61,6 +89,7 @@ func (m *RouteManager) Add(cfg RouteConfig) error {
 		return fmt.Errorf("failed to add route to %s: %w", cfg.Destination, err)
 	}
 
+	m.recordInstalled(cfg)
 	return nil
 }
 
@@ -68,9 +97,12 @@ func (m *RouteManager) Add(cfg RouteConfig) error {
 // When cfg.Protocol is set, the deletion is scoped to routes installed by that
 // protocol, so we never remove a route the daemon did not install.
 func (m *RouteManager) Delete(cfg RouteConfig) error {
+	// SCOPE_NOWHERE matches any scope; the default (universe) would not
+	// match device routes, which have link scope.
 	route := &netlink.Route{
-		Dst: cfg.Destination,
-		Gw:  cfg.Gateway,
+		Dst:   cfg.Destination,
+		Gw:    cfg.Gateway,
+		Scope: netlink.SCOPE_NOWHERE,
 	}
 
 	if cfg.Table > 0 {
@@ -81,13 +113,171 @@ func (m *RouteManager) Delete(cfg RouteConfig) error {
 		route.Protocol = netlink.RouteProtocol(cfg.Protocol)
 	}
 
+	// Routes to the same destination may only differ by metric.
+	if cfg.Metric > 0 {
+		route.Priority = cfg.Metric
+	}
+
+	// Recorded before the deletion: its notification may be read by an
+	// event subscriber before RouteDel returns.
+	key := deletionKey(cfg.Table, cfg.Destination)
+	m.recordDeletion(key, time.Now())
 	if err := netlink.RouteDel(route); err != nil {
+		m.claimDeletion(key, time.Now())
 		return fmt.Errorf("failed to delete route to %s: %w", cfg.Destination, err)
 	}
 
+	m.forgetInstalled(cfg)
 	return nil
 }
 
+// OwnDeletion reports whether the deletion of the route to dst in table was
+// made by this manager, so an event subscriber can tell a withdrawal or a
+// superseded metric apart from a route removed behind the daemon's back.
+// Each deletion is claimed once.
+func (m *RouteManager) OwnDeletion(table int, dst *net.IPNet) bool {
+	return m.claimDeletion(deletionKey(table, dst), time.Now())
+}
+
+func (m *RouteManager) claimDeletion(key string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireDeletions(now)
+	pending := m.deleting[key]
+	if len(pending) == 0 {
+		return false
+	}
+	if len(pending) == 1 {
+		delete(m.deleting, key)
+	} else {
+		m.deleting[key] = pending[1:]
+	}
+	return true
+}
+
+func (m *RouteManager) recordDeletion(key string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deleting == nil {
+		m.deleting = make(map[string][]time.Time)
+	}
+	m.expireDeletions(at)
+	m.deleting[key] = append(m.deleting[key], at)
+}
+
+// expireDeletions forgets the deletions whose notification never came.
+func (m *RouteManager) expireDeletions(now time.Time) {
+	for key, pending := range m.deleting {
+		for len(pending) > 0 && now.Sub(pending[0]) > ownDeletionTTL {
+			pending = pending[1:]
+		}
+		if len(pending) == 0 {
+			delete(m.deleting, key)
+		} else {
+			m.deleting[key] = pending
+		}
+	}
+}
+
+// deletionKey identifies a route by table and destination. The main table
+// is reported as RT_TABLE_MAIN by the kernel, and a default route may come
+// without a destination.
+func deletionKey(table int, dst *net.IPNet) string {
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	prefix := "default"
+	if dst != nil {
+		if ones, _ := dst.Mask.Size(); ones > 0 {
+			prefix = dst.String()
+		}
+	}
+	return fmt.Sprintf("%d/%s", table, prefix)
+}
+
+// InstalledPrefixes returns the destinations of the n-netman routes
+// (RouteProtocolNNetMan) in a table, as installed and deleted through this
+// manager. The routes found in the table the first time it is asked for
+// (e.g. kept by a graceful restart) are included; the table is not read
+// again afterwards.
+func (m *RouteManager) InstalledPrefixes(table int) []string {
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.installed == nil {
+		m.installed = make(map[int]map[string]map[int]bool)
+	}
+	if _, ok := m.installed[table]; !ok {
+		m.installed[table] = make(map[string]map[int]bool)
+		if routes, err := m.ListByProtocol(table, RouteProtocolNNetMan); err == nil {
+			for _, r := range routes {
+				m.addInstalled(table, r.Destination, r.Metric)
+			}
+		}
+	}
+
+	prefixes := make([]string, 0, len(m.installed[table]))
+	for prefix := range m.installed[table] {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// recordInstalled adds an installed n-netman route to the set returned by
+// InstalledPrefixes.
+func (m *RouteManager) recordInstalled(cfg RouteConfig) {
+	if cfg.Protocol != RouteProtocolNNetMan || cfg.Destination == nil {
+		return
+	}
+	table := cfg.Table
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A table never listed is read in full on first use.
+	if _, ok := m.installed[table]; ok {
+		m.addInstalled(table, cfg.Destination, cfg.Metric)
+	}
+}
+
+// forgetInstalled removes a deleted route from the set returned by
+// InstalledPrefixes. A deletion without a metric removes the route to the
+// destination whatever its metric.
+func (m *RouteManager) forgetInstalled(cfg RouteConfig) {
+	if cfg.Protocol != RouteProtocolNNetMan || cfg.Destination == nil {
+		return
+	}
+	table := cfg.Table
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := cfg.Destination.String()
+	metrics := m.installed[table][prefix]
+	if cfg.Metric > 0 {
+		delete(metrics, cfg.Metric)
+	}
+	if cfg.Metric == 0 || len(metrics) == 0 {
+		delete(m.installed[table], prefix)
+	}
+}
+
+func (m *RouteManager) addInstalled(table int, dst *net.IPNet, metric int) {
+	if dst == nil {
+		return
+	}
+	prefix := dst.String()
+	if m.installed[table][prefix] == nil {
+		m.installed[table][prefix] = make(map[int]bool)
+	}
+	m.installed[table][prefix][metric] = true
+}
+
 // Replace adds or replaces a route.
 func (m *RouteManager) Replace(cfg RouteConfig) error {
 	route := &netlink.Route{
@@ -96,6 +286,11 @@ func (m *RouteManager) Replace(cfg RouteConfig) error {
 		Protocol: netlink.RouteProtocol(cfg.Protocol),
 	}
 
+	if len(cfg.NextHops) > 0 {
+		route.Gw = nil
+		route.MultiPath = multiPath(cfg.NextHops)
+	}
+
 	if cfg.Table > 0 {
 		route.Table = cfg.Table
 	}
@@ -110,15 +305,88 @@ func (m *RouteManager) Replace(cfg RouteConfig) error {
 			return fmt.Errorf("device %s not found: %w", cfg.Device, err)
 		}
 		route.LinkIndex = link.Attrs().Index
+		// Like `ip route`, a route without a gateway is on-link.
+		if route.Gw == nil && route.MultiPath == nil {
+			route.Scope = netlink.SCOPE_LINK
+		}
 	}
 
 	if err := netlink.RouteReplace(route); err != nil {
 		return fmt.Errorf("failed to replace route to %s: %w", cfg.Destination, err)
 	}
 
+	m.recordInstalled(cfg)
 	return nil
 }
 
+// GatewayVia returns the gateway of the main table route to dst out of
+// iface, or nil when dst is on-link there. Unlike `ip route get <dst> oif
+// <iface>`, the host routes pinned by n-netman (RouteProtocolUnderlay) are
+// ignored: once installed, the pin of a peer would resolve to itself and
+// never follow a change of the underlay gateway.
+func (m *RouteManager) GatewayVia(dst net.IP, iface string) (net.IP, error) {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", iface, err)
+	}
+	family := netlink.FAMILY_V4
+	if dst.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	routes, err := netlink.RouteListFiltered(family, &netlink.Route{Table: unix.RT_TABLE_MAIN}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+	gw, ok := gatewayFor(routes, dst, link.Attrs().Index)
+	if !ok {
+		return nil, fmt.Errorf("no route to %s via %s", dst, iface)
+	}
+	return gw, nil
+}
+
+// gatewayFor returns the gateway of the most specific unicast route to dst
+// out of the interface with index linkIndex (the lowest metric wins a tie),
+// skipping the RouteProtocolUnderlay host routes. ok is false when no route
+// matches.
+func gatewayFor(routes []netlink.Route, dst net.IP, linkIndex int) (gw net.IP, ok bool) {
+	bestLen, bestMetric := -1, 0
+	for _, r := range routes {
+		if int(r.Protocol) == RouteProtocolUnderlay || (r.Type != 0 && r.Type != unix.RTN_UNICAST) {
+			continue
+		}
+		hop, onLink := r.Gw, r.LinkIndex == linkIndex
+		for _, nh := range r.MultiPath {
+			if !onLink && nh.LinkIndex == linkIndex {
+				hop, onLink = nh.Gw, true
+			}
+		}
+		if !onLink {
+			continue
+		}
+		ones := 0
+		if r.Dst != nil {
+			if !r.Dst.Contains(dst) {
+				continue
+			}
+			ones, _ = r.Dst.Mask.Size()
+		}
+		if ones > bestLen || (ones == bestLen && r.Priority < bestMetric) {
+			gw, ok = hop, true
+			bestLen, bestMetric = ones, r.Priority
+		}
+	}
+	return gw, ok
+}
+
+// multiPath builds the nexthops of an ECMP route, one per gateway.
+func multiPath(gateways []net.IP) []*netlink.NexthopInfo {
+	nhs := make([]*netlink.NexthopInfo, 0, len(gateways))
+	for _, gw := range gateways {
+		nhs = append(nhs, &netlink.NexthopInfo{Gw: gw})
+	}
+	return nhs
+}
+
 // List returns all routes in a routing table.
 func (m *RouteManager) List(table int) ([]RouteInfo, error) {
 	filter := &netlink.Route{}
@@ -140,6 +408,9 @@ func (m *RouteManager) List(table int) ([]RouteInfo, error) {
 			Metric:      r.Priority,
 			Protocol:    int(r.Protocol),
 		}
+		for _, nh := range r.MultiPath {
+			info.NextHops = append(info.NextHops, nh.Gw)
+		}
 
 		// Get device name if available
 		if r.LinkIndex > 0 {
@@ -159,6 +430,7 @@ func (m *RouteManager) List(table int) ([]RouteInfo, error) {
 type RouteInfo struct {
 	Destination *net.IPNet
 	Gateway     net.IP
+	NextHops    []net.IP // Next-hops of a multipath route (Gateway is nil)
 	Device      string
 	Table       int
 	Metric      int
@@ -0,0 +1,106 @@
+package netlink
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestRouteManager_OwnDeletion(t *testing.T) {
+	m := NewRouteManager()
+	_, dst, _ := net.ParseCIDR("10.1.0.0/24")
+	now := time.Now()
+
+	m.recordDeletion(deletionKey(100, dst), now)
+	m.recordDeletion(deletionKey(100, dst), now)
+	if m.OwnDeletion(200, dst) {
+		t.Fatal("expected a deletion in another table not to be claimed")
+	}
+	for i := 0; i < 2; i++ {
+		if !m.OwnDeletion(100, dst) {
+			t.Fatalf("expected deletion %d to be claimed", i+1)
+		}
+	}
+	if m.OwnDeletion(100, dst) {
+		t.Fatal("expected each deletion to be claimed once")
+	}
+
+	// The main table and default routes match however they are reported.
+	_, def, _ := net.ParseCIDR("0.0.0.0/0")
+	m.recordDeletion(deletionKey(0, def), now)
+	if !m.OwnDeletion(254, nil) {
+		t.Fatal("expected the default route of the main table to be claimed")
+	}
+
+	// A notification that never came is forgotten.
+	m.recordDeletion(deletionKey(100, dst), now.Add(-2*ownDeletionTTL))
+	if m.OwnDeletion(100, dst) {
+		t.Fatal("expected an expired deletion not to be claimed")
+	}
+}
+
+func TestGatewayFor(t *testing.T) {
+	cidr := func(s string) *net.IPNet {
+		_, n, _ := net.ParseCIDR(s)
+		return n
+	}
+	peer := net.ParseIP("192.168.1.11")
+	routes := []netlink.Route{
+		{Dst: nil, Gw: net.ParseIP("10.0.0.1"), LinkIndex: 2, Priority: 100},
+		{Dst: cidr("192.168.0.0/16"), Gw: net.ParseIP("10.0.0.254"), LinkIndex: 2, Priority: 20},
+		{Dst: cidr("192.168.0.0/16"), Gw: net.ParseIP("10.0.0.253"), LinkIndex: 2, Priority: 10},
+		{Dst: cidr("192.168.1.0/24"), LinkIndex: 3},
+		// The pin of the peer itself, as installed by the reconciler.
+		{Dst: cidr("192.168.1.11/32"), Gw: net.ParseIP("10.0.0.9"), LinkIndex: 2, Protocol: RouteProtocolUnderlay},
+	}
+
+	// The pin is skipped; the most specific route out of the interface,
+	// then the lowest metric, wins.
+	if gw, ok := gatewayFor(routes, peer, 2); !ok || !gw.Equal(net.ParseIP("10.0.0.253")) {
+		t.Fatalf("gatewayFor(ifindex 2) = %v, %v, want 10.0.0.253", gw, ok)
+	}
+	// On-link out of another interface.
+	if gw, ok := gatewayFor(routes, peer, 3); !ok || gw != nil {
+		t.Fatalf("gatewayFor(ifindex 3) = %v, %v, want on-link", gw, ok)
+	}
+	if _, ok := gatewayFor(routes, peer, 4); ok {
+		t.Fatal("gatewayFor(ifindex 4) found a route out of an interface without one")
+	}
+	// A nexthop of an ECMP route counts for its own interface.
+	ecmp := []netlink.Route{{Dst: cidr("192.168.0.0/16"), MultiPath: []*netlink.NexthopInfo{
+		{Gw: net.ParseIP("10.0.0.1"), LinkIndex: 2},
+		{Gw: net.ParseIP("10.1.0.1"), LinkIndex: 5},
+	}}}
+	if gw, ok := gatewayFor(ecmp, peer, 5); !ok || !gw.Equal(net.ParseIP("10.1.0.1")) {
+		t.Fatalf("gatewayFor(ECMP, ifindex 5) = %v, %v, want 10.1.0.1", gw, ok)
+	}
+}
+
+func TestRouteManager_InstalledPrefixes(t *testing.T) {
+	m := NewRouteManager()
+	// Table 100 was already read: no routes were kept in it.
+	m.installed[100] = make(map[string]map[int]bool)
+	_, a, _ := net.ParseCIDR("10.1.0.0/24")
+	_, b, _ := net.ParseCIDR("10.2.0.0/24")
+
+	m.recordInstalled(RouteConfig{Destination: a, Table: 100, Metric: 100, Protocol: RouteProtocolNNetMan})
+	m.recordInstalled(RouteConfig{Destination: a, Table: 100, Metric: 200, Protocol: RouteProtocolNNetMan})
+	m.recordInstalled(RouteConfig{Destination: b, Table: 100, Protocol: RouteProtocolNNetMan})
+	m.recordInstalled(RouteConfig{Destination: b, Table: 100, Protocol: RouteProtocolUnderlay})
+	if got := m.InstalledPrefixes(100); len(got) != 2 || got[0] != "10.1.0.0/24" || got[1] != "10.2.0.0/24" {
+		t.Fatalf("InstalledPrefixes() = %v, want both prefixes", got)
+	}
+
+	// A superseded metric leaves the prefix installed.
+	m.forgetInstalled(RouteConfig{Destination: a, Table: 100, Metric: 200, Protocol: RouteProtocolNNetMan})
+	if got := m.InstalledPrefixes(100); len(got) != 2 {
+		t.Fatalf("InstalledPrefixes() after a superseded metric = %v, want both prefixes", got)
+	}
+	m.forgetInstalled(RouteConfig{Destination: a, Table: 100, Protocol: RouteProtocolNNetMan})
+	m.forgetInstalled(RouteConfig{Destination: b, Table: 100, Protocol: RouteProtocolUnderlay})
+	if got := m.InstalledPrefixes(100); len(got) != 1 || got[0] != "10.2.0.0/24" {
+		t.Fatalf("InstalledPrefixes() after a deletion = %v, want [10.2.0.0/24]", got)
+	}
+}
@@ -2,113 +2,190 @@
 package netlink
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
-	"strconv"
-	"strings"
+	"net"
+	"syscall"
 
 	"github.com/vishvananda/netlink"
 )
 
-// RulePriority is the default priority for n-netman policy rules.
+// RulePriority is the first priority given to n-netman policy rules when an
+// overlay does not set lookup_rules.priority.
 // Using 100 to leave room for higher-priority system rules.
 const RulePriority = 100
 
-// EnsureRuleByInterface creates policy rules for a bridge interface.
-// Creates both iif (input) and oif (output) rules pointing to the table.
-//
-// NOTE: We use 'ip rule add' command directly instead of vishvananda/netlink
-// library's RuleAdd due to issues where RuleAdd returns "invalid argument"
-// for rules with iif/oif. This is similar to the FDB workaround.
-func EnsureRuleByInterface(bridgeName string, table int) error {
-	// Verify interface exists
-	_, err := netlink.LinkByName(bridgeName)
-	if err != nil {
-		return fmt.Errorf("failed to find interface %s: %w", bridgeName, err)
-	}
-
-	// Create iif rule: ip rule add iif <bridge> lookup <table> priority <prio>
-	if err := addRuleViaCLI("iif", bridgeName, table, RulePriority); err != nil {
-		return fmt.Errorf("failed to create iif rule for %s: %w", bridgeName, err)
-	}
+// RuleProtocolNNetMan marks the policy rules installed by n-netman, like the
+// protocol of its routes. Kernels before 4.17 do not keep the protocol of a
+// rule and report it as unspecified (0).
+const RuleProtocolNNetMan = RouteProtocolNNetMan
 
-	// Create oif rule: ip rule add oif <bridge> lookup <table> priority <prio>
-	if err := addRuleViaCLI("oif", bridgeName, table, RulePriority+1); err != nil {
-		return fmt.Errorf("failed to create oif rule for %s: %w", bridgeName, err)
-	}
+// RuleManager manages the policy rules of overlays: iif/oif rules for their
+// bridges and from/to rules for their prefixes.
+type RuleManager struct{}
 
-	return nil
+// NewRuleManager creates a new rule manager.
+func NewRuleManager() *RuleManager {
+	return &RuleManager{}
 }
 
-// addRuleViaCLI adds a policy rule using the ip command.
-func addRuleViaCLI(direction, ifname string, table, priority int) error {
-	// ip rule add iif/oif <ifname> lookup <table> priority <priority>
-	cmd := exec.Command("ip", "rule", "add",
-		direction, ifname,
-		"lookup", strconv.Itoa(table),
-		"priority", strconv.Itoa(priority))
+// RuleInfo is a policy rule pointing to a table. A rule managed by n-netman
+// matches on a single selector: an input or output interface, or a source
+// (Src) or destination (Dst) prefix. The struct is comparable, so rules can
+// be used as map keys.
+type RuleInfo struct {
+	Family   int // netlink.FAMILY_V4 or netlink.FAMILY_V6
+	IifName  string
+	OifName  string
+	Src      string
+	Dst      string
+	Table    int
+	Priority int
+	Protocol int
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		outputStr := strings.TrimSpace(string(output))
-		// Check if rule already exists
-		if strings.Contains(outputStr, "File exists") {
-			return nil
+// Selector returns the selector of the rule ("iif", "oif", "from" or "to")
+// and the interface or prefix it matches. ok is false when the rule matches
+// on no selector or on several of them.
+func (r RuleInfo) Selector() (selector, value string, ok bool) {
+	n := 0
+	for _, s := range []struct{ selector, value string }{
+		{"iif", r.IifName},
+		{"oif", r.OifName},
+		{"from", r.Src},
+		{"to", r.Dst},
+	} {
+		if s.value != "" {
+			selector, value = s.selector, s.value
+			n++
 		}
-		return fmt.Errorf("ip rule add failed: %s: %w", outputStr, err)
 	}
+	if n != 1 {
+		return "", "", false
+	}
+	return selector, value, true
+}
 
-	return nil
+// Owned reports whether the rule was installed by n-netman.
+func (r RuleInfo) Owned() bool {
+	return r.Protocol == RuleProtocolNNetMan
 }
 
-// DeleteRulesByInterface removes policy rules for a bridge interface.
-func DeleteRulesByInterface(bridgeName string, table int) error {
-	// Delete iif rule
-	if err := deleteRuleViaCLI("iif", bridgeName, table, RulePriority); err != nil {
-		return fmt.Errorf("failed to delete iif rule for %s: %w", bridgeName, err)
+// List returns the policy rules of both address families.
+func (m *RuleManager) List() ([]RuleInfo, error) {
+	rules, err := netlink.RuleList(netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
 	}
-
-	// Delete oif rule
-	if err := deleteRuleViaCLI("oif", bridgeName, table, RulePriority+1); err != nil {
-		return fmt.Errorf("failed to delete oif rule for %s: %w", bridgeName, err)
+	result := make([]RuleInfo, 0, len(rules))
+	for _, r := range rules {
+		info := RuleInfo{
+			Family:   r.Family,
+			IifName:  r.IifName,
+			OifName:  r.OifName,
+			Table:    r.Table,
+			Priority: r.Priority,
+			Protocol: int(r.Protocol),
+		}
+		if r.Src != nil {
+			info.Src = r.Src.String()
+		}
+		if r.Dst != nil {
+			info.Dst = r.Dst.String()
+		}
+		result = append(result, info)
 	}
+	return result, nil
+}
 
+// Add installs a policy rule. A rule that already exists is not an error.
+func (m *RuleManager) Add(rule RuleInfo) error {
+	r, err := toNetlinkRule(rule)
+	if err != nil {
+		return err
+	}
+	if err := netlink.RuleAdd(r); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("failed to add rule %s: %w", ruleString(rule), err)
+	}
 	return nil
 }
 
-// deleteRuleViaCLI removes a policy rule using the ip command.
-func deleteRuleViaCLI(direction, ifname string, table, priority int) error {
-	cmd := exec.Command("ip", "rule", "del",
-		direction, ifname,
-		"lookup", strconv.Itoa(table),
-		"priority", strconv.Itoa(priority))
-
-	output, err := cmd.CombinedOutput()
+// Delete removes a policy rule. A rule that does not exist is not an error.
+func (m *RuleManager) Delete(rule RuleInfo) error {
+	r, err := toNetlinkRule(rule)
 	if err != nil {
-		outputStr := strings.TrimSpace(string(output))
-		// Ignore "No such process" (rule doesn't exist)
-		if strings.Contains(outputStr, "No such process") ||
-			strings.Contains(outputStr, "No such file or directory") {
-			return nil
-		}
-		return fmt.Errorf("ip rule del failed: %s: %w", outputStr, err)
+		return err
+	}
+	if err := netlink.RuleDel(r); err != nil && !errors.Is(err, syscall.ENOENT) {
+		return fmt.Errorf("failed to delete rule %s: %w", ruleString(rule), err)
 	}
-
 	return nil
 }
 
-// ListRulesByTable returns all rules pointing to a specific table (both families).
-func ListRulesByTable(table int) ([]netlink.Rule, error) {
-	rules, err := netlink.RuleList(netlink.FAMILY_ALL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list rules: %w", err)
+// DiffRules returns the desired rules missing from current, and the rules of
+// current that are not desired. current holds the rules managed by the
+// caller. Rules are compared without their protocol, so a rule installed
+// before the protocol was set (or on a kernel that does not keep it) is
+// kept; the missing rules are returned as owned (RuleProtocolNNetMan).
+func DiffRules(current, desired []RuleInfo) (add, del []RuleInfo) {
+	key := func(r RuleInfo) RuleInfo {
+		r.Protocol = 0
+		return r
 	}
+	want := make(map[RuleInfo]bool, len(desired))
+	for _, r := range desired {
+		want[key(r)] = true
+	}
+	have := make(map[RuleInfo]bool, len(current))
+	for _, r := range current {
+		have[key(r)] = true
+		if !want[key(r)] {
+			del = append(del, r)
+		}
+	}
+	for _, r := range desired {
+		if k := key(r); !have[k] {
+			have[k] = true
+			r.Protocol = RuleProtocolNNetMan
+			add = append(add, r)
+		}
+	}
+	return add, del
+}
 
-	var result []netlink.Rule
-	for _, r := range rules {
-		if r.Table == table {
-			result = append(result, r)
+// toNetlinkRule converts a rule to its netlink form.
+func toNetlinkRule(rule RuleInfo) (*netlink.Rule, error) {
+	r := netlink.NewRule()
+	r.Family = rule.Family
+	r.IifName = rule.IifName
+	r.OifName = rule.OifName
+	r.Table = rule.Table
+	r.Priority = rule.Priority
+	r.Protocol = uint8(rule.Protocol)
+	for _, p := range []struct {
+		prefix string
+		dst    **net.IPNet
+	}{
+		{rule.Src, &r.Src},
+		{rule.Dst, &r.Dst},
+	} {
+		if p.prefix == "" {
+			continue
 		}
+		_, ipnet, err := net.ParseCIDR(p.prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule prefix %q: %w", p.prefix, err)
+		}
+		*p.dst = ipnet
 	}
-	return result, nil
+	return r, nil
+}
+
+// ruleString formats a rule like ip rule show does.
+func ruleString(rule RuleInfo) string {
+	selector, value, ok := rule.Selector()
+	if !ok {
+		return fmt.Sprintf("priority %d lookup %d", rule.Priority, rule.Table)
+	}
+	return fmt.Sprintf("%s %s lookup %d priority %d", selector, value, rule.Table, rule.Priority)
 }
@@ -0,0 +1,50 @@
+package netlink
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRules(t *testing.T) {
+	iif := RuleInfo{Family: 2, IifName: "br-100", Table: 200, Priority: 100}
+	oif := RuleInfo{Family: 2, OifName: "br-100", Table: 200, Priority: 101}
+	stale := RuleInfo{Family: 2, Dst: "10.9.0.0/24", Table: 200, Priority: 101, Protocol: RuleProtocolNNetMan}
+	owned := func(r RuleInfo) RuleInfo {
+		r.Protocol = RuleProtocolNNetMan
+		return r
+	}
+
+	// iif was installed without a protocol: it matches and is kept.
+	add, del := DiffRules([]RuleInfo{iif, stale}, []RuleInfo{iif, oif, oif})
+	if want := []RuleInfo{owned(oif)}; !reflect.DeepEqual(add, want) {
+		t.Errorf("add = %+v, want %+v", add, want)
+	}
+	if want := []RuleInfo{stale}; !reflect.DeepEqual(del, want) {
+		t.Errorf("del = %+v, want %+v", del, want)
+	}
+
+	if add, del := DiffRules([]RuleInfo{owned(iif)}, []RuleInfo{iif}); len(add) != 0 || len(del) != 0 {
+		t.Errorf("converged rules diff = %+v, %+v, want none", add, del)
+	}
+}
+
+func TestRuleInfo_Selector(t *testing.T) {
+	tests := []struct {
+		rule          RuleInfo
+		selector, val string
+		ok            bool
+	}{
+		{RuleInfo{IifName: "br-100"}, "iif", "br-100", true},
+		{RuleInfo{OifName: "br-100"}, "oif", "br-100", true},
+		{RuleInfo{Src: "10.0.0.0/24"}, "from", "10.0.0.0/24", true},
+		{RuleInfo{Dst: "2001:db8::/64"}, "to", "2001:db8::/64", true},
+		{RuleInfo{Src: "10.0.0.0/24", Dst: "10.1.0.0/24"}, "", "", false},
+		{RuleInfo{Table: 254}, "", "", false},
+	}
+	for _, tt := range tests {
+		selector, val, ok := tt.rule.Selector()
+		if selector != tt.selector || val != tt.val || ok != tt.ok {
+			t.Errorf("Selector(%+v) = %q %q %v, want %q %q %v", tt.rule, selector, val, ok, tt.selector, tt.val, tt.ok)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package netlink
 import (
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/vishvananda/netlink"
 )
@@ -48,8 +49,8 @@ func (m *VXLANManager) Create(cfg VXLANConfig) error {
 			// Refuse to destroy a non-VXLAN interface that happens to share the name.
 			return fmt.Errorf("interface %s exists but is not a VXLAN (%T); refusing to replace it", cfg.Name, existing)
 		}
-		if vxlan.VxlanId == cfg.VNI {
-			// Same VNI: reconcile MTU, ensure up, and (re)attach to the bridge.
+		if len(ReplaceDiff(vxlanInfo(vxlan), cfg)) == 0 {
+			// Same tunnel: reconcile MTU, ensure up, and (re)attach to the bridge.
 			if cfg.MTU > 0 && existing.Attrs().MTU != cfg.MTU {
 				if err := netlink.LinkSetMTU(existing, cfg.MTU); err != nil {
 					return fmt.Errorf("failed to set MTU on vxlan %s: %w", cfg.Name, err)
@@ -65,9 +66,10 @@ func (m *VXLANManager) Create(cfg VXLANConfig) error {
 			}
 			return nil
 		}
-		// VNI changed: the kernel cannot mutate the VNI in place, so recreate.
+		// VNI, port, addresses, underlay device or learning changed: the
+		// kernel cannot mutate them in place, so recreate.
 		if err := netlink.LinkDel(existing); err != nil {
-			return fmt.Errorf("failed to delete vxlan %s for VNI change: %w", cfg.Name, err)
+			return fmt.Errorf("failed to delete vxlan %s for recreation: %w", cfg.Name, err)
 		}
 	}
 
@@ -160,15 +162,110 @@ func (m *VXLANManager) Get(name string) (*VXLANInfo, error) {
 		return nil, fmt.Errorf("%s is not a vxlan interface", name)
 	}
 
+	return vxlanInfo(vxlan), nil
+}
+
+// vxlanInfo describes a VXLAN link, resolving its master and underlay
+// device names.
+func vxlanInfo(vxlan *netlink.Vxlan) *VXLANInfo {
+	name := func(index int) string {
+		if index > 0 {
+			if l, err := netlink.LinkByIndex(index); err == nil {
+				return l.Attrs().Name
+			}
+		}
+		return ""
+	}
 	return &VXLANInfo{
 		Name:     vxlan.Attrs().Name,
 		VNI:      vxlan.VxlanId,
 		DstPort:  vxlan.Port,
-		LocalIP:  vxlan.SrcAddr,
+		LocalIP:  LocalAddress(vxlan.SrcAddr, vxlan.Attrs().Alias),
+		Group:    vxlan.Group,
+		VtepDev:  name(vxlan.VtepDevIndex),
 		MTU:      vxlan.Attrs().MTU,
 		Learning: vxlan.Learning,
 		Up:       vxlan.Attrs().Flags&net.FlagUp != 0,
-	}, nil
+		Master:   name(vxlan.Attrs().MasterIndex),
+		Alias:    vxlan.Attrs().Alias,
+	}
+}
+
+// VXLANDiff is an attribute of an existing VXLAN interface that differs from
+// its desired value.
+type VXLANDiff struct {
+	Attr string
+	Old  string
+	New  string
+}
+
+// ReplaceDiff returns the attributes of an existing VXLAN interface that
+// differ from cfg and that the kernel cannot change in place: the VNI, UDP
+// port, local address, multicast group, underlay device and learning. The
+// interface has to be recreated to change any of them. An unspecified local
+// address or group is the same as none.
+func ReplaceDiff(info *VXLANInfo, cfg VXLANConfig) []VXLANDiff {
+	if cfg.DstPort == 0 {
+		cfg.DstPort = 4789
+	}
+	var diffs []VXLANDiff
+	add := func(attr, old, new string) {
+		if old != new {
+			diffs = append(diffs, VXLANDiff{Attr: attr, Old: old, New: new})
+		}
+	}
+	add("vni", strconv.Itoa(info.VNI), strconv.Itoa(cfg.VNI))
+	add("dstport", strconv.Itoa(info.DstPort), strconv.Itoa(cfg.DstPort))
+	if LocalFamilyChanged(info.LocalIP, cfg.LocalIP) {
+		add("local", ipOrNone(info.LocalIP), ipOrNone(cfg.LocalIP))
+	} else {
+		add("local", ipOrNone(specified(info.LocalIP)), ipOrNone(specified(cfg.LocalIP)))
+	}
+	add("group", ipOrNone(specified(info.Group)), ipOrNone(specified(cfg.Group)))
+	add("dev", info.VtepDev, cfg.VtepDev)
+	add("learning", strconv.FormatBool(info.Learning), strconv.FormatBool(cfg.Learning))
+	return diffs
+}
+
+// specified returns ip, or nil when it is unspecified.
+func specified(ip net.IP) net.IP {
+	if ip.IsUnspecified() {
+		return nil
+	}
+	return ip
+}
+
+func ipOrNone(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// LocalFamilyChanged reports whether the local address of a VXLAN interface
+// moves to the other address family (IPv4 to IPv6 or back). No local address
+// means an IPv4 socket: the kernel opens one unless the local address is
+// IPv6, even unspecified.
+func LocalFamilyChanged(current, desired net.IP) bool {
+	return isIPv6(current) != isIPv6(desired)
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+// LocalAddress returns the local address of a VXLAN interface from the one
+// reported by the kernel and the interface alias. The kernel reports no
+// unspecified local address, so an interface bound to "::" is told apart
+// from an IPv4 one by the family recorded in its ownership alias.
+func LocalAddress(reported net.IP, alias string) net.IP {
+	if reported != nil && !reported.IsUnspecified() {
+		return reported
+	}
+	if o, ok := ParseOwnerAlias(alias); ok && o.IPv6 {
+		return net.IPv6unspecified
+	}
+	return nil
 }
 
 // VXLANInfo contains information about a VXLAN interface.
@@ -177,9 +274,13 @@ type VXLANInfo struct {
 	VNI      int
 	DstPort  int
 	LocalIP  net.IP
+	Group    net.IP // Multicast group (nil if none)
+	VtepDev  string // Underlay device the tunnel is bound to ("" if none)
 	MTU      int
 	Learning bool
 	Up       bool
+	Master   string // Bridge the interface is attached to ("" if none)
+	Alias    string // Interface alias (carries the n-netman ownership marker)
 }
 
 // AttachToBridge attaches a VXLAN interface to a bridge.
@@ -0,0 +1,264 @@
+// Package netplan reads netplan configuration files (read-only) to infer the
+// underlay of the node and the static routes it declares.
+package netplan
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Network is the merged network definition of a set of netplan files.
+type Network struct {
+	// Interfaces holds the declared interfaces (ethernets, bonds, bridges,
+	// vlans, ...) by name.
+	Interfaces map[string]*Interface
+}
+
+// Interface is an interface declared in netplan.
+type Interface struct {
+	Name string
+	// Kind is the netplan section the interface is declared in, e.g.
+	// "ethernets" or "vlans".
+	Kind      string
+	Addresses []*net.IPNet // Static addresses (IP with its subnet mask)
+	DHCP4     bool
+	DHCP6     bool
+	Gateway4  string
+	Gateway6  string
+	Routes    []Route
+}
+
+// Route is a static route declared on a netplan interface.
+type Route struct {
+	Interface string
+	To        string // Destination prefix, or "default"
+	Via       string
+	Metric    int
+	Table     int
+}
+
+// IsDefault reports whether the route is a default route.
+func (r Route) IsDefault() bool {
+	switch r.To {
+	case "default", "0.0.0.0/0", "::/0":
+		return true
+	}
+	return false
+}
+
+// sections are the netplan sections that declare interfaces.
+var sections = []string{"ethernets", "bonds", "bridges", "vlans", "wifis", "tunnels", "vrfs", "modems"}
+
+// file is the YAML layout of a netplan file.
+type file struct {
+	Network map[string]yaml.Node `yaml:"network"`
+}
+
+// ifaceYAML is the YAML layout of a netplan interface.
+type ifaceYAML struct {
+	Addresses addressList `yaml:"addresses"`
+	DHCP4     flag        `yaml:"dhcp4"`
+	DHCP6     flag        `yaml:"dhcp6"`
+	Gateway4  string      `yaml:"gateway4"`
+	Gateway6  string      `yaml:"gateway6"`
+	Routes    []struct {
+		To     string `yaml:"to"`
+		Via    string `yaml:"via"`
+		Metric int    `yaml:"metric"`
+		Table  int    `yaml:"table"`
+	} `yaml:"routes"`
+}
+
+// addressList is the addresses of an interface. Netplan accepts plain
+// entries ("10.0.0.5/24") and entries with options ("10.0.0.5/24": {label: x}).
+type addressList []string
+
+func (a *addressList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: addresses must be a list", node.Line)
+	}
+	for _, item := range node.Content {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			*a = append(*a, item.Value)
+		case yaml.MappingNode:
+			for i := 0; i < len(item.Content); i += 2 {
+				*a = append(*a, item.Content[i].Value)
+			}
+		default:
+			return fmt.Errorf("line %d: invalid address entry", item.Line)
+		}
+	}
+	return nil
+}
+
+// flag is a netplan boolean, which also accepts yes/no and on/off.
+type flag bool
+
+func (f *flag) UnmarshalYAML(node *yaml.Node) error {
+	switch strings.ToLower(node.Value) {
+	case "true", "yes", "on":
+		*f = true
+	case "false", "no", "off":
+		*f = false
+	default:
+		return fmt.Errorf("line %d: invalid boolean %q", node.Line, node.Value)
+	}
+	return nil
+}
+
+// Load reads the netplan files (*.yaml) in paths, which may be directories
+// or files. Like netplan, the files are applied in the lexical order of
+// their names, so a later file extends or overrides the interfaces declared
+// by an earlier one.
+func Load(paths []string) (*Network, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read netplan path %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*.yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list netplan files in %s: %w", p, err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no netplan files found in %s", strings.Join(paths, ", "))
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return filepath.Base(files[i]) < filepath.Base(files[j])
+	})
+
+	n := &Network{Interfaces: make(map[string]*Interface)}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read netplan file: %w", err)
+		}
+		if err := n.merge(data); err != nil {
+			return nil, fmt.Errorf("failed to parse netplan file %s: %w", path, err)
+		}
+	}
+	return n, nil
+}
+
+// Parse parses a single netplan document.
+func Parse(data []byte) (*Network, error) {
+	n := &Network{Interfaces: make(map[string]*Interface)}
+	if err := n.merge(data); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// merge applies a netplan document over the network.
+func (n *Network) merge(data []byte) error {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	for _, kind := range sections {
+		node, ok := f.Network[kind]
+		if !ok {
+			continue
+		}
+		var ifaces map[string]ifaceYAML
+		if err := node.Decode(&ifaces); err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+		for name, y := range ifaces {
+			iface, ok := n.Interfaces[name]
+			if !ok {
+				iface = &Interface{Name: name, Kind: kind}
+				n.Interfaces[name] = iface
+			}
+			if err := iface.apply(y); err != nil {
+				return fmt.Errorf("%s %s: %w", kind, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply merges the definition of an interface from one file.
+func (i *Interface) apply(y ifaceYAML) error {
+	for _, a := range y.Addresses {
+		ip, ipnet, err := net.ParseCIDR(a)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %w", a, err)
+		}
+		ipnet.IP = ip
+		if !slices.ContainsFunc(i.Addresses, func(e *net.IPNet) bool { return e.String() == ipnet.String() }) {
+			i.Addresses = append(i.Addresses, ipnet)
+		}
+	}
+	i.DHCP4 = i.DHCP4 || bool(y.DHCP4)
+	i.DHCP6 = i.DHCP6 || bool(y.DHCP6)
+	if y.Gateway4 != "" {
+		i.Gateway4 = y.Gateway4
+	}
+	if y.Gateway6 != "" {
+		i.Gateway6 = y.Gateway6
+	}
+	for _, r := range y.Routes {
+		if r.To == "" {
+			return fmt.Errorf("route without a destination (to)")
+		}
+		if r.To != "default" {
+			if _, _, err := net.ParseCIDR(r.To); err != nil {
+				return fmt.Errorf("invalid route destination %q: %w", r.To, err)
+			}
+		}
+		route := Route{Interface: i.Name, To: r.To, Via: r.Via, Metric: r.Metric, Table: r.Table}
+		if !slices.Contains(i.Routes, route) {
+			i.Routes = append(i.Routes, route)
+		}
+	}
+	return nil
+}
+
+// names returns the interface names in lexical order.
+func (n *Network) names() []string {
+	names := make([]string, 0, len(n.Interfaces))
+	for name := range n.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StaticRoutes returns the static routes declared on the interfaces, by
+// interface name. Default routes are skipped: they describe how the node
+// itself leaves the underlay, not a network it serves.
+func (n *Network) StaticRoutes() []Route {
+	var routes []Route
+	for _, name := range n.names() {
+		for _, r := range n.Interfaces[name].Routes {
+			if !r.IsDefault() {
+				routes = append(routes, r)
+			}
+		}
+	}
+	return routes
+}
+
+// hasDefaultRoute reports whether the interface carries a default route.
+func (i *Interface) hasDefaultRoute() bool {
+	return i.Gateway4 != "" || i.Gateway6 != "" || slices.ContainsFunc(i.Routes, Route.IsDefault)
+}
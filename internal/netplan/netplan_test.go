@@ -0,0 +1,185 @@
+package netplan
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const base = `
+network:
+  version: 2
+  ethernets:
+    eth0:
+      dhcp4: yes
+      routes:
+        - to: default
+          via: 192.168.1.1
+    eth1:
+      addresses:
+        - 10.0.0.5/24
+        - "2001:db8::5/64":
+            label: eth1:0
+      routes:
+        - to: 172.20.0.0/16
+          via: 10.0.0.254
+          metric: 50
+  vlans:
+    vlan10:
+      id: 10
+      link: eth1
+      addresses: [10.10.0.5/24]
+`
+
+func TestParse(t *testing.T) {
+	n, err := Parse([]byte(base))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(n.Interfaces) != 3 {
+		t.Fatalf("parsed %d interfaces, want 3", len(n.Interfaces))
+	}
+	eth0, eth1 := n.Interfaces["eth0"], n.Interfaces["eth1"]
+	if !eth0.DHCP4 || !eth0.hasDefaultRoute() {
+		t.Errorf("eth0 = %+v, want dhcp4 with the default route", eth0)
+	}
+	if len(eth1.Addresses) != 2 || eth1.Addresses[1].IP.String() != "2001:db8::5" {
+		t.Errorf("eth1 addresses = %v, want the IPv4 and the labelled IPv6 address", eth1.Addresses)
+	}
+	if n.Interfaces["vlan10"].Kind != "vlans" {
+		t.Errorf("vlan10 kind = %q, want vlans", n.Interfaces["vlan10"].Kind)
+	}
+
+	// The default route is not a static route to export.
+	routes := n.StaticRoutes()
+	if len(routes) != 1 || routes[0].To != "172.20.0.0/16" || routes[0].Interface != "eth1" || routes[0].Metric != 50 {
+		t.Fatalf("StaticRoutes = %+v, want 172.20.0.0/16 on eth1", routes)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"address": "network:\n  ethernets:\n    eth0:\n      addresses: [not-an-ip]\n",
+		"route":   "network:\n  ethernets:\n    eth0:\n      routes:\n        - to: 10.0.0.0/33\n",
+		"flag":    "network:\n  ethernets:\n    eth0:\n      dhcp4: maybe\n",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoad_MergesInLexicalOrder(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("01-base.yaml", base)
+	write("90-extra.yaml", `
+network:
+  ethernets:
+    eth1:
+      addresses: [10.0.1.5/24]
+      routes:
+        - to: 172.21.0.0/16
+          via: 10.0.1.254
+`)
+	write("README", "not a netplan file")
+
+	n, err := Load([]string{dir, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(n.Interfaces["eth1"].Addresses); got != 3 {
+		t.Errorf("eth1 has %d addresses, want the 3 of both files", got)
+	}
+	if got := len(n.StaticRoutes()); got != 2 {
+		t.Errorf("%d static routes, want 2", got)
+	}
+
+	if _, err := Load([]string{filepath.Join(dir, "missing")}); err == nil || !strings.Contains(err.Error(), "no netplan files") {
+		t.Errorf("Load of a missing path = %v, want no netplan files found", err)
+	}
+}
+
+func TestSelectUnderlay(t *testing.T) {
+	n, err := Parse([]byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := func(name string) ([]*net.IPNet, error) {
+		if name == "eth0" {
+			_, ipnet, _ := net.ParseCIDR("192.168.1.0/24")
+			ipnet.IP = net.ParseIP("192.168.1.20")
+			return []*net.IPNet{ipnet}, nil
+		}
+		return nil, nil
+	}
+
+	tests := []struct {
+		name      string
+		prefer    []string
+		families  []string
+		peers     []string
+		wantIface string
+		wantAddr  string
+		wantWhy   string
+	}{
+		{
+			name:      "preferred interface",
+			prefer:    []string{"missing", "vlan10"},
+			wantIface: "vlan10",
+			wantAddr:  "10.10.0.5",
+			wantWhy:   "prefer_interfaces",
+		},
+		{
+			name:      "subnet of a peer",
+			peers:     []string{"10.0.0.9"},
+			families:  []string{"ipv6", "ipv4"},
+			wantIface: "eth1",
+			wantAddr:  "2001:db8::5",
+			wantWhy:   "peer 10.0.0.9",
+		},
+		{
+			name:      "default route, DHCP address read live",
+			peers:     []string{"203.0.113.1"},
+			wantIface: "eth0",
+			wantAddr:  "192.168.1.20",
+			wantWhy:   "default route",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var peers []net.IP
+			for _, p := range tt.peers {
+				peers = append(peers, net.ParseIP(p))
+			}
+			u, err := n.SelectUnderlay(tt.prefer, tt.families, peers, live)
+			if err != nil {
+				t.Fatalf("SelectUnderlay: %v", err)
+			}
+			if u.Interface != tt.wantIface || u.Address.String() != tt.wantAddr || !strings.Contains(u.Reason, tt.wantWhy) {
+				t.Fatalf("underlay = %s %s (%s), want %s %s (%s)", u.Interface, u.Address, u.Reason, tt.wantIface, tt.wantAddr, tt.wantWhy)
+			}
+			if u.Source != "netplan" {
+				t.Errorf("source = %q, want netplan", u.Source)
+			}
+		})
+	}
+
+	// Without the live addresses of eth0 the first interface by name is used.
+	n.Interfaces["eth0"].Routes = nil
+	u, err := n.SelectUnderlay(nil, nil, nil, nil)
+	if err != nil || u.Interface != "eth1" || !strings.Contains(u.Reason, "first interface") {
+		t.Fatalf("underlay = %+v (%v), want eth1 as the first interface with an address", u, err)
+	}
+
+	empty := &Network{Interfaces: map[string]*Interface{"eth0": {Name: "eth0", DHCP4: true}}}
+	if _, err := empty.SelectUnderlay(nil, nil, nil, nil); err == nil {
+		t.Fatal("expected an error without any usable address")
+	}
+}
@@ -0,0 +1,187 @@
+package netplan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+)
+
+// Underlay is the interface and address the node uses on the underlay: the
+// source of its VXLAN tunnels and the next-hop of the routes it exports when
+// its overlays have no bridge IP.
+type Underlay struct {
+	Interface string
+	Address   net.IP
+	// Source is "netplan" when the underlay was inferred from the netplan
+	// files, "kernel" when it was found on the live interfaces.
+	Source string
+	// Reason explains why the interface was picked.
+	Reason string
+}
+
+// AddressFunc returns the addresses (with their subnet mask) of a live
+// interface.
+type AddressFunc func(iface string) ([]*net.IPNet, error)
+
+// Host reads the live interfaces of the node, e.g. netlink.LinkManager.
+type Host interface {
+	// UpInterfaces returns the names of the interfaces that are up,
+	// loopback excluded.
+	UpInterfaces() ([]string, error)
+	// InterfaceAddresses returns the addresses (with their subnet mask) of
+	// an interface.
+	InterfaceAddresses(name string) ([]*net.IPNet, error)
+}
+
+// errNoUnderlay is returned when no interface qualifies as the underlay.
+var errNoUnderlay = errors.New("no interface with a usable address")
+
+// SelectUnderlay picks the underlay among the interfaces of the network, in
+// this order:
+//
+//  1. the first interface of preferInterfaces with an address;
+//  2. an interface whose subnet contains a peer endpoint;
+//  3. the interface carrying the default route;
+//  4. the first interface with an address, by name.
+//
+// The address is picked by family in the order of preferFamilies ("ipv4",
+// "ipv6"); an interface with addresses of other families only is still used.
+// Interfaces configured with DHCP have no static address: their live
+// addresses are read with live, when set.
+func (n *Network) SelectUnderlay(preferInterfaces, preferFamilies []string, peers []net.IP, live AddressFunc) (Underlay, error) {
+	addrs := make(map[string][]*net.IPNet)
+	var candidates []string
+	for _, name := range n.names() {
+		iface := n.Interfaces[name]
+		a := usable(iface.Addresses)
+		if len(a) == 0 && (iface.DHCP4 || iface.DHCP6) && live != nil {
+			if l, err := live(name); err == nil {
+				a = usable(l)
+			}
+		}
+		if len(a) > 0 {
+			addrs[name] = a
+			candidates = append(candidates, name)
+		}
+	}
+
+	pick := func(name, reason string) Underlay {
+		return Underlay{
+			Interface: name,
+			Address:   pickAddress(addrs[name], preferFamilies),
+			Source:    "netplan",
+			Reason:    reason,
+		}
+	}
+
+	for _, name := range preferInterfaces {
+		if _, ok := addrs[name]; ok {
+			return pick(name, "listed in netplan.underlay.prefer_interfaces"), nil
+		}
+	}
+	for _, peer := range peers {
+		for _, name := range candidates {
+			if slices.ContainsFunc(addrs[name], func(a *net.IPNet) bool { return a.Contains(peer) }) {
+				return pick(name, fmt.Sprintf("its subnet contains peer %s", peer)), nil
+			}
+		}
+	}
+	for _, name := range candidates {
+		if n.Interfaces[name].hasDefaultRoute() {
+			return pick(name, "it carries the default route"), nil
+		}
+	}
+	if len(candidates) > 0 {
+		return pick(candidates[0], "first interface with an address"), nil
+	}
+	return Underlay{}, errNoUnderlay
+}
+
+// usable drops loopback and link-local addresses.
+func usable(addrs []*net.IPNet) []*net.IPNet {
+	var out []*net.IPNet
+	for _, a := range addrs {
+		if !a.IP.IsLoopback() && !a.IP.IsLinkLocalUnicast() {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// pickAddress returns the first address of the first preferred family, or
+// the first address when none matches.
+func pickAddress(addrs []*net.IPNet, families []string) net.IP {
+	for _, family := range families {
+		for _, a := range addrs {
+			if (family == "ipv4") == (a.IP.To4() != nil) {
+				return a.IP
+			}
+		}
+	}
+	return addrs[0].IP
+}
+
+// LoadConfig reads the netplan files of cfg (netplan.config_paths). It
+// returns nil when netplan is disabled. Callers load them once per config
+// (load or reload) and keep the result.
+func LoadConfig(cfg *config.Config) (*Network, error) {
+	if !cfg.Netplan.Enabled {
+		return nil, nil
+	}
+	return Load(cfg.Netplan.ConfigPaths)
+}
+
+// InferUnderlay selects the underlay of the node. With netplan enabled it is
+// inferred from n, the netplan files of cfg (see LoadConfig and
+// SelectUnderlay). Otherwise, or when that fails, it falls back to the first
+// live interface of host whose subnet contains the endpoint of the first
+// peer.
+func InferUnderlay(cfg *config.Config, n *Network, host Host) (Underlay, error) {
+	var peers []net.IP
+	for _, p := range cfg.GetPeers() {
+		if ip := net.ParseIP(p.Endpoint.Address); ip != nil {
+			peers = append(peers, ip)
+		}
+	}
+
+	fallback := "netplan disabled"
+	switch {
+	case !cfg.Netplan.Enabled:
+	case n == nil:
+		fallback = "netplan files not loaded"
+	default:
+		u, err := n.SelectUnderlay(cfg.Netplan.Underlay.PreferInterfaces, cfg.Netplan.Underlay.PreferAddressFamilies, peers, host.InterfaceAddresses)
+		if err == nil {
+			return u, nil
+		}
+		fallback = fmt.Sprintf("netplan inference failed: %v", err)
+	}
+
+	if len(peers) == 0 {
+		return Underlay{}, fmt.Errorf("%s, and no peer endpoint to match an interface against", fallback)
+	}
+	ifaces, err := host.UpInterfaces()
+	if err != nil {
+		return Underlay{}, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		addrs, err := host.InterfaceAddresses(iface)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if a.Contains(peers[0]) {
+				return Underlay{
+					Interface: iface,
+					Address:   a.IP,
+					Source:    "kernel",
+					Reason:    fmt.Sprintf("%s; its subnet contains peer %s", fallback, peers[0]),
+				}, nil
+			}
+		}
+	}
+	return Underlay{}, fmt.Errorf("%s, and no interface subnet contains peer %s", fallback, peers[0])
+}
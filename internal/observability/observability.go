@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -24,6 +25,32 @@ type PeerStatus struct {
 	Status   string `json:"status"`
 	LastSeen string `json:"last_seen,omitempty"`
 	Routes   int    `json:"routes"`
+	// RelayedVia is the peer relaying traffic to this (down) peer, if any.
+	RelayedVia string `json:"relayed_via,omitempty"`
+	// Link is the underlay quality measured over the keepalive stream.
+	Link *LinkQuality `json:"link,omitempty"`
+	// Limits reports the peer's limit violations, if any.
+	Limits *PeerLimitStatus `json:"limits,omitempty"`
+}
+
+// PeerLimitStatus is the state of the limits of a peer (peers[].limits).
+type PeerLimitStatus struct {
+	MaxPrefixes     int  `json:"max_prefixes,omitempty"`
+	OverMaxPrefixes bool `json:"over_max_prefixes,omitempty"`
+	// TornDownUntil is set while the peer's announcements are refused for
+	// exceeding max_prefixes (RFC 3339).
+	TornDownUntil        string `json:"torn_down_until,omitempty"`
+	RateLimited          bool   `json:"rate_limited,omitempty"`
+	RefusedAnnouncements uint64 `json:"refused_announcements,omitempty"`
+}
+
+// LinkQuality is the underlay quality towards a peer, measured from the
+// keepalive sequence numbers it echoes back.
+type LinkQuality struct {
+	RTTMs       float64 `json:"rtt_ms"`
+	JitterMs    float64 `json:"jitter_ms"`
+	LossPercent float64 `json:"loss_percent"`
+	Samples     uint64  `json:"samples"`
 }
 
 // NodeStatus represents the overall status of the daemon.
@@ -65,9 +92,18 @@ type Metrics struct {
 	PeersConnected  prometheus.Gauge
 	PeersHealthy    prometheus.Gauge
 
+	// Per-peer link quality (from keepalive sequences)
+	PeerRTT    *prometheus.HistogramVec
+	PeerJitter *prometheus.GaugeVec
+	PeerLoss   *prometheus.GaugeVec
+
 	// Route metrics
 	RoutesExported prometheus.Gauge
 	RoutesImported prometheus.Gauge
+	RoutesRejected *prometheus.CounterVec
+
+	// Per-peer limit violations (max_prefixes, announce_rate)
+	PeerLimitViolations *prometheus.CounterVec
 
 	// Control plane metrics
 	GRPCRequestsTotal   *prometheus.CounterVec
@@ -128,6 +164,22 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name:      "peers_healthy",
 			Help:      "Number of healthy peers",
 		}),
+		PeerRTT: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "nnetman",
+			Name:      "peer_rtt_seconds",
+			Help:      "Keepalive round-trip time per peer",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"peer_id"}),
+		PeerJitter: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "nnetman",
+			Name:      "peer_jitter_seconds",
+			Help:      "Keepalive RTT jitter per peer (RFC 3550 smoothed variation)",
+		}, []string{"peer_id"}),
+		PeerLoss: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "nnetman",
+			Name:      "peer_keepalive_loss_ratio",
+			Help:      "Fraction of unanswered keepalives per peer over the last 100",
+		}, []string{"peer_id"}),
 		RoutesExported: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: "nnetman",
 			Name:      "routes_exported",
@@ -138,6 +190,16 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name:      "routes_imported",
 			Help:      "Number of routes imported from peers",
 		}),
+		RoutesRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nnetman",
+			Name:      "routes_rejected_total",
+			Help:      "Total number of route announcements rejected, per peer and reason",
+		}, []string{"peer_id", "reason"}),
+		PeerLimitViolations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nnetman",
+			Name:      "peer_limit_violations_total",
+			Help:      "Total number of violations of the per-peer limits, per peer and limit",
+		}, []string{"peer_id", "limit"}),
 		GRPCRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "nnetman",
 			Name:      "grpc_requests_total",
@@ -164,8 +226,13 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 	m.PeersConfigured = registerOrExisting(reg, m.PeersConfigured)
 	m.PeersConnected = registerOrExisting(reg, m.PeersConnected)
 	m.PeersHealthy = registerOrExisting(reg, m.PeersHealthy)
+	m.PeerRTT = registerOrExisting(reg, m.PeerRTT)
+	m.PeerJitter = registerOrExisting(reg, m.PeerJitter)
+	m.PeerLoss = registerOrExisting(reg, m.PeerLoss)
 	m.RoutesExported = registerOrExisting(reg, m.RoutesExported)
 	m.RoutesImported = registerOrExisting(reg, m.RoutesImported)
+	m.RoutesRejected = registerOrExisting(reg, m.RoutesRejected)
+	m.PeerLimitViolations = registerOrExisting(reg, m.PeerLimitViolations)
 	m.GRPCRequestsTotal = registerOrExisting(reg, m.GRPCRequestsTotal)
 	m.GRPCRequestDuration = registerOrExisting(reg, m.GRPCRequestDuration)
 
@@ -191,7 +258,7 @@ func registerOrExisting[T prometheus.Collector](reg prometheus.Registerer, c T)
 
 // Server provides HTTP endpoints for metrics and health checks.
 type Server struct {
-	cfg            *config.Config
+	cfg            atomic.Pointer[config.Config]
 	logger         *slog.Logger
 	metricsServer  *http.Server
 	healthServer   *http.Server
@@ -206,13 +273,20 @@ type Server struct {
 
 // NewServer creates a new observability server.
 func NewServer(cfg *config.Config, logger *slog.Logger) *Server {
-	return &Server{
-		cfg:       cfg,
+	s := &Server{
 		logger:    logger,
 		healthy:   true,
 		ready:     false,
 		startTime: time.Now(),
 	}
+	s.cfg.Store(cfg)
+	return s
+}
+
+// SetConfig swaps the configuration reported by /status. Listen addresses
+// are bound at Start and are not changed by a reload.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.cfg.Store(cfg)
 }
 
 // SetStatusProvider sets the status provider for the /status endpoint.
@@ -233,15 +307,16 @@ func (s *Server) SetHealthFunc(fn func() bool) {
 
 // Start starts the metrics and health check servers.
 func (s *Server) Start(ctx context.Context) error {
+	cfg := s.cfg.Load()
 	// Start metrics server
-	if s.cfg.Observability.Metrics.Enabled {
+	if cfg.Observability.Metrics.Enabled {
 		if err := s.startMetricsServer(); err != nil {
 			return err
 		}
 	}
 
 	// Start health check server
-	if s.cfg.Observability.Healthcheck.Enabled {
+	if cfg.Observability.Healthcheck.Enabled {
 		if err := s.startHealthServer(); err != nil {
 			return err
 		}
@@ -252,8 +327,8 @@ func (s *Server) Start(ctx context.Context) error {
 
 func (s *Server) startMetricsServer() error {
 	addr := fmt.Sprintf("%s:%d",
-		s.cfg.Observability.Metrics.Listen.Address,
-		s.cfg.Observability.Metrics.Listen.Port,
+		s.cfg.Load().Observability.Metrics.Listen.Address,
+		s.cfg.Load().Observability.Metrics.Listen.Port,
 	)
 
 	mux := http.NewServeMux()
@@ -280,8 +355,8 @@ func (s *Server) startMetricsServer() error {
 
 func (s *Server) startHealthServer() error {
 	addr := fmt.Sprintf("%s:%d",
-		s.cfg.Observability.Healthcheck.Listen.Address,
-		s.cfg.Observability.Healthcheck.Listen.Port,
+		s.cfg.Load().Observability.Healthcheck.Listen.Address,
+		s.cfg.Load().Observability.Healthcheck.Listen.Port,
 	)
 
 	mux := http.NewServeMux()
@@ -358,7 +433,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Build a snapshot status response (no live streaming).
 	status := NodeStatus{
-		NodeID: s.cfg.Node.ID,
+		NodeID: s.cfg.Load().Node.ID,
 		Uptime: time.Since(s.startTime).Round(time.Second).String(),
 		Peers:  make(map[string]PeerStatus),
 		Routes: RouteStats{},
@@ -370,7 +445,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		status.Routes = provider.GetRouteStats()
 	} else {
 		// Fallback: show configured peers with unknown status when daemon is offline.
-		for _, peer := range s.cfg.GetPeers() {
+		for _, peer := range s.cfg.Load().GetPeers() {
 			status.Peers[peer.ID] = PeerStatus{
 				ID:       peer.ID,
 				Endpoint: peer.Endpoint.Address,
@@ -379,7 +454,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		// Count exported routes across all overlays (v1 and v2).
-		for _, o := range s.cfg.GetOverlays() {
+		for _, o := range s.cfg.Load().GetOverlays() {
 			status.Routes.Exported += len(o.Routing.Export.Networks)
 		}
 	}
@@ -0,0 +1,96 @@
+package reconciler
+
+import (
+	"net"
+
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// VXLANManager creates and inspects VXLAN interfaces.
+type VXLANManager interface {
+	Create(cfg nlink.VXLANConfig) error
+	Delete(name string) error
+	Get(name string) (*nlink.VXLANInfo, error)
+	Exists(name string) bool
+}
+
+// BridgeManager creates and inspects bridges and their addresses.
+type BridgeManager interface {
+	Create(cfg nlink.BridgeConfig) error
+	Delete(name string) error
+	Get(name string) (*nlink.BridgeInfo, error)
+	Exists(name string) bool
+	Addresses(bridgeName string) ([]string, error)
+	AddAddress(bridgeName, cidr string) error
+	DeleteAddress(bridgeName, cidr string) error
+}
+
+// FDBManager manages the head-end replication entries of VXLAN interfaces.
+type FDBManager interface {
+	List(vxlanName string) ([]nlink.FDBEntry, error)
+	AddPeer(vxlanName string, remoteIP net.IP, via string) error
+	DeletePeer(vxlanName string, remoteIP net.IP) error
+}
+
+// RouteManager lists and removes routes for pruning, and keeps the host
+// routes of peers pinned to an underlay interface (learned routes are
+// installed by the daemon).
+type RouteManager interface {
+	ListByProtocol(table, protocol int) ([]nlink.RouteInfo, error)
+	Replace(cfg nlink.RouteConfig) error
+	Delete(cfg nlink.RouteConfig) error
+	GatewayVia(dst net.IP, iface string) (net.IP, error)
+}
+
+// RuleManager manages the policy rules of overlays.
+type RuleManager interface {
+	List() ([]nlink.RuleInfo, error)
+	Add(rule nlink.RuleInfo) error
+	Delete(rule nlink.RuleInfo) error
+}
+
+// LinkManager reads interface addresses and manages ownership markers. It
+// is also the netplan.Host the underlay is inferred against.
+type LinkManager interface {
+	Addresses(name string) ([]net.IP, error)
+	UpInterfaces() ([]string, error)
+	InterfaceAddresses(name string) ([]*net.IPNet, error)
+	SetOwnership(name string, o nlink.Ownership) error
+	ListOwned() ([]nlink.OwnedLink, error)
+}
+
+// Backend is the kernel API the reconciler plans against and applies
+// changes through. The default talks netlink; tests inject an in-memory
+// kernel with WithBackend.
+type Backend struct {
+	VXLAN  VXLANManager
+	Bridge BridgeManager
+	FDB    FDBManager
+	Route  RouteManager
+	Rule   RuleManager
+	Link   LinkManager
+}
+
+// NetlinkBackend returns the Backend backed by the Linux kernel.
+func NetlinkBackend() Backend {
+	return Backend{
+		VXLAN:  nlink.NewVXLANManager(),
+		Bridge: nlink.NewBridgeManager(),
+		FDB:    nlink.NewFDBManager(),
+		Route:  nlink.NewRouteManager(),
+		Rule:   nlink.NewRuleManager(),
+		Link:   nlink.NewLinkManager(),
+	}
+}
+
+// WithBackend replaces the kernel backend.
+func WithBackend(b Backend) Option {
+	return func(r *Reconciler) {
+		r.vxlan = b.VXLAN
+		r.bridge = b.Bridge
+		r.fdb = b.FDB
+		r.route = b.Route
+		r.rule = b.Rule
+		r.link = b.Link
+	}
+}
@@ -0,0 +1,352 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// defaultEventDebounce coalesces bursts of kernel events (e.g. a device
+// deleted together with its addresses, FDB entries and routes) into a single
+// reconciliation of the affected overlay.
+const defaultEventDebounce = 500 * time.Millisecond
+
+// overlayEvent is a kernel change that broke part of an overlay's state.
+type overlayEvent struct {
+	vni    int
+	reason string
+	// routesLost is set when routes n-netman installed for the overlay were
+	// (or may have been) removed behind its back. The kernel flushes routes
+	// through a device that is deleted or set down without notifying.
+	routesLost bool
+	// rulesOnly is set when only the to rules of the overlay (lookup_rules
+	// in prefix mode) may be out of date: a route was installed or
+	// withdrawn by the daemon itself.
+	rulesOnly bool
+}
+
+// WithEventDebounce sets how long the reconciler waits after a kernel event
+// before reconciling the affected overlay. 0 disables event-driven
+// reconciliation, leaving only the periodic loop.
+func WithEventDebounce(d time.Duration) Option {
+	return func(r *Reconciler) {
+		r.debounce = d
+	}
+}
+
+// WithRouteRestore sets the function called after an overlay is reconciled
+// because routes n-netman installed in its table were removed externally.
+// The reconciler does not own learned routes; the caller reinstalls them.
+func WithRouteRestore(fn func(overlay config.OverlayDef)) Option {
+	return func(r *Reconciler) {
+		r.restoreRoutes = fn
+	}
+}
+
+// WithOwnRouteDeletions sets the function telling whether the deletion of a
+// route in an overlay table was made by the daemon (a withdrawal or a
+// superseded best path), e.g. nlink.RouteManager.OwnDeletion. Those
+// deletions do not count as routes lost.
+func WithOwnRouteDeletions(fn func(table int, dst *net.IPNet) bool) Option {
+	return func(r *Reconciler) {
+		r.ownRouteDeletion = fn
+	}
+}
+
+// WithAddressChanges sets the function called with the name of each
+// interface whose addresses are added or removed, so the caller can follow
+// them (e.g. the connected subnets exported by include_connected) without a
+// subscription of its own. It is called from the kernel watcher and must not
+// block.
+func WithAddressChanges(fn func(link string)) Option {
+	return func(r *Reconciler) {
+		r.addrChanged = fn
+	}
+}
+
+// watchKernel subscribes to netlink link, address, route and neighbor
+// updates and sends the events affecting a managed overlay to out until ctx
+// is cancelled. It returns false if the subscriptions could not be set up.
+func (r *Reconciler) watchKernel(ctx context.Context, out chan<- overlayEvent) bool {
+	links := make(chan netlink.LinkUpdate, 64)
+	addrs := make(chan netlink.AddrUpdate, 64)
+	routes := make(chan netlink.RouteUpdate, 256)
+	neighs := make(chan netlink.NeighUpdate, 256)
+	onErr := func(err error) {
+		r.logger.Warn("netlink subscription error", "error", err)
+	}
+
+	done := make(chan struct{})
+	if err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		r.logger.Warn("failed to subscribe to link updates", "error", err)
+		close(done)
+		return false
+	}
+	if err := netlink.AddrSubscribeWithOptions(addrs, done, netlink.AddrSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		r.logger.Warn("failed to subscribe to address updates", "error", err)
+		close(done)
+		return false
+	}
+	if err := netlink.RouteSubscribeWithOptions(routes, done, netlink.RouteSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		r.logger.Warn("failed to subscribe to route updates", "error", err)
+		close(done)
+		return false
+	}
+	if err := netlink.NeighSubscribeWithOptions(neighs, done, netlink.NeighSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		r.logger.Warn("failed to subscribe to neighbor updates", "error", err)
+		close(done)
+		return false
+	}
+
+	// Interface names by index, kept current by the link updates, so the
+	// address and neighbor updates do not need a lookup each.
+	names := make(map[int]string)
+	if all, err := netlink.LinkList(); err == nil {
+		for _, l := range all {
+			names[l.Attrs().Index] = l.Attrs().Name
+		}
+	}
+	linkName := func(index int) string {
+		if index <= 0 {
+			return ""
+		}
+		if name, ok := names[index]; ok {
+			return name
+		}
+		return linkNameByIndex(index)
+	}
+
+	go func() {
+		defer close(done)
+		for links != nil || addrs != nil || routes != nil || neighs != nil {
+			var events []overlayEvent
+			select {
+			case <-ctx.Done():
+				return
+			case u, ok := <-links:
+				if !ok {
+					links = nil
+					continue
+				}
+				attrs := u.Attrs()
+				if u.Header.Type == unix.RTM_DELLINK {
+					delete(names, attrs.Index)
+				} else {
+					names[attrs.Index] = attrs.Name
+				}
+				events = linkEventOverlays(r.cfg.Load().GetOverlays(), attrs.Name,
+					u.Header.Type == unix.RTM_DELLINK, attrs.Flags&net.FlagUp != 0, linkName(attrs.MasterIndex))
+			case u, ok := <-addrs:
+				if !ok {
+					addrs = nil
+					continue
+				}
+				name := linkName(u.LinkIndex)
+				if r.addrChanged != nil && name != "" {
+					r.addrChanged(name)
+				}
+				events = addrEventOverlays(r.cfg.Load().GetOverlays(), name, u.LinkAddress, u.NewAddr)
+			case u, ok := <-routes:
+				if !ok {
+					routes = nil
+					continue
+				}
+				deleted := u.Type == unix.RTM_DELROUTE
+				// Withdrawn or superseded by the daemon itself.
+				own := deleted && int(u.Protocol) == nlink.RouteProtocolNNetMan &&
+					r.ownRouteDeletion != nil && r.ownRouteDeletion(u.Table, u.Dst)
+				events = routeEventOverlays(r.cfg.Load().GetOverlays(), u.Table, int(u.Protocol), deleted, own)
+			case u, ok := <-neighs:
+				if !ok {
+					neighs = nil
+					continue
+				}
+				events = neighEventOverlays(r.cfg.Load().GetOverlays(), linkName(u.LinkIndex), u.HardwareAddr, u.Type == unix.RTM_DELNEIGH)
+			}
+			for _, ev := range events {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		r.logger.Warn("netlink subscriptions closed, relying on periodic reconciliation")
+	}()
+	return true
+}
+
+// linkNameByIndex resolves an interface index, returning "" if it no longer
+// exists.
+func linkNameByIndex(index int) string {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return link.Attrs().Name
+}
+
+// linkEventOverlays maps a link update to the overlays it breaks: a VXLAN
+// device or bridge deleted or set down, a VXLAN device detached from its
+// bridge, or any change to an overlay's underlay interface. Updates that
+// leave a device in the desired state (including the ones caused by the
+// reconciler itself) are ignored.
+func linkEventOverlays(overlays []config.OverlayDef, name string, deleted, up bool, master string) []overlayEvent {
+	var out []overlayEvent
+	for _, o := range overlays {
+		switch name {
+		case o.Name:
+			switch {
+			case deleted:
+				out = append(out, overlayEvent{vni: o.VNI, reason: "vxlan deleted"})
+			case !up:
+				out = append(out, overlayEvent{vni: o.VNI, reason: "vxlan down"})
+			case master != o.Bridge.Name:
+				out = append(out, overlayEvent{vni: o.VNI, reason: "vxlan detached from bridge"})
+			}
+		case o.Bridge.Name:
+			switch {
+			case deleted:
+				out = append(out, overlayEvent{vni: o.VNI, reason: "bridge deleted", routesLost: true})
+			case !up:
+				out = append(out, overlayEvent{vni: o.VNI, reason: "bridge down", routesLost: true})
+			}
+		case o.UnderlayInterface:
+			if name != "" {
+				out = append(out, overlayEvent{vni: o.VNI, reason: "underlay changed"})
+			}
+		}
+	}
+	return out
+}
+
+// addrEventOverlays maps an address update to the overlays it breaks: the
+// configured bridge IP removed, or any address change on the underlay
+// interface (the VXLAN local IP is derived from it).
+func addrEventOverlays(overlays []config.OverlayDef, link string, addr net.IPNet, added bool) []overlayEvent {
+	if link == "" {
+		return nil
+	}
+	var out []overlayEvent
+	for _, o := range overlays {
+		switch {
+		case link == o.UnderlayInterface:
+			out = append(out, overlayEvent{vni: o.VNI, reason: "underlay address changed"})
+		case link == o.Bridge.Name && !added:
+			for _, cidr := range []string{o.Bridge.IPv4, o.Bridge.IPv6} {
+				if ip, _, err := net.ParseCIDR(cidr); err == nil && ip.Equal(addr.IP) {
+					out = append(out, overlayEvent{vni: o.VNI, reason: "bridge address removed"})
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// routeEventOverlays maps a route deletion in an overlay's import table, for
+// a route installed by n-netman, to the overlays using that table. Routes
+// added or deleted by the daemon itself (own) only matter to an overlay with
+// lookup_rules in prefix mode: they are mapped to an update of its rules
+// alone, so its to rules follow the imported prefixes.
+func routeEventOverlays(overlays []config.OverlayDef, table, protocol int, deleted, own bool) []overlayEvent {
+	if protocol != nlink.RouteProtocolNNetMan {
+		return nil
+	}
+	var out []overlayEvent
+	for _, o := range overlays {
+		t := o.Routing.Import.Install.Table
+		if t == 0 {
+			t = config.DefaultImportTable
+		}
+		if t != table {
+			continue
+		}
+		lookup := o.Routing.Import.Install.LookupRules
+		switch {
+		case deleted && !own:
+			out = append(out, overlayEvent{vni: o.VNI, reason: "route removed", routesLost: true})
+		case lookup.Enabled && lookup.PrefixMode():
+			out = append(out, overlayEvent{vni: o.VNI, reason: "imported routes changed", rulesOnly: true})
+		}
+	}
+	return out
+}
+
+// neighEventOverlays maps the deletion of a head-end replication FDB entry
+// (all-zero MAC) on an overlay's VXLAN device to that overlay. Learned MACs
+// come and go constantly and are ignored.
+func neighEventOverlays(overlays []config.OverlayDef, link string, mac net.HardwareAddr, deleted bool) []overlayEvent {
+	if !deleted || link == "" || !bytes.Equal(mac, make(net.HardwareAddr, 6)) {
+		return nil
+	}
+	for _, o := range overlays {
+		if o.Name == link {
+			return []overlayEvent{{vni: o.VNI, reason: "fdb entry removed"}}
+		}
+	}
+	return nil
+}
+
+// debouncer delays the reconciliation of an overlay until its events stop
+// for the debounce period, merging the events received meanwhile.
+type debouncer struct {
+	delay   time.Duration
+	pending map[int]*pendingOverlay
+	due     chan int
+}
+
+type pendingOverlay struct {
+	timer      *time.Timer
+	reasons    []string
+	routesLost bool
+	// rulesOnly holds while every merged event is rulesOnly.
+	rulesOnly bool
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{
+		delay:   delay,
+		pending: make(map[int]*pendingOverlay),
+		due:     make(chan int, 64),
+	}
+}
+
+// add records an event and (re)starts the overlay's timer. When it fires
+// the VNI is sent on due. Not safe for concurrent use: add and take are
+// called from the reconciler loop only.
+func (d *debouncer) add(ev overlayEvent) {
+	p, ok := d.pending[ev.vni]
+	if !ok {
+		p = &pendingOverlay{rulesOnly: true}
+		d.pending[ev.vni] = p
+		vni := ev.vni
+		p.timer = time.AfterFunc(d.delay, func() { d.due <- vni })
+	} else {
+		p.timer.Reset(d.delay)
+	}
+	p.reasons = append(p.reasons, ev.reason)
+	p.routesLost = p.routesLost || ev.routesLost
+	p.rulesOnly = p.rulesOnly && ev.rulesOnly
+}
+
+// take returns and clears the merged events of an overlay that became due.
+func (d *debouncer) take(vni int) (*pendingOverlay, bool) {
+	p, ok := d.pending[vni]
+	delete(d.pending, vni)
+	return p, ok
+}
+
+// stop cancels the pending timers.
+func (d *debouncer) stop() {
+	for _, p := range d.pending {
+		p.timer.Stop()
+	}
+}
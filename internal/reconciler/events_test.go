@@ -0,0 +1,208 @@
+package reconciler
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+func testOverlays() []config.OverlayDef {
+	a := config.OverlayDef{VNI: 100, Name: "vxlan100", UnderlayInterface: "eth0"}
+	a.Bridge.Name = "br-100"
+	a.Bridge.IPv4 = "10.100.0.1/24"
+	a.Routing.Import.Install.Table = 200
+
+	b := config.OverlayDef{VNI: 200, Name: "vxlan200", UnderlayInterface: "eth1"}
+	b.Bridge.Name = "br-200"
+	return []config.OverlayDef{a, b}
+}
+
+func vnis(events []overlayEvent) []int {
+	var out []int
+	for _, ev := range events {
+		out = append(out, ev.vni)
+	}
+	return out
+}
+
+func sameVNIs(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLinkEventOverlays(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		deleted bool
+		up      bool
+		master  string
+		want    []int
+	}{
+		{"vxlan deleted", "vxlan100", true, false, "", []int{100}},
+		{"vxlan down", "vxlan100", false, false, "br-100", []int{100}},
+		{"vxlan detached", "vxlan100", false, true, "", []int{100}},
+		{"vxlan in desired state", "vxlan100", false, true, "br-100", nil},
+		{"bridge deleted", "br-200", true, false, "", []int{200}},
+		{"bridge up", "br-200", false, true, "", nil},
+		{"underlay changed", "eth1", false, true, "", []int{200}},
+		{"unmanaged link", "tap0", true, false, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := vnis(linkEventOverlays(testOverlays(), tt.link, tt.deleted, tt.up, tt.master))
+			if !sameVNIs(got, tt.want) {
+				t.Fatalf("linkEventOverlays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddrEventOverlays(t *testing.T) {
+	ipnet := func(s string) net.IPNet {
+		ip, n, _ := net.ParseCIDR(s)
+		n.IP = ip
+		return *n
+	}
+	tests := []struct {
+		name  string
+		link  string
+		addr  net.IPNet
+		added bool
+		want  []int
+	}{
+		{"bridge address removed", "br-100", ipnet("10.100.0.1/24"), false, []int{100}},
+		{"bridge address added", "br-100", ipnet("10.100.0.1/24"), true, nil},
+		{"other bridge address removed", "br-100", ipnet("10.100.0.9/24"), false, nil},
+		{"underlay address added", "eth0", ipnet("192.168.1.10/24"), true, []int{100}},
+		{"unknown link", "", ipnet("10.100.0.1/24"), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := vnis(addrEventOverlays(testOverlays(), tt.link, tt.addr, tt.added))
+			if !sameVNIs(got, tt.want) {
+				t.Fatalf("addrEventOverlays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteEventOverlays(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    int
+		protocol int
+		deleted  bool
+		own      bool
+		want     []int
+	}{
+		{"n-netman route deleted", 200, nlink.RouteProtocolNNetMan, true, false, []int{100}},
+		{"default table", 100, nlink.RouteProtocolNNetMan, true, false, []int{200}},
+		{"route added", 200, nlink.RouteProtocolNNetMan, false, false, nil},
+		{"route withdrawn", 200, nlink.RouteProtocolNNetMan, true, true, nil},
+		{"foreign protocol", 200, 4, true, false, nil},
+		{"unmanaged table", 254, nlink.RouteProtocolNNetMan, true, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := routeEventOverlays(testOverlays(), tt.table, tt.protocol, tt.deleted, tt.own)
+			if got := vnis(events); !sameVNIs(got, tt.want) {
+				t.Fatalf("routeEventOverlays() = %v, want %v", got, tt.want)
+			}
+			for _, ev := range events {
+				if !ev.routesLost {
+					t.Fatalf("route event for VNI %d should flag routesLost", ev.vni)
+				}
+			}
+		})
+	}
+
+	// With prefix lookup rules a route added or withdrawn by the daemon
+	// changes the to rules only, and no route was lost.
+	overlays := testOverlays()
+	overlays[0].Routing.Import.Install.LookupRules = config.LookupRulesConfig{Enabled: true, Mode: "prefix"}
+	for _, deleted := range []bool{false, true} {
+		events := routeEventOverlays(overlays, 200, nlink.RouteProtocolNNetMan, deleted, deleted)
+		if len(events) != 1 || events[0].vni != 100 || events[0].routesLost || !events[0].rulesOnly {
+			t.Fatalf("routeEventOverlays(deleted=%v) = %+v, want a rules update of VNI 100", deleted, events)
+		}
+	}
+	// A route removed behind the daemon's back needs the whole overlay.
+	events := routeEventOverlays(overlays, 200, nlink.RouteProtocolNNetMan, true, false)
+	if len(events) != 1 || !events[0].routesLost || events[0].rulesOnly {
+		t.Fatalf("routeEventOverlays() for a lost route = %+v, want routesLost only", events)
+	}
+}
+
+func TestNeighEventOverlays(t *testing.T) {
+	zero := make(net.HardwareAddr, 6)
+	learned, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	tests := []struct {
+		name    string
+		link    string
+		mac     net.HardwareAddr
+		deleted bool
+		want    []int
+	}{
+		{"flood entry deleted", "vxlan200", zero, true, []int{200}},
+		{"flood entry added", "vxlan200", zero, false, nil},
+		{"learned mac deleted", "vxlan200", learned, true, nil},
+		{"other device", "br-200", zero, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := vnis(neighEventOverlays(testOverlays(), tt.link, tt.mac, tt.deleted))
+			if !sameVNIs(got, tt.want) {
+				t.Fatalf("neighEventOverlays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebouncer_CoalescesPerOverlay(t *testing.T) {
+	d := newDebouncer(50 * time.Millisecond)
+	defer d.stop()
+
+	d.add(overlayEvent{vni: 100, reason: "imported routes changed", rulesOnly: true})
+	d.add(overlayEvent{vni: 200, reason: "imported routes changed", rulesOnly: true})
+	time.Sleep(20 * time.Millisecond)
+	d.add(overlayEvent{vni: 100, reason: "route removed", routesLost: true})
+
+	got := make(map[int]*pendingOverlay)
+	for len(got) < 2 {
+		select {
+		case vni := <-d.due:
+			p, ok := d.take(vni)
+			if !ok {
+				t.Fatalf("VNI %d due twice", vni)
+			}
+			got[vni] = p
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for overlays, got %v", got)
+		}
+	}
+
+	if p := got[100]; len(p.reasons) != 2 || !p.routesLost || p.rulesOnly {
+		t.Fatalf("VNI 100 = %+v, want 2 merged reasons with routesLost", p)
+	}
+	if p := got[200]; len(p.reasons) != 1 || p.routesLost || !p.rulesOnly {
+		t.Fatalf("VNI 200 = %+v, want 1 reason for the rules only", p)
+	}
+
+	select {
+	case vni := <-d.due:
+		t.Fatalf("unexpected extra reconciliation of VNI %d", vni)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
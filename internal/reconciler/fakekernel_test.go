@@ -0,0 +1,590 @@
+package reconciler
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// fakeKernel is an in-memory kernel for reconciler tests. It models links
+// (VXLANs, bridges and plain interfaces) with their masters, MTU, state,
+// alias and addresses, the FDB of VXLAN devices, routes and policy rules,
+// and mirrors the semantics of the netlink managers the reconciler relies
+// on. Operations can be made to fail with failOn.
+type fakeKernel struct {
+	mu     sync.Mutex
+	links  map[string]*fakeLink
+	fdb    map[string][]nlink.FDBEntry
+	routes []nlink.RouteInfo
+	// gateways are the underlay gateways by interface; interfaces without
+	// one reach every destination on-link.
+	gateways map[string]net.IP
+	rules    []nlink.RuleInfo
+	fail     map[string]error
+	calls    []string
+}
+
+type fakeLink struct {
+	kind string // "vxlan", "bridge" or "device"
+	// VXLAN attributes the kernel cannot change in place.
+	vni      int
+	dstPort  int
+	local    net.IP
+	group    net.IP
+	vtepDev  string
+	learning bool
+
+	stp    bool
+	mtu    int
+	up     bool
+	master string
+	alias  string
+	addrs  []string
+	ips    []net.IP
+}
+
+func newFakeKernel() *fakeKernel {
+	return &fakeKernel{
+		links:    make(map[string]*fakeLink),
+		fdb:      make(map[string][]nlink.FDBEntry),
+		gateways: make(map[string]net.IP),
+		fail:     make(map[string]error),
+	}
+}
+
+// backend returns the Backend to inject with WithBackend.
+func (k *fakeKernel) backend() Backend {
+	return Backend{
+		VXLAN:  fakeVXLAN{k},
+		Bridge: fakeBridge{k},
+		FDB:    fakeFDB{k},
+		Route:  fakeRoute{k},
+		Rule:   fakeRule{k},
+		Link:   fakeLinks{k},
+	}
+}
+
+// failOn makes the operation (e.g. "vxlan.create") on the named resource
+// fail with err.
+func (k *fakeKernel) failOn(op, name string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fail[op+" "+name] = err
+}
+
+// call records a mutating operation and returns the injected failure, if any.
+// Callers hold k.mu.
+func (k *fakeKernel) call(op, name string) error {
+	if err := k.fail[op+" "+name]; err != nil {
+		return err
+	}
+	k.calls = append(k.calls, op+" "+name)
+	return nil
+}
+
+// mutations returns and clears the recorded mutating operations.
+func (k *fakeKernel) mutations() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	calls := k.calls
+	k.calls = nil
+	return calls
+}
+
+func (k *fakeKernel) link(name string) *fakeLink {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.links[name]
+}
+
+// addDevice adds a plain interface (e.g. an underlay NIC) with addresses.
+func (k *fakeKernel) addDevice(name string, ips ...string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := &fakeLink{kind: "device", mtu: 1500, up: true}
+	for _, ip := range ips {
+		l.ips = append(l.ips, net.ParseIP(ip))
+	}
+	k.links[name] = l
+}
+
+// peers returns the head-end replication destinations of a VXLAN device.
+func (k *fakeKernel) peers(vxlanName string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var out []string
+	for _, e := range k.fdb[vxlanName] {
+		if nlink.IsZeroMAC(e.MAC) {
+			out = append(out, e.RemoteIP.String())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (k *fakeKernel) deleteLink(name string) {
+	delete(k.links, name)
+	delete(k.fdb, name)
+	for _, l := range k.links {
+		if l.master == name {
+			l.master = ""
+		}
+	}
+}
+
+type fakeVXLAN struct{ k *fakeKernel }
+
+func (f fakeVXLAN) Create(cfg nlink.VXLANConfig) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("vxlan.create", cfg.Name); err != nil {
+		return err
+	}
+	if cfg.MTU == 0 {
+		cfg.MTU = 1450
+	}
+	if cfg.Bridge != "" {
+		if br := k.links[cfg.Bridge]; br == nil || br.kind != "bridge" {
+			return fmt.Errorf("bridge %s not found", cfg.Bridge)
+		}
+	}
+
+	l := k.links[cfg.Name]
+	switch {
+	case l != nil && l.kind != "vxlan":
+		return fmt.Errorf("interface %s exists but is not a VXLAN; refusing to replace it", cfg.Name)
+	case l != nil && len(nlink.ReplaceDiff(l.vxlanInfo(cfg.Name), cfg)) == 0:
+		l.mtu, l.up = cfg.MTU, true
+	default:
+		if l != nil {
+			k.deleteLink(cfg.Name) // recreate
+		}
+		for name, other := range k.links {
+			if other.kind == "vxlan" && other.vni == cfg.VNI {
+				return fmt.Errorf("vxlan %s already uses VNI %d", name, cfg.VNI)
+			}
+		}
+		if cfg.DstPort == 0 {
+			cfg.DstPort = 4789
+		}
+		l = &fakeLink{
+			kind: "vxlan", vni: cfg.VNI, dstPort: cfg.DstPort, local: cfg.LocalIP, group: cfg.Group,
+			vtepDev: cfg.VtepDev, learning: cfg.Learning, mtu: cfg.MTU, up: true,
+		}
+		k.links[cfg.Name] = l
+	}
+	l.master = cfg.Bridge
+	return nil
+}
+
+func (f fakeVXLAN) Delete(name string) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("vxlan.delete", name); err != nil {
+		return err
+	}
+	k.deleteLink(name)
+	return nil
+}
+
+func (f fakeVXLAN) Get(name string) (*nlink.VXLANInfo, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.links[name]
+	if l == nil || l.kind != "vxlan" {
+		return nil, fmt.Errorf("vxlan %s not found", name)
+	}
+	return l.vxlanInfo(name), nil
+}
+
+func (l *fakeLink) vxlanInfo(name string) *nlink.VXLANInfo {
+	info := &nlink.VXLANInfo{
+		Name: name, VNI: l.vni, DstPort: l.dstPort, Group: l.group, VtepDev: l.vtepDev,
+		Learning: l.learning, MTU: l.mtu, Up: l.up, Master: l.master, Alias: l.alias,
+	}
+	if !l.local.IsUnspecified() {
+		// Like the kernel, an unspecified local address is not reported.
+		info.LocalIP = l.local
+	}
+	info.LocalIP = nlink.LocalAddress(info.LocalIP, l.alias)
+	return info
+}
+
+func (f fakeVXLAN) Exists(name string) bool {
+	_, err := f.Get(name)
+	return err == nil
+}
+
+type fakeBridge struct{ k *fakeKernel }
+
+func (f fakeBridge) Create(cfg nlink.BridgeConfig) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("bridge.create", cfg.Name); err != nil {
+		return err
+	}
+	if cfg.MTU == 0 {
+		cfg.MTU = 1500
+	}
+	l := k.links[cfg.Name]
+	switch {
+	case l == nil:
+		k.links[cfg.Name] = &fakeLink{kind: "bridge", mtu: cfg.MTU, up: true, stp: cfg.STP}
+	case l.kind != "bridge":
+		return fmt.Errorf("interface %s exists but is not a bridge; refusing to replace it", cfg.Name)
+	default:
+		l.mtu, l.up, l.stp = cfg.MTU, true, cfg.STP
+	}
+	return nil
+}
+
+func (f fakeBridge) Delete(name string) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("bridge.delete", name); err != nil {
+		return err
+	}
+	k.deleteLink(name)
+	return nil
+}
+
+func (f fakeBridge) Get(name string) (*nlink.BridgeInfo, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.links[name]
+	if l == nil || l.kind != "bridge" {
+		return nil, fmt.Errorf("bridge %s not found", name)
+	}
+	info := &nlink.BridgeInfo{Name: name, MTU: l.mtu, Up: l.up, STP: l.stp}
+	for n, other := range k.links {
+		if other.master == name {
+			info.AttachedInterfaces = append(info.AttachedInterfaces, n)
+		}
+	}
+	sort.Strings(info.AttachedInterfaces)
+	return info, nil
+}
+
+func (f fakeBridge) Exists(name string) bool {
+	_, err := f.Get(name)
+	return err == nil
+}
+
+func (f fakeBridge) Addresses(bridgeName string) ([]string, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.links[bridgeName]
+	if l == nil {
+		return nil, fmt.Errorf("bridge %s not found", bridgeName)
+	}
+	return append([]string(nil), l.addrs...), nil
+}
+
+func (f fakeBridge) AddAddress(bridgeName, cidr string) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("address.add", cidr); err != nil {
+		return err
+	}
+	l := k.links[bridgeName]
+	if l == nil {
+		return fmt.Errorf("bridge %s not found", bridgeName)
+	}
+	cidr = normalizeCIDR(cidr)
+	for _, a := range l.addrs {
+		if a == cidr {
+			return nil
+		}
+	}
+	l.addrs = append(l.addrs, cidr)
+	return nil
+}
+
+func (f fakeBridge) DeleteAddress(bridgeName, cidr string) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("address.delete", cidr); err != nil {
+		return err
+	}
+	l := k.links[bridgeName]
+	if l == nil {
+		return nil
+	}
+	cidr = normalizeCIDR(cidr)
+	for i, a := range l.addrs {
+		if a == cidr {
+			l.addrs = append(l.addrs[:i], l.addrs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type fakeFDB struct{ k *fakeKernel }
+
+func (f fakeFDB) List(vxlanName string) ([]nlink.FDBEntry, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if l := k.links[vxlanName]; l == nil || l.kind != "vxlan" {
+		return nil, fmt.Errorf("interface %s not found", vxlanName)
+	}
+	return append([]nlink.FDBEntry(nil), k.fdb[vxlanName]...), nil
+}
+
+func (f fakeFDB) AddPeer(vxlanName string, remoteIP net.IP, via string) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("fdb.add", vxlanName+"/"+remoteIP.String()); err != nil {
+		return err
+	}
+	if l := k.links[vxlanName]; l == nil || l.kind != "vxlan" {
+		return fmt.Errorf("interface %s not found", vxlanName)
+	}
+	k.fdb[vxlanName] = append(k.fdb[vxlanName], nlink.FDBEntry{
+		MAC: make(net.HardwareAddr, 6), RemoteIP: remoteIP, VXLANName: vxlanName, Permanent: true, Via: via,
+	})
+	return nil
+}
+
+func (f fakeFDB) DeletePeer(vxlanName string, remoteIP net.IP) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("fdb.delete", vxlanName+"/"+remoteIP.String()); err != nil {
+		return err
+	}
+	entries := k.fdb[vxlanName]
+	for i, e := range entries {
+		if nlink.IsZeroMAC(e.MAC) && e.RemoteIP.Equal(remoteIP) {
+			k.fdb[vxlanName] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type fakeRoute struct{ k *fakeKernel }
+
+func (f fakeRoute) ListByProtocol(table, protocol int) ([]nlink.RouteInfo, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var out []nlink.RouteInfo
+	for _, r := range k.routes {
+		if r.Table == table && r.Protocol == protocol {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f fakeRoute) Replace(cfg nlink.RouteConfig) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("route.replace", fmt.Sprintf("%s@%d", cfg.Destination, cfg.Table)); err != nil {
+		return err
+	}
+	if cfg.Device != "" && k.links[cfg.Device] == nil {
+		return fmt.Errorf("device %s not found", cfg.Device)
+	}
+	route := nlink.RouteInfo{
+		Destination: cfg.Destination, Gateway: cfg.Gateway, Device: cfg.Device,
+		Table: cfg.Table, Protocol: cfg.Protocol,
+	}
+	for i, r := range k.routes {
+		if r.Table == cfg.Table && r.Destination.String() == cfg.Destination.String() {
+			k.routes[i] = route
+			return nil
+		}
+	}
+	k.routes = append(k.routes, route)
+	return nil
+}
+
+func (f fakeRoute) GatewayVia(dst net.IP, iface string) (net.IP, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.links[iface] == nil {
+		return nil, fmt.Errorf("no route to %s via %s", dst, iface)
+	}
+	return k.gateways[iface], nil
+}
+
+func (f fakeRoute) Delete(cfg nlink.RouteConfig) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.call("route.delete", fmt.Sprintf("%s@%d", cfg.Destination, cfg.Table)); err != nil {
+		return err
+	}
+	for i, r := range k.routes {
+		if r.Table == cfg.Table && r.Destination.String() == cfg.Destination.String() {
+			k.routes = append(k.routes[:i], k.routes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no such process")
+}
+
+type fakeRule struct{ k *fakeKernel }
+
+func (f fakeRule) List() ([]nlink.RuleInfo, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]nlink.RuleInfo(nil), k.rules...), nil
+}
+
+// ruleInfo returns an IPv4 rule installed by n-netman.
+func ruleInfo(selector, value string, table, priority int) nlink.RuleInfo {
+	r := nlink.RuleInfo{Family: netlink.FAMILY_V4, Table: table, Priority: priority, Protocol: nlink.RuleProtocolNNetMan}
+	switch selector {
+	case "oif":
+		r.OifName = value
+	case "from":
+		r.Src = value
+	case "to":
+		r.Dst = value
+	default:
+		r.IifName = value
+	}
+	if strings.Contains(value, ":") {
+		r.Family = netlink.FAMILY_V6
+	}
+	return r
+}
+
+func (f fakeRule) Add(rule nlink.RuleInfo) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	selector, value, _ := rule.Selector()
+	if err := k.call("rule.add", selector+" "+value); err != nil {
+		return err
+	}
+	for _, r := range k.rules {
+		if r == rule {
+			return nil
+		}
+	}
+	k.rules = append(k.rules, rule)
+	return nil
+}
+
+// Delete matches any protocol when the rule has none, like the kernel.
+func (f fakeRule) Delete(rule nlink.RuleInfo) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	selector, value, _ := rule.Selector()
+	if err := k.call("rule.delete", selector+" "+value); err != nil {
+		return err
+	}
+	for i, r := range k.rules {
+		if rule.Protocol == 0 {
+			r.Protocol = 0
+		}
+		if r == rule {
+			k.rules = append(k.rules[:i], k.rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type fakeLinks struct{ k *fakeKernel }
+
+func (f fakeLinks) Addresses(name string) ([]net.IP, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.links[name]
+	if l == nil {
+		return nil, fmt.Errorf("interface %s not found", name)
+	}
+	return append([]net.IP(nil), l.ips...), nil
+}
+
+func (f fakeLinks) UpInterfaces() ([]string, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var names []string
+	for name, l := range k.links {
+		if l.up {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// InterfaceAddresses returns the addresses of an interface as host prefixes:
+// the fake kernel does not model subnet masks.
+func (f fakeLinks) InterfaceAddresses(name string) ([]*net.IPNet, error) {
+	ips, err := f.Addresses(name)
+	if err != nil {
+		return nil, err
+	}
+	var out []*net.IPNet
+	for _, ip := range ips {
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return out, nil
+}
+
+func (f fakeLinks) SetOwnership(name string, o nlink.Ownership) error {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.links[name]
+	if l == nil {
+		return fmt.Errorf("interface %s not found", name)
+	}
+	if alias := o.Alias(); l.alias != alias {
+		if err := k.call("link.alias", name); err != nil {
+			return err
+		}
+		l.alias = alias
+	}
+	return nil
+}
+
+func (f fakeLinks) ListOwned() ([]nlink.OwnedLink, error) {
+	k := f.k
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var out []nlink.OwnedLink
+	for name, l := range k.links {
+		o, ok := nlink.ParseOwnerAlias(l.alias)
+		if !ok || (l.kind != "vxlan" && l.kind != "bridge") {
+			continue
+		}
+		out = append(out, nlink.OwnedLink{Name: name, Kind: l.kind, Ownership: o})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
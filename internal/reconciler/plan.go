@@ -0,0 +1,1155 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/netplan"
+)
+
+// Action is what a Change does to a kernel resource.
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionReplace Action = "replace" // delete and create again (e.g. VNI change)
+	ActionDelete  Action = "delete"
+)
+
+// Kinds of kernel resources managed by the reconciler.
+const (
+	KindBridge  = "bridge"
+	KindVXLAN   = "vxlan"
+	KindAddress = "address"
+	KindFDB     = "fdb"
+	KindRule    = "rule"
+	KindRoute   = "route"
+)
+
+// AttrChange is the change of a single attribute of a resource. Old is empty
+// for created resources and New for deleted ones.
+type AttrChange struct {
+	Attr string `json:"attr"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Change is a single difference between the desired and the actual state.
+type Change struct {
+	Action  Action       `json:"action"`
+	Kind    string       `json:"kind"`
+	Name    string       `json:"name"`
+	Overlay string       `json:"overlay,omitempty"`
+	VNI     int          `json:"vni,omitempty"`
+	Attrs   []AttrChange `json:"attrs,omitempty"`
+
+	apply func() error
+}
+
+// Plan is the ordered list of changes that brings the kernel to the
+// desired state. Reconcile computes a plan and applies it; `nnet apply
+// --dry-run` only renders it.
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+	// Errors are the overlays that could not be planned.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PlanSummary counts the changes like `terraform plan` does: a replacement
+// counts as one add and one destroy.
+type PlanSummary struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+func (p *Plan) add(changes ...Change) {
+	for _, c := range changes {
+		switch c.Action {
+		case ActionCreate:
+			p.Summary.Add++
+		case ActionUpdate:
+			p.Summary.Change++
+		case ActionReplace:
+			p.Summary.Add++
+			p.Summary.Destroy++
+		case ActionDelete:
+			p.Summary.Destroy++
+		}
+	}
+	p.Changes = append(p.Changes, changes...)
+}
+
+// Render prints the plan in the style of `terraform plan`.
+func (p *Plan) Render(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes. The system matches the configuration.")
+	} else {
+		fmt.Fprintln(w, "n-netman will perform the following actions:")
+		for _, c := range p.Changes {
+			fmt.Fprintln(w)
+			comment, symbol := "", ""
+			switch c.Action {
+			case ActionCreate:
+				comment, symbol = "will be created", "  +"
+			case ActionUpdate:
+				comment, symbol = "will be updated in-place", "  ~"
+			case ActionReplace:
+				comment, symbol = "must be replaced", "-/+"
+			case ActionDelete:
+				comment, symbol = "will be destroyed", "  -"
+			}
+			if c.Overlay != "" {
+				comment += fmt.Sprintf(" (overlay %s, VNI %d)", c.Overlay, c.VNI)
+			}
+			fmt.Fprintf(w, "  # %s.%s %s\n", c.Kind, c.Name, comment)
+			fmt.Fprintf(w, "%s %s %q {\n", symbol, c.Kind, c.Name)
+			for _, a := range c.Attrs {
+				switch {
+				case c.Action == ActionDelete:
+					fmt.Fprintf(w, "      - %s = %s\n", a.Attr, a.Old)
+				case a.Old == "":
+					fmt.Fprintf(w, "      + %s = %s\n", a.Attr, a.New)
+				default:
+					fmt.Fprintf(w, "      ~ %s = %s -> %s\n", a.Attr, a.Old, a.New)
+				}
+			}
+			fmt.Fprintln(w, "    }")
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Plan: %d to add, %d to change, %d to destroy.\n",
+			p.Summary.Add, p.Summary.Change, p.Summary.Destroy)
+	}
+	for _, e := range p.Errors {
+		fmt.Fprintf(w, "\nError: %s\n", e)
+	}
+}
+
+// Plan computes the changes needed to bring the kernel to the desired state
+// without applying them. Overlays that cannot be planned are reported in the
+// returned error and in Plan.Errors; the others are still planned.
+func (r *Reconciler) Plan(ctx context.Context) (*Plan, error) {
+	return r.plan(ctx, r.cfg.Load())
+}
+
+func (r *Reconciler) plan(ctx context.Context, cfg *config.Config) (*Plan, error) {
+	plan := &Plan{Changes: []Change{}}
+	var errs []error
+
+	// Tear down what removed overlays left behind first: a renamed overlay
+	// cannot be created while the old device still holds its VNI.
+	if r.pruneEnabled(cfg) {
+		changes, err := r.planPruneChanges(cfg)
+		plan.add(changes...)
+		if err != nil {
+			err = fmt.Errorf("prune: %w", err)
+			plan.Errors = append(plan.Errors, err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	// Host routes go first: the FDB entries of pinned peers rely on them.
+	changes, err := r.planUnderlayRoutes(cfg)
+	plan.add(changes...)
+	if err != nil {
+		err = fmt.Errorf("underlay routes: %w", err)
+		plan.Errors = append(plan.Errors, err.Error())
+		errs = append(errs, err)
+	}
+
+	for _, overlay := range cfg.GetOverlays() {
+		changes, err := r.planOverlay(ctx, cfg, overlay)
+		plan.add(changes...)
+		if err != nil {
+			err = fmt.Errorf("overlay %s (VNI %d): %w", overlay.Name, overlay.VNI, err)
+			plan.Errors = append(plan.Errors, err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	changes, err = r.planOrphanRules(cfg)
+	plan.add(changes...)
+	if err != nil {
+		err = fmt.Errorf("policy rules: %w", err)
+		plan.Errors = append(plan.Errors, err.Error())
+		errs = append(errs, err)
+	}
+	return plan, errors.Join(errs...)
+}
+
+// Apply executes the changes of a plan in order. A failed change skips the
+// remaining changes of the same overlay (they depend on it), but the other
+// overlays are still applied.
+func (r *Reconciler) Apply(ctx context.Context, plan *Plan) error {
+	failed := make(map[int]bool)
+	var errs []error
+	for _, c := range plan.Changes {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if c.VNI != 0 && failed[c.VNI] {
+			continue
+		}
+		r.logger.Info("applying change", "action", c.Action, "kind", c.Kind, "name", c.Name, "overlay", c.Overlay, "vni", c.VNI)
+		if err := c.apply(); err != nil {
+			r.logger.Error("change failed", "action", c.Action, "kind", c.Kind, "name", c.Name, "error", err)
+			failed[c.VNI] = true
+			if c.Overlay != "" {
+				err = fmt.Errorf("overlay %s (VNI %d): %s %s %s: %w", c.Overlay, c.VNI, c.Action, c.Kind, c.Name, err)
+			} else {
+				err = fmt.Errorf("%s %s %s: %w", c.Action, c.Kind, c.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// planOverlay plans a single overlay: bridge, bridge addresses, VXLAN, FDB
+// and policy rules, in the order they must be applied.
+func (r *Reconciler) planOverlay(ctx context.Context, cfg *config.Config, overlay config.OverlayDef) ([]Change, error) {
+	var changes []Change
+	add := func(c Change) {
+		c.Overlay, c.VNI = overlay.Name, overlay.VNI
+		changes = append(changes, c)
+	}
+
+	r.planBridge(cfg, overlay, add)
+	vxlanFresh := r.planVXLAN(cfg, overlay, add)
+	if err := r.planFDB(cfg, overlay, vxlanFresh, add); err != nil {
+		return changes, fmt.Errorf("fdb: %w", err)
+	}
+	if err := r.planRules(cfg, overlay, add); err != nil {
+		return changes, fmt.Errorf("policy rules: %w", err)
+	}
+	return changes, nil
+}
+
+// planBridge plans the overlay's bridge and its addresses.
+func (r *Reconciler) planBridge(cfg *config.Config, overlay config.OverlayDef, add func(Change)) {
+	bridgeName := overlay.Bridge.Name
+
+	// Prefer KVM bridge settings when the bridge is marked as managed.
+	var bridgeCfg *config.BridgeDef
+	for i := range cfg.KVM.Bridges {
+		if cfg.KVM.Bridges[i].Name == bridgeName {
+			bridgeCfg = &cfg.KVM.Bridges[i]
+			break
+		}
+	}
+	managed := bridgeCfg != nil && bridgeCfg.Manage
+
+	// Determine MTU from overlay or defaults
+	mtu := 1450 // Default
+	if overlay.MTU > 0 {
+		mtu = overlay.MTU
+	}
+	var stp bool
+	if managed {
+		stp = bridgeCfg.STP
+		if bridgeCfg.MTU > 0 {
+			mtu = bridgeCfg.MTU
+		}
+	}
+	want := nlink.BridgeConfig{Name: bridgeName, STP: stp, MTU: mtu}
+
+	info, err := r.bridge.Get(bridgeName)
+	exists := err == nil
+	switch {
+	case !exists:
+		// Only a bridge created here is marked as owned; a pre-existing
+		// bridge is never removed by pruning.
+		attrs := []AttrChange{{Attr: "mtu", New: strconv.Itoa(mtu)}}
+		if stp {
+			attrs = append(attrs, AttrChange{Attr: "stp", New: "true"})
+		}
+		add(Change{
+			Action: ActionCreate, Kind: KindBridge, Name: bridgeName, Attrs: attrs,
+			apply: func() error {
+				if err := r.bridge.Create(want); err != nil {
+					return fmt.Errorf("failed to create bridge %s: %w", bridgeName, err)
+				}
+				return r.link.SetOwnership(bridgeName, nlink.Ownership{VNI: overlay.VNI})
+			},
+		})
+	case managed:
+		// Unmanaged bridges that already exist are left as they are.
+		var attrs []AttrChange
+		if info.MTU != mtu {
+			attrs = append(attrs, AttrChange{Attr: "mtu", Old: strconv.Itoa(info.MTU), New: strconv.Itoa(mtu)})
+		}
+		if info.STP != stp {
+			attrs = append(attrs, AttrChange{Attr: "stp", Old: strconv.FormatBool(info.STP), New: strconv.FormatBool(stp)})
+		}
+		if !info.Up {
+			attrs = append(attrs, AttrChange{Attr: "state", Old: "down", New: "up"})
+		}
+		if len(attrs) > 0 {
+			add(Change{
+				Action: ActionUpdate, Kind: KindBridge, Name: bridgeName, Attrs: attrs,
+				apply: func() error { return r.bridge.Create(want) },
+			})
+		}
+	}
+
+	// Add IP address to bridge if configured (used as overlay gateway/next-hop).
+	var current map[string]bool
+	if exists {
+		addrs, err := r.bridge.Addresses(bridgeName)
+		if err == nil {
+			current = make(map[string]bool, len(addrs))
+			for _, a := range addrs {
+				current[a] = true
+			}
+		}
+	}
+	for _, cidr := range []string{overlay.Bridge.IPv4, overlay.Bridge.IPv6} {
+		if cidr == "" || current[normalizeCIDR(cidr)] {
+			continue
+		}
+		cidr := cidr
+		add(Change{
+			Action: ActionCreate, Kind: KindAddress, Name: cidr,
+			Attrs: []AttrChange{{Attr: "dev", New: bridgeName}},
+			apply: func() error {
+				if err := r.bridge.AddAddress(bridgeName, cidr); err != nil {
+					return fmt.Errorf("failed to add %s to bridge %s: %w", cidr, bridgeName, err)
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// normalizeCIDR returns the form the kernel reports an address in
+// ("10.100.0.1/24"), so configured and actual addresses compare equal.
+func normalizeCIDR(cidr string) string {
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		return cidr
+	}
+	return addr.IPNet.String()
+}
+
+// planVXLAN plans the overlay's VXLAN interface. It reports whether the
+// interface will be (re)created, in which case its FDB starts empty.
+func (r *Reconciler) planVXLAN(cfg *config.Config, overlay config.OverlayDef, add func(Change)) bool {
+	bumMode := overlay.BUM.GetMode()
+
+	// Determine local underlay IP and VTEP device for kernel encapsulation.
+	// Without an explicit underlay_interface, the underlay inferred from
+	// netplan is used when enabled. The local IP is of the family of the
+	// overlay's VTEPs.
+	ipv6 := cfg.IPv6VTEP(overlay)
+	var localIP net.IP
+	var vtepDev string
+	if overlay.UnderlayInterface != "" {
+		// A missing underlay device cannot be bound to; the VXLAN is
+		// recreated with it once it shows up.
+		if _, err := r.link.Addresses(overlay.UnderlayInterface); err == nil {
+			vtepDev = overlay.UnderlayInterface
+		}
+		localIP = r.detectUnderlayIP(overlay.UnderlayInterface, ipv6)
+	} else if cfg.Netplan.Enabled {
+		if u, err := netplan.InferUnderlay(cfg, r.netplan.Load(), r.link); err != nil {
+			r.logger.Debug("failed to infer the underlay from netplan", "vxlan", overlay.Name, "error", err)
+		} else if u.Source == "netplan" {
+			vtepDev = u.Interface
+			localIP = u.Address
+			if (localIP.To4() == nil) != ipv6 {
+				localIP = r.detectUnderlayIP(u.Interface, ipv6)
+			}
+		}
+	}
+	if ipv6 && (localIP == nil || localIP.To4() != nil) {
+		// An IPv6 VXLAN needs an IPv6 local address, even unspecified, or
+		// the kernel only opens an IPv4 socket and drops IPv6 VTEP traffic.
+		if localIP != nil {
+			r.logger.Warn("no IPv6 address on the underlay for IPv6 VTEPs, binding to any",
+				"vxlan", overlay.Name, "interface", vtepDev)
+		}
+		localIP = net.IPv6unspecified
+	}
+
+	// Determine multicast group (only for multicast mode)
+	var group net.IP
+	if bumMode == "multicast" && overlay.BUM.Group != "" {
+		group = net.ParseIP(overlay.BUM.Group)
+		if group == nil {
+			r.logger.Warn("invalid multicast group, falling back to head-end-replication",
+				"vxlan", overlay.Name,
+				"group", overlay.BUM.Group)
+		}
+	}
+
+	// Use default DstPort and MTU if not specified
+	dstPort := overlay.DstPort
+	if dstPort == 0 {
+		dstPort = 4789
+	}
+	mtu := overlay.MTU
+	if mtu == 0 {
+		mtu = 1450
+	}
+
+	want := nlink.VXLANConfig{
+		Name:     overlay.Name,
+		VNI:      overlay.VNI,
+		DstPort:  dstPort,
+		LocalIP:  localIP,
+		MTU:      mtu,
+		Learning: overlay.Learning,
+		Bridge:   overlay.Bridge.Name,
+		Group:    group,
+		VtepDev:  vtepDev,
+	}
+	// Record what the overlay put on the host so pruning can undo it once
+	// the overlay is removed from the config.
+	owner := ownershipFor(cfg, overlay)
+	ensure := func() error {
+		if err := r.vxlan.Create(want); err != nil {
+			return fmt.Errorf("failed to create vxlan %s: %w", overlay.Name, err)
+		}
+		return r.link.SetOwnership(overlay.Name, owner)
+	}
+
+	info, err := r.vxlan.Get(overlay.Name)
+	if err != nil {
+		attrs := []AttrChange{
+			{Attr: "vni", New: strconv.Itoa(overlay.VNI)},
+			{Attr: "dstport", New: strconv.Itoa(dstPort)},
+			{Attr: "mtu", New: strconv.Itoa(mtu)},
+			{Attr: "learning", New: strconv.FormatBool(overlay.Learning)},
+			{Attr: "master", New: overlay.Bridge.Name},
+		}
+		if localIP != nil {
+			attrs = append(attrs, AttrChange{Attr: "local", New: localIP.String()})
+		}
+		if vtepDev != "" {
+			attrs = append(attrs, AttrChange{Attr: "dev", New: vtepDev})
+		}
+		if group != nil {
+			attrs = append(attrs, AttrChange{Attr: "group", New: group.String()})
+		}
+		add(Change{Action: ActionCreate, Kind: KindVXLAN, Name: overlay.Name, Attrs: attrs, apply: ensure})
+		return true
+	}
+
+	// The kernel cannot change the VNI, port, addresses, underlay device or
+	// learning of a VXLAN in place.
+	if diffs := nlink.ReplaceDiff(info, want); len(diffs) > 0 {
+		attrs := make([]AttrChange, 0, len(diffs))
+		for _, d := range diffs {
+			attrs = append(attrs, AttrChange{Attr: d.Attr, Old: d.Old, New: d.New + " (forces replacement)"})
+		}
+		add(Change{Action: ActionReplace, Kind: KindVXLAN, Name: overlay.Name, Attrs: attrs, apply: ensure})
+		return true
+	}
+
+	var attrs []AttrChange
+	if info.MTU != mtu {
+		attrs = append(attrs, AttrChange{Attr: "mtu", Old: strconv.Itoa(info.MTU), New: strconv.Itoa(mtu)})
+	}
+	if !info.Up {
+		attrs = append(attrs, AttrChange{Attr: "state", Old: "down", New: "up"})
+	}
+	if info.Master != overlay.Bridge.Name {
+		attrs = append(attrs, AttrChange{Attr: "master", Old: info.Master, New: overlay.Bridge.Name})
+	}
+	if alias := owner.Alias(); info.Alias != alias {
+		attrs = append(attrs, AttrChange{Attr: "alias", Old: info.Alias, New: alias})
+	}
+	if len(attrs) > 0 {
+		add(Change{Action: ActionUpdate, Kind: KindVXLAN, Name: overlay.Name, Attrs: attrs, apply: ensure})
+	}
+	return false
+}
+
+// planFDB plans the head-end replication entries (00:00:00:00:00:00) of the
+// overlay's VXLAN interface, with the via interface of peers pinned by
+// endpoint.via_interface. In multicast mode the kernel handles BUM traffic
+// via the multicast group and no entries are needed. Only peers that
+// participate in this overlay's VNI are flooded (avoids cross-overlay leak),
+// and a hub-spoke spoke only floods to hub VTEPs. Learned unicast entries
+// (real MACs) are never touched.
+func (r *Reconciler) planFDB(cfg *config.Config, overlay config.OverlayDef, fresh bool, add func(Change)) error {
+	if overlay.BUM.GetMode() == "multicast" {
+		return nil
+	}
+
+	var desired []net.IP
+	desiredVia := make(map[string]string)
+	for _, peer := range cfg.GetActivePeersForVNI(overlay.VNI) {
+		ip := net.ParseIP(peer.Endpoint.Address)
+		if ip == nil {
+			r.logger.Warn("invalid peer IP, skipping", "peer_id", peer.ID, "address", peer.Endpoint.Address)
+			continue
+		}
+		if _, ok := desiredVia[ip.String()]; !ok {
+			desiredVia[ip.String()] = peer.Endpoint.ViaInterface
+			desired = append(desired, ip)
+		}
+	}
+
+	current := make(map[string]nlink.FDBEntry)
+	if !fresh {
+		entries, err := r.fdb.List(overlay.Name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if nlink.IsZeroMAC(e.MAC) {
+				current[e.RemoteIP.String()] = e
+			}
+		}
+	}
+
+	vxlanName := overlay.Name
+	for _, ip := range desired {
+		ip, via := ip, desiredVia[ip.String()]
+		attrs := []AttrChange{{Attr: "dst", New: ip.String()}}
+		cur, ok := current[ip.String()]
+		switch {
+		case !ok:
+			if via != "" {
+				attrs = append(attrs, AttrChange{Attr: "via", New: via})
+			}
+			add(Change{
+				Action: ActionCreate, Kind: KindFDB, Name: fdbName(vxlanName, ip), Attrs: attrs,
+				apply: func() error { return r.fdb.AddPeer(vxlanName, ip, via) },
+			})
+		case cur.Via != via:
+			// The kernel cannot change the via of an entry in place.
+			add(Change{
+				Action: ActionReplace, Kind: KindFDB, Name: fdbName(vxlanName, ip),
+				Attrs: []AttrChange{{Attr: "via", Old: cur.Via, New: via}},
+				apply: func() error {
+					if err := r.fdb.DeletePeer(vxlanName, ip); err != nil {
+						return err
+					}
+					return r.fdb.AddPeer(vxlanName, ip, via)
+				},
+			})
+		}
+	}
+
+	stale := make([]string, 0, len(current))
+	for key := range current {
+		if _, ok := desiredVia[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	for _, key := range stale {
+		ip := current[key].RemoteIP
+		add(Change{
+			Action: ActionDelete, Kind: KindFDB, Name: fdbName(vxlanName, ip),
+			Attrs: []AttrChange{{Attr: "dst", Old: ip.String()}},
+			apply: func() error { return r.fdb.DeletePeer(vxlanName, ip) },
+		})
+	}
+	return nil
+}
+
+func fdbName(vxlanName string, ip net.IP) string {
+	return vxlanName + "/" + ip.String()
+}
+
+// underlayTable is the table of the underlay host routes: the main table,
+// where the VXLAN driver and the control plane look the peers up.
+const underlayTable = 254
+
+// planUnderlayRoutes plans the host routes of the peers pinned to an
+// underlay interface by endpoint.via_interface: a /32 (or /128) route to the
+// peer's VTEP out of that interface, through the gateway the kernel uses
+// there, so encapsulated traffic to the peer cannot leave through another
+// uplink. Host routes of peers that are gone or no longer pinned are removed.
+func (r *Reconciler) planUnderlayRoutes(cfg *config.Config) ([]Change, error) {
+	routes, err := r.route.ListByProtocol(underlayTable, nlink.RouteProtocolUnderlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+	current := make(map[string]nlink.RouteInfo)
+	for _, rt := range routes {
+		if rt.Destination != nil {
+			current[rt.Destination.String()] = rt
+		}
+	}
+
+	var changes []Change
+	var errs []error
+	desired := make(map[string]bool)
+	for _, peer := range cfg.GetActivePeers() {
+		via := peer.Endpoint.ViaInterface
+		ip := net.ParseIP(peer.Endpoint.Address)
+		if via == "" || ip == nil {
+			continue
+		}
+		dst := hostPrefix(ip)
+		if desired[dst.String()] {
+			continue
+		}
+		desired[dst.String()] = true
+
+		gw, err := r.route.GatewayVia(ip, via)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer.ID, err))
+			continue
+		}
+		cur, exists := current[dst.String()]
+		if exists && cur.Device == via && cur.Gateway.Equal(gw) {
+			continue
+		}
+
+		rc := nlink.RouteConfig{
+			Destination: dst,
+			Gateway:     gw,
+			Device:      via,
+			Table:       underlayTable,
+			Protocol:    nlink.RouteProtocolUnderlay,
+		}
+		action := ActionCreate
+		if exists {
+			action = ActionUpdate
+		}
+		attrs := []AttrChange{{Attr: "dev", Old: cur.Device, New: via}}
+		if gw != nil || cur.Gateway != nil {
+			attrs = append(attrs, AttrChange{Attr: "via", Old: ipString(cur.Gateway), New: ipString(gw)})
+		}
+		changes = append(changes, Change{
+			Action: action, Kind: KindRoute,
+			Name:  fmt.Sprintf("%s@%d", dst, underlayTable),
+			Attrs: attrs,
+			apply: func() error { return r.route.Replace(rc) },
+		})
+	}
+
+	stale := make([]string, 0, len(current))
+	for key := range current {
+		if !desired[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	for _, key := range stale {
+		rt := current[key]
+		rc := nlink.RouteConfig{
+			Destination: rt.Destination,
+			Table:       underlayTable,
+			Protocol:    nlink.RouteProtocolUnderlay,
+		}
+		changes = append(changes, Change{
+			Action: ActionDelete, Kind: KindRoute,
+			Name:  fmt.Sprintf("%s@%d", key, underlayTable),
+			Attrs: []AttrChange{{Attr: "dev", Old: rt.Device}},
+			apply: func() error { return r.route.Delete(rc) },
+		})
+	}
+	return changes, errors.Join(errs...)
+}
+
+// hostPrefix returns the /32 or /128 prefix of an address.
+func hostPrefix(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// ipString formats an optional address.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// ruleName names a policy rule in a plan, e.g. "iif br-100 lookup 200".
+// IPv6 interface rules are suffixed with "(ipv6)", as they share the
+// selector of their IPv4 twin.
+func ruleName(rule nlink.RuleInfo) string {
+	selector, value, _ := rule.Selector()
+	name := fmt.Sprintf("%s %s lookup %d", selector, value, rule.Table)
+	if rule.Family == netlink.FAMILY_V6 && (selector == "iif" || selector == "oif") {
+		name += " (ipv6)"
+	}
+	return name
+}
+
+// ruleFamilies returns the address families of the interface rules of an
+// overlay: IPv6 when the bridge has an IPv6 address, IPv4 unless it only has
+// that one.
+func ruleFamilies(overlay config.OverlayDef) []int {
+	var families []int
+	if overlay.Bridge.IPv4 != "" || overlay.Bridge.IPv6 == "" {
+		families = append(families, netlink.FAMILY_V4)
+	}
+	if overlay.Bridge.IPv6 != "" {
+		families = append(families, netlink.FAMILY_V6)
+	}
+	return families
+}
+
+// interfaceRules returns the iif/oif policy rules of a bridge in a table.
+func interfaceRules(overlay config.OverlayDef, table, priority int) []nlink.RuleInfo {
+	var rules []nlink.RuleInfo
+	for _, family := range ruleFamilies(overlay) {
+		rules = append(rules,
+			nlink.RuleInfo{Family: family, IifName: overlay.Bridge.Name, Table: table, Priority: priority},
+			nlink.RuleInfo{Family: family, OifName: overlay.Bridge.Name, Table: table, Priority: priority + 1})
+	}
+	return rules
+}
+
+// prefixRules returns the policy rules of an overlay in prefix mode: a from
+// rule per exported prefix and a to rule per imported one. Default prefixes
+// are skipped, as a rule for them would send all the traffic of the node to
+// the table.
+func prefixRules(exported, imported []string, table, priority int) []nlink.RuleInfo {
+	var rules []nlink.RuleInfo
+	seen := make(map[nlink.RuleInfo]bool)
+	for _, set := range []struct {
+		to       bool
+		prefixes []string
+	}{
+		{false, exported},
+		{true, imported},
+	} {
+		for _, p := range set.prefixes {
+			_, ipnet, err := net.ParseCIDR(p)
+			if err != nil {
+				continue
+			}
+			if ones, _ := ipnet.Mask.Size(); ones == 0 {
+				continue
+			}
+			rule := nlink.RuleInfo{Family: netlink.FAMILY_V4, Table: table, Priority: priority}
+			if ipnet.IP.To4() == nil {
+				rule.Family = netlink.FAMILY_V6
+			}
+			// The kernel rejects prefixes with host bits set.
+			if set.to {
+				rule.Dst, rule.Priority = ipnet.String(), priority+1
+			} else {
+				rule.Src = ipnet.String()
+			}
+			if !seen[rule] {
+				seen[rule] = true
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// exportedPrefixesFor returns the prefixes exported by an overlay.
+func (r *Reconciler) exportedPrefixesFor(overlay config.OverlayDef) []string {
+	if r.exportedPrefixes != nil {
+		return r.exportedPrefixes(overlay)
+	}
+	return overlay.Routing.Export.Networks
+}
+
+// importedPrefixesFor returns the prefixes of the routes n-netman installed
+// in a table.
+func (r *Reconciler) importedPrefixesFor(table int) ([]string, error) {
+	if r.importedPrefixes != nil {
+		return r.importedPrefixes(table), nil
+	}
+	routes, err := r.route.ListByProtocol(table, nlink.RouteProtocolNNetMan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes in table %d: %w", table, err)
+	}
+	var prefixes []string
+	for _, rt := range routes {
+		if rt.Destination != nil {
+			prefixes = append(prefixes, rt.Destination.String())
+		}
+	}
+	return prefixes, nil
+}
+
+// ownRule reports whether a kernel rule in a table owned by an overlay is
+// managed by n-netman: rules it installed, and rules left without a protocol
+// by earlier versions (or by kernels that do not keep it) matching one of the
+// selectors it generates for the overlay (see generatedSelectors).
+func ownRule(rule nlink.RuleInfo, generated map[string]bool) bool {
+	if rule.Owned() {
+		return true
+	}
+	selector, value, ok := rule.Selector()
+	if !ok || rule.Protocol != 0 {
+		return false
+	}
+	return generated[selector+" "+value]
+}
+
+// generatedSelectors returns, by table, the selectors ("iif br-100",
+// "to 10.9.0.0/24") n-netman generates for the overlays using it in either
+// mode. The prefixes are only looked up for the tables holding a from or to
+// rule without protocol.
+func (r *Reconciler) generatedSelectors(overlays []config.OverlayDef, current []nlink.RuleInfo) (map[int]map[string]bool, error) {
+	prefixed := make(map[int]bool)
+	for _, rule := range current {
+		if selector, _, ok := rule.Selector(); ok && rule.Protocol == 0 && (selector == "from" || selector == "to") {
+			prefixed[rule.Table] = true
+		}
+	}
+
+	out := make(map[int]map[string]bool)
+	for _, o := range overlays {
+		table := o.Routing.Import.Install.Table
+		if table == 0 {
+			continue
+		}
+		rules := interfaceRules(o, table, 0)
+		if prefixed[table] {
+			imported, err := r.importedPrefixesFor(table)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, prefixRules(r.exportedPrefixesFor(o), imported, table, 0)...)
+		}
+		if out[table] == nil {
+			out[table] = make(map[string]bool)
+		}
+		for _, rule := range rules {
+			selector, value, _ := rule.Selector()
+			out[table][selector+" "+value] = true
+		}
+	}
+	return out, nil
+}
+
+// overlayTable returns the table the policy rules of an overlay point to,
+// 0 when it has none.
+func overlayTable(overlay config.OverlayDef) int {
+	if !overlay.Routing.Import.Install.LookupRules.Enabled {
+		return 0
+	}
+	return overlay.Routing.Import.Install.Table
+}
+
+// rulePriorities allocates the priority of the policy rules of each overlay
+// with lookup_rules, by VNI: the first rule uses it and the second the next
+// one, so two overlays never share a priority. lookup_rules.priority is used
+// when set. The other overlays keep the priority of the rules already in
+// their table when it is still free, or get the lowest free one from
+// nlink.RulePriority on, in VNI order. A priority held by a rule n-netman
+// does not manage (see ownRule) is never free.
+func rulePriorities(overlays []config.OverlayDef, current []nlink.RuleInfo, generated map[int]map[string]bool) map[int]int {
+	used := make(map[int]bool)
+	for _, rule := range current {
+		if !ownRule(rule, generated[rule.Table]) {
+			used[rule.Priority] = true
+		}
+	}
+	out := make(map[int]int)
+	reserve := func(vni, p int) {
+		out[vni] = p
+		used[p], used[p+1] = true, true
+	}
+	free := func(p int) bool { return p > 0 && !used[p] && !used[p+1] }
+
+	sorted := make([]config.OverlayDef, 0, len(overlays))
+	for _, o := range overlays {
+		if overlayTable(o) == 0 {
+			continue
+		}
+		if p := o.Routing.Import.Install.LookupRules.Priority; p != 0 {
+			reserve(o.VNI, p)
+			continue
+		}
+		sorted = append(sorted, o)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].VNI < sorted[j].VNI })
+
+	var pending []config.OverlayDef
+	for _, o := range sorted {
+		hint := 0
+		for _, rule := range current {
+			if rule.Table != overlayTable(o) || !ownRule(rule, generated[rule.Table]) {
+				continue
+			}
+			p := rule.Priority
+			if selector, _, _ := rule.Selector(); selector == "oif" || selector == "to" {
+				p--
+			}
+			if hint == 0 || p < hint {
+				hint = p
+			}
+		}
+		if hint != 0 && free(hint) {
+			reserve(o.VNI, hint)
+		} else {
+			pending = append(pending, o)
+		}
+	}
+	next := nlink.RulePriority
+	for _, o := range pending {
+		for !free(next) {
+			next++
+		}
+		reserve(o.VNI, next)
+	}
+	return out
+}
+
+// desiredRules returns the policy rules of an overlay: none without
+// lookup_rules, iif/oif rules for its bridge, or from/to rules for its
+// prefixes in prefix mode.
+func (r *Reconciler) desiredRules(overlay config.OverlayDef, priority int) ([]nlink.RuleInfo, error) {
+	table := overlayTable(overlay)
+	if table == 0 {
+		return nil, nil
+	}
+	if !overlay.Routing.Import.Install.LookupRules.PrefixMode() {
+		return interfaceRules(overlay, table, priority), nil
+	}
+	imported, err := r.importedPrefixesFor(table)
+	if err != nil {
+		return nil, err
+	}
+	return prefixRules(r.exportedPrefixesFor(overlay), imported, table, priority), nil
+}
+
+// ruleChanges plans the deletion of the rules in del and the creation of
+// the rules in add. Deletions go first: a rule left by an earlier version
+// may only differ from its replacement by the protocol.
+func (r *Reconciler) ruleChanges(add, del []nlink.RuleInfo) []Change {
+	var changes []Change
+	for _, rule := range del {
+		rule := rule
+		changes = append(changes, Change{
+			Action: ActionDelete, Kind: KindRule, Name: ruleName(rule),
+			Attrs: []AttrChange{{Attr: "priority", Old: strconv.Itoa(rule.Priority)}},
+			apply: func() error { return r.rule.Delete(rule) },
+		})
+	}
+	for _, rule := range add {
+		rule := rule
+		changes = append(changes, Change{
+			Action: ActionCreate, Kind: KindRule, Name: ruleName(rule),
+			Attrs: []AttrChange{{Attr: "priority", New: strconv.Itoa(rule.Priority)}},
+			apply: func() error { return r.rule.Add(rule) },
+		})
+	}
+	return changes
+}
+
+// planRules plans the policy routing rules (ip rule) of an overlay. When
+// lookup_rules.enabled is true, iif/oif rules (or from/to rules in prefix
+// mode) point to the overlay's table. The rules of the overlay's table that
+// are no longer desired, e.g. after a change of mode, priority or prefixes,
+// or with lookup_rules disabled, are removed.
+func (r *Reconciler) planRules(cfg *config.Config, overlay config.OverlayDef, add func(Change)) error {
+	lookup := overlay.Routing.Import.Install.LookupRules
+	if lookup.Enabled && overlay.Routing.Import.Install.Table == 0 {
+		r.logger.Warn("lookup_rules enabled but no table specified, skipping", "overlay", overlay.Name)
+		return nil
+	}
+
+	current, err := r.rule.List()
+	if err != nil {
+		return err
+	}
+	generated, err := r.generatedSelectors(cfg.GetOverlays(), current)
+	if err != nil {
+		return err
+	}
+	desired, err := r.desiredRules(overlay, rulePriorities(cfg.GetOverlays(), current, generated)[overlay.VNI])
+	if err != nil {
+		return err
+	}
+
+	// With lookup_rules disabled, rules left in its table are still the
+	// overlay's.
+	table := overlay.Routing.Import.Install.Table
+	var own []nlink.RuleInfo
+	for _, rule := range current {
+		if table != 0 && rule.Table == table && ownRule(rule, generated[table]) {
+			own = append(own, rule)
+		}
+	}
+	created, deleted := nlink.DiffRules(own, desired)
+	for _, c := range r.ruleChanges(created, deleted) {
+		add(c)
+	}
+	return nil
+}
+
+// planOrphanRules plans the removal of the policy rules n-netman installed in
+// tables no overlay uses any more (e.g. after a change of table), and of the
+// rules left in the table of a removed overlay.
+func (r *Reconciler) planOrphanRules(cfg *config.Config) ([]Change, error) {
+	used := make(map[int]bool)
+	for _, o := range cfg.GetOverlays() {
+		if t := o.Routing.Import.Install.Table; t != 0 {
+			used[t] = true
+		}
+	}
+	// The tables of removed overlays are known from their VXLAN devices.
+	removed := make(map[int]string)
+	owned, err := r.link.ListOwned()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range owned {
+		if t := l.Ownership.Table; t != 0 && !used[t] && l.Ownership.Bridge != "" {
+			removed[t] = l.Ownership.Bridge
+		}
+	}
+
+	current, err := r.rule.List()
+	if err != nil {
+		return nil, err
+	}
+	var orphans []nlink.RuleInfo
+	for _, rule := range current {
+		if used[rule.Table] {
+			continue
+		}
+		// Only the bridge selectors of a removed overlay are still known.
+		if br, ok := removed[rule.Table]; rule.Owned() || (ok && ownRule(rule, map[string]bool{"iif " + br: true, "oif " + br: true})) {
+			orphans = append(orphans, rule)
+		}
+	}
+	return r.ruleChanges(nil, orphans), nil
+}
+
+// planPruneChanges plans the teardown of the owned resources of overlays no
+// longer in the config. A bridge that still has interfaces attached (other
+// than the pruned VXLANs, e.g. VM taps) is left in place.
+func (r *Reconciler) planPruneChanges(cfg *config.Config) ([]Change, error) {
+	owned, err := r.link.ListOwned()
+	if err != nil {
+		return nil, err
+	}
+	pp := planPrune(cfg, owned)
+	if pp.Empty() {
+		return nil, nil
+	}
+
+	desiredBridges := make(map[string]bool)
+	for _, o := range cfg.GetOverlays() {
+		desiredBridges[o.Bridge.Name] = true
+	}
+	pruned := make(map[string]bool)
+	prunedBridges := make(map[string]bool)
+	for _, l := range pp.VXLANs {
+		pruned[l.Name] = true
+	}
+	var bridges []nlink.OwnedLink
+	for _, l := range pp.Bridges {
+		info, err := r.bridge.Get(l.Name)
+		if err != nil {
+			continue
+		}
+		var others []string
+		for _, iface := range info.AttachedInterfaces {
+			if !pruned[iface] {
+				others = append(others, iface)
+			}
+		}
+		if len(others) > 0 {
+			r.logger.Warn("not pruning bridge with attached interfaces", "bridge", l.Name, "attached", others)
+			continue
+		}
+		prunedBridges[l.Name] = true
+		bridges = append(bridges, l)
+	}
+
+	var changes []Change
+	var errs []error
+	for _, l := range pp.VXLANs {
+		l := l
+		vni := l.Ownership.VNI
+		changes = append(changes, Change{
+			Action: ActionDelete, Kind: KindVXLAN, Name: l.Name, VNI: vni,
+			Attrs: []AttrChange{{Attr: "vni", Old: strconv.Itoa(vni)}},
+			apply: func() error { return r.vxlan.Delete(l.Name) },
+		})
+
+		br := l.Ownership.Bridge
+		if br == "" || desiredBridges[br] {
+			continue
+		}
+		if prunedBridges[br] {
+			continue // the addresses go with the bridge
+		}
+		addrs, err := r.bridge.Addresses(br)
+		if err != nil {
+			continue
+		}
+		current := make(map[string]bool, len(addrs))
+		for _, a := range addrs {
+			current[a] = true
+		}
+		for _, cidr := range l.Ownership.Addresses {
+			if !current[normalizeCIDR(cidr)] {
+				continue
+			}
+			cidr := cidr
+			changes = append(changes, Change{
+				Action: ActionDelete, Kind: KindAddress, Name: cidr, VNI: vni,
+				Attrs: []AttrChange{{Attr: "dev", Old: br}},
+				apply: func() error { return r.bridge.DeleteAddress(br, cidr) },
+			})
+		}
+	}
+
+	for _, table := range pp.Tables {
+		routes, err := r.route.ListByProtocol(table, nlink.RouteProtocolNNetMan)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list routes in table %d: %w", table, err))
+			continue
+		}
+		for _, rt := range routes {
+			if rt.Destination == nil {
+				continue
+			}
+			rc := nlink.RouteConfig{
+				Destination: rt.Destination,
+				Gateway:     rt.Gateway,
+				Table:       table,
+				Protocol:    nlink.RouteProtocolNNetMan,
+			}
+			attrs := []AttrChange{{Attr: "table", Old: strconv.Itoa(table)}}
+			if rt.Gateway != nil {
+				attrs = append(attrs, AttrChange{Attr: "via", Old: rt.Gateway.String()})
+			}
+			changes = append(changes, Change{
+				Action: ActionDelete, Kind: KindRoute,
+				Name:  fmt.Sprintf("%s@%d", rt.Destination, table),
+				Attrs: attrs,
+				apply: func() error { return r.route.Delete(rc) },
+			})
+		}
+	}
+
+	for _, l := range bridges {
+		l := l
+		changes = append(changes, Change{
+			Action: ActionDelete, Kind: KindBridge, Name: l.Name, VNI: l.Ownership.VNI,
+			apply: func() error { return r.bridge.Delete(l.Name) },
+		})
+	}
+
+	return changes, errors.Join(errs...)
+}
@@ -0,0 +1,160 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+func TestPlan_SummaryAndRender(t *testing.T) {
+	plan := &Plan{}
+	plan.add(
+		Change{Action: ActionCreate, Kind: KindBridge, Name: "br-100", Overlay: "vxlan100", VNI: 100,
+			Attrs: []AttrChange{{Attr: "mtu", New: "1450"}}},
+		Change{Action: ActionUpdate, Kind: KindVXLAN, Name: "vxlan200", Overlay: "vxlan200", VNI: 200,
+			Attrs: []AttrChange{{Attr: "mtu", Old: "1500", New: "1450"}}},
+		Change{Action: ActionReplace, Kind: KindVXLAN, Name: "vxlan300", Overlay: "vxlan300", VNI: 300,
+			Attrs: []AttrChange{{Attr: "vni", Old: "301", New: "300 (forces replacement)"}}},
+		Change{Action: ActionDelete, Kind: KindFDB, Name: "vxlan200/192.168.1.20", Overlay: "vxlan200", VNI: 200,
+			Attrs: []AttrChange{{Attr: "dst", Old: "192.168.1.20"}}},
+	)
+
+	if want := (PlanSummary{Add: 2, Change: 1, Destroy: 2}); plan.Summary != want {
+		t.Fatalf("Summary = %+v, want %+v", plan.Summary, want)
+	}
+
+	var out bytes.Buffer
+	plan.Render(&out)
+	for _, want := range []string{
+		`  + bridge "br-100" {`,
+		`      + mtu = 1450`,
+		`  ~ vxlan "vxlan200" {`,
+		`      ~ mtu = 1500 -> 1450`,
+		`-/+ vxlan "vxlan300" {`,
+		`  - fdb "vxlan200/192.168.1.20" {`,
+		`      - dst = 192.168.1.20`,
+		`Plan: 2 to add, 1 to change, 2 to destroy.`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("rendered plan missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPlan_RenderEmpty(t *testing.T) {
+	var out bytes.Buffer
+	(&Plan{}).Render(&out)
+	if !strings.Contains(out.String(), "No changes.") {
+		t.Fatalf("empty plan rendered as:\n%s", out.String())
+	}
+}
+
+func TestPlan_JSON(t *testing.T) {
+	plan := &Plan{Changes: []Change{}}
+	plan.add(Change{Action: ActionCreate, Kind: KindRule, Name: "iif br-100 lookup 200", Overlay: "vxlan100", VNI: 100,
+		apply: func() error { return nil }})
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded struct {
+		Changes []map[string]any `json:"changes"`
+		Summary PlanSummary      `json:"summary"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(decoded.Changes) != 1 || decoded.Changes[0]["action"] != "create" || decoded.Changes[0]["kind"] != "rule" {
+		t.Fatalf("changes = %v", decoded.Changes)
+	}
+	if decoded.Summary.Add != 1 {
+		t.Fatalf("summary = %+v, want 1 to add", decoded.Summary)
+	}
+}
+
+func TestApply_IsolatesOverlayFailures(t *testing.T) {
+	r := New(nil, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	var applied []string
+	step := func(name string, err error) Change {
+		vni := 100
+		if strings.HasPrefix(name, "b") {
+			vni = 200
+		}
+		return Change{Action: ActionCreate, Kind: KindVXLAN, Name: name, Overlay: name[:1], VNI: vni,
+			apply: func() error {
+				applied = append(applied, name)
+				return err
+			}}
+	}
+	plan := &Plan{}
+	plan.add(
+		step("a1", nil),
+		step("a2", errors.New("boom")),
+		step("a3", nil), // skipped: depends on a2
+		step("b1", nil),
+		step("b2", nil),
+	)
+
+	err := r.Apply(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Apply() error = %v, want the a2 failure", err)
+	}
+	if got, want := strings.Join(applied, ","), "a1,a2,b1,b2"; got != want {
+		t.Fatalf("applied = %s, want %s", got, want)
+	}
+}
+
+func TestRulePriorities(t *testing.T) {
+	overlays := testOverlays()
+	for i := range overlays {
+		overlays[i].Routing.Import.Install.LookupRules.Enabled = true
+		overlays[i].Routing.Import.Install.Table = 200 + 100*i
+	}
+	c := overlays[0]
+	c.VNI, c.Name, c.Bridge.Name = 50, "vxlan50", "br-50"
+	c.Routing.Import.Install.Table = 150
+	overlays = append(overlays, c)
+
+	// Without rules in the kernel, by VNI from the default priority.
+	if got, want := rulePriorities(overlays, nil, nil), map[int]int{50: 100, 100: 102, 200: 104}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rulePriorities() = %v, want %v", got, want)
+	}
+
+	// VNI 200 keeps the priority of its rules (seen through its to rule), an
+	// explicit priority wins over the one VNI 100 had.
+	overlays[0].Routing.Import.Install.LookupRules.Priority = 101
+	current := []nlink.RuleInfo{
+		ruleInfo("iif", "br-100", 200, 100),
+		ruleInfo("to", "10.9.0.0/24", 300, 501),
+	}
+	if got, want := rulePriorities(overlays, current, nil), map[int]int{50: 103, 100: 101, 200: 500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rulePriorities() = %v, want %v", got, want)
+	}
+
+	// The priorities of foreign rules are skipped, including as the second
+	// rule of an overlay; a rule without protocol is only the overlay's when
+	// it matches a generated selector.
+	overlays[0].Routing.Import.Install.LookupRules.Priority = 0
+	foreign := ruleInfo("from", "10.0.0.0/8", 500, 101)
+	foreign.Protocol = 4
+	legacy := ruleInfo("to", "10.9.0.0/24", 300, 501)
+	legacy.Protocol = 0
+	current = []nlink.RuleInfo{foreign, legacy}
+	if got, want := rulePriorities(overlays, current, nil), map[int]int{50: 102, 100: 104, 200: 106}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rulePriorities() with foreign rules = %v, want %v", got, want)
+	}
+	generated := map[int]map[string]bool{300: {"to 10.9.0.0/24": true}}
+	if got, want := rulePriorities(overlays, current, generated), map[int]int{50: 102, 100: 104, 200: 500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rulePriorities() with a legacy rule = %v, want %v", got, want)
+	}
+}
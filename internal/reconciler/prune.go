@@ -0,0 +1,95 @@
+package reconciler
+
+import (
+	"sort"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// WithPrune forces pruning of removed overlays on, regardless of the
+// reconcile.prune setting (used by `nnet apply --prune`).
+func WithPrune(enabled bool) Option {
+	return func(r *Reconciler) {
+		r.prune = enabled
+	}
+}
+
+// pruneEnabled reports whether removed overlays should be torn down.
+func (r *Reconciler) pruneEnabled(cfg *config.Config) bool {
+	return r.prune || cfg.Reconcile.Prune
+}
+
+// prunePlan lists the owned resources no longer backed by the config.
+type prunePlan struct {
+	// VXLANs are the owned VXLAN devices of removed overlays. Their bridge
+	// addresses go with them unless the bridge is still in use by a desired
+	// overlay. Their policy rules are removed on every cycle, with or
+	// without pruning (see planOrphanRules).
+	VXLANs []nlink.OwnedLink
+	// Bridges are owned bridges no desired overlay or KVM bridge uses.
+	Bridges []nlink.OwnedLink
+	// Tables are the import tables no desired overlay uses anymore; the
+	// n-netman routes in them are flushed.
+	Tables []int
+}
+
+// Empty reports whether there is nothing to prune.
+func (p prunePlan) Empty() bool {
+	return len(p.VXLANs) == 0 && len(p.Bridges) == 0 && len(p.Tables) == 0
+}
+
+// planPrune compares the owned devices against the desired config.
+func planPrune(cfg *config.Config, owned []nlink.OwnedLink) prunePlan {
+	desiredVXLANs := make(map[string]bool)
+	desiredBridges := make(map[string]bool)
+	desiredTables := make(map[int]bool)
+	for _, o := range cfg.GetOverlays() {
+		desiredVXLANs[o.Name] = true
+		desiredBridges[o.Bridge.Name] = true
+		desiredTables[cfg.ImportTable(o.VNI)] = true
+	}
+	for _, b := range cfg.KVM.Bridges {
+		desiredBridges[b.Name] = true
+	}
+
+	var plan prunePlan
+	staleTables := make(map[int]bool)
+	for _, l := range owned {
+		switch l.Kind {
+		case "vxlan":
+			// A desired name with another VNI is replaced by the overlay's
+			// own plan, not pruned.
+			if desiredVXLANs[l.Name] {
+				continue
+			}
+			plan.VXLANs = append(plan.VXLANs, l)
+			if t := l.Ownership.Table; t != 0 && !desiredTables[t] && !staleTables[t] {
+				staleTables[t] = true
+				plan.Tables = append(plan.Tables, t)
+			}
+		case "bridge":
+			if !desiredBridges[l.Name] {
+				plan.Bridges = append(plan.Bridges, l)
+			}
+		}
+	}
+	sort.Ints(plan.Tables)
+	return plan
+}
+
+// ownershipFor is what the reconciler records on an overlay's VXLAN device.
+func ownershipFor(cfg *config.Config, overlay config.OverlayDef) nlink.Ownership {
+	o := nlink.Ownership{
+		VNI:    overlay.VNI,
+		Bridge: overlay.Bridge.Name,
+		Table:  cfg.ImportTable(overlay.VNI),
+		IPv6:   cfg.IPv6VTEP(overlay),
+	}
+	for _, cidr := range []string{overlay.Bridge.IPv4, overlay.Bridge.IPv6} {
+		if cidr != "" {
+			o.Addresses = append(o.Addresses, cidr)
+		}
+	}
+	return o
+}
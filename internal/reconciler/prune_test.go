@@ -0,0 +1,54 @@
+package reconciler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+func TestPlanPrune(t *testing.T) {
+	cfg := &config.Config{Version: 2, Overlays: testOverlays()}
+	cfg.KVM.Bridges = []config.BridgeDef{{Name: "br-vms"}}
+
+	owned := []nlink.OwnedLink{
+		// Desired overlays.
+		{Name: "vxlan100", Kind: "vxlan", Ownership: nlink.Ownership{VNI: 100, Bridge: "br-100", Table: 200}},
+		{Name: "br-100", Kind: "bridge", Ownership: nlink.Ownership{VNI: 100}},
+		// VNI changed under the same name: replaced by the overlay plan.
+		{Name: "vxlan200", Kind: "vxlan", Ownership: nlink.Ownership{VNI: 201, Bridge: "br-200", Table: 100}},
+		// Removed overlay with its own table and bridge.
+		{Name: "vxlan300", Kind: "vxlan", Ownership: nlink.Ownership{VNI: 300, Bridge: "br-300", Table: 300}},
+		{Name: "br-300", Kind: "bridge", Ownership: nlink.Ownership{VNI: 300}},
+		// Bridge now declared under kvm.bridges.
+		{Name: "br-vms", Kind: "bridge", Ownership: nlink.Ownership{VNI: 400}},
+	}
+
+	plan := planPrune(cfg, owned)
+
+	var vxlans, bridges []string
+	for _, l := range plan.VXLANs {
+		vxlans = append(vxlans, l.Name)
+	}
+	for _, l := range plan.Bridges {
+		bridges = append(bridges, l.Name)
+	}
+	if want := []string{"vxlan300"}; !reflect.DeepEqual(vxlans, want) {
+		t.Fatalf("VXLANs = %v, want %v", vxlans, want)
+	}
+	if want := []string{"br-300"}; !reflect.DeepEqual(bridges, want) {
+		t.Fatalf("Bridges = %v, want %v", bridges, want)
+	}
+	// Table 100 is still used by overlay 200; only 300 is flushed.
+	if want := []int{300}; !reflect.DeepEqual(plan.Tables, want) {
+		t.Fatalf("Tables = %v, want %v", plan.Tables, want)
+	}
+}
+
+func TestPlanPrune_NothingOwned(t *testing.T) {
+	cfg := &config.Config{Version: 2, Overlays: testOverlays()}
+	if plan := planPrune(cfg, nil); !plan.Empty() {
+		t.Fatalf("planPrune() = %+v, want empty", plan)
+	}
+}
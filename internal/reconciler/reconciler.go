@@ -9,25 +9,49 @@ import (
 	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nishisan-dev/n-netman/internal/config"
-	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/netplan"
 	"github.com/nishisan-dev/n-netman/internal/observability"
-	"github.com/vishvananda/netlink"
 )
 
 // Reconciler manages the reconciliation loop.
 type Reconciler struct {
-	cfg    *config.Config
-	vxlan  *nlink.VXLANManager
-	bridge *nlink.BridgeManager
-	fdb    *nlink.FDBManager
-	route  *nlink.RouteManager
+	cfg atomic.Pointer[config.Config]
+	// netplan is the netplan network of cfg (nil when netplan is disabled or
+	// its files cannot be read).
+	netplan atomic.Pointer[netplan.Network]
+
+	vxlan  VXLANManager
+	bridge BridgeManager
+	fdb    FDBManager
+	route  RouteManager
+	rule   RuleManager
+	link   LinkManager
 
 	interval time.Duration
 	logger   *slog.Logger
 	metrics  *observability.Metrics
+	trigger  chan struct{}
+
+	// debounce is the delay before an overlay is reconciled after a kernel
+	// event; 0 disables event-driven reconciliation.
+	debounce      time.Duration
+	restoreRoutes func(overlay config.OverlayDef)
+	// ownRouteDeletion tells the route deletions made by the daemon apart
+	// from the ones made behind its back.
+	ownRouteDeletion func(table int, dst *net.IPNet) bool
+	// addrChanged is called with the interface of each address update.
+	addrChanged func(link string)
+	prune       bool
+	// exportedPrefixes returns the prefixes an overlay exports, for the
+	// from rules of lookup_rules in prefix mode.
+	exportedPrefixes func(overlay config.OverlayDef) []string
+	// importedPrefixes returns the prefixes of the routes installed in a
+	// table, for the to rules of lookup_rules in prefix mode.
+	importedPrefixes func(table int) []string
 
 	mu      sync.RWMutex
 	running bool
@@ -38,22 +62,52 @@ type Reconciler struct {
 // New creates a new Reconciler with the given configuration.
 func New(cfg *config.Config, opts ...Option) *Reconciler {
 	r := &Reconciler{
-		cfg:      cfg,
-		vxlan:    nlink.NewVXLANManager(),
-		bridge:   nlink.NewBridgeManager(),
-		fdb:      nlink.NewFDBManager(),
-		route:    nlink.NewRouteManager(),
 		interval: 10 * time.Second,
 		logger:   slog.Default(),
+		trigger:  make(chan struct{}, 1),
+		debounce: defaultEventDebounce,
 	}
 
+	r.cfg.Store(cfg)
+	WithBackend(NetlinkBackend())(r)
+
 	for _, opt := range opts {
 		opt(r)
 	}
+	r.loadNetplan(cfg)
 
 	return r
 }
 
+// SetConfig swaps the desired state used by the following cycles. Overlays
+// added by the new config are created on the next reconciliation.
+func (r *Reconciler) SetConfig(cfg *config.Config) {
+	r.loadNetplan(cfg)
+	r.cfg.Store(cfg)
+}
+
+// loadNetplan reads the netplan files of cfg, which the underlay is inferred
+// from, once per config instead of on every cycle.
+func (r *Reconciler) loadNetplan(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	n, err := netplan.LoadConfig(cfg)
+	if err != nil {
+		r.logger.Warn("failed to read the netplan files", "error", err)
+	}
+	r.netplan.Store(n)
+}
+
+// Trigger requests a reconciliation cycle without waiting for the next tick.
+// Requests made while one is already pending are coalesced.
+func (r *Reconciler) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
 // Option is a functional option for configuring the Reconciler.
 type Option func(*Reconciler)
 
@@ -78,7 +132,30 @@ func WithMetrics(m *observability.Metrics) Option {
 	}
 }
 
+// WithExportedPrefixes sets the function returning the prefixes an overlay
+// exports (networks, connected subnets and netplan static routes), which get
+// from rules with lookup_rules.mode prefix. Without it only export.networks
+// is used.
+func WithExportedPrefixes(fn func(overlay config.OverlayDef) []string) Option {
+	return func(r *Reconciler) {
+		r.exportedPrefixes = fn
+	}
+}
+
+// WithImportedPrefixes sets the function returning the prefixes of the
+// routes n-netman installed in a table, e.g.
+// nlink.RouteManager.InstalledPrefixes, which get to rules with
+// lookup_rules.mode prefix. Without it the table is listed on every plan.
+func WithImportedPrefixes(fn func(table int) []string) Option {
+	return func(r *Reconciler) {
+		r.importedPrefixes = fn
+	}
+}
+
 // Run starts the reconciliation loop. It blocks until the context is cancelled.
+// Besides the periodic full cycle, kernel changes to managed interfaces,
+// addresses, FDB entries and routes trigger a debounced reconciliation of the
+// affected overlay only; the periodic cycle remains as a safety net.
 func (r *Reconciler) Run(ctx context.Context) error {
 	r.mu.Lock()
 	if r.running {
@@ -94,7 +171,20 @@ func (r *Reconciler) Run(ctx context.Context) error {
 		r.mu.Unlock()
 	}()
 
-	r.logger.Info("starting reconciler loop", "interval", r.interval)
+	r.logger.Info("starting reconciler loop", "interval", r.interval, "event_debounce", r.debounce)
+
+	// Subscribe before the initial cycle so nothing changed in between is
+	// missed. A nil channel blocks forever when events are disabled.
+	var events chan overlayEvent
+	deb := newDebouncer(r.debounce)
+	defer deb.stop()
+	if r.debounce > 0 {
+		events = make(chan overlayEvent, 64)
+		if !r.watchKernel(ctx, events) {
+			r.logger.Warn("netlink events unavailable, relying on periodic reconciliation")
+			events = nil
+		}
+	}
 
 	// Initial reconciliation
 	if err := r.Reconcile(ctx); err != nil {
@@ -113,8 +203,67 @@ func (r *Reconciler) Run(ctx context.Context) error {
 			if err := r.Reconcile(ctx); err != nil {
 				r.logger.Error("reconciliation failed", "error", err)
 			}
+		case <-r.trigger:
+			if err := r.Reconcile(ctx); err != nil {
+				r.logger.Error("reconciliation failed", "error", err)
+			}
+		case ev := <-events:
+			r.logger.Debug("kernel event affects overlay", "vni", ev.vni, "reason", ev.reason)
+			deb.add(ev)
+		case vni := <-deb.due:
+			if p, ok := deb.take(vni); ok {
+				r.reconcileOverlayByVNI(ctx, vni, p)
+			}
+		}
+	}
+}
+
+// reconcileOverlayByVNI reconciles a single overlay after kernel events
+// changed its state. The overlay is looked up in the current config, so
+// events for an overlay removed meanwhile are dropped.
+func (r *Reconciler) reconcileOverlayByVNI(ctx context.Context, vni int, p *pendingOverlay) {
+	var overlay config.OverlayDef
+	found := false
+	for _, o := range r.cfg.Load().GetOverlays() {
+		if o.VNI == vni {
+			overlay, found = o, true
+			break
 		}
 	}
+	if !found {
+		return
+	}
+
+	if p.rulesOnly {
+		r.logger.Debug("updating overlay rules after imported route changes",
+			"overlay", overlay.Name, "vni", vni)
+		if err := r.reconcileRules(ctx, overlay); err != nil {
+			r.logger.Error("overlay rules reconciliation failed",
+				"overlay", overlay.Name, "vni", vni, "error", err)
+		}
+		return
+	}
+
+	r.logger.Info("reconciling overlay after kernel changes",
+		"overlay", overlay.Name, "vni", vni, "reasons", p.reasons)
+	if r.metrics != nil {
+		r.metrics.ReconciliationsTotal.Inc()
+	}
+	if err := r.reconcileOverlay(ctx, overlay); err != nil {
+		r.logger.Error("overlay reconciliation failed",
+			"overlay", overlay.Name, "vni", vni, "error", err)
+		if r.metrics != nil {
+			r.metrics.ReconciliationErrors.Inc()
+		}
+		return
+	}
+	r.updateNetworkMetrics(r.cfg.Load().GetOverlays())
+
+	// Routes are reinstalled after the overlay itself, since they resolve
+	// their next-hop through the bridge.
+	if p.routesLost && r.restoreRoutes != nil {
+		r.restoreRoutes(overlay)
+	}
 }
 
 // Reconcile performs a single reconciliation cycle.
@@ -132,27 +281,22 @@ func (r *Reconciler) Reconcile(ctx context.Context) error {
 	r.logger.Debug("starting reconciliation")
 
 	// Get all overlays (works for both v1 and v2 configs)
-	overlays := r.cfg.GetOverlays()
-	if len(overlays) == 0 {
+	cfg := r.cfg.Load()
+	overlays := cfg.GetOverlays()
+	if len(overlays) == 0 && !r.pruneEnabled(cfg) {
 		r.logger.Warn("no overlays configured, skipping reconciliation")
 		return nil
 	}
 
-	// Reconcile each overlay independently: a failure in one overlay must not
-	// prevent the others from being reconciled.
-	var errs []error
-	for _, overlay := range overlays {
-		if err := r.reconcileOverlay(ctx, overlay); err != nil {
-			r.logger.Error("overlay reconciliation failed",
-				"overlay", overlay.Name, "vni", overlay.VNI, "error", err)
-			errs = append(errs, fmt.Errorf("overlay %s (VNI %d): %w", overlay.Name, overlay.VNI, err))
-		}
-	}
+	// Compute the plan and execute it. Each overlay is planned and applied
+	// independently: a failure in one overlay must not prevent the others
+	// from being reconciled.
+	plan, planErr := r.plan(ctx, cfg)
+	applyErr := r.Apply(ctx, plan)
 
 	r.updateNetworkMetrics(overlays)
 
-	if len(errs) > 0 {
-		err := errors.Join(errs...)
+	if err := errors.Join(planErr, applyErr); err != nil {
 		r.setError(err)
 		if r.metrics != nil {
 			r.metrics.ReconciliationErrors.Inc()
@@ -160,7 +304,7 @@ func (r *Reconciler) Reconcile(ctx context.Context) error {
 		return err
 	}
 
-	r.logger.Debug("reconciliation complete", "overlay_count", len(overlays))
+	r.logger.Debug("reconciliation complete", "overlay_count", len(overlays), "changes", len(plan.Changes))
 	r.setError(nil)
 	if r.metrics != nil {
 		r.metrics.LastReconcileTime.SetToCurrentTime()
@@ -190,246 +334,53 @@ func (r *Reconciler) updateNetworkMetrics(overlays []config.OverlayDef) {
 	r.metrics.FDBEntriesTotal.Set(float64(fdbEntries))
 }
 
-// reconcileOverlay reconciles a single overlay (bridge, VXLAN, FDB).
+// reconcileOverlay plans and applies a single overlay (bridge, VXLAN, FDB,
+// policy rules).
 func (r *Reconciler) reconcileOverlay(ctx context.Context, overlay config.OverlayDef) error {
 	r.logger.Debug("reconciling overlay", "name", overlay.Name, "vni", overlay.VNI, "bridge", overlay.Bridge)
 
-	// Step 1: Ensure bridge exists
-	if err := r.reconcileBridgeForOverlay(ctx, overlay); err != nil {
-		return fmt.Errorf("bridge reconciliation failed: %w", err)
-	}
-
-	// Step 2: Ensure VXLAN interface exists and is attached to bridge
-	if err := r.reconcileVXLANForOverlay(ctx, overlay); err != nil {
-		return fmt.Errorf("vxlan reconciliation failed: %w", err)
-	}
-
-	// Step 3: Sync FDB entries for peers
-	if err := r.reconcileFDBForOverlay(ctx, overlay); err != nil {
-		return fmt.Errorf("fdb reconciliation failed: %w", err)
-	}
-
-	// Step 4: Sync policy routing rules (ip rule) if enabled
-	if err := r.reconcilePolicyRulesForOverlay(ctx, overlay); err != nil {
-		return fmt.Errorf("policy rules reconciliation failed: %w", err)
-	}
-
-	return nil
-}
-
-// reconcileBridgeForOverlay ensures the bridge for an overlay exists and is configured correctly.
-func (r *Reconciler) reconcileBridgeForOverlay(ctx context.Context, overlay config.OverlayDef) error {
-	bridgeName := overlay.Bridge.Name
-
-	// Prefer KVM bridge settings when the bridge is marked as managed.
-	var bridgeCfg *config.BridgeDef
-	for i := range r.cfg.KVM.Bridges {
-		if r.cfg.KVM.Bridges[i].Name == bridgeName {
-			bridgeCfg = &r.cfg.KVM.Bridges[i]
-			break
-		}
-	}
-
-	// Determine MTU from overlay or defaults
-	mtu := 1450 // Default
-	if overlay.MTU > 0 {
-		mtu = overlay.MTU
-	}
-
-	if bridgeCfg != nil && bridgeCfg.Manage {
-		stp := bridgeCfg.STP
-		if bridgeCfg.MTU > 0 {
-			mtu = bridgeCfg.MTU
-		}
-
-		r.logger.Debug("ensuring managed bridge", "name", bridgeName, "mtu", mtu, "stp", stp)
-
-		if err := r.bridge.Create(nlink.BridgeConfig{
-			Name: bridgeName,
-			STP:  stp,
-			MTU:  mtu,
-		}); err != nil {
-			return fmt.Errorf("failed to create bridge %s: %w", bridgeName, err)
-		}
-	} else if !r.bridge.Exists(bridgeName) {
-		// Bridge doesn't exist and isn't managed - create with defaults
-		r.logger.Debug("creating unmanaged bridge", "name", bridgeName)
-
-		if err := r.bridge.Create(nlink.BridgeConfig{
-			Name: bridgeName,
-			MTU:  mtu,
-		}); err != nil {
-			return fmt.Errorf("failed to create bridge %s: %w", bridgeName, err)
-		}
-	}
-
-	// Add IP address to bridge if configured (used as overlay gateway/next-hop).
-	if overlay.Bridge.IPv4 != "" {
-		r.logger.Debug("adding IPv4 address to bridge", "bridge", bridgeName, "address", overlay.Bridge.IPv4)
-		if err := r.bridge.AddAddress(bridgeName, overlay.Bridge.IPv4); err != nil {
-			return fmt.Errorf("failed to add IPv4 to bridge %s: %w", bridgeName, err)
-		}
-	}
-	if overlay.Bridge.IPv6 != "" {
-		r.logger.Debug("adding IPv6 address to bridge", "bridge", bridgeName, "address", overlay.Bridge.IPv6)
-		if err := r.bridge.AddAddress(bridgeName, overlay.Bridge.IPv6); err != nil {
-			return fmt.Errorf("failed to add IPv6 to bridge %s: %w", bridgeName, err)
-		}
-	}
-
-	return nil
-}
-
-// reconcileVXLANForOverlay ensures the VXLAN interface for an overlay exists and is attached to the bridge.
-func (r *Reconciler) reconcileVXLANForOverlay(ctx context.Context, overlay config.OverlayDef) error {
-	bumMode := overlay.BUM.GetMode()
-
-	r.logger.Debug("ensuring vxlan interface",
-		"name", overlay.Name,
-		"vni", overlay.VNI,
-		"bridge", overlay.Bridge.Name,
-		"underlay_interface", overlay.UnderlayInterface,
-		"bum_mode", bumMode,
-	)
-
-	// Determine local underlay IP and VTEP device for kernel encapsulation.
-	var localIP net.IP
-	var vtepDev string
-	if overlay.UnderlayInterface != "" {
-		vtepDev = overlay.UnderlayInterface
-		localIP = r.detectUnderlayIP(overlay.UnderlayInterface)
-	}
-
-	// Determine multicast group (only for multicast mode)
-	var group net.IP
-	if bumMode == "multicast" && overlay.BUM.Group != "" {
-		group = net.ParseIP(overlay.BUM.Group)
-		if group == nil {
-			r.logger.Warn("invalid multicast group, falling back to head-end-replication",
-				"vxlan", overlay.Name,
-				"group", overlay.BUM.Group)
-		}
-	}
-
-	// Use default DstPort if not specified
-	dstPort := overlay.DstPort
-	if dstPort == 0 {
-		dstPort = 4789
-	}
-
-	cfg := nlink.VXLANConfig{
-		Name:     overlay.Name,
-		VNI:      overlay.VNI,
-		DstPort:  dstPort,
-		LocalIP:  localIP,
-		MTU:      overlay.MTU,
-		Learning: overlay.Learning,
-		Bridge:   overlay.Bridge.Name,
-		Group:    group,
-		VtepDev:  vtepDev,
-	}
-
-	if err := r.vxlan.Create(cfg); err != nil {
-		return fmt.Errorf("failed to create vxlan %s: %w", overlay.Name, err)
-	}
-
-	return nil
-}
-
-// reconcileFDBForOverlay syncs FDB entries with configured peers for an overlay.
-// For head-end-replication mode, populates FDB with 00:00:00:00:00:00 entries.
-// For multicast mode, the kernel handles BUM traffic via IGMP.
-func (r *Reconciler) reconcileFDBForOverlay(ctx context.Context, overlay config.OverlayDef) error {
-	bumMode := overlay.BUM.GetMode()
-
-	// Multicast mode: kernel handles BUM via multicast group, skip FDB sync
-	if bumMode == "multicast" {
-		r.logger.Debug("skipping fdb sync, multicast mode enabled", "vxlan", overlay.Name)
-		return nil
-	}
-
-	// Head-end replication mode: populate FDB with 00:00:00:00:00:00 entries
-	// This is required for BUM traffic even when learning=true. Only peers that
-	// participate in this overlay's VNI are flooded (avoids cross-overlay leak).
-	peers := r.cfg.GetPeersForVNI(overlay.VNI)
-
-	// Build list of peer IPs
-	var peerIPs []net.IP
-	for _, peer := range peers {
-		ip := net.ParseIP(peer.Endpoint.Address)
-		if ip == nil {
-			r.logger.Warn("invalid peer IP, skipping", "peer_id", peer.ID, "address", peer.Endpoint.Address)
-			continue
-		}
-		peerIPs = append(peerIPs, ip)
-	}
-
-	r.logger.Debug("syncing fdb entries for head-end replication",
-		"vxlan", overlay.Name,
-		"peer_count", len(peerIPs),
-		"bum_mode", bumMode)
-
-	if err := r.fdb.SyncPeers(overlay.Name, peerIPs); err != nil {
-		return fmt.Errorf("failed to sync fdb for %s: %w", overlay.Name, err)
+	changes, err := r.planOverlay(ctx, r.cfg.Load(), overlay)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	plan := &Plan{}
+	plan.add(changes...)
+	return r.Apply(ctx, plan)
 }
 
-// reconcilePolicyRulesForOverlay syncs policy routing rules (ip rule) for an overlay.
-// When lookup_rules.enabled is true, creates iif/oif rules pointing to the overlay's table.
-func (r *Reconciler) reconcilePolicyRulesForOverlay(ctx context.Context, overlay config.OverlayDef) error {
-	// Skip if lookup_rules is not enabled
-	if !overlay.Routing.Import.Install.LookupRules.Enabled {
-		return nil
-	}
-
-	bridgeName := overlay.Bridge.Name
-	table := overlay.Routing.Import.Install.Table
-
-	if table == 0 {
-		r.logger.Warn("lookup_rules enabled but no table specified, skipping", "overlay", overlay.Name)
-		return nil
-	}
-
-	r.logger.Debug("ensuring policy rules",
-		"overlay", overlay.Name,
-		"bridge", bridgeName,
-		"table", table,
-	)
-
-	if err := nlink.EnsureRuleByInterface(bridgeName, table); err != nil {
-		return fmt.Errorf("failed to ensure policy rules for %s: %w", bridgeName, err)
+// reconcileRules reconciles only the policy rules of an overlay, for a
+// change of the prefixes imported into its table in prefix mode.
+func (r *Reconciler) reconcileRules(ctx context.Context, overlay config.OverlayDef) error {
+	var changes []Change
+	err := r.planRules(r.cfg.Load(), overlay, func(c Change) {
+		c.Overlay, c.VNI = overlay.Name, overlay.VNI
+		changes = append(changes, c)
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	plan := &Plan{}
+	plan.add(changes...)
+	return r.Apply(ctx, plan)
 }
 
 // detectUnderlayIP returns the first IP address of the specified interface.
-// Prefers IPv4, falls back to IPv6 if no v4 address is found.
-func (r *Reconciler) detectUnderlayIP(ifaceName string) net.IP {
-	link, err := netlink.LinkByName(ifaceName)
+// Prefers the requested family (IPv4 unless ipv6), falls back to the other
+// one. IPv6 link-local addresses (fe80::) are skipped.
+func (r *Reconciler) detectUnderlayIP(ifaceName string, ipv6 bool) net.IP {
+	ips, err := r.link.Addresses(ifaceName)
 	if err != nil {
 		r.logger.Warn("underlay interface not found", "interface", ifaceName, "error", err)
 		return nil
 	}
 
-	// Try IPv4 first
-	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
-	if err == nil && len(addrs) > 0 {
-		r.logger.Debug("detected underlay IPv4", "interface", ifaceName, "ip", addrs[0].IP)
-		return addrs[0].IP
-	}
-
-	// Fall back to IPv6
-	addrs, err = netlink.AddrList(link, netlink.FAMILY_V6)
-	if err == nil && len(addrs) > 0 {
-		// Skip link-local addresses (fe80::)
-		for _, addr := range addrs {
-			if !addr.IP.IsLinkLocalUnicast() {
-				r.logger.Debug("detected underlay IPv6", "interface", ifaceName, "ip", addr.IP)
-				return addr.IP
+	for _, wantV6 := range []bool{ipv6, !ipv6} {
+		for _, ip := range ips {
+			if (ip.To4() == nil) != wantV6 || ip.IsLinkLocalUnicast() {
+				continue
 			}
+			r.logger.Debug("detected underlay IP", "interface", ifaceName, "ip", ip)
+			return ip
 		}
 	}
 
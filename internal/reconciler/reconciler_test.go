@@ -0,0 +1,655 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/nishisan-dev/n-netman/internal/config"
+	nlink "github.com/nishisan-dev/n-netman/internal/netlink"
+)
+
+// testConfig returns two head-end replication overlays with peers: VNI 100
+// (lookup rules in table 200) is shared with both peers, VNI 200 only with
+// peer-b.
+func testConfig() *config.Config {
+	cfg := &config.Config{Version: 2, Overlays: testOverlays()}
+	cfg.Overlays[0].Routing.Import.Install.LookupRules.Enabled = true
+	cfg.Peers = []config.PeerConfig{
+		{ID: "peer-a", Endpoint: config.EndpointConfig{Address: "192.168.1.10"}, VNIs: []int{100}},
+		{ID: "peer-b", Endpoint: config.EndpointConfig{Address: "192.168.1.11"}},
+	}
+	return cfg
+}
+
+func newTestReconciler(cfg *config.Config, k *fakeKernel, opts ...Option) *Reconciler {
+	opts = append([]Option{
+		WithBackend(k.backend()),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	return New(cfg, opts...)
+}
+
+// converged asserts the fake kernel holds the desired state of testConfig.
+func converged(t *testing.T, k *fakeKernel) {
+	t.Helper()
+	for _, want := range []struct {
+		name, master string
+		vni, mtu     int
+		peers        []string
+	}{
+		{"vxlan100", "br-100", 100, 1450, []string{"192.168.1.10", "192.168.1.11"}},
+		{"vxlan200", "br-200", 200, 1450, []string{"192.168.1.11"}},
+	} {
+		l := k.link(want.name)
+		if l == nil {
+			t.Fatalf("%s missing", want.name)
+		}
+		if l.vni != want.vni || l.mtu != want.mtu || !l.up || l.master != want.master {
+			t.Fatalf("%s = %+v, want vni %d mtu %d up master %s", want.name, *l, want.vni, want.mtu, want.master)
+		}
+		if _, ok := nlink.ParseOwnerAlias(l.alias); !ok {
+			t.Fatalf("%s has no ownership alias (%q)", want.name, l.alias)
+		}
+		if got := k.peers(want.name); !reflect.DeepEqual(got, want.peers) {
+			t.Fatalf("%s FDB peers = %v, want %v", want.name, got, want.peers)
+		}
+		if br := k.link(want.master); br == nil || br.kind != "bridge" || !br.up {
+			t.Fatalf("bridge %s not up", want.master)
+		}
+	}
+	if got := k.link("br-100").addrs; !reflect.DeepEqual(got, []string{"10.100.0.1/24"}) {
+		t.Fatalf("br-100 addresses = %v", got)
+	}
+	if len(k.rules) != 2 {
+		t.Fatalf("rules = %+v, want iif/oif for br-100", k.rules)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup prepares the kernel after a first successful reconcile.
+		setup func(k *fakeKernel)
+		// want are the mutations the second reconcile must perform.
+		want []string
+	}{
+		{
+			name: "converged host is left untouched",
+			want: nil,
+		},
+		{
+			name:  "vni change recreates the vxlan and its fdb",
+			setup: func(k *fakeKernel) { k.link("vxlan200").vni = 201 },
+			want:  []string{"vxlan.create vxlan200", "link.alias vxlan200", "fdb.add vxlan200/192.168.1.11"},
+		},
+		{
+			name:  "learning drift recreates the vxlan",
+			setup: func(k *fakeKernel) { k.link("vxlan200").learning = true },
+			want:  []string{"vxlan.create vxlan200", "link.alias vxlan200", "fdb.add vxlan200/192.168.1.11"},
+		},
+		{
+			name:  "dstport drift recreates the vxlan",
+			setup: func(k *fakeKernel) { k.link("vxlan200").dstPort = 8472 },
+			want:  []string{"vxlan.create vxlan200", "link.alias vxlan200", "fdb.add vxlan200/192.168.1.11"},
+		},
+		{
+			name: "local address, group or underlay device drift recreates the vxlan",
+			setup: func(k *fakeKernel) {
+				l := k.link("vxlan200")
+				l.local, l.group, l.vtepDev = net.ParseIP("192.168.1.99"), net.ParseIP("239.1.1.1"), "eth1"
+			},
+			want: []string{"vxlan.create vxlan200", "link.alias vxlan200", "fdb.add vxlan200/192.168.1.11"},
+		},
+		{
+			name:  "mtu drift is corrected in place",
+			setup: func(k *fakeKernel) { k.link("vxlan100").mtu = 1500 },
+			want:  []string{"vxlan.create vxlan100"},
+		},
+		{
+			name:  "detached vxlan is reattached to its bridge",
+			setup: func(k *fakeKernel) { k.link("vxlan100").master = "" },
+			want:  []string{"vxlan.create vxlan100"},
+		},
+		{
+			name: "deleted bridge is recreated and the vxlan reattached",
+			setup: func(k *fakeKernel) {
+				k.mu.Lock()
+				k.deleteLink("br-100")
+				k.rules = nil
+				k.mu.Unlock()
+			},
+			want: []string{
+				"bridge.create br-100", "link.alias br-100", "address.add 10.100.0.1/24",
+				"vxlan.create vxlan100", "rule.add iif br-100", "rule.add oif br-100",
+			},
+		},
+		{
+			name: "stale flood entry removed, learned mac kept",
+			setup: func(k *fakeKernel) {
+				mac, _ := net.ParseMAC("52:54:00:12:34:56")
+				k.fdb["vxlan200"] = append(k.fdb["vxlan200"],
+					nlink.FDBEntry{MAC: make(net.HardwareAddr, 6), RemoteIP: net.ParseIP("192.168.1.99")},
+					nlink.FDBEntry{MAC: mac, RemoteIP: net.ParseIP("192.168.1.11")})
+			},
+			want: []string{"fdb.delete vxlan200/192.168.1.99"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newFakeKernel()
+			k.addDevice("eth0", "192.168.1.1")
+			k.addDevice("eth1", "fe80::1", "2001:db8::1")
+			r := newTestReconciler(testConfig(), k)
+
+			if err := r.Reconcile(context.Background()); err != nil {
+				t.Fatalf("first Reconcile() error = %v", err)
+			}
+			converged(t, k)
+			k.mutations()
+
+			if tt.setup != nil {
+				tt.setup(k)
+			}
+			if err := r.Reconcile(context.Background()); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if got := k.mutations(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("mutations = %q, want %q", got, tt.want)
+			}
+			converged(t, k)
+		})
+	}
+}
+
+func TestReconcile_ManagedBridgeSTP(t *testing.T) {
+	k := newFakeKernel()
+	cfg := testConfig()
+	cfg.KVM.Bridges = []config.BridgeDef{{Name: "br-100", STP: true, MTU: 1450, Manage: true}}
+	r := newTestReconciler(cfg, k)
+
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !k.link("br-100").stp {
+		t.Fatal("managed bridge br-100 created without STP")
+	}
+	k.mutations()
+
+	k.link("br-100").stp = false
+	plan, err := r.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []AttrChange{{Attr: "stp", Old: "false", New: "true"}}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionUpdate || plan.Changes[0].Name != "br-100" ||
+		!reflect.DeepEqual(plan.Changes[0].Attrs, want) {
+		t.Fatalf("plan = %+v, want the STP drift of br-100", plan.Changes)
+	}
+	if err := r.Apply(context.Background(), plan); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !k.link("br-100").stp {
+		t.Fatal("STP drift not corrected")
+	}
+}
+
+func TestReconcile_IsolatesOverlayFailures(t *testing.T) {
+	k := newFakeKernel()
+	k.failOn("vxlan.create", "vxlan100", errors.New("boom"))
+	r := newTestReconciler(testConfig(), k)
+
+	err := r.Reconcile(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "overlay vxlan100 (VNI 100)") {
+		t.Fatalf("Reconcile() error = %v, want the vxlan100 failure", err)
+	}
+	if r.Status().LastErr == nil {
+		t.Fatal("Status().LastErr not set")
+	}
+
+	// The failing overlay stops at the failed change...
+	if k.link("vxlan100") != nil {
+		t.Fatal("vxlan100 should not exist")
+	}
+	if len(k.rules) != 0 {
+		t.Fatalf("rules of the failed overlay were applied: %+v", k.rules)
+	}
+	// ...while the other overlay is fully reconciled.
+	if l := k.link("vxlan200"); l == nil || l.master != "br-200" {
+		t.Fatalf("vxlan200 = %+v, want attached to br-200", l)
+	}
+	if got := k.peers("vxlan200"); !reflect.DeepEqual(got, []string{"192.168.1.11"}) {
+		t.Fatalf("vxlan200 peers = %v", got)
+	}
+
+	// Once the failure clears, the next cycle converges.
+	k.failOn("vxlan.create", "vxlan100", nil)
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() after recovery error = %v", err)
+	}
+	converged(t, k)
+}
+
+func TestReconcile_RefusesForeignInterface(t *testing.T) {
+	k := newFakeKernel()
+	k.addDevice("vxlan200")
+	r := newTestReconciler(testConfig(), k)
+
+	if err := r.Reconcile(context.Background()); err == nil || !strings.Contains(err.Error(), "refusing to replace") {
+		t.Fatalf("Reconcile() error = %v, want refusal", err)
+	}
+	if l := k.link("vxlan200"); l.kind != "device" {
+		t.Fatalf("foreign interface was replaced: %+v", l)
+	}
+}
+
+func TestReconcile_UnderlayLocalIP(t *testing.T) {
+	k := newFakeKernel()
+	k.addDevice("eth1", "fe80::1", "2001:db8::1")
+	r := newTestReconciler(testConfig(), k)
+
+	if got := r.detectUnderlayIP("eth1", false); !got.Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("detectUnderlayIP(eth1) = %v, want the global IPv6", got)
+	}
+	k.addDevice("eth0", "2001:db8::2", "192.168.1.1")
+	if got := r.detectUnderlayIP("eth0", false); !got.Equal(net.ParseIP("192.168.1.1")) {
+		t.Fatalf("detectUnderlayIP(eth0) = %v, want the IPv4", got)
+	}
+	if got := r.detectUnderlayIP("eth0", true); !got.Equal(net.ParseIP("2001:db8::2")) {
+		t.Fatalf("detectUnderlayIP(eth0, ipv6) = %v, want the IPv6", got)
+	}
+	if got := r.detectUnderlayIP("missing", false); got != nil {
+		t.Fatalf("detectUnderlayIP(missing) = %v, want nil", got)
+	}
+}
+
+func TestReconcile_NetplanUnderlay(t *testing.T) {
+	dir := t.TempDir()
+	netplanFile := "network:\n  ethernets:\n    eth1:\n      dhcp4: true\n"
+	if err := os.WriteFile(filepath.Join(dir, "50-test.yaml"), []byte(netplanFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k := newFakeKernel()
+	k.addDevice("eth1", "192.168.1.5")
+	cfg := testConfig()
+	cfg.Netplan = config.NetplanConfig{Enabled: true, ConfigPaths: []string{dir}}
+	cfg.Overlays[0].UnderlayInterface = ""
+	r := newTestReconciler(cfg, k)
+
+	// The files are read with the config, not on every cycle.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	// The DHCP address of the netplan underlay is read from the backend.
+	if l := k.link("vxlan100"); l.vtepDev != "eth1" || !l.local.Equal(net.ParseIP("192.168.1.5")) {
+		t.Fatalf("vxlan100 dev %q local %v, want eth1 192.168.1.5", l.vtepDev, l.local)
+	}
+}
+
+func TestReconcile_IPv6VTEPs(t *testing.T) {
+	k := newFakeKernel()
+	k.addDevice("eth0", "192.168.1.1", "2001:db8::1")
+	cfg := testConfig()
+	cfg.Overlays[1].UnderlayInterface = "eth0"
+	cfg.Peers[0].VNIs = []int{100}
+	cfg.Peers[1].VNIs = []int{200}
+	cfg.Peers[1].Endpoint.Address = "2001:db8::11"
+	r := newTestReconciler(cfg, k)
+
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	// On the same dual-stack underlay, the IPv4 overlay gets its IPv4
+	// address and the IPv6 one its IPv6 address, with its IPv6 peer in the FDB.
+	if l := k.link("vxlan100"); !l.local.Equal(net.ParseIP("192.168.1.1")) {
+		t.Fatalf("vxlan100 local = %v, want 192.168.1.1", l.local)
+	}
+	if l := k.link("vxlan200"); !l.local.Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("vxlan200 local = %v, want 2001:db8::1", l.local)
+	}
+	if got := k.peers("vxlan200"); !reflect.DeepEqual(got, []string{"2001:db8::11"}) {
+		t.Fatalf("vxlan200 peers = %v, want the IPv6 VTEP", got)
+	}
+	k.mutations()
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := k.mutations(); len(got) != 0 {
+		t.Fatalf("converged host mutated: %q", got)
+	}
+
+	// Moving the overlay back to IPv4 VTEPs recreates the VXLAN.
+	cfg.Overlays[1].UnderlayInterface = "eth0"
+	cfg.Peers[1].Endpoint.Address = "192.168.1.11"
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if l := k.link("vxlan200"); !l.local.Equal(net.ParseIP("192.168.1.1")) {
+		t.Fatalf("vxlan200 local = %v, want 192.168.1.1", l.local)
+	}
+	if got := k.peers("vxlan200"); !reflect.DeepEqual(got, []string{"192.168.1.11"}) {
+		t.Fatalf("vxlan200 peers = %v, want the IPv4 VTEP", got)
+	}
+
+	// Without an underlay interface an IPv6 VXLAN binds to any. The kernel
+	// reports no local address for an IPv4 VXLAN without one nor for "::",
+	// so the move is only seen, and then kept, thanks to the family in the
+	// ownership alias.
+	cfg.Overlays[1].UnderlayInterface = ""
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if l := k.link("vxlan200"); l.local != nil {
+		t.Fatalf("vxlan200 local = %v, want none", l.local)
+	}
+	cfg.Peers[1].Endpoint.Address = "2001:db8::11"
+	k.mutations()
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if l := k.link("vxlan200"); !l.local.Equal(net.IPv6unspecified) {
+		t.Fatalf("vxlan200 local = %v, want ::", l.local)
+	}
+	if got := k.mutations(); !slices.Contains(got, "vxlan.create vxlan200") {
+		t.Fatalf("mutations = %q, want vxlan200 recreated", got)
+	}
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := k.mutations(); len(got) != 0 {
+		t.Fatalf("IPv6 any VXLAN recreated again: %q", got)
+	}
+	plan, err := newTestReconciler(cfg, newFakeKernel()).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	var out strings.Builder
+	plan.Render(&out)
+	if !strings.Contains(out.String(), "+ local = ::\n") {
+		t.Fatalf("plan does not bind vxlan200 to the IPv6 any address:\n%s", out.String())
+	}
+}
+
+func TestReconcile_ViaInterface(t *testing.T) {
+	k := newFakeKernel()
+	k.addDevice("eth0", "192.168.1.1")
+	k.addDevice("eth1", "10.0.0.1")
+	k.gateways["eth1"] = net.ParseIP("10.0.0.254")
+	cfg := testConfig()
+	cfg.Peers[1].Endpoint.ViaInterface = "eth1"
+	r := newTestReconciler(cfg, k)
+
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	converged(t, k)
+	want := []nlink.RouteInfo{{
+		Destination: &net.IPNet{IP: net.ParseIP("192.168.1.11").To4(), Mask: net.CIDRMask(32, 32)},
+		Gateway:     net.ParseIP("10.0.0.254"),
+		Device:      "eth1",
+		Table:       254,
+		Protocol:    nlink.RouteProtocolUnderlay,
+	}}
+	if !reflect.DeepEqual(k.routes, want) {
+		t.Fatalf("routes = %+v, want the host route of peer-b out of eth1", k.routes)
+	}
+	vias := func() map[string]string {
+		out := make(map[string]string)
+		for name, entries := range k.fdb {
+			for _, e := range entries {
+				out[fdbName(name, e.RemoteIP)] = e.Via
+			}
+		}
+		return out
+	}
+	if got := vias(); got["vxlan100/192.168.1.11"] != "eth1" || got["vxlan200/192.168.1.11"] != "eth1" || got["vxlan100/192.168.1.10"] != "" {
+		t.Fatalf("FDB vias = %v, want eth1 for peer-b only", got)
+	}
+	k.mutations()
+
+	// Moving the peer to another uplink updates the route and recreates
+	// its flood entries.
+	next := testConfig()
+	next.Peers[1].Endpoint.ViaInterface = "eth0"
+	r.SetConfig(next)
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got, want := k.mutations(), []string{
+		"route.replace 192.168.1.11/32@254",
+		"fdb.delete vxlan100/192.168.1.11", "fdb.add vxlan100/192.168.1.11",
+		"fdb.delete vxlan200/192.168.1.11", "fdb.add vxlan200/192.168.1.11",
+	}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mutations = %q, want %q", got, want)
+	}
+	if len(k.routes) != 1 || k.routes[0].Device != "eth0" || k.routes[0].Gateway != nil {
+		t.Fatalf("routes = %+v, want the on-link host route out of eth0", k.routes)
+	}
+
+	// Unpinning the peer removes its host route.
+	r.SetConfig(testConfig())
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(k.routes) != 0 {
+		t.Fatalf("routes = %+v, want the host route removed", k.routes)
+	}
+	if got := vias(); got["vxlan100/192.168.1.11"] != "" {
+		t.Fatalf("FDB vias = %v, want none", got)
+	}
+
+	// An interface without a route to the peer is reported.
+	next = testConfig()
+	next.Peers[1].Endpoint.ViaInterface = "missing"
+	r.SetConfig(next)
+	if err := r.Reconcile(context.Background()); err == nil || !strings.Contains(err.Error(), "peer peer-b") {
+		t.Fatalf("Reconcile() error = %v, want the peer-b route failure", err)
+	}
+}
+
+func TestReconcile_PrefixLookupRules(t *testing.T) {
+	k := newFakeKernel()
+	cfg := testConfig()
+	cfg.Overlays[0].Routing.Import.Install.LookupRules = config.LookupRulesConfig{Enabled: true, Mode: "prefix", Priority: 300}
+	cfg.Overlays[0].Routing.Export.Networks = []string{"172.16.10.0/24"}
+	exported := func(o config.OverlayDef) []string {
+		return append(o.Routing.Export.Networks, "172.16.11.5/24")
+	}
+	r := newTestReconciler(cfg, k, WithExportedPrefixes(exported))
+
+	// The iif rule left by interface mode is stale; the default route gets
+	// no rule. A from rule left without protocol is adopted.
+	legacy := ruleInfo("from", "172.16.10.0/24", 200, 300)
+	legacy.Protocol = 0
+	k.rules = append(k.rules, ruleInfo("iif", "br-100", 200, nlink.RulePriority), legacy)
+	k.routes = append(k.routes,
+		nlink.RouteInfo{Destination: mustCIDR("10.9.0.0/24"), Table: 200, Protocol: nlink.RouteProtocolNNetMan},
+		nlink.RouteInfo{Destination: mustCIDR("0.0.0.0/0"), Table: 200, Protocol: nlink.RouteProtocolNNetMan},
+		nlink.RouteInfo{Destination: mustCIDR("10.8.0.0/24"), Table: 200, Protocol: 4})
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	want := []nlink.RuleInfo{
+		legacy,
+		ruleInfo("from", "172.16.11.0/24", 200, 300),
+		ruleInfo("to", "10.9.0.0/24", 200, 301),
+	}
+	if !reflect.DeepEqual(k.rules, want) {
+		t.Fatalf("rules = %+v, want %+v", k.rules, want)
+	}
+
+	// The to rules follow the imported routes, in their family.
+	k.mutations()
+	k.routes[0].Destination = mustCIDR("2001:db8:9::/64")
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got, want := k.mutations(), []string{"rule.delete to 10.9.0.0/24", "rule.add to 2001:db8:9::/64"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mutations = %q, want %q", got, want)
+	}
+
+	// Back to interface mode: the prefix rules are removed.
+	cfg.Overlays[0].Routing.Import.Install.LookupRules.Mode = "interface"
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	want = []nlink.RuleInfo{ruleInfo("iif", "br-100", 200, 300), ruleInfo("oif", "br-100", 200, 301)}
+	if !reflect.DeepEqual(k.rules, want) {
+		t.Fatalf("rules = %+v, want %+v", k.rules, want)
+	}
+}
+
+func TestReconcileRules_ImportedPrefixes(t *testing.T) {
+	k := newFakeKernel()
+	cfg := testConfig()
+	cfg.Overlays[0].Routing.Import.Install.LookupRules = config.LookupRulesConfig{Enabled: true, Mode: "prefix", Priority: 300}
+	installed := []string{"10.9.0.0/24"}
+	imported := func(table int) []string {
+		if table != 200 {
+			return nil
+		}
+		return installed
+	}
+	r := newTestReconciler(cfg, k, WithImportedPrefixes(imported))
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// The to rules follow the installed routes, not the kernel table, and
+	// only the rules of the overlay are reconciled.
+	k.mutations()
+	k.routes = append(k.routes, nlink.RouteInfo{Destination: mustCIDR("10.8.0.0/24"), Table: 200, Protocol: nlink.RouteProtocolNNetMan})
+	installed = []string{"10.7.0.0/24"}
+	delete(k.links, "vxlan100")
+	if err := r.reconcileRules(context.Background(), cfg.Overlays[0]); err != nil {
+		t.Fatalf("reconcileRules() error = %v", err)
+	}
+	if got, want := k.mutations(), []string{"rule.delete to 10.9.0.0/24", "rule.add to 10.7.0.0/24"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mutations = %q, want %q", got, want)
+	}
+}
+
+func TestReconcile_RuleSync(t *testing.T) {
+	k := newFakeKernel()
+	cfg := testConfig()
+	cfg.Overlays[1].Routing.Import.Install.Table = 300
+	cfg.Overlays[1].Routing.Import.Install.LookupRules.Enabled = true
+	cfg.Overlays[1].Bridge.IPv4 = "10.200.0.1/24"
+	cfg.Overlays[1].Bridge.IPv6 = "2001:db8:200::1/64"
+	r := newTestReconciler(cfg, k)
+
+	// A rule left by an earlier version (without protocol) is kept as it is,
+	// foreign rules are left alone, even without protocol in the overlay's
+	// table when n-netman would not have generated them.
+	legacy := ruleInfo("iif", "br-100", 200, 100)
+	legacy.Protocol = 0
+	foreign := ruleInfo("from", "10.0.0.0/8", 500, 90)
+	foreign.Protocol = 4
+	manual := ruleInfo("to", "10.5.0.0/24", 200, 90)
+	manual.Protocol = 0
+	k.rules = []nlink.RuleInfo{legacy, foreign, manual}
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	v6 := func(rule nlink.RuleInfo) nlink.RuleInfo {
+		rule.Family = netlink.FAMILY_V6
+		return rule
+	}
+	want := []nlink.RuleInfo{
+		legacy, foreign, manual,
+		ruleInfo("oif", "br-100", 200, 101),
+		ruleInfo("iif", "br-200", 300, 102), ruleInfo("oif", "br-200", 300, 103),
+		v6(ruleInfo("iif", "br-200", 300, 102)), v6(ruleInfo("oif", "br-200", 300, 103)),
+	}
+	if !reflect.DeepEqual(k.rules, want) {
+		t.Fatalf("rules = %+v, want %+v", k.rules, want)
+	}
+
+	// A new table moves the rules: the ones of the old table are removed.
+	cfg.Overlays[1].Routing.Import.Install.Table = 310
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	moved := 0
+	for _, rule := range k.rules {
+		switch rule.Table {
+		case 300:
+			t.Fatalf("rule %+v left in the old table", rule)
+		case 310:
+			if rule.Priority != 102 && rule.Priority != 103 {
+				t.Fatalf("rule %+v moved to another priority", rule)
+			}
+			moved++
+		}
+	}
+	if moved != 4 {
+		t.Fatalf("rules = %+v, want the 4 rules of br-200 in table 310", k.rules)
+	}
+
+	// Without lookup_rules the overlay's rules go away.
+	cfg.Overlays[0].Routing.Import.Install.LookupRules.Enabled = false
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for _, rule := range k.rules {
+		if rule.Table == 200 && rule != manual {
+			t.Fatalf("rule %+v left after lookup_rules was disabled", rule)
+		}
+	}
+}
+
+func TestReconcile_Prune(t *testing.T) {
+	k := newFakeKernel()
+	cfg := testConfig()
+	r := newTestReconciler(cfg, k, WithPrune(true))
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	k.routes = append(k.routes,
+		nlink.RouteInfo{Destination: mustCIDR("10.9.0.0/24"), Table: 200, Protocol: nlink.RouteProtocolNNetMan},
+		nlink.RouteInfo{Destination: mustCIDR("10.8.0.0/24"), Table: 200, Protocol: 4})
+
+	// Drop VNI 100: its table (200), rules, bridge and address go away.
+	next := testConfig()
+	next.Overlays = next.Overlays[1:]
+	next.Peers[0].VNIs = nil
+	r.SetConfig(next)
+	if err := r.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Reconcile() after removal error = %v", err)
+	}
+
+	if k.link("vxlan100") != nil || k.link("br-100") != nil {
+		t.Fatal("vxlan100/br-100 not pruned")
+	}
+	if len(k.rules) != 0 {
+		t.Fatalf("rules not pruned: %+v", k.rules)
+	}
+	if len(k.routes) != 1 || k.routes[0].Protocol != 4 {
+		t.Fatalf("routes = %+v, want only the foreign route left", k.routes)
+	}
+	if k.link("vxlan200") == nil || k.link("br-200") == nil {
+		t.Fatal("remaining overlay was pruned")
+	}
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -2,27 +2,71 @@
 package routing
 
 import (
+	"cmp"
 	"net"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/nishisan-dev/n-netman/internal/config"
 	"github.com/nishisan-dev/n-netman/internal/controlplane"
+	nlmgr "github.com/nishisan-dev/n-netman/internal/netlink"
+	"github.com/nishisan-dev/n-netman/internal/netplan"
 )
 
 // Manager handles route export and import according to configured policies.
 type Manager struct {
-	cfg *config.Config
+	cfg atomic.Pointer[config.Config]
 
 	mu             sync.RWMutex
 	exportedRoutes []controlplane.Route
+	// netplan is the netplan network of the config, read when the config is
+	// set; nil when netplan is disabled or its files cannot be read.
+	netplan *netplan.Network
+
+	// connectedPrefixes returns the connected subnets of an interface
+	// (include_connected).
+	connectedPrefixes func(iface string) ([]*net.IPNet, error)
 }
 
 // NewManager creates a new routing manager.
 func NewManager(cfg *config.Config) *Manager {
-	return &Manager{
-		cfg: cfg,
+	m := &Manager{
+		connectedPrefixes: nlmgr.NewLinkManager().ConnectedPrefixes,
 	}
+	m.cfg.Store(cfg)
+	m.netplan, _ = netplan.LoadConfig(cfg)
+	return m
+}
+
+// SetConfig swaps the policies used by the manager, re-reads the netplan
+// files and drops the cached export list so it is rebuilt from the new
+// config.
+func (m *Manager) SetConfig(cfg *config.Config) {
+	np, _ := netplan.LoadConfig(cfg)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg.Store(cfg)
+	m.netplan = np
+	m.exportedRoutes = nil
+}
+
+// Netplan returns the netplan network read with the current config, or nil
+// when netplan is disabled or its files could not be read.
+func (m *Manager) Netplan() *netplan.Network {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.netplan
+}
+
+// exportMetric returns the metric of the routes exported under an export
+// policy (100 when unset).
+func exportMetric(exportCfg config.ExportConfig) uint32 {
+	if exportCfg.Metric == 0 {
+		return 100
+	}
+	return uint32(exportCfg.Metric)
 }
 
 // GetExportRoutes returns the routes that should be exported according to policy.
@@ -41,11 +85,8 @@ func (m *Manager) GetExportRoutes() []controlplane.Route {
 	// Build export routes from config (cached for future calls).
 	var routes []controlplane.Route
 
-	exportCfg := m.cfg.Routing.Export
-	metric := uint32(exportCfg.Metric)
-	if metric == 0 {
-		metric = 100
-	}
+	exportCfg := m.cfg.Load().Routing.Export
+	metric := exportMetric(exportCfg)
 
 	// Add explicitly configured networks
 	for _, network := range exportCfg.Networks {
@@ -56,8 +97,8 @@ func (m *Manager) GetExportRoutes() []controlplane.Route {
 		})
 	}
 
-	// TODO: If include_connected, scan local interfaces and add connected routes
-	// TODO: If include_netplan_static, parse netplan and add static routes
+	// Connected subnets and netplan static routes are exported per overlay,
+	// and are not cached: see GetExportRoutesForOverlay.
 
 	m.mu.Lock()
 	m.exportedRoutes = routes
@@ -72,10 +113,7 @@ func (m *Manager) GetExportRoutesForOverlay(overlay config.OverlayDef) []control
 	var routes []controlplane.Route
 
 	exportCfg := overlay.Routing.Export
-	metric := uint32(exportCfg.Metric)
-	if metric == 0 {
-		metric = 100
-	}
+	metric := exportMetric(exportCfg)
 
 	// Add explicitly configured networks for this overlay
 	for _, network := range exportCfg.Networks {
@@ -87,13 +125,181 @@ func (m *Manager) GetExportRoutesForOverlay(overlay config.OverlayDef) []control
 		})
 	}
 
+	// Prefixes already listed in networks are exported once.
+	extra := append(m.GetConnectedRoutesForOverlay(overlay), m.getNetplanRoutesForOverlay(overlay)...)
+	for _, r := range extra {
+		if !slices.ContainsFunc(routes, func(e controlplane.Route) bool { return samePrefix(e.Prefix, r.Prefix) }) {
+			routes = append(routes, r)
+		}
+	}
+
+	return routes
+}
+
+// ExportedPrefixes returns the prefixes exported by an overlay (see
+// GetExportRoutesForOverlay).
+func (m *Manager) ExportedPrefixes(overlay config.OverlayDef) []string {
+	var prefixes []string
+	for _, r := range m.GetExportRoutesForOverlay(overlay) {
+		prefixes = append(prefixes, r.Prefix)
+	}
+	return prefixes
+}
+
+// GetConnectedRoutesForOverlay returns the connected subnets of the overlay
+// bridge and of export.connected_interfaces when include_connected is set.
+// They are read from the kernel on every call, so a subnet follows the
+// addresses of its interface. The overlay's own subnet (the one of the
+// bridge IPv4/IPv6, shared by every node of the overlay) is not exported, and
+// missing interfaces are skipped.
+func (m *Manager) GetConnectedRoutesForOverlay(overlay config.OverlayDef) []controlplane.Route {
+	exportCfg := overlay.Routing.Export
+	if !exportCfg.IncludeConnected {
+		return nil
+	}
+	metric := exportMetric(exportCfg)
+
+	var own []net.IP
+	for _, cidr := range []string{overlay.Bridge.IPv4, overlay.Bridge.IPv6} {
+		if ip, _, err := net.ParseCIDR(cidr); err == nil {
+			own = append(own, ip)
+		}
+	}
+
+	var routes []controlplane.Route
+	seen := make(map[string]bool)
+	for _, iface := range append([]string{overlay.Bridge.Name}, exportCfg.ConnectedInterfaces...) {
+		if iface == "" {
+			continue
+		}
+		prefixes, err := m.connectedPrefixes(iface)
+		if err != nil {
+			continue
+		}
+		for _, p := range prefixes {
+			if seen[p.String()] || slices.ContainsFunc(own, p.Contains) {
+				continue
+			}
+			seen[p.String()] = true
+			routes = append(routes, controlplane.Route{
+				Prefix: p.String(),
+				Metric: metric,
+				VNI:    uint32(overlay.VNI),
+			})
+		}
+	}
+	return routes
+}
+
+// getNetplanRoutesForOverlay returns the destinations of the static routes
+// declared in netplan on the overlay's bridge or connected_interfaces when
+// include_netplan_static is set (default routes excluded), so an overlay does
+// not export the routes of the underlay or of other overlays. The netplan
+// files are read with the config; when they cannot be read nothing is
+// exported.
+func (m *Manager) getNetplanRoutesForOverlay(overlay config.OverlayDef) []controlplane.Route {
+	exportCfg := overlay.Routing.Export
+	np := m.Netplan()
+	if !exportCfg.IncludeNetplanStatic || np == nil {
+		return nil
+	}
+	metric := exportMetric(exportCfg)
+	ifaces := append([]string{overlay.Bridge.Name}, exportCfg.ConnectedInterfaces...)
+
+	var routes []controlplane.Route
+	for _, r := range np.StaticRoutes() {
+		if !slices.Contains(ifaces, r.Interface) {
+			continue
+		}
+		routes = append(routes, controlplane.Route{
+			Prefix: r.To,
+			Metric: metric,
+			VNI:    uint32(overlay.VNI),
+		})
+	}
+	return routes
+}
+
+// samePrefix reports whether two CIDR strings denote the same network.
+func samePrefix(a, b string) bool {
+	_, na, errA := net.ParseCIDR(a)
+	_, nb, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return na.String() == nb.String()
+}
+
+// GetReadvertisedRoutes returns the learned routes this node re-advertises to
+// its peers under the active topology: for each (VNI, prefix), the path it
+// selected among the routes learned from its peers (controlplane.BestPaths),
+// with the next-hop rewritten to this node's own next-hop for the route's VNI
+// and address family (nextHops; routes without a local next-hop of their
+// family are dropped) and the metric increased by one so direct paths stay
+// preferred over transit paths. The routes are ordered by VNI and prefix.
+//
+// Routes are re-advertised when this node is a hub in hub-spoke mode (spoke
+// routes, so spokes reach each other through it; routes learned from other
+// hubs are skipped) or when transit is allowed (every learned route). In both
+// cases routes learned from peers outside transit_policy are not re-exported.
+// A prefix whose selected path is skipped is not re-advertised through
+// another one. Re-advertised routes keep their PeerID, OriginatorID and Path
+// so the control plane can apply split horizon and loop prevention per
+// destination peer.
+func (m *Manager) GetReadvertisedRoutes(learned []controlplane.Route, nextHops controlplane.OverlayNextHops) []controlplane.Route {
+	cfg := m.cfg.Load()
+	hub := cfg.IsHub()
+	if !hub && !cfg.Topology.TransitEnabled() {
+		return nil
+	}
+
+	type key struct {
+		vni    uint32
+		prefix string
+	}
+	candidates := make(map[key][]controlplane.Route)
+	var keys []key
+	for _, r := range learned {
+		if r.PeerID == "" {
+			continue
+		}
+		k := key{r.VNI, r.Prefix}
+		if _, ok := candidates[k]; !ok {
+			keys = append(keys, k)
+		}
+		candidates[k] = append(candidates[k], r)
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		if a.vni != b.vni {
+			return cmp.Compare(a.vni, b.vni)
+		}
+		return strings.Compare(a.prefix, b.prefix)
+	})
+
+	var routes []controlplane.Route
+	for _, k := range keys {
+		r := controlplane.BestPaths(candidates[k], false)[0]
+		if !cfg.Topology.IsTransitPeer(r.PeerID) {
+			continue
+		}
+		if hub && cfg.Topology.IsHub(r.PeerID) {
+			continue
+		}
+		nextHop := nextHops.For(r.VNI, r.Prefix)
+		if nextHop == "" {
+			continue
+		}
+		r.NextHop = nextHop
+		r.Metric++
+		routes = append(routes, r)
+	}
 	return routes
 }
 
 // ShouldImport checks if a route should be imported according to policy.
 // DEPRECATED: Use ShouldImportForOverlay for multi-overlay support.
 func (m *Manager) ShouldImport(route controlplane.Route) bool {
-	importCfg := m.cfg.Routing.Import
+	importCfg := m.cfg.Load().Routing.Import
 
 	// Parse the route's prefix
 	_, routeNet, err := net.ParseCIDR(route.Prefix)
@@ -148,7 +354,7 @@ func evalImportPolicy(routeNet *net.IPNet, importCfg config.ImportConfig) bool {
 func (m *Manager) GetImportTableForOverlay(overlay config.OverlayDef) int {
 	table := overlay.Routing.Import.Install.Table
 	if table == 0 {
-		return 100 // default
+		return config.DefaultImportTable
 	}
 	return table
 }
@@ -1,6 +1,10 @@
 package routing
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/nishisan-dev/n-netman/internal/config"
@@ -113,3 +117,204 @@ func TestGetExportRoutesForOverlay(t *testing.T) {
 		}
 	}
 }
+
+func TestGetExportRoutesForOverlay_IncludeConnected(t *testing.T) {
+	mgr := NewManager(&config.Config{})
+	addrs := map[string][]string{
+		"br-100": {"10.100.0.0/24", "172.16.10.0/24", "2001:db8:10::/64"},
+		"eth1":   {"172.16.20.0/24", "172.16.10.0/24"},
+	}
+	mgr.connectedPrefixes = func(iface string) ([]*net.IPNet, error) {
+		var out []*net.IPNet
+		for _, cidr := range addrs[iface] {
+			_, n, _ := net.ParseCIDR(cidr)
+			out = append(out, n)
+		}
+		return out, nil
+	}
+	overlay := config.OverlayDef{
+		VNI:    100,
+		Bridge: config.BridgeConfig{Name: "br-100", IPv4: "10.100.0.1/24"},
+		Routing: config.OverlayRouting{Export: config.ExportConfig{
+			Networks:            []string{"172.16.20.0/24"},
+			IncludeConnected:    true,
+			ConnectedInterfaces: []string{"eth1", "missing"},
+		}},
+	}
+
+	var got []string
+	for _, r := range mgr.GetExportRoutesForOverlay(overlay) {
+		got = append(got, r.Prefix)
+	}
+	// The overlay's own subnet is skipped, and each prefix is exported once.
+	want := []string{"172.16.20.0/24", "172.16.10.0/24", "2001:db8:10::/64"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("exported %v, want %v", got, want)
+	}
+
+	// The subnet of a removed address is no longer exported.
+	addrs["br-100"] = addrs["br-100"][:1]
+	addrs["eth1"] = nil
+	if routes := mgr.GetConnectedRoutesForOverlay(overlay); len(routes) != 0 {
+		t.Fatalf("connected routes = %+v, want none", routes)
+	}
+
+	// Without include_connected, interfaces are not read.
+	overlay.Routing.Export.IncludeConnected = false
+	mgr.connectedPrefixes = func(string) ([]*net.IPNet, error) {
+		t.Fatal("interfaces read without include_connected")
+		return nil, nil
+	}
+	mgr.GetExportRoutesForOverlay(overlay)
+}
+
+func TestGetExportRoutesForOverlay_IncludeNetplanStatic(t *testing.T) {
+	dir := t.TempDir()
+	netplanFile := `
+network:
+  ethernets:
+    eth0:
+      addresses: [192.168.1.5/24]
+      routes:
+        - to: 192.168.0.0/16
+          via: 192.168.1.1
+    eth1:
+      addresses: [10.0.0.5/24]
+      routes:
+        - to: default
+          via: 10.0.0.1
+        - to: 172.20.0.0/16
+          via: 10.0.0.254
+        - to: 172.16.10.0/24
+          via: 10.0.0.254
+  bridges:
+    br-100:
+      addresses: [10.100.0.1/24]
+      routes:
+        - to: 172.30.0.0/16
+          via: 10.100.0.254
+`
+	if err := os.WriteFile(filepath.Join(dir, "50-test.yaml"), []byte(netplanFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewManager(&config.Config{Netplan: config.NetplanConfig{Enabled: true, ConfigPaths: []string{dir}}})
+	overlay := config.OverlayDef{
+		VNI:    100,
+		Bridge: config.BridgeConfig{Name: "br-100"},
+		Routing: config.OverlayRouting{Export: config.ExportConfig{
+			Networks:             []string{"172.16.10.0/24"},
+			IncludeNetplanStatic: true,
+			ConnectedInterfaces:  []string{"eth1"},
+		}},
+	}
+
+	// The files are read with the config, not on every call.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range mgr.GetExportRoutesForOverlay(overlay) {
+		got = append(got, r.Prefix)
+	}
+	// Only the routes of the bridge and connected_interfaces are exported:
+	// the default route and the routes of eth0 are skipped, and
+	// 172.16.10.0/24 is exported once.
+	if want := []string{"172.16.10.0/24", "172.30.0.0/16", "172.20.0.0/16"}; !slices.Equal(got, want) {
+		t.Fatalf("exported %v, want %v", got, want)
+	}
+}
+
+func TestGetReadvertisedRoutes(t *testing.T) {
+	learned := []controlplane.Route{
+		{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "spoke-1", NextHop: "10.100.0.11"},
+		{Prefix: "192.168.2.0/24", VNI: 100, PeerID: "hub-2", NextHop: "10.100.0.2"},
+		{Prefix: "192.168.3.0/24", VNI: 200, PeerID: "spoke-3", NextHop: "10.200.0.13"},
+		{Prefix: "192.168.9.0/24", VNI: 100, NextHop: "10.100.0.1"}, // local
+		{Prefix: "2001:db8:1::/64", VNI: 100, PeerID: "spoke-1", NextHop: "fd00:100::11"},
+	}
+	// This node is not attached to VNI 200, and VNI 100 is IPv4-only here.
+	nextHop := controlplane.OverlayNextHops{100: {"10.100.0.1"}}
+
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "hub-1"},
+		Topology: config.TopologyConfig{Mode: "hub-spoke", Hubs: []string{"hub-1", "hub-2"}},
+	}
+	got := NewManager(cfg).GetReadvertisedRoutes(learned, nextHop)
+	if len(got) != 1 {
+		t.Fatalf("expected only the IPv4 spoke-1 route to be re-advertised, got %+v", got)
+	}
+	if got[0].NextHop != "10.100.0.1" || got[0].PeerID != "spoke-1" {
+		t.Fatalf("expected next-hop rewritten to hub and origin peer kept, got %+v", got[0])
+	}
+
+	// With an IPv6 next-hop the IPv6 route is re-advertised through it.
+	nextHop[100] = append(nextHop[100], "fd00:100::1")
+	got = NewManager(cfg).GetReadvertisedRoutes(learned, nextHop)
+	if len(got) != 2 || got[1].Prefix != "2001:db8:1::/64" || got[1].NextHop != "fd00:100::1" {
+		t.Fatalf("expected the IPv6 route re-advertised via fd00:100::1, got %+v", got)
+	}
+
+	// Spokes never re-advertise.
+	cfg.Node.ID = "spoke-1"
+	if got := NewManager(cfg).GetReadvertisedRoutes(learned, nextHop); len(got) != 0 {
+		t.Fatalf("expected spoke to re-advertise nothing, got %+v", got)
+	}
+}
+
+func TestGetReadvertisedRoutes_Transit(t *testing.T) {
+	learned := []controlplane.Route{
+		{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "a", NextHop: "10.100.0.11", Metric: 100},
+		{Prefix: "192.168.2.0/24", VNI: 100, PeerID: "untrusted", NextHop: "10.100.0.12"},
+		{Prefix: "192.168.9.0/24", VNI: 100, NextHop: "10.100.0.1"}, // local
+	}
+	nextHop := controlplane.OverlayNextHops{100: {"10.100.0.1"}}
+
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "t"},
+		Topology: config.TopologyConfig{Transit: "deny"},
+	}
+	if got := NewManager(cfg).GetReadvertisedRoutes(learned, nextHop); len(got) != 0 {
+		t.Fatalf("expected transit deny to re-export nothing, got %+v", got)
+	}
+
+	cfg.Topology.Transit = "allow"
+	cfg.Topology.TransitPolicy.DeniedTransitPeers = []string{"untrusted"}
+	got := NewManager(cfg).GetReadvertisedRoutes(learned, nextHop)
+	if len(got) != 1 || got[0].PeerID != "a" {
+		t.Fatalf("expected only the route from a to be re-exported, got %+v", got)
+	}
+	if got[0].NextHop != "10.100.0.1" || got[0].Metric != 101 {
+		t.Fatalf("expected next-hop rewritten and metric bumped, got %+v", got[0])
+	}
+}
+
+func TestGetReadvertisedRoutes_SelectedPath(t *testing.T) {
+	// Two peers announce 192.168.1.0/24; only the selected path (lowest
+	// metric, then lowest peer ID) is re-advertised, whatever the order.
+	learned := []controlplane.Route{
+		{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "c", NextHop: "10.100.0.13", Metric: 100},
+		{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "b", NextHop: "10.100.0.12", Metric: 50},
+		{Prefix: "192.168.1.0/24", VNI: 100, PeerID: "a", NextHop: "10.100.0.11", Metric: 100},
+	}
+	nextHop := controlplane.OverlayNextHops{100: {"10.100.0.1"}}
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "t"},
+		Topology: config.TopologyConfig{Transit: "allow"},
+	}
+	m := NewManager(cfg)
+	for i := 0; i < 3; i++ {
+		got := m.GetReadvertisedRoutes(learned, nextHop)
+		if len(got) != 1 || got[0].PeerID != "b" || got[0].Metric != 51 {
+			t.Fatalf("GetReadvertisedRoutes() = %+v, want the path from b only", got)
+		}
+		learned = append(learned[1:], learned[0])
+	}
+
+	// A selected path learned from a peer outside transit_policy is not
+	// replaced by another one.
+	cfg.Topology.TransitPolicy.DeniedTransitPeers = []string{"b"}
+	if got := NewManager(cfg).GetReadvertisedRoutes(learned, nextHop); len(got) != 0 {
+		t.Fatalf("GetReadvertisedRoutes() = %+v, want nothing", got)
+	}
+}